@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/koyashimano/matlab-formatter/internal/batch"
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// runBatch formats the files framed on r with format and writes a frame for
+// each of them to w: the formatted source joined by join, or its diff with
+// showDiff, or the error. Files exceeding limits are answered by an error
+// frame. It returns an error when a stream cannot be read or written, or when
+// any file failed.
+func runBatch(r io.Reader, w io.Writer, format func(filename string, lines []string) ([]string, error), join func(lines []string, src []byte) string, limits fileLimits, showDiff bool, diffFormat string) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	failed := 0
+	for {
+		req, err := batch.Read(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			bw.Flush()
+			return fmt.Errorf("batch: %w", err)
+		}
+		resp := batch.Frame{Kind: batch.KindFile, Name: req.Name}
+		if req.Kind != batch.KindFile {
+			err = fmt.Errorf("unknown frame kind %q", req.Kind)
+		} else if reason := limits.check(int64(len(req.Content))); reason != "" {
+			err = fmt.Errorf("skipped: %s", reason)
+		} else {
+			resp.Content, err = formatFrame(req, format, join, showDiff, diffFormat)
+		}
+		if err != nil {
+			resp = batch.Frame{Kind: batch.KindError, Name: req.Name, Content: []byte(err.Error() + "\n")}
+			failed++
+		}
+		if err := batch.Write(bw, resp); err != nil {
+			return err
+		}
+		// Answer each request as soon as it is formatted.
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("batch: %d of the files failed", failed)
+	}
+	return nil
+}
+
+// formatFrame returns the result of formatting the content of req.
+func formatFrame(req batch.Frame, format func(filename string, lines []string) ([]string, error), join func(lines []string, src []byte) string, showDiff bool, diffFormat string) ([]byte, error) {
+	lines, err := formatter.ReadLines(bytes.NewReader(req.Content))
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format(req.Name, lines)
+	if err != nil {
+		return nil, err
+	}
+	if !showDiff {
+		return []byte(join(formatted, req.Content)), nil
+	}
+	var buf bytes.Buffer
+	d := fileDiff{Path: req.Name, Hunks: diff.Hunks(lines, formatted, diffContext), before: req.Content, after: []byte(join(formatted, req.Content))}
+	if err := writeDiffs(&buf, diffFormat, []fileDiff{d}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
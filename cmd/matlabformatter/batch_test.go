@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/koyashimano/matlab-formatter/internal/batch"
+)
+
+func TestRunFormatBatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("two/.matlabformatter.toml", "[format]\nindentWidth = 2\n")
+	writeFile("three/.matlabformatter.toml", "[format]\nindentWidth = 3\n")
+
+	// The frames alternate between the directories, so each one switches
+	// the options.
+	var in bytes.Buffer
+	names := []string{"two/a.m", "three/b.m", "two/c.m", "other/d.m"}
+	for _, name := range names {
+		frame := batch.Frame{Kind: batch.KindFile, Name: filepath.Join(dir, name), Content: []byte("if x\ny=1;\nend\n")}
+		if err := batch.Write(&in, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("frames", in.String())
+	stdin, err := os.Open(filepath.Join(dir, "frames"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() { os.Stdin = saved })
+
+	out := filepath.Join(dir, "out")
+	if status := runFormat([]string{"--batch", "--output-file=" + out}); status != exitOK {
+		t.Fatalf("got status %d, want %d", status, exitOK)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		frame, err := batch.Read(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(frame.Content))
+	}
+	want := []string{
+		"if x\n  y = 1;\nend\n",
+		"if x\n   y = 1;\nend\n",
+		"if x\n  y = 1;\nend\n",
+		"if x\n    y = 1;\nend\n",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("batch mismatch (-want +got):\n%s", diff)
+	}
+}
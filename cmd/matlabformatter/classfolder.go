@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+)
+
+// classFolderDescription describes lint.ClassFolderRule in rule listings.
+const classFolderDescription = "Method file of an @ClassName folder not declared in the classdef, or whose signature differs from the declaration"
+
+// reportClassFolders writes the class-folder findings for the @ClassName
+// folders containing filenames to w.
+func reportClassFolders(w io.Writer, filenames []string) error {
+	findings, err := classFolderFindings(filenames, lint.DefaultOptions())
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(findings))
+	for path := range findings {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	results := make([]fileFindings, len(paths))
+	for i, path := range paths {
+		results[i] = fileFindings{Path: path, Findings: findings[path]}
+	}
+	return writeFindings(w, "text", results)
+}
+
+// classFolderFindings checks the @ClassName folders containing any of
+// filenames with lint.CheckClassFolder, reading every MATLAB file of those
+// folders. The findings are keyed by cleaned path.
+func classFolderFindings(filenames []string, opts lint.Options) (map[string][]lint.Finding, error) {
+	checked := make(map[string]bool)
+	result := make(map[string][]lint.Finding)
+	for _, filename := range filenames {
+		dir := filepath.Dir(filepath.Clean(filename))
+		if filename == "-" || !strings.HasPrefix(filepath.Base(dir), "@") || checked[dir] {
+			continue
+		}
+		checked[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		files := make(map[string][]string)
+		for _, e := range entries {
+			if e.IsDir() || filepath.Ext(e.Name()) != sourceExtension {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if files[path], err = readFileLines(path); err != nil {
+				return nil, err
+			}
+		}
+		for path, findings := range lint.CheckClassFolder(dir, files, opts) {
+			result[path] = append(result[path], findings...)
+		}
+	}
+	return result, nil
+}
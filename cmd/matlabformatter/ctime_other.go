@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "io/fs"
+
+// setCreationTime does nothing on platforms that do not allow setting the
+// creation time of files.
+func setCreationTime(path string, info fs.FileInfo) error {
+	return nil
+}
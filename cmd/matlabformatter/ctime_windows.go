@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileWriteAttributes is the access right to change the times of a file.
+const fileWriteAttributes = 0x100
+
+// setCreationTime sets the creation time of the file at path to that of the
+// file described by info.
+func setCreationTime(path string, info fs.FileInfo) error {
+	d, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return nil
+	}
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	h, err := syscall.CreateFile(p, fileWriteAttributes, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(h)
+	return syscall.SetFileTime(h, &d.CreationTime, nil, nil)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/koyashimano/matlab-formatter/internal/deps"
+	"github.com/koyashimano/matlab-formatter/internal/walk"
+)
+
+func runDeps(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter deps", flag.ExitOnError)
+	format := fs.String("format", "json", "Output format: json, dot")
+	addAlias(fs, "output", "format", false)
+	external := fs.Bool("external", true, "Include calls to functions outside the analyzed files")
+	gitignore := fs.Bool("gitignore", false, "Skip files and directories ignored by .gitignore files and .git/info/exclude")
+	followSymlinks := fs.Bool("follow-symlinks", false, "Walk the directories symbolic links point to")
+
+	paths, err := parseFilenames(fs, args)
+	if err != nil {
+		if errors.Is(err, errMissingFilename) {
+			printDepsUsage()
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
+	}
+	if *format != "json" && *format != "dot" {
+		fmt.Fprintf(os.Stderr, "invalid format %q (valid values: json, dot)\n", *format)
+		return exitUsage
+	}
+
+	filenames, err := expandPaths(paths, walk.Options{Gitignore: *gitignore, FollowSymlinks: *followSymlinks})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return errorStatus(err)
+	}
+
+	status := exitOK
+	var sources []deps.Source
+	for _, filename := range filenames {
+		lines, err := readFileLines(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			status = exitIO
+			continue
+		}
+		sources = append(sources, deps.Source{Path: filename, Lines: lines})
+	}
+
+	graph := deps.Build(sources)
+	if !*external {
+		graph = withoutExternal(graph)
+	}
+
+	if *format == "dot" {
+		err = writeDot(os.Stdout, graph)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(graph)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return status
+}
+
+// withoutExternal drops external units and the calls to them.
+func withoutExternal(g *deps.Graph) *deps.Graph {
+	result := &deps.Graph{Units: []deps.Unit{}, Calls: []deps.Call{}}
+	for _, u := range g.Units {
+		if u.Kind != deps.KindExternal {
+			result.Units = append(result.Units, u)
+		}
+	}
+	for _, c := range g.Calls {
+		if c.Kind != deps.KindExternal {
+			result.Calls = append(result.Calls, c)
+		}
+	}
+	return result
+}
+
+// writeDot writes the graph in Graphviz DOT format, drawing external
+// functions as dashed boxes.
+func writeDot(w io.Writer, g *deps.Graph) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("digraph deps {\n")
+	for _, u := range g.Units {
+		shape := "ellipse"
+		switch u.Kind {
+		case deps.KindScript, deps.KindFunction, deps.KindClass:
+			shape = "box"
+		case deps.KindExternal:
+			shape = "box, style=dashed"
+		}
+		printf("  %q [shape=%s];\n", u.ID, shape)
+	}
+	for _, c := range g.Calls {
+		printf("  %q -> %q;\n", c.From, c.To)
+	}
+	printf("}\n")
+	return err
+}
+
+func printDepsUsage() {
+	fmt.Fprintf(os.Stderr, "usage: matlabformatter deps [options...] <file or directory...>\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --format=string (default json) - Output format: json, dot\n")
+	fmt.Fprintf(os.Stderr, "    --output=string - Alias of --format\n")
+	fmt.Fprintf(os.Stderr, "    --external=bool (default true) - Include calls to functions outside the analyzed files\n")
+	fmt.Fprintf(os.Stderr, "    --gitignore (default false) - Skip files and directories ignored by .gitignore files and .git/info/exclude\n")
+	fmt.Fprintf(os.Stderr, "    --follow-symlinks (default false) - Walk the directories symbolic links point to\n")
+}
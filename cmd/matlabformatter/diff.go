@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+)
+
+// diffContext is the number of unchanged lines shown around each hunk.
+const diffContext = 3
+
+// fileDiff holds the hunks of one formatted file.
+type fileDiff struct {
+	Path  string      `json:"path"`
+	Hunks []diff.Hunk `json:"hunks"`
+	// before and after hold the content of the file before and after
+	// formatting for the patch format. Files without them, such as the
+	// callbacks of Simulink models, are left out of patches.
+	before, after []byte
+}
+
+// fileEdits holds the text edits of one formatted file.
+type fileEdits struct {
+	Path  string          `json:"path"`
+	Edits []diff.TextEdit `json:"edits"`
+}
+
+// writeEdits writes the text edits of the files as JSON.
+func writeEdits(w io.Writer, edits []fileEdits) error {
+	if edits == nil {
+		edits = []fileEdits{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(edits)
+}
+
+func writeDiffs(w io.Writer, format string, diffs []fileDiff) error {
+	if format == "patch" {
+		for _, d := range diffs {
+			if d.after == nil {
+				continue
+			}
+			if err := diff.WritePatch(w, patchPath(d.Path), d.before, d.after); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if format == "json" {
+		for i := range diffs {
+			if diffs[i].Hunks == nil {
+				diffs[i].Hunks = []diff.Hunk{}
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diffs)
+	}
+
+	for _, d := range diffs {
+		name := strings.TrimPrefix(filepath.ToSlash(d.Path), "/")
+		if err := diff.WriteUnified(w, "a/"+name, "b/"+name, d.Hunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchPath returns the name of the file at path in a patch: relative to the
+// working directory when below it, so the patch applies from there.
+func patchPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && filepath.IsLocal(rel) {
+				path = rel
+			}
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// parseClasses parses the comma-separated change classes of --minimal. It
+// returns nil when the list is empty, meaning every change is applied.
+func parseClasses(list string) (map[diff.Class]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	classes := make(map[diff.Class]bool)
+	for _, name := range strings.Split(list, ",") {
+		c, ok := diff.ParseClass(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("invalid change class %q (valid values: indentation, spacing, structural)", name)
+		}
+		classes[c] = true
+	}
+	return classes, nil
+}
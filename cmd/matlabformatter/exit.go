@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Exit statuses of the commands. When several apply, the highest is used.
+const (
+	exitOK = 0
+	// exitChanged reports files that would be reformatted, or lint
+	// warnings.
+	exitChanged = 1
+	// exitUsage reports invalid flags, arguments and configuration files.
+	exitUsage = 2
+	// exitDiagnostic reports input that could not be parsed or formatted,
+	// or lint errors.
+	exitDiagnostic = 3
+	// exitIO reports files that could not be read or written.
+	exitIO = 4
+)
+
+// errorStatus returns the exit status reporting err: exitIO for errors of
+// the file system and exitDiagnostic for others.
+func errorStatus(err error) int {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) || errors.As(err, &syscallErr) {
+		return exitIO
+	}
+	return exitDiagnostic
+}
+
+// argumentStatus returns the exit status reporting an error opening a file
+// named by the arguments, such as a configuration file: exitIO when it could
+// not be opened or read and exitUsage when it is invalid.
+func argumentStatus(err error) int {
+	if status := errorStatus(err); status == exitIO {
+		return status
+	}
+	return exitUsage
+}
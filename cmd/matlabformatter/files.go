@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koyashimano/matlab-formatter/internal/walk"
+)
+
+// sourceExtension is the extension of files picked up when walking
+// directories.
+const sourceExtension = ".m"
+
+// firstDirectory returns the first of paths that is a directory, or "" when
+// none is.
+func firstDirectory(paths []string) string {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// expandPaths replaces directory arguments with the MATLAB files found below
+// them as configured by opts. Other arguments, including "-", are returned
+// unchanged.
+func expandPaths(paths []string, opts walk.Options) ([]string, error) {
+	opts.Extension = sourceExtension
+	return walk.Files(paths, opts)
+}
+
+// packageHierarchy returns the package of the file at path, such as "a.b" for
+// a file in +a/+b or +a/+b/@C, together with every package of the hierarchy
+// the package belongs to. roots caches the packages found below each
+// directory holding top-level packages.
+func packageHierarchy(path string, roots map[string][]string) (string, []string, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil || path == "-" {
+		return "", nil, err
+	}
+	if base := filepath.Base(dir); strings.HasPrefix(base, "@") || base == "private" {
+		dir = filepath.Dir(dir)
+	}
+	var names []string
+	for strings.HasPrefix(filepath.Base(dir), "+") {
+		names = append([]string{filepath.Base(dir)[1:]}, names...)
+		dir = filepath.Dir(dir)
+	}
+	if len(names) == 0 {
+		return "", nil, nil
+	}
+
+	packages, ok := roots[dir]
+	if !ok {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() || p == dir {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			parts := strings.Split(rel, string(filepath.Separator))
+			for i, part := range parts {
+				if !strings.HasPrefix(part, "+") {
+					return filepath.SkipDir
+				}
+				parts[i] = part[1:]
+			}
+			packages = append(packages, strings.Join(parts, "."))
+			return nil
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		roots[dir] = packages
+	}
+	return strings.Join(names, "."), packages, nil
+}
+
+// rewriteOptions selects the metadata kept when files are rewritten.
+type rewriteOptions struct {
+	// preserveMtime restores the modification time of the file.
+	preserveMtime bool
+	// preserveCreationTime restores the creation time of the file on the
+	// platforms that allow setting it.
+	preserveCreationTime bool
+}
+
+// rewriteFile replaces the contents of the existing file filename with data,
+// keeping its permissions and the metadata selected by opts. The file is
+// replaced atomically by a temporary file of the same directory, which gets
+// the owner, group and extended attributes of the file where the platform
+// and the permissions of the user allow it. Symbolic links are followed.
+// Files in directories the user cannot create files in are rewritten in
+// place, which is not atomic, with a warning.
+func rewriteFile(filename string, data []byte, opts rewriteOptions) error {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if errors.Is(err, fs.ErrPermission) {
+		// The file is truncated before it is written, so an interrupted
+		// rewrite may lose its contents.
+		logger.Warn("rewritten in place: cannot create a temporary file in its directory", "file", filename)
+		if err := os.WriteFile(target, data, info.Mode()); err != nil {
+			return err
+		}
+		return restoreTimes(target, info, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		// Changing the owner clears the set-user-ID bit, so it goes first.
+		preserveOwner(tmp, info)
+		err = tmp.Chmod(info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky))
+	}
+	if err == nil {
+		// The data reaches the disk before the file replaces the original,
+		// so a crash cannot leave an empty or truncated file behind.
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := copyXattrs(target, tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	return restoreTimes(target, info, opts)
+}
+
+// restoreTimes sets the times selected by opts of the file at path back to
+// those of info.
+func restoreTimes(path string, info fs.FileInfo, opts rewriteOptions) error {
+	if opts.preserveMtime {
+		if err := os.Chtimes(path, time.Now(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+	if opts.preserveCreationTime {
+		return setCreationTime(path, info)
+	}
+	return nil
+}
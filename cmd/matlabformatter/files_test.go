@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// old is the modification time of the files before they are rewritten.
+var old = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestRewriteFile(t *testing.T) {
+	tests := []struct {
+		name string
+		mode fs.FileMode
+		// link rewrites the file through a symbolic link to it.
+		link bool
+		// readOnlyDir removes the write permission of the directory of the
+		// file, so it is rewritten in place.
+		readOnlyDir bool
+		opts        rewriteOptions
+		// keepMtime reports whether the modification time stays old.
+		keepMtime bool
+	}{
+		{name: "mode", mode: 0o640},
+		{name: "executable", mode: 0o755},
+		{name: "preserve mtime", mode: 0o644, opts: rewriteOptions{preserveMtime: true}, keepMtime: true},
+		{name: "symbolic link", mode: 0o600, link: true},
+		{name: "read-only directory", mode: 0o644, readOnlyDir: true, opts: rewriteOptions{preserveMtime: true}, keepMtime: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.readOnlyDir && os.Geteuid() == 0 {
+				t.Skip("root may create files in read-only directories")
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.m")
+			if err := os.WriteFile(path, []byte("x=1;\n"), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+			name := path
+			if tt.link {
+				name = filepath.Join(t.TempDir(), "link.m")
+				if err := os.Symlink(path, name); err != nil {
+					t.Skipf("symbolic links: %v", err)
+				}
+			}
+			if tt.readOnlyDir {
+				if err := os.Chmod(dir, 0o555); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(dir, 0o755) })
+			}
+			var stderr bytes.Buffer
+			saved := logger
+			logger = slog.New(&messageHandler{w: &stderr, level: logLevel, mu: new(sync.Mutex)})
+			t.Cleanup(func() { logger = saved })
+
+			if err := rewriteFile(name, []byte("x = 1;\n"), tt.opts); err != nil {
+				t.Fatalf("rewriteFile: %v", err)
+			}
+			// Rewriting in place is not atomic and is reported.
+			if warned := strings.Contains(stderr.String(), "rewritten in place"); warned != tt.readOnlyDir {
+				t.Errorf("warned %v, want %v: %q", warned, tt.readOnlyDir, stderr.String())
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "x = 1;\n" {
+				t.Errorf("got %q, want %q", got, "x = 1;\n")
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode() != tt.mode {
+				t.Errorf("got mode %v, want %v", info.Mode(), tt.mode)
+			}
+			if kept := info.ModTime().Equal(old); kept != tt.keepMtime {
+				t.Errorf("modification time %v kept: %v, want %v", info.ModTime(), kept, tt.keepMtime)
+			}
+			if tt.link {
+				if info, err := os.Lstat(name); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+					t.Errorf("the symbolic link was replaced: %v, %v", info, err)
+				}
+			}
+			// No temporary file is left behind.
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+				t.Errorf("directory holds %v, %v; want a.m only", entries, err)
+			}
+		})
+	}
+}
+
+func TestRestoreTimes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts rewriteOptions
+		want bool
+	}{
+		{"none", rewriteOptions{}, false},
+		{"mtime", rewriteOptions{preserveMtime: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.m")
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			if err := os.Chtimes(path, now, now); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := restoreTimes(path, info, tt.opts); err != nil {
+				t.Fatalf("restoreTimes: %v", err)
+			}
+			info, err = os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.ModTime().Equal(old); got != tt.want {
+				t.Errorf("modification time %v restored: %v, want %v", info.ModTime(), got, tt.want)
+			}
+		})
+	}
+}
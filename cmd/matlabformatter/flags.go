@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"unicode"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// deprecatedFlags lists the camelCase long flags of earlier releases. They
+// remain accepted as aliases of their kebab-case names.
+var deprecatedFlags = []string{
+	"formatGenerated",
+	"generatedMarkers",
+	"startLine",
+	"endLine",
+	"indentWidth",
+	"separateBlocks",
+	"indentMode",
+	"addSpaces",
+	"matrixIndent",
+	"tabWidth",
+	"sortImports",
+}
+
+// flagAlias is a flag forwarding to the flag it is an alias of. Deprecated
+// aliases that were set are reported by warnDeprecated.
+type flagAlias struct {
+	flag.Value
+	name       string
+	target     string
+	deprecated bool
+}
+
+func (a *flagAlias) Set(s string) error {
+	return a.Value.Set(s)
+}
+
+func (a *flagAlias) String() string {
+	// The flag package calls String on zero values to detect defaults.
+	if a.Value == nil {
+		return ""
+	}
+	return a.Value.String()
+}
+
+// IsBoolFlag lets aliases of boolean flags be given without a value.
+func (a *flagAlias) IsBoolFlag() bool {
+	b, ok := a.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// addAlias defines the flag name as an alias of the existing flag target.
+func addAlias(fs *flag.FlagSet, name, target string, deprecated bool) {
+	f := fs.Lookup(target)
+	usage := "Alias of --" + target
+	if deprecated {
+		usage = "Deprecated alias of --" + target
+	}
+	fs.Var(&flagAlias{Value: f.Value, name: name, target: target, deprecated: deprecated}, name, usage)
+}
+
+// addDeprecatedAliases defines the deprecated camelCase spellings of the
+// kebab-case flags of fs.
+func addDeprecatedAliases(fs *flag.FlagSet) {
+	for _, name := range deprecatedFlags {
+		if fs.Lookup(kebabCase(name)) != nil {
+			addAlias(fs, name, kebabCase(name), true)
+		}
+	}
+}
+
+// canonicalFlag returns the name of the flag that name is an alias of, or
+// name itself.
+func canonicalFlag(fs *flag.FlagSet, name string) string {
+	if f := fs.Lookup(name); f != nil {
+		if a, ok := f.Value.(*flagAlias); ok {
+			return a.target
+		}
+	}
+	return name
+}
+
+// flagAliases returns the deprecated aliases of the kebab-case flag name.
+func flagAliases(name string) []string {
+	var aliases []string
+	for _, alias := range deprecatedFlags {
+		if kebabCase(alias) == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// kebabCase converts a camelCase name such as indentWidth to indent-width.
+func kebabCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lineRanges collects the values of a repeatable --lines flag.
+type lineRanges []formatter.LineRange
+
+func (r *lineRanges) String() string {
+	items := make([]string, len(*r))
+	for i, lr := range *r {
+		items[i] = lr.String()
+	}
+	return strings.Join(items, ",")
+}
+
+func (r *lineRanges) Set(s string) error {
+	for _, item := range splitList(s) {
+		lr, err := formatter.ParseLineRange(item)
+		if err != nil {
+			return err
+		}
+		*r = append(*r, lr)
+	}
+	return nil
+}
+
+// formatFlags defines the flags of the formatting options on fs, defaulting
+// to opts, and returns a function reading the options they are set to.
+func formatFlags(fs *flag.FlagSet, opts formatter.Options) func() formatter.Options {
+	startLine := fs.Int("start-line", opts.StartLine, "Start line (1-based)")
+	endLine := fs.Int("end-line", opts.EndLine, "End line (inclusive, 0 for end of file)")
+	indentWidth := fs.Int("indent-width", opts.IndentWidth, "Number of spaces per indentation level")
+	separateBlocks := fs.Bool("separate-blocks", opts.SeparateBlocks, "Insert blank lines between blocks")
+	separatedBlocks := fs.String("separated-blocks", opts.SeparatedBlocks, "Comma-separated kinds of blocks separated by --separate-blocks: all, function, classdef, if, loop, switch, try, spmd, arguments")
+	maxBlankLines := fs.Int("max-blank-lines", opts.MaxBlankLines, "Maximum number of consecutive blank lines (0 removes blank lines)")
+	functionBlankLines := fs.Int("function-blank-lines", opts.FunctionBlankLines, "Number of blank lines before each function that is not nested (0 keeps them)")
+	nestedFunctionBlankLines := fs.Int("nested-function-blank-lines", opts.NestedFunctionBlankLines, "Number of blank lines before each nested function (0 keeps them)")
+	indentMode := fs.String("indent-mode", opts.IndentMode, "Indentation mode: all_functions, only_nested_functions, classic")
+	nestedIndentWidth := fs.Int("nested-indent-width", opts.NestedIndentWidth, "Number of spaces to indent nested function bodies (0 uses --indent-width)")
+	classdefIndent := fs.String("classdef-indent", opts.ClassdefIndent, "Classdef indentation: all, blocks, classdef")
+	addSpaces := fs.String("add-spaces", opts.AddSpaces, "Operator spacing: all_operators, exclude_pow, no_spaces")
+	spacedOperators := fs.String("spaced-operators", opts.SpacedOperators, "Comma-separated classes of operators written with spaces, replacing --add-spaces: assignment, relational, arithmetic, elementwise, power, logical, none")
+	spaceShortCircuit := fs.Bool("space-short-circuit", opts.SpaceShortCircuit, "Write spaces around && and || whatever the operator spacing")
+	colonSpacing := fs.String("colon-spacing", opts.ColonSpacing, "Spacing around the colons of ranges such as 1:0.1:10: tight, spaced, keep")
+	unarySigns := fs.String("unary-signs", opts.UnarySigns, "Where a + or - with whitespace only before it is a sign: context for inside matrices and cell arrays, whitespace for everywhere")
+	spaceAfterComma := fs.Bool("space-after-comma", opts.SpaceAfterComma, "Write a space after commas and semicolons, independently of --add-spaces")
+	spaceInParens := fs.Bool("space-in-parens", opts.SpaceInParens, "Write a space inside parentheses, as in ( x + 1 )")
+	spaceInBrackets := fs.Bool("space-in-brackets", opts.SpaceInBrackets, "Write a space inside square brackets, as in [ 1, 2 ]")
+	spaceInBraces := fs.Bool("space-in-braces", opts.SpaceInBraces, "Write a space inside braces, as in { 'a', 'b' }")
+	matrixIndent := fs.String("matrix-indent", opts.MatrixIndent, "Matrix indentation: aligned, simple, preserve")
+	alignMatrixColumns := fs.Bool("align-matrix-columns", opts.AlignMatrixColumns, "Align the elements of the rows of multi-line matrices in columns, right-aligning numbers")
+	matrixSeparator := fs.String("matrix-separator", opts.MatrixSeparator, "Separator between matrix elements: comma, space, keep")
+	trimMatrixSeparators := fs.Bool("trim-matrix-separators", opts.TrimMatrixSeparators, "Remove separators before the closing bracket of a matrix")
+	normalizeEnds := fs.Bool("normalize-ends", opts.NormalizeEnds, "Write endif, endfor, endwhile, endswitch, endfunction and end_try_catch as end and remove the semicolon of end;")
+	normalizeNumbers := fs.Bool("normalize-numbers", opts.NormalizeNumbers, "Write numeric literals with a leading zero and without a bare decimal point")
+	trimNumberZeros := fs.Bool("trim-number-zeros", opts.TrimNumberZeros, "Remove trailing zeros from the fractions of numeric literals")
+	exponentCase := fs.String("exponent-case", opts.ExponentCase, "Exponent marker of numeric literals: lower, upper, keep")
+	trimExponents := fs.Bool("trim-exponents", opts.TrimExponents, "Remove plus signs and leading zeros from the exponents of numeric literals")
+	complexSpacing := fs.String("complex-spacing", opts.ComplexSpacing, "Spacing around the operator of complex literals such as 3+4i: tight, spaced, keep")
+	tabWidth := fs.Int("tab-width", opts.TabWidth, "Tab stop distance used to convert tabs in indentation (0 keeps tabs)")
+	indentStyle := fs.String("indent-style", opts.IndentStyle, "Characters of indentation: space, tab")
+	detectIndentation := fs.Bool("detect-indentation", opts.DetectIndentation, "Keep the indentation width and style detected in each file")
+	lineEnding := fs.String("line-ending", opts.LineEnding, "Line ending of the formatted source: auto to keep the dominant one of each file, lf, crlf, cr")
+	finalNewline := fs.Bool("final-newline", opts.FinalNewline, "End the formatted source with a line ending")
+	keepTrailingLines := fs.Bool("keep-trailing-lines", opts.KeepTrailingLines, "Keep the trailing blank lines of each file and whether it ends with a line ending")
+	sortImports := fs.Bool("sort-imports", opts.SortImports, "Sort and deduplicate import statements")
+	testFixturesFirst := fs.Bool("test-fixtures-first", opts.TestFixturesFirst, "Move the shared fixtures of function-based test files after the main function")
+	testFunctionSpacing := fs.Bool("test-function-spacing", opts.TestFunctionSpacing, "Separate the functions of function-based test files by one blank line")
+	groupImports := fs.Bool("group-imports", opts.GroupImports, "Sort imports and separate them by top-level package with blank lines")
+	qualifyImports := fs.Bool("qualify-imports", opts.QualifyImports, "Rewrite imports relative to the +package of the file into fully-qualified form")
+	continuationIndent := fs.Int("continuation-indent", opts.ContinuationIndent, "Number of spaces to indent continuation lines (0 uses --indent-width)")
+	continuationStyle := fs.String("continuation-style", opts.ContinuationStyle, "Indentation of continuation lines: indent, aligned")
+	alignAssignments := fs.Bool("align-assignments", opts.AlignAssignments, "Align the = of consecutive lines holding one assignment each")
+	commentColumn := fs.Int("comment-column", opts.CommentColumn, "Column in which trailing comments start (0 keeps one space before them)")
+	maxLineLength := fs.Int("max-line-length", opts.MaxLineLength, "Maximum number of columns of a line (0 for no limit)")
+	joinContinuations := fs.Bool("join-continuations", opts.JoinContinuations, "Join the lines of statements continued with ... that fit within --max-line-length")
+	dialect := fs.String("dialect", opts.Dialect, "Language of the code, deciding whether # starts comments: matlab, octave, auto")
+	strictBlocks := fs.Bool("strict-blocks", opts.StrictBlocks, "Fail on files whose blocks do not match their ends instead of formatting them")
+	only := fs.String("only", opts.Only, "Apply only one kind of formatting: indent, spacing")
+	return func() formatter.Options {
+		return formatter.Options{
+			StartLine:                *startLine,
+			EndLine:                  *endLine,
+			IndentWidth:              *indentWidth,
+			SeparateBlocks:           *separateBlocks,
+			SeparatedBlocks:          *separatedBlocks,
+			MaxBlankLines:            *maxBlankLines,
+			FunctionBlankLines:       *functionBlankLines,
+			NestedFunctionBlankLines: *nestedFunctionBlankLines,
+			IndentMode:               *indentMode,
+			NestedIndentWidth:        *nestedIndentWidth,
+			ClassdefIndent:           *classdefIndent,
+			AddSpaces:                *addSpaces,
+			SpacedOperators:          *spacedOperators,
+			SpaceShortCircuit:        *spaceShortCircuit,
+			ColonSpacing:             *colonSpacing,
+			UnarySigns:               *unarySigns,
+			SpaceAfterComma:          *spaceAfterComma,
+			SpaceInParens:            *spaceInParens,
+			SpaceInBrackets:          *spaceInBrackets,
+			SpaceInBraces:            *spaceInBraces,
+			MatrixIndent:             *matrixIndent,
+			AlignMatrixColumns:       *alignMatrixColumns,
+			MatrixSeparator:          *matrixSeparator,
+			TrimMatrixSeparators:     *trimMatrixSeparators,
+			NormalizeEnds:            *normalizeEnds,
+			NormalizeNumbers:         *normalizeNumbers,
+			TrimNumberZeros:          *trimNumberZeros,
+			ExponentCase:             *exponentCase,
+			TrimExponents:            *trimExponents,
+			ComplexSpacing:           *complexSpacing,
+			SortImports:              *sortImports,
+			GroupImports:             *groupImports,
+			QualifyImports:           *qualifyImports,
+			TabWidth:                 *tabWidth,
+			IndentStyle:              *indentStyle,
+			DetectIndentation:        *detectIndentation,
+			LineEnding:               *lineEnding,
+			FinalNewline:             *finalNewline,
+			KeepTrailingLines:        *keepTrailingLines,
+			TestFixturesFirst:        *testFixturesFirst,
+			TestFunctionSpacing:      *testFunctionSpacing,
+			ContinuationIndent:       *continuationIndent,
+			ContinuationStyle:        *continuationStyle,
+			AlignAssignments:         *alignAssignments,
+			CommentColumn:            *commentColumn,
+			MaxLineLength:            *maxLineLength,
+			JoinContinuations:        *joinContinuations,
+			Dialect:                  *dialect,
+			StrictBlocks:             *strictBlocks,
+			Only:                     *only,
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/koyashimano/matlab-formatter/internal/outline"
+)
+
+// fileFolding holds the folding ranges of one file as printed by the folding
+// command.
+type fileFolding struct {
+	Path   string                 `json:"path"`
+	Ranges []outline.FoldingRange `json:"ranges"`
+}
+
+func runFolding(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter folding", flag.ExitOnError)
+
+	filenames, err := parseFilenames(fs, args)
+	if err != nil {
+		if errors.Is(err, errMissingFilename) {
+			fmt.Fprintf(os.Stderr, "usage: matlabformatter folding <file...>\n")
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
+	}
+
+	status := exitOK
+	results := []fileFolding{}
+	for _, filename := range filenames {
+		lines, err := readFileLines(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			status = exitIO
+			continue
+		}
+		ranges := outline.FoldingRanges(lines)
+		if ranges == nil {
+			ranges = []outline.FoldingRange{}
+		}
+		results = append(results, fileFolding{Path: filename, Ranges: ranges})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return status
+}
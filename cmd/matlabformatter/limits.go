@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// bytesPerMB converts the megabytes of --max-file-size and --max-memory.
+const bytesPerMB = 1 << 20
+
+// memoryPerByte estimates the memory needed to format a file per byte of it:
+// its lines, the formatted copy, the parse of the block structure and the
+// formatted content. BenchmarkFormatMemory measures a peak heap of 60 to 75
+// bytes per byte. Diffs are not covered, as their memory grows with the
+// square of the number of changed lines.
+const memoryPerByte = 64
+
+// fileLimits holds the limits of --max-file-size and --max-memory, in bytes.
+// Zero disables a limit.
+type fileLimits struct {
+	maxFileSize int64
+	maxMemory   int64
+}
+
+// newFileLimits returns the limits given in megabytes. A memory limit also
+// becomes the soft memory limit of the runtime, so the garbage collector
+// works harder before the limit is reached.
+func newFileLimits(maxFileSizeMB, maxMemoryMB int) (fileLimits, error) {
+	if maxFileSizeMB < 0 || maxMemoryMB < 0 {
+		return fileLimits{}, fmt.Errorf("--max-file-size and --max-memory must not be negative")
+	}
+	l := fileLimits{maxFileSize: int64(maxFileSizeMB) * bytesPerMB, maxMemory: int64(maxMemoryMB) * bytesPerMB}
+	if l.maxMemory > 0 {
+		debug.SetMemoryLimit(l.maxMemory)
+	}
+	return l, nil
+}
+
+// check returns why a file of size bytes is skipped, or "" when it is within
+// the limits.
+func (l fileLimits) check(size int64) string {
+	switch {
+	case l.maxFileSize > 0 && size > l.maxFileSize:
+		return fmt.Sprintf("larger than %d MB", l.maxFileSize/bytesPerMB)
+	case l.maxMemory > 0 && size*memoryPerByte > l.maxMemory:
+		return fmt.Sprintf("formatting needs an estimated %d MB of memory, more than --max-memory=%d", (size*memoryPerByte+bytesPerMB-1)/bytesPerMB, l.maxMemory/bytesPerMB)
+	}
+	return ""
+}
+
+// checkFile returns why the file filename is skipped, or "" when it is within
+// the limits or cannot be checked, such as stdin.
+func (l fileLimits) checkFile(filename string) string {
+	if filename == "-" || l == (fileLimits{}) {
+		return ""
+	}
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		// Reading the file reports the error.
+		return ""
+	}
+	return l.check(info.Size())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+func TestFileLimitsCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits fileLimits
+		size   int64
+		want   string
+	}{
+		{"no limits", fileLimits{}, 1 << 40, ""},
+		{"within the size limit", fileLimits{maxFileSize: bytesPerMB}, bytesPerMB, ""},
+		{"larger than the size limit", fileLimits{maxFileSize: bytesPerMB}, bytesPerMB + 1, "larger than 1 MB"},
+		{"within the memory limit", fileLimits{maxMemory: 16 * bytesPerMB}, 16 * bytesPerMB / memoryPerByte, ""},
+		{"over the memory limit", fileLimits{maxMemory: 16 * bytesPerMB}, 16*bytesPerMB/memoryPerByte + 1, "formatting needs an estimated 17 MB of memory, more than --max-memory=16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.check(tt.size); got != tt.want {
+				t.Errorf("check(%d) = %q, want %q", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkFormatMemory measures the memory needed to read, format and join
+// a file, which memoryPerByte estimates: the peak of the heap and the heap
+// allocated meanwhile, both per byte of the file. The peak is sampled, so it
+// may miss short spikes.
+func BenchmarkFormatMemory(b *testing.B) {
+	f, err := formatter.New()
+	if err != nil {
+		b.Fatalf("New: %v", err)
+	}
+	var src strings.Builder
+	for src.Len() < bytesPerMB {
+		src.WriteString("function y=f(x)\n% Scale x.\nif x>0\ny=x*2; % double\nelse\ny=[1,2;3,4];\nend\nend\n")
+	}
+	source := []byte(src.String())
+
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	heap := func() uint64 {
+		metrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+	var peak, allocated float64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		base := heap()
+		done := make(chan struct{})
+		highest := make(chan uint64)
+		go func() {
+			var top uint64
+			ticker := time.NewTicker(100 * time.Microsecond)
+			defer ticker.Stop()
+			for {
+				top = max(top, heap())
+				select {
+				case <-done:
+					highest <- top
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+
+		lines, err := formatter.ReadLines(bytes.NewReader(source))
+		if err != nil {
+			b.Fatalf("ReadLines: %v", err)
+		}
+		formatted, err := f.FormatLinesContext(context.Background(), lines)
+		if err != nil {
+			b.Fatalf("FormatLinesContext: %v", err)
+		}
+		joined := f.JoinLinesLike(formatted, source)
+
+		close(done)
+		top := <-highest
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(joined)
+		peak += float64(top-min(top, base)) / float64(len(source))
+		allocated += float64(after.TotalAlloc-before.TotalAlloc) / float64(len(source))
+	}
+	b.ReportMetric(peak/float64(b.N), "peak-B/byte")
+	b.ReportMetric(allocated/float64(b.N), "allocated-B/byte")
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/config"
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+	"github.com/koyashimano/matlab-formatter/internal/script"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// Exit statuses of the lint subcommand, reflecting the highest severity
+// reported.
+const (
+	lintExitClean   = exitOK
+	lintExitWarning = exitChanged
+	lintExitError   = exitDiagnostic
+)
+
+func runLint(args []string) int {
+	opts := lint.DefaultOptions()
+
+	fs := flag.NewFlagSet("matlabformatter lint", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Apply available fixes, rewriting files in place")
+	startLine := fs.Int("start-line", opts.StartLine, "Start line (1-based)")
+	endLine := fs.Int("end-line", opts.EndLine, "End line (inclusive, 0 for end of file)")
+	configPath := fs.String("config", "", "Configuration file setting rule severities")
+	profile := fs.String("profile", "", "Profile of the configuration file to apply")
+	allowScripts := fs.Bool("allow-scripts", false, "Run the Starlark script rules of the configuration file")
+	maxWarnings := fs.Int("max-warnings", -1, "Fail when more than this many warnings are reported (-1 for no limit)")
+	werror := fs.Bool("werror", false, "Report warnings as errors")
+	output := fs.String("output", "text", "Output format: text, checkstyle")
+	tabWidth := fs.Int("tab-width", opts.TabWidth, "Tab stop distance used by the mixed-indentation fix")
+	functionOrder := fs.String("local-function-order", "", "Order enforced by local-function-order: alphabetical, first-use")
+	addDeprecatedAliases(fs)
+
+	filenames, err := parseFilenames(fs, args)
+	if err != nil {
+		if errors.Is(err, errMissingFilename) {
+			printLintUsage()
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
+	}
+	warnDeprecated(fs)
+
+	sources := commandLineSources(fs)
+	if err := sources.applyEnv(fs, "config", "profile", "tab-width", "local-function-order"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	opts.StartLine = *startLine
+	opts.EndLine = *endLine
+	opts.TabWidth = *tabWidth
+
+	if *output != "text" && *output != "checkstyle" {
+		fmt.Fprintf(os.Stderr, "invalid output format %q (valid values: text, checkstyle)\n", *output)
+		return exitUsage
+	}
+
+	// Without --config, the configuration file found for the first file
+	// applies to all of them.
+	cfgPath := *configPath
+	if cfgPath == "" {
+		if cfgPath, err = (configFinder{}).find(filenames[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return argumentStatus(err)
+		}
+	}
+	cfg, err := loadConfig(cfgPath, *profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return argumentStatus(err)
+	}
+	if cfg != nil {
+		if opts.CustomRules, err = lintCustomRules(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		if len(cfg.Lint.Scripts) > 0 && !*allowScripts {
+			fmt.Fprintf(os.Stderr, "%s: skipping %d script rules, pass --allow-scripts to run them\n", cfg.Path, len(cfg.Lint.Scripts))
+		} else if opts.ExternalRules, err = lintScriptRules(cfg, opts.CustomRules); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return argumentStatus(err)
+		}
+		if opts.Severities, err = lintSeverities(cfg, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		if cfg.Lint.LocalFunctionOrder != "" {
+			opts.LocalFunctionOrder = cfg.Lint.LocalFunctionOrder
+		}
+		if h := cfg.Lint.Header; h != nil {
+			opts.HeaderTemplate = strings.Split(strings.TrimRight(h.Template, "\n"), "\n")
+			opts.HeaderUpdateYear = h.UpdateYear
+		}
+	}
+	if *functionOrder != "" {
+		opts.LocalFunctionOrder = *functionOrder
+	}
+	if opts.LocalFunctionOrder != "alphabetical" && opts.LocalFunctionOrder != "first-use" {
+		fmt.Fprintf(os.Stderr, "invalid local function order %q (valid values: alphabetical, first-use)\n", opts.LocalFunctionOrder)
+		return exitUsage
+	}
+
+	status := lintExitClean
+	warnings := 0
+	// Fixed stdin content goes to stdout, so report on stderr instead.
+	out := os.Stdout
+	folders, err := classFolderFindings(filenames, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return errorStatus(err)
+	}
+	var results []fileFindings
+	for _, filename := range filenames {
+		if *fix && filename == "-" {
+			out = os.Stderr
+		}
+
+		findings, err := lintFile(filename, opts, *fix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			status = max(status, errorStatus(err))
+			continue
+		}
+		if more := folders[filepath.Clean(filename)]; len(more) > 0 {
+			findings = append(findings, more...)
+			sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+		}
+
+		for i := range findings {
+			if *werror && findings[i].Severity == lint.SeverityWarning {
+				findings[i].Severity = lint.SeverityError
+			}
+
+			switch findings[i].Severity {
+			case lint.SeverityError:
+				status = max(status, lintExitError)
+			case lint.SeverityWarning:
+				warnings++
+				if status < lintExitWarning {
+					status = lintExitWarning
+				}
+			}
+		}
+		results = append(results, fileFindings{Path: filename, Findings: findings})
+	}
+
+	if err := writeFindings(out, *output, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	if *maxWarnings >= 0 && warnings > *maxWarnings {
+		fmt.Fprintf(os.Stderr, "too many warnings (%d, maximum %d)\n", warnings, *maxWarnings)
+		if status < lintExitWarning {
+			status = lintExitWarning
+		}
+	} else if *maxWarnings >= 0 && status == lintExitWarning {
+		status = lintExitClean
+	}
+
+	return status
+}
+
+// lintSeverities converts the rule severities of a configuration file,
+// rejecting unknown rule IDs.
+func lintSeverities(cfg *config.Config, opts lint.Options) (map[string]lint.Severity, error) {
+	known := map[string]bool{lint.UnusedSuppression: true, lint.ClassFolderRule: true}
+	for _, r := range lint.Rules() {
+		known[r.ID] = true
+	}
+	for _, r := range opts.CustomRules {
+		known[r.ID] = true
+	}
+	for _, r := range cfg.Lint.Scripts {
+		known[r.ID] = true
+	}
+
+	ids := make([]string, 0, len(cfg.Lint.Rules))
+	for id := range cfg.Lint.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	severities := make(map[string]lint.Severity, len(ids))
+	for _, id := range ids {
+		name := cfg.Lint.Rules[id]
+		line := cfg.Line("lint.rules." + id)
+		if !known[id] {
+			return nil, fmt.Errorf("%s:%d: unknown lint rule %q", cfg.Path, line, id)
+		}
+		s, err := lint.ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", cfg.Path, line, err)
+		}
+		severities[id] = s
+	}
+	return severities, nil
+}
+
+// lintCustomRules compiles the custom rules of a configuration file. Their IDs
+// must not clash with built-in rules or each other.
+func lintCustomRules(cfg *config.Config) ([]lint.CustomRule, error) {
+	taken := map[string]bool{lint.UnusedSuppression: true, lint.ClassFolderRule: true}
+	for _, r := range lint.Rules() {
+		taken[r.ID] = true
+	}
+
+	var rules []lint.CustomRule
+	for _, c := range cfg.Lint.Custom {
+		if taken[c.ID] {
+			return nil, fmt.Errorf("%s:%d: custom rule ID %q is already in use", cfg.Path, c.Line, c.ID)
+		}
+		taken[c.ID] = true
+
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: custom rule %s: %v", cfg.Path, c.Line, c.ID, err)
+		}
+		r := lint.CustomRule{ID: c.ID, Pattern: pattern, Message: c.Message, Severity: lint.SeverityWarning}
+		if r.Message == "" {
+			r.Message = "code matches " + c.Pattern
+		}
+		if c.Severity != "" {
+			if r.Severity, err = lint.ParseSeverity(c.Severity); err != nil {
+				return nil, fmt.Errorf("%s:%d: custom rule %s: %v", cfg.Path, c.Line, c.ID, err)
+			}
+		}
+		if c.HasReplacement {
+			replacement := c.Replacement
+			r.Replacement = &replacement
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// lintScriptRules loads the Starlark rules of a configuration file. Their IDs
+// must not clash with built-in rules, custom rules or each other.
+func lintScriptRules(cfg *config.Config, custom []lint.CustomRule) ([]lint.ExternalRule, error) {
+	taken := map[string]bool{lint.UnusedSuppression: true, lint.ClassFolderRule: true}
+	for _, r := range lint.Rules() {
+		taken[r.ID] = true
+	}
+	for _, r := range custom {
+		taken[r.ID] = true
+	}
+
+	var rules []lint.ExternalRule
+	for _, s := range cfg.Lint.Scripts {
+		if taken[s.ID] {
+			return nil, fmt.Errorf("%s:%d: script rule ID %q is already in use", cfg.Path, s.Line, s.ID)
+		}
+		taken[s.ID] = true
+
+		severity := lint.SeverityWarning
+		if s.Severity != "" {
+			var err error
+			if severity, err = lint.ParseSeverity(s.Severity); err != nil {
+				return nil, fmt.Errorf("%s:%d: script rule %s: %v", cfg.Path, s.Line, s.ID, err)
+			}
+		}
+		r, err := script.Load(s.ID, s.File, severity)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: script rule %s: %v", cfg.Path, s.Line, s.ID, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// lintFile lints a single file and returns the remaining findings. With fix
+// set the fixed content is written back to the file, or to stdout for "-".
+func lintFile(filename string, opts lint.Options, fix bool) ([]lint.Finding, error) {
+	src, err := readSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := formatter.ReadLines(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	opts.Path = filename
+
+	if !fix || formatter.IsGenerated(lines, formatter.DefaultGeneratedMarkers) {
+		if fix {
+			fmt.Fprintf(os.Stderr, "%s: not fixing generated file\n", filename)
+		}
+		return lint.Run(lines, opts), nil
+	}
+
+	fixed, findings, err := lint.Fix(lines, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fixed lines are written with the line endings and final newline
+	// of the source, and files nothing was fixed in are left untouched.
+	joiner, err := formatter.New(
+		formatter.WithLineEnding(formatter.LineEndingAuto),
+		formatter.WithKeepTrailingLines(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	content := joiner.JoinLinesLike(fixed, src)
+	if filename == "-" {
+		_, err = io.WriteString(os.Stdout, content)
+		return findings, err
+	}
+	if slices.Equal(fixed, lines) {
+		return findings, nil
+	}
+
+	if err := rewriteFile(filename, []byte(content), rewriteOptions{}); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func printLintUsage() {
+	fmt.Fprintf(os.Stderr, "usage: matlabformatter lint [options...] <file...>\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --fix (default false) - Apply available fixes, rewriting files in place\n")
+	opts := lint.DefaultOptions()
+	fmt.Fprintf(os.Stderr, "    --start-line=int (default %d)\n", opts.StartLine)
+	fmt.Fprintf(os.Stderr, "    --end-line=int (default %d)\n", opts.EndLine)
+	fmt.Fprintf(os.Stderr, "    --config=string - Configuration file setting rule severities\n")
+	fmt.Fprintf(os.Stderr, "    --profile=string - Profile of the configuration file to apply\n")
+	fmt.Fprintf(os.Stderr, "    --allow-scripts (default false) - Run the Starlark script rules of the configuration file\n")
+	fmt.Fprintf(os.Stderr, "    --max-warnings=int (default -1) - Fail when more warnings are reported\n")
+	fmt.Fprintf(os.Stderr, "    --werror (default false) - Report warnings as errors\n")
+	fmt.Fprintf(os.Stderr, "    --output=string (default text) - Output format: text, checkstyle\n")
+	fmt.Fprintf(os.Stderr, "    --tab-width=int (default %d) - Tab stop distance used by the mixed-indentation fix\n", opts.TabWidth)
+	fmt.Fprintf(os.Stderr, "    --local-function-order=string (default %s) - Order enforced by local-function-order: alphabetical, first-use\n", opts.LocalFunctionOrder)
+	fmt.Fprintf(os.Stderr, "  The camelCase spellings of earlier releases, such as --startLine, are deprecated aliases.\n")
+	fmt.Fprintf(os.Stderr, "  RULES:\n")
+	for _, r := range lint.Rules() {
+		fixable := ""
+		if r.Fixable {
+			fixable = " (fixable)"
+		}
+		fmt.Fprintf(os.Stderr, "    %s (%s) - %s%s\n", r.ID, r.Severity, r.Description, fixable)
+	}
+	fmt.Fprintf(os.Stderr, "    %s (%s) - %s\n", lint.ClassFolderRule, lint.SeverityWarning, classFolderDescription)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+)
+
+func TestLintFileFixKeepsLineEndings(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"crlf", "x = 1;  \r\ny = 2;\r\n", "x = 1;\r\ny = 2;\r\n"},
+		{"crlf without final newline", "x = 1;\t\r\ny = 2;", "x = 1;\r\ny = 2;"},
+		{"cr", "x = 1; \ry = 2;\r", "x = 1;\ry = 2;\r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "fixture.m")
+			if err := os.WriteFile(filename, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := lintFile(filename, lint.DefaultOptions(), true); err != nil {
+				t.Fatalf("lintFile: %v", err)
+			}
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintFileFixLeavesUnchangedFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fixture.m")
+	src := "x = 1;\r\ny = 2;"
+	if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filename, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lintFile(filename, lint.DefaultOptions(), true); err != nil {
+		t.Fatalf("lintFile: %v", err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("modification time changed to %v, want %v", info.ModTime(), old)
+	}
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != src {
+		t.Errorf("got %q, want %q", got, src)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logLevel is the minimum level of the messages logged, set by --log-level
+// and --quiet.
+var logLevel = new(slog.LevelVar)
+
+// logger receives the text written besides the output of the commands:
+// errors, warnings and notes about the files processed. Messages about a
+// file carry it in a "file" attribute.
+var logger = slog.New(&messageHandler{w: os.Stderr, level: logLevel, mu: new(sync.Mutex)})
+
+// setLogLevel sets the level of logger from the --log-level and --quiet
+// flags.
+func setLogLevel(level string, quiet bool) error {
+	if quiet {
+		logLevel.Set(slog.LevelError)
+		return nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (valid values: debug, info, warn, error)", level)
+	}
+	logLevel.Set(l)
+	return nil
+}
+
+// warnDeprecated logs a warning for each deprecated alias set on fs.
+func warnDeprecated(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		if a, ok := f.Value.(*flagAlias); ok && a.deprecated {
+			logger.Warn(fmt.Sprintf("--%s is deprecated, use --%s", a.name, a.target))
+		}
+	})
+}
+
+// messageHandler writes records as lines of the form "file: message",
+// followed by the other attributes as key=value pairs. Warnings and debug
+// messages start with their level, as in "debug: file: message".
+type messageHandler struct {
+	w     io.Writer
+	level slog.Leveler
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+func (h *messageHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *messageHandler) Handle(_ context.Context, r slog.Record) error {
+	var file string
+	var rest []string
+	add := func(a slog.Attr) bool {
+		if a.Key == "file" {
+			file = a.Value.String()
+		} else {
+			rest = append(rest, a.Key+"="+a.Value.String())
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+
+	var b strings.Builder
+	switch {
+	case r.Level == slog.LevelWarn:
+		b.WriteString("warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("debug: ")
+	}
+	if file != "" {
+		b.WriteString(file + ": ")
+	}
+	b.WriteString(r.Message)
+	for _, s := range rest {
+		b.WriteString(" " + s)
+	}
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *messageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup is not used by the commands; groups are flattened.
+func (h *messageHandler) WithGroup(string) slog.Handler {
+	return h
+}
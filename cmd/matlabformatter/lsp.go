@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/koyashimano/matlab-formatter/internal/editorconfig"
+	"github.com/koyashimano/matlab-formatter/internal/lsp"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// exitNoShutdown is the status the protocol requires when the client exits
+// without requesting a shutdown.
+const exitNoShutdown = 1
+
+func runLSP(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter lsp", flag.ExitOnError)
+	readOptions := formatFlags(fs, formatter.DefaultOptions())
+	configPath := fs.String("config", "", "Configuration file setting formatting options, instead of the .matlabformatter.toml files found for each document")
+	profile := fs.String("profile", "", "Profile of the configuration file to apply")
+	useEditorconfig := fs.Bool("editorconfig", true, "Read indentation and line ending settings from .editorconfig files")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		printLSPUsage()
+		return exitUsage
+	}
+	sources := commandLineSources(fs)
+	configurable := append([]string{"config", "profile", "editorconfig"}, formatOptionFlags()...)
+	if err := sources.applyEnv(fs, configurable...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	// Each document is formatted with the options of the configuration file
+	// and the .editorconfig files found for it, falling back to the settings
+	// of the editor. Documents that are not files use the configuration file
+	// found from the working directory.
+	finder := configFinder{}
+	server := lsp.NewServer(func(path string, editor lsp.FormattingOptions) (*formatter.Formatter, error) {
+		cfgPath := *configPath
+		var props map[string]editorconfig.Property
+		var err error
+		if cfgPath == "" {
+			file := path
+			if file == "" {
+				file = "-"
+			}
+			if cfgPath, err = finder.find(file); err != nil {
+				return nil, err
+			}
+		}
+		if path != "" && *useEditorconfig {
+			if props, err = lookupEditorconfig(path); err != nil {
+				return nil, err
+			}
+		}
+		s, err := sources.forFile(fs, cfgPath, *profile, props)
+		if err != nil {
+			return nil, err
+		}
+		s.applyEditorSettings(fs, editor)
+		return formatter.NewStrict(readOptions())
+	})
+	if err := server.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, lsp.ErrNoShutdown) {
+			return exitNoShutdown
+		}
+		return exitIO
+	}
+	return exitOK
+}
+
+// applyEditorSettings sets the indentation flags not set otherwise from the
+// settings the editor sends with formatting requests.
+func (s optionSources) applyEditorSettings(fs *flag.FlagSet, editor lsp.FormattingOptions) {
+	if _, ok := s["indent-width"]; !ok && editor.TabSize > 0 {
+		fs.Set("indent-width", fmt.Sprint(editor.TabSize))
+		s["indent-width"] = optionSource{Kind: "editor"}
+	}
+	if _, ok := s["indent-style"]; !ok {
+		style := "tab"
+		if editor.InsertSpaces {
+			style = "space"
+		}
+		fs.Set("indent-style", style)
+		s["indent-style"] = optionSource{Kind: "editor"}
+	}
+}
+
+func printLSPUsage() {
+	fmt.Fprintf(os.Stderr, "usage: matlabformatter lsp [options...]\n")
+	fmt.Fprintf(os.Stderr, "  Serves textDocument/formatting, textDocument/rangeFormatting, textDocument/documentSymbol\n")
+	fmt.Fprintf(os.Stderr, "  and textDocument/foldingRange requests over stdio.\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --config=string - Configuration file setting formatting options, instead of the .matlabformatter.toml files found for each document\n")
+	fmt.Fprintf(os.Stderr, "    --profile=string - Profile of the configuration file to apply\n")
+	fmt.Fprintf(os.Stderr, "    --editorconfig=bool (default true) - Read indentation and line ending settings from .editorconfig files\n")
+	fmt.Fprintf(os.Stderr, "    The formatting options of the formatter, such as --indent-width, are accepted too.\n")
+}
@@ -2,106 +2,711 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/koyashimano/matlab-formatter/internal/formatter"
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+	"github.com/koyashimano/matlab-formatter/internal/editorconfig"
+	"github.com/koyashimano/matlab-formatter/internal/walk"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
 )
 
 var errMissingFilename = errors.New("missing filename")
 
+// subcommands maps the first command-line argument to the command it selects.
+// Without a subcommand the arguments are files to format.
+var subcommands = map[string]func(args []string) int{
+	"lint":    runLint,
+	"metrics": runMetrics,
+	"deps":    runDeps,
+	"symbols": runSymbols,
+	"folding": runFolding,
+	"rules":   runRules,
+	"lsp":     runLSP,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+	os.Exit(runFormat(os.Args[1:]))
+}
+
+// runFormat formats the files named by args and returns the exit status.
+func runFormat(args []string) int {
+	// An interrupt stops formatting, leaving the file being formatted and
+	// the remaining ones unchanged.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	opts := formatter.DefaultOptions()
 
 	fs := flag.NewFlagSet("matlabformatter", flag.ExitOnError)
-	write := fs.Bool("w", false, "Write result to source file instead of stdout")
-	startLine := fs.Int("startLine", opts.StartLine, "Start line (1-based)")
-	endLine := fs.Int("endLine", opts.EndLine, "End line (inclusive, 0 for end of file)")
-	indentWidth := fs.Int("indentWidth", opts.IndentWidth, "Number of spaces per indentation level")
-	separateBlocks := fs.Bool("separateBlocks", opts.SeparateBlocks, "Insert blank lines between blocks")
-	indentMode := fs.String("indentMode", opts.IndentMode, "Indentation mode: all_functions, only_nested_functions, classic")
-	addSpaces := fs.String("addSpaces", opts.AddSpaces, "Operator spacing: all_operators, exclude_pow, no_spaces")
-	matrixIndent := fs.String("matrixIndent", opts.MatrixIndent, "Matrix indentation: aligned, simple")
-
-	filenames, err := parseFilenames(fs, os.Args[1:])
-	if err != nil {
+	write := fs.Bool("write", false, "Write result to source file instead of stdout")
+	recursive := fs.Bool("recursive", false, "Format the .m files below directory arguments")
+	gitignore := fs.Bool("gitignore", false, "With -r, skip files and directories ignored by .gitignore files and .git/info/exclude")
+	followSymlinks := fs.Bool("follow-symlinks", false, "With -r, walk the directories symbolic links point to")
+	showDiff := fs.Bool("diff", false, "Print the changes as a diff instead of the formatted source")
+	output := fs.String("output", "unified", "Print the changes in this format, as --diff does: unified, json, patch")
+	editsFormat := fs.String("edits", "", "Print the changes as text edits instead of the formatted source: json")
+	showWhitespace := fs.Bool("show-whitespace", false, "Print the formatted source with visible spaces, tabs and line ends to stderr")
+	whitespaceFile := fs.String("show-whitespace-file", "", "Write the --show-whitespace output to this file instead of stderr")
+	trace := fs.Bool("trace", false, "Print the classification and spacing rules of each line as JSON to stderr")
+	minimal := fs.String("minimal", "", "Apply only changes of the listed classes: indentation, spacing, structural")
+	classFolders := fs.Bool("class-folders", false, "Report method files of @ClassName folders that do not match the classdef")
+	quiet := fs.Bool("quiet", false, "Log errors only")
+	logLevelName := fs.String("log-level", "info", "Minimum level of the messages logged to stderr: debug, info, warn, error")
+	timeoutPerFile := fs.Duration("timeout-per-file", 0, "Stop formatting a file after this long, such as 5s, leaving it unchanged (0 for no limit)")
+	maxFileSize := fs.Int("max-file-size", 0, "Skip files larger than this many megabytes (0 for no limit)")
+	maxMemory := fs.Int("max-memory", 0, "Skip files, leaving them unchanged, whose formatting would need more than this many megabytes of memory as estimated from their size; diffs may need more (0 for no limit)")
+	dryRun := fs.Bool("dry-run", false, "Format the files without writing or printing them")
+	check := fs.Bool("check", false, "Like --dry-run, and name the files that would be reformatted on stderr")
+	list := fs.Bool("list", false, "Print the paths of the files whose formatting differs instead of the formatted source")
+	status := fs.Bool("status", false, "Print one line per file: changed, unchanged, skipped or error")
+	porcelain := fs.Bool("porcelain", false, "Print --status lines in a stable, tab-separated format for scripts")
+	preserveMtime := fs.Bool("preserve-mtime", false, "Keep the modification time of files rewritten by --write")
+	preserveCreationTime := fs.Bool("preserve-creation-time", false, "Keep the creation time of files rewritten by --write (Windows only)")
+	inputFD := fs.Int("input-fd", -1, "Read the source from this file descriptor instead of a file (-1 disables)")
+	outputFD := fs.Int("output-fd", -1, "Write the output to this file descriptor instead of stdout (-1 disables)")
+	outputFile := fs.String("output-file", "", "Write the output to this file or named pipe instead of stdout")
+	batch := fs.Bool("batch", false, "Format the files framed on stdin and write the framed results to stdout")
+	mlappMode := fs.String("mlapp", "extract", "How --write stores the code of .mlapp apps: extract, repack")
+	formatGenerated := fs.Bool("format-generated", false, "Format files marked as generated instead of skipping them")
+	generatedMarkers := fs.String("generated-markers", strings.Join(formatter.DefaultGeneratedMarkers, ","), "Comma-separated phrases marking generated files")
+	var ranges lineRanges
+	fs.Var(&ranges, "lines", "Line range START:END to format; may be repeated")
+	section := fs.String("section", "", "Format only the %% section with this 1-based index or title")
+	readOptions := formatFlags(fs, opts)
+	configPath := fs.String("config", "", "Configuration file setting formatting options")
+	profile := fs.String("profile", "", "Profile of the configuration file to apply")
+	explainConfig := fs.Bool("explain-config", false, "Print the effective options and where they were set as JSON, then exit")
+	useEditorconfig := fs.Bool("editorconfig", true, "Read indentation and line ending settings from .editorconfig files")
+	addAlias(fs, "w", "write", false)
+	addAlias(fs, "r", "recursive", false)
+	addAlias(fs, "l", "list", false)
+	addAlias(fs, "d", "diff", false)
+	addAlias(fs, "eol", "line-ending", false)
+	addDeprecatedAliases(fs)
+
+	filenames, err := parseFilenames(fs, args)
+	if errors.Is(err, errMissingFilename) && *inputFD >= 0 {
+		filenames, err = []string{"-"}, nil
+	}
+	if err != nil && !((*explainConfig || *batch) && errors.Is(err, errMissingFilename)) {
 		if errors.Is(err, errMissingFilename) {
 			printUsage()
 		} else {
-			fmt.Fprintln(os.Stderr, err)
+			logger.Error(err.Error())
+		}
+		return exitUsage
+	}
+
+	if err := setLogLevel(*logLevelName, *quiet); err != nil {
+		logger.Error(err.Error())
+		return exitUsage
+	}
+	warnDeprecated(fs)
+
+	sources := commandLineSources(fs)
+	// Selecting an output format prints the changes in it.
+	if _, ok := sources["output"]; ok {
+		*showDiff = true
+	}
+	configurable := append([]string{"config", "profile", "editorconfig"}, formatOptionFlags()...)
+	if err := sources.applyEnv(fs, configurable...); err != nil {
+		logger.Error(err.Error())
+		return exitUsage
+	}
+	// Without --config, each file is formatted with the options of the
+	// configuration file found for it, and the settings of the .editorconfig
+	// files applying to it fill in the options left unset; those of the first
+	// file apply until they differ.
+	envSources := maps.Clone(sources)
+	finder := configFinder{}
+	first := "-"
+	if len(filenames) > 0 {
+		first = filenames[0]
+	}
+	cfgPath := *configPath
+	if cfgPath == "" {
+		if cfgPath, err = finder.find(first); err != nil {
+			logger.Error(err.Error())
+			return argumentStatus(err)
+		}
+	}
+	cfg, err := loadConfig(cfgPath, *profile)
+	if err != nil {
+		logger.Error(err.Error())
+		return argumentStatus(err)
+	}
+	if cfg != nil {
+		logger.Debug("loaded configuration", "path", cfg.Path)
+		if err := sources.applyFormatConfig(fs, cfg); err != nil {
+			logger.Error(err.Error())
+			return exitUsage
+		}
+	}
+	var editorProps map[string]editorconfig.Property
+	if *useEditorconfig {
+		if editorProps, err = lookupEditorconfig(first); err != nil {
+			logger.Error(err.Error())
+			return argumentStatus(err)
+		}
+		sources.applyEditorconfig(fs, editorProps)
+	}
+	if *explainConfig {
+		if err := sources.explainOptions(os.Stdout, fs, configurable...); err != nil {
+			logger.Error(err.Error())
+			return exitIO
 		}
-		os.Exit(1)
+		return exitOK
 	}
 
-	options := formatter.Options{
-		StartLine:      *startLine,
-		EndLine:        *endLine,
-		IndentWidth:    *indentWidth,
-		SeparateBlocks: *separateBlocks,
-		IndentMode:     *indentMode,
-		AddSpaces:      *addSpaces,
-		MatrixIndent:   *matrixIndent,
+	if len(ranges) > 0 || *section != "" {
+		_, hasStart := sources["start-line"]
+		_, hasEnd := sources["end-line"]
+		if hasStart || hasEnd {
+			logger.Error("--lines and --section cannot be combined with --start-line or --end-line")
+			return exitUsage
+		}
+	}
+
+	options := readOptions()
+
+	f, err := formatter.NewStrict(options)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitUsage
+	}
+	// formatterFor returns the formatter configured by the configuration
+	// file at path, or by the flags alone when path is "", and by the
+	// .editorconfig settings props.
+	formatterFor := func(path string, props map[string]editorconfig.Property) (*formatter.Formatter, formatter.Options, error) {
+		if _, err := envSources.forFile(fs, path, *profile, props); err != nil {
+			return nil, formatter.Options{}, err
+		}
+		options := readOptions()
+		f, err := formatter.NewStrict(options)
+		if err != nil {
+			return nil, formatter.Options{}, fmt.Errorf("%s: %w", path, err)
+		}
+		return f, options, nil
 	}
 
-	f, err := formatter.New(options)
+	if err := redirectStreams(*inputFD, *outputFD, *outputFile); err != nil {
+		logger.Error(err.Error())
+		return argumentStatus(err)
+	}
+	if *batch && (len(filenames) > 0 || *write) {
+		logger.Error("--batch reads the files from stdin and cannot be combined with file arguments or --write")
+		return exitUsage
+	}
+	if *recursive {
+		if filenames, err = expandPaths(filenames, walk.Options{Gitignore: *gitignore, FollowSymlinks: *followSymlinks}); err != nil {
+			logger.Error(err.Error())
+			return argumentStatus(err)
+		}
+	} else if dir := firstDirectory(filenames); dir != "" {
+		logger.Error("is a directory; use -r to format the .m files below it", "file", dir)
+		return exitUsage
+	}
+	if !mlappModes[*mlappMode] {
+		logger.Error(fmt.Sprintf("invalid mlapp mode %q (valid values: extract, repack)", *mlappMode))
+		return exitUsage
+	}
+	if *output != "unified" && *output != "json" && *output != "patch" {
+		logger.Error(fmt.Sprintf("invalid output format %q (valid values: unified, json, patch)", *output))
+		return exitUsage
+	}
+	if *editsFormat != "" && *editsFormat != "json" {
+		logger.Error(fmt.Sprintf("invalid edits format %q (valid values: json)", *editsFormat))
+		return exitUsage
+	}
+	if *editsFormat != "" && (*showDiff || *write || *batch) {
+		logger.Error("--edits cannot be combined with --diff, --write or --batch")
+		return exitUsage
+	}
+	limits, err := newFileLimits(*maxFileSize, *maxMemory)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		return exitUsage
+	}
+	keep, err := parseClasses(*minimal)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitUsage
+	}
+
+	markers := splitList(*generatedMarkers)
+
+	var whitespaceOut io.Writer
+	switch {
+	case *whitespaceFile != "":
+		out, err := os.Create(*whitespaceFile)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitIO
+		}
+		defer out.Close()
+		whitespaceOut = out
+	case *showWhitespace:
+		whitespaceOut = os.Stderr
 	}
 
 	// Process each file
-	hasError := false
+	exitStatus := exitOK
+	var diffs []fileDiff
+	var edits []fileEdits
+	var skipped []string
+	// tests lists the function-based test files the test conventions changed.
+	var tests []string
+	// Test conventions rearrange whole files only.
+	testConventions := options.Only == "" && len(ranges) == 0 && *section == "" && options.StartLine <= 1 && options.EndLine == 0
+	var traces []fileTrace
+	// extracted lists the apps whose formatted code was written next to them.
+	var extracted []extractedApp
+	packageRoots := make(map[string][]string)
+	rewrite := rewriteOptions{preserveMtime: *preserveMtime, preserveCreationTime: *preserveCreationTime}
+	// configure switches f and options to those of the configuration file
+	// and the .editorconfig settings applying to filename.
+	configure := func(filename string) error {
+		if *configPath != "" && !*useEditorconfig {
+			return nil
+		}
+		path, props, err := cfgPath, editorProps, error(nil)
+		if *configPath == "" {
+			path, err = finder.find(filename)
+		}
+		if err == nil && *useEditorconfig {
+			props, err = lookupEditorconfig(filename)
+		}
+		if err == nil && (path != cfgPath || !maps.Equal(props, editorProps)) {
+			f, options, err = formatterFor(path, props)
+		}
+		if err != nil {
+			return err
+		}
+		cfgPath, editorProps = path, props
+		return nil
+	}
+	// formatSource formats the lines read from filename.
+	formatSource := func(filename string, lines []string) ([]string, error) {
+		var opts []formatter.CallOption
+		if options.QualifyImports {
+			pkg, packages, err := packageHierarchy(filename, packageRoots)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, formatter.InPackage(pkg, packages))
+		}
+
+		formatted := lines
+		var lineTraces []formatter.LineTrace
+		if !*formatGenerated && formatter.IsGenerated(lines, markers) {
+			// Generated files pass through unchanged.
+			skipped = append(skipped, filename)
+		} else if formatted, lineTraces, err = formatWithTimeout(ctx, f, lines, ranges, *section, *trace, *timeoutPerFile, opts...); err != nil {
+			return nil, err
+		} else {
+			if testConventions && formatter.IsTestFile(filename, formatted) {
+				if conventional := f.ApplyTestConventions(formatted); !slices.Equal(conventional, formatted) {
+					formatted = conventional
+					tests = append(tests, filename)
+				}
+			}
+			if *trace {
+				traces = append(traces, fileTrace{Path: filename, Lines: lineTraces})
+			}
+		}
+		if keep != nil {
+			formatted = diff.Apply(lines, formatted, func(c diff.Change) bool { return keep[c.Class] })
+		}
+
+		if whitespaceOut != nil {
+			if err := writeWhitespace(whitespaceOut, filename, formatted); err != nil {
+				logger.Error(err.Error(), "file", filename)
+				exitStatus = max(exitStatus, exitIO)
+			}
+		}
+		return formatted, nil
+	}
+
+	if *batch {
+		// Each file is formatted with the options applying to its name.
+		format := func(filename string, lines []string) ([]string, error) {
+			if err := configure(filename); err != nil {
+				return nil, err
+			}
+			return formatSource(filename, lines)
+		}
+		join := func(lines []string, src []byte) string { return f.JoinLinesLike(lines, src) }
+		if err := runBatch(os.Stdin, os.Stdout, format, join, limits, *showDiff, *output); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+	// statuses records the outcome of each file for --status.
+	var statuses []fileStatus
+	fail := func(filename string, err error) {
+		logger.Error(err.Error(), "file", filename)
+		exitStatus = max(exitStatus, errorStatus(err))
+		statuses = append(statuses, fileStatus{Path: filename, Status: statusError, Detail: err.Error()})
+	}
+	// With --dry-run nothing is written, and the formatted source is not
+	// printed when --status or --list report on the files instead. --check
+	// is a --dry-run naming the files that would change.
+	dryRunning := *dryRun || *check
+	writeFiles := *write && !dryRunning
+	// Patches and text edits need the exact content of each file.
+	patch := *showDiff && *output == "patch"
+	printEdits := *editsFormat != ""
+	var sourceOut io.Writer = os.Stdout
+	if dryRunning || *status || *list || printEdits {
+		sourceOut = io.Discard
+	}
 	for _, filename := range filenames {
-		// If -w flag is set and not reading from stdin, write to file
-		if *write && filename != "-" {
-			var buf bytes.Buffer
-			if err := f.FormatFile(filename, &buf); err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
-				hasError = true
+		if ctx.Err() != nil {
+			logger.Error("interrupted; the remaining files were left unchanged")
+			exitStatus = max(exitStatus, exitDiagnostic)
+			break
+		}
+		logger.Debug("formatting", "file", filename)
+		if err := configure(filename); err != nil {
+			fail(filename, err)
+			continue
+		}
+		if reason := limits.checkFile(filename); reason != "" {
+			logger.Warn("skipped: "+reason+"; the file was left unchanged", "file", filename)
+			statuses = append(statuses, fileStatus{Path: filename, Status: statusSkipped, Detail: reason})
+			continue
+		}
+		if isModel(filename) {
+			d, changed, err := formatModel(ctx, sourceOut, f, filename, keep, *showDiff, writeFiles, rewrite)
+			if err != nil {
+				fail(filename, err)
 				continue
 			}
+			switch {
+			case patch && changed:
+				logger.Warn("the callbacks of Simulink models are left out of patches", "file", filename)
+			case printEdits && changed:
+				logger.Warn("the callbacks of Simulink models are left out of the edits", "file", filename)
+			}
+			diffs = append(diffs, d...)
+			statuses = append(statuses, changedStatus(filename, changed))
+			continue
+		}
+
+		var lines []string
+		// app holds the container of an App Designer app.
+		var app []byte
+		// source holds the content of the file, whose line ending the
+		// formatted code keeps with --line-ending=auto.
+		var source []byte
+		if isApp(filename) {
+			lines, app, err = readApp(filename)
+		} else if source, err = readSource(filename); err == nil {
+			lines, err = formatter.ReadLines(bytes.NewReader(source))
+		}
+		if err != nil {
+			fail(filename, err)
+			continue
+		}
+		generated := len(skipped)
+		formatted, err := formatSource(filename, lines)
+		if err != nil {
+			fail(filename, err)
+			continue
+		}
+		if len(skipped) > generated {
+			statuses = append(statuses, fileStatus{Path: filename, Status: statusSkipped, Detail: "generated"})
+		} else {
+			statuses = append(statuses, changedStatus(filename, !slices.Equal(lines, formatted)))
+		}
 
-			// Write to file with same permissions as original
-			info, err := os.Stat(filename)
+		switch {
+		case printEdits && app != nil:
+			if !slices.Equal(lines, formatted) {
+				logger.Warn("left out of the edits: the code of apps is not stored as text", "file", filename)
+			}
+		case printEdits:
+			after := f.JoinLinesLike(formatted, source)
+			if len(skipped) > generated {
+				after = string(source)
+			}
+			edits = append(edits, fileEdits{Path: filename, Edits: diff.TextEdits(string(source), after)})
+		case *showDiff:
+			d := fileDiff{Path: filename, Hunks: diff.Hunks(lines, formatted, diffContext)}
+			switch {
+			case source != nil && filename != "-":
+				d.before, d.after = source, []byte(f.JoinLinesLike(formatted, source))
+			case patch && len(d.Hunks) > 0:
+				logger.Warn("left out of the patch: only source files on disk can be patched", "file", filename)
+			}
+			diffs = append(diffs, d)
+		case writeFiles && app != nil:
+			path, err := writeApp(filename, app, formatted, f.JoinLines, *mlappMode, rewrite)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
-				hasError = true
+				fail(filename, err)
 				continue
 			}
-
-			if err := os.WriteFile(filename, buf.Bytes(), info.Mode()); err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
-				hasError = true
+			if path != filename {
+				extracted = append(extracted, extractedApp{app: filename, code: path})
+			}
+		case writeFiles && filename != "-":
+			if err := rewriteFile(filename, []byte(f.JoinLinesLike(formatted, source)), rewrite); err != nil {
+				fail(filename, err)
 				continue
 			}
-		} else {
-			if err := f.FormatFile(filename, os.Stdout); err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
-				hasError = true
+		default:
+			if _, err := io.WriteString(sourceOut, f.JoinLinesLike(formatted, source)); err != nil {
+				fail(filename, err)
 				continue
 			}
 		}
 	}
 
-	if hasError {
-		os.Exit(1)
+	for _, filename := range skipped {
+		logger.Info("skipped generated file", "file", filename)
+	}
+	for _, e := range extracted {
+		logger.Info("wrote formatted app code to "+e.code, "file", e.app)
+	}
+	for _, filename := range tests {
+		logger.Info("applied function-based test conventions", "file", filename)
+	}
+
+	if *classFolders {
+		if err := reportClassFolders(os.Stderr, filenames); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	if *trace {
+		if err := writeTraces(os.Stderr, traces); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	if *status {
+		// Diffs and edits take stdout.
+		out := io.Writer(os.Stdout)
+		if *showDiff || printEdits {
+			out = os.Stderr
+		}
+		if err := writeStatuses(out, statuses, *porcelain); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	if *showDiff && !*batch {
+		if err := writeDiffs(os.Stdout, *output, diffs); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	if printEdits {
+		if err := writeEdits(os.Stdout, edits); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	if *list {
+		if err := writeChanged(os.Stdout, statuses); err != nil {
+			logger.Error(err.Error())
+			exitStatus = max(exitStatus, errorStatus(err))
+		}
+	}
+
+	for _, s := range statuses {
+		if s.Status != statusChanged || !dryRunning {
+			continue
+		}
+		if *check {
+			logger.Info("would be reformatted", "file", s.Path)
+		}
+		exitStatus = max(exitStatus, exitChanged)
+	}
+	return exitStatus
+}
+
+// formatWithTimeout is formatLines stopping with errTimedOut when it takes
+// longer than timeout, if positive, and with the error of ctx when ctx is
+// done.
+func formatWithTimeout(ctx context.Context, f *formatter.Formatter, lines []string, ranges lineRanges, section string, trace bool, timeout time.Duration, opts ...formatter.CallOption) ([]string, []formatter.LineTrace, error) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	formatted, traced, err := formatLines(ctx, f, lines, ranges, section, trace, opts...)
+	return formatted, traced, formatError(err, timeout)
+}
+
+// formatLines formats the given ranges of lines together with the selected
+// section, or the range of the formatter options when there are neither,
+// returning also the traces of the formatted lines with trace. It stops with
+// the error of ctx when ctx is done.
+func formatLines(ctx context.Context, f *formatter.Formatter, lines []string, ranges lineRanges, section string, trace bool, opts ...formatter.CallOption) ([]string, []formatter.LineTrace, error) {
+	if section != "" {
+		r, err := sectionRange(lines, section)
+		if err != nil {
+			return nil, nil, err
+		}
+		ranges = append(ranges[:len(ranges):len(ranges)], r)
+	}
+	switch {
+	case len(ranges) > 0 && trace:
+		return f.FormatRangesTrace(ctx, lines, ranges, opts...)
+	case len(ranges) > 0:
+		formatted, err := f.FormatRangesContext(ctx, lines, ranges, opts...)
+		return formatted, nil, err
+	case trace:
+		return f.FormatLinesTrace(ctx, lines, opts...)
+	}
+	formatted, err := f.FormatLinesContext(ctx, lines, opts...)
+	return formatted, nil, err
+}
+
+// formatOptionFlags returns the flags setting the options of the formatting
+// rules, which can also be set by the configuration file and environment.
+func formatOptionFlags() []string {
+	var names []string
+	for _, r := range formatter.Rules() {
+		for _, o := range r.Options {
+			names = append(names, kebabCase(o.Name))
+		}
+	}
+	return names
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// joinLines returns the content for lines, terminating every line with a
+// newline.
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
 	}
+	return b.String()
 }
 
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "usage: matlabformatter [options...] <file...>\n")
 	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
-	fmt.Fprintf(os.Stderr, "    -w (default false) - Write result to source file instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "    -w, --write (default false) - Write result to source file instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "    -r, --recursive (default false) - Format the .m files below directory arguments\n")
+	fmt.Fprintf(os.Stderr, "    --gitignore (default false) - With -r, skip files and directories ignored by .gitignore files and .git/info/exclude\n")
+	fmt.Fprintf(os.Stderr, "    --follow-symlinks (default false) - With -r, walk the directories symbolic links point to\n")
+	fmt.Fprintf(os.Stderr, "    -d, --diff (default false) - Print the changes as a diff instead of the formatted source\n")
+	fmt.Fprintf(os.Stderr, "    --output=string (default unified) - Print the changes in this format, as --diff does: unified, json, patch\n")
+	fmt.Fprintf(os.Stderr, "    --edits=string - Print the changes as text edits instead of the formatted source: json\n")
+	fmt.Fprintf(os.Stderr, "    --show-whitespace (default false) - Print the formatted source with visible spaces, tabs and line ends to stderr\n")
+	fmt.Fprintf(os.Stderr, "    --show-whitespace-file=string - Write the --show-whitespace output to this file instead of stderr\n")
+	fmt.Fprintf(os.Stderr, "    --trace (default false) - Print the classification and spacing rules of each line as JSON to stderr\n")
+	fmt.Fprintf(os.Stderr, "    --minimal=string - Apply only changes of the listed classes: indentation, spacing, structural\n")
+	fmt.Fprintf(os.Stderr, "    --class-folders (default false) - Report method files of @ClassName folders that do not match the classdef\n")
+	fmt.Fprintf(os.Stderr, "    --quiet (default false) - Log errors only\n")
+	fmt.Fprintf(os.Stderr, "    --log-level=string (default info) - Minimum level of the messages logged to stderr: debug, info, warn, error\n")
+	fmt.Fprintf(os.Stderr, "    --timeout-per-file=duration (default 0) - Stop formatting a file after this long, such as 5s, leaving it unchanged (0 for no limit)\n")
+	fmt.Fprintf(os.Stderr, "    --max-file-size=int (default 0) - Skip files larger than this many megabytes (0 for no limit)\n")
+	fmt.Fprintf(os.Stderr, "    --max-memory=int (default 0) - Skip files, leaving them unchanged, whose formatting would need more than this many megabytes of memory as estimated from their size; diffs may need more (0 for no limit)\n")
+	fmt.Fprintf(os.Stderr, "    --dry-run (default false) - Format the files without writing or printing them\n")
+	fmt.Fprintf(os.Stderr, "    --check (default false) - Like --dry-run, and name the files that would be reformatted on stderr\n")
+	fmt.Fprintf(os.Stderr, "    -l, --list (default false) - Print the paths of the files whose formatting differs instead of the formatted source\n")
+	fmt.Fprintf(os.Stderr, "    --status (default false) - Print one line per file: changed, unchanged, skipped or error\n")
+	fmt.Fprintf(os.Stderr, "    --porcelain (default false) - Print --status lines in a stable, tab-separated format for scripts\n")
+	fmt.Fprintf(os.Stderr, "    --preserve-mtime (default false) - Keep the modification time of files rewritten by --write\n")
+	fmt.Fprintf(os.Stderr, "    --preserve-creation-time (default false) - Keep the creation time of files rewritten by --write (Windows only)\n")
+	fmt.Fprintf(os.Stderr, "    --input-fd=int (default -1) - Read the source from this file descriptor instead of a file (-1 disables)\n")
+	fmt.Fprintf(os.Stderr, "    --output-fd=int (default -1) - Write the output to this file descriptor instead of stdout (-1 disables)\n")
+	fmt.Fprintf(os.Stderr, "    --output-file=string - Write the output to this file or named pipe instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "    --batch (default false) - Format the files framed on stdin and write the framed results to stdout\n")
+	fmt.Fprintf(os.Stderr, "    --mlapp=string (default extract) - How --write stores the code of .mlapp apps: extract, repack\n")
+	fmt.Fprintf(os.Stderr, "    --format-generated (default false) - Format files marked as generated instead of skipping them\n")
+	fmt.Fprintf(os.Stderr, "    --generated-markers=string (default %s) - Comma-separated phrases marking generated files\n", strings.Join(formatter.DefaultGeneratedMarkers, ","))
 	opts := formatter.DefaultOptions()
-	fmt.Fprintf(os.Stderr, "    --startLine=int (default %d)\n", opts.StartLine)
-	fmt.Fprintf(os.Stderr, "    --endLine=int (default %d)\n", opts.EndLine)
-	fmt.Fprintf(os.Stderr, "    --indentWidth=int (default %d)\n", opts.IndentWidth)
-	fmt.Fprintf(os.Stderr, "    --separateBlocks=bool (default %t)\n", opts.SeparateBlocks)
-	fmt.Fprintf(os.Stderr, "    --indentMode=string (default %s)\n", opts.IndentMode)
-	fmt.Fprintf(os.Stderr, "    --addSpaces=string (default %s)\n", opts.AddSpaces)
-	fmt.Fprintf(os.Stderr, "    --matrixIndent=string (default %s)\n", opts.MatrixIndent)
+	fmt.Fprintf(os.Stderr, "    --start-line=int (default %d)\n", opts.StartLine)
+	fmt.Fprintf(os.Stderr, "    --end-line=int (default %d)\n", opts.EndLine)
+	fmt.Fprintf(os.Stderr, "    --lines=START:END - Line range to format, e.g. 120:+40 or -50:; may be repeated\n")
+	fmt.Fprintf(os.Stderr, "    --section=string - Format only the %%%% section with this 1-based index or title\n")
+	fmt.Fprintf(os.Stderr, "    --indent-width=int (default %d)\n", opts.IndentWidth)
+	fmt.Fprintf(os.Stderr, "    --separate-blocks=bool (default %t)\n", opts.SeparateBlocks)
+	fmt.Fprintf(os.Stderr, "    --separated-blocks=string (default %s) - Comma-separated kinds of blocks separated by --separate-blocks: all, function, classdef, if, loop, switch, try, spmd, arguments\n", opts.SeparatedBlocks)
+	fmt.Fprintf(os.Stderr, "    --max-blank-lines=int (default %d) - Maximum number of consecutive blank lines (0 removes blank lines)\n", opts.MaxBlankLines)
+	fmt.Fprintf(os.Stderr, "    --function-blank-lines=int (default %d) - Number of blank lines before each function that is not nested (0 keeps them)\n", opts.FunctionBlankLines)
+	fmt.Fprintf(os.Stderr, "    --nested-function-blank-lines=int (default %d) - Number of blank lines before each nested function (0 keeps them)\n", opts.NestedFunctionBlankLines)
+	fmt.Fprintf(os.Stderr, "    --indent-mode=string (default %s)\n", opts.IndentMode)
+	fmt.Fprintf(os.Stderr, "    --nested-indent-width=int (default %d) - Number of spaces to indent nested function bodies (0 uses --indent-width)\n", opts.NestedIndentWidth)
+	fmt.Fprintf(os.Stderr, "    --classdef-indent=string (default %s) - Classdef indentation: all, blocks, classdef\n", opts.ClassdefIndent)
+	fmt.Fprintf(os.Stderr, "    --add-spaces=string (default %s)\n", opts.AddSpaces)
+	fmt.Fprintf(os.Stderr, "    --spaced-operators=string - Comma-separated classes of operators written with spaces, replacing --add-spaces: assignment, relational, arithmetic, elementwise, power, logical, none\n")
+	fmt.Fprintf(os.Stderr, "    --space-short-circuit=bool (default %t) - Write spaces around && and || whatever the operator spacing\n", opts.SpaceShortCircuit)
+	fmt.Fprintf(os.Stderr, "    --colon-spacing=string (default %s) - Spacing around the colons of ranges such as 1:0.1:10: tight, spaced, keep\n", opts.ColonSpacing)
+	fmt.Fprintf(os.Stderr, "    --unary-signs=string (default %s) - Where a + or - with whitespace only before it is a sign: context for inside matrices and cell arrays, whitespace for everywhere\n", opts.UnarySigns)
+	fmt.Fprintf(os.Stderr, "    --space-after-comma=bool (default %t) - Write a space after commas and semicolons, independently of --add-spaces\n", opts.SpaceAfterComma)
+	fmt.Fprintf(os.Stderr, "    --space-in-parens=bool (default %t) - Write a space inside parentheses, as in ( x + 1 )\n", opts.SpaceInParens)
+	fmt.Fprintf(os.Stderr, "    --space-in-brackets=bool (default %t) - Write a space inside square brackets, as in [ 1, 2 ]\n", opts.SpaceInBrackets)
+	fmt.Fprintf(os.Stderr, "    --space-in-braces=bool (default %t) - Write a space inside braces, as in { 'a', 'b' }\n", opts.SpaceInBraces)
+	fmt.Fprintf(os.Stderr, "    --matrix-indent=string (default %s)\n", opts.MatrixIndent)
+	fmt.Fprintf(os.Stderr, "    --align-matrix-columns=bool (default %t) - Align the elements of the rows of multi-line matrices in columns, right-aligning numbers\n", opts.AlignMatrixColumns)
+	fmt.Fprintf(os.Stderr, "    --matrix-separator=string (default %s) - Separator between matrix elements: comma, space, keep\n", opts.MatrixSeparator)
+	fmt.Fprintf(os.Stderr, "    --trim-matrix-separators=bool (default %t) - Remove separators before the closing bracket of a matrix\n", opts.TrimMatrixSeparators)
+	fmt.Fprintf(os.Stderr, "    --normalize-ends=bool (default %t) - Write endif, endfor, endwhile, endswitch, endfunction and end_try_catch as end and remove the semicolon of end;\n", opts.NormalizeEnds)
+	fmt.Fprintf(os.Stderr, "    --normalize-numbers=bool (default %t) - Write numeric literals with a leading zero and without a bare decimal point\n", opts.NormalizeNumbers)
+	fmt.Fprintf(os.Stderr, "    --trim-number-zeros=bool (default %t) - Remove trailing zeros from the fractions of numeric literals\n", opts.TrimNumberZeros)
+	fmt.Fprintf(os.Stderr, "    --exponent-case=string (default %s) - Exponent marker of numeric literals: lower, upper, keep\n", opts.ExponentCase)
+	fmt.Fprintf(os.Stderr, "    --trim-exponents=bool (default %t) - Remove plus signs and leading zeros from the exponents of numeric literals\n", opts.TrimExponents)
+	fmt.Fprintf(os.Stderr, "    --complex-spacing=string (default %s) - Spacing around the operator of complex literals such as 3+4i: tight, spaced, keep\n", opts.ComplexSpacing)
+	fmt.Fprintf(os.Stderr, "    --tab-width=int (default %d)\n", opts.TabWidth)
+	fmt.Fprintf(os.Stderr, "    --indent-style=string (default %s) - Characters of indentation: space, tab\n", opts.IndentStyle)
+	fmt.Fprintf(os.Stderr, "    --detect-indentation=bool (default %t) - Keep the indentation width and style detected in each file\n", opts.DetectIndentation)
+	fmt.Fprintf(os.Stderr, "    --line-ending=string (default %s) - Line ending of the formatted source: auto, lf, crlf, cr\n", opts.LineEnding)
+	fmt.Fprintf(os.Stderr, "    --final-newline=bool (default %t) - End the formatted source with a line ending\n", opts.FinalNewline)
+	fmt.Fprintf(os.Stderr, "    --keep-trailing-lines=bool (default %t) - Keep the trailing blank lines of each file and whether it ends with a line ending\n", opts.KeepTrailingLines)
+	fmt.Fprintf(os.Stderr, "    --sort-imports=bool (default %t)\n", opts.SortImports)
+	fmt.Fprintf(os.Stderr, "    --group-imports=bool (default %t) - Sort imports and separate them by top-level package with blank lines\n", opts.GroupImports)
+	fmt.Fprintf(os.Stderr, "    --qualify-imports=bool (default %t) - Rewrite imports relative to the +package of the file into fully-qualified form\n", opts.QualifyImports)
+	fmt.Fprintf(os.Stderr, "    --test-fixtures-first=bool (default %t) - Move the shared fixtures of function-based test files after the main function\n", opts.TestFixturesFirst)
+	fmt.Fprintf(os.Stderr, "    --test-function-spacing=bool (default %t) - Separate the functions of function-based test files by one blank line\n", opts.TestFunctionSpacing)
+	fmt.Fprintf(os.Stderr, "    --continuation-indent=int (default %d) - Number of spaces to indent continuation lines (0 uses --indent-width)\n", opts.ContinuationIndent)
+	fmt.Fprintf(os.Stderr, "    --continuation-style=string (default %s) - Indentation of continuation lines: indent, aligned\n", opts.ContinuationStyle)
+	fmt.Fprintf(os.Stderr, "    --align-assignments=bool (default %t) - Align the = of consecutive lines holding one assignment each\n", opts.AlignAssignments)
+	fmt.Fprintf(os.Stderr, "    --comment-column=int (default %d) - Column in which trailing comments start (0 keeps one space before them)\n", opts.CommentColumn)
+	fmt.Fprintf(os.Stderr, "    --max-line-length=int (default %d) - Maximum number of columns of a line (0 for no limit)\n", opts.MaxLineLength)
+	fmt.Fprintf(os.Stderr, "    --join-continuations=bool (default %t) - Join the lines of statements continued with ... that fit within --max-line-length\n", opts.JoinContinuations)
+	fmt.Fprintf(os.Stderr, "    --dialect=string (default %s) - Language of the code, deciding whether # starts comments: matlab, octave, auto\n", opts.Dialect)
+	fmt.Fprintf(os.Stderr, "    --strict-blocks=bool (default %t) - Fail on files whose blocks do not match their ends instead of formatting them\n", opts.StrictBlocks)
+	fmt.Fprintf(os.Stderr, "    --only=string - Apply only one kind of formatting: indent, spacing\n")
+	fmt.Fprintf(os.Stderr, "    --config=string - Configuration file setting formatting options\n")
+	fmt.Fprintf(os.Stderr, "    --profile=string - Profile of the configuration file to apply\n")
+	fmt.Fprintf(os.Stderr, "    --explain-config (default false) - Print the effective options and where they were set as JSON, then exit\n")
+	fmt.Fprintf(os.Stderr, "    --editorconfig=bool (default true) - Read indentation and line ending settings from .editorconfig files\n")
+	fmt.Fprintf(os.Stderr, "  The camelCase spellings of earlier releases, such as --indentWidth, are deprecated aliases.\n")
 }
 
 func parseFilenames(fs *flag.FlagSet, args []string) ([]string, error) {
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// largeSource returns a file of n assignments, each formatted differently
+// from how it is written.
+func largeSource(n int) string {
+	return strings.Repeat("x=1;\n", n)
+}
+
+func TestRunFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		// files holds the contents of the files created in a temporary
+		// directory before the run.
+		files map[string]string
+		env   map[string]string
+		// args are the arguments of the run; those not starting with "-"
+		// name files of the temporary directory.
+		args   []string
+		status int
+		// want holds the contents of the files after the run, and mode the
+		// permissions of those that are checked.
+		want map[string]string
+		mode map[string]fs.FileMode
+	}{
+		{
+			name:   "check unchanged",
+			files:  map[string]string{"a.m": "x = 1;\n"},
+			args:   []string{"--check", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "x = 1;\n"},
+		},
+		{
+			name:   "check changed",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--check", "a.m"},
+			status: exitChanged,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "write keeps mode and line endings",
+			files:  map[string]string{"a.m": "if x\r\ny=1;\r\nend\r\n"},
+			args:   []string{"--write", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "if x\r\n    y = 1;\r\nend\r\n"},
+			mode:   map[string]fs.FileMode{"a.m": 0o640},
+		},
+		{
+			name:   "invalid option value",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--write", "--indent-mode=bogus", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "lines with start line",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--write", "--lines=1:1", "--start-line=1", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "invalid environment variable",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			env:    map[string]string{"MATLABFORMATTER_INDENT_WIDTH": "two"},
+			args:   []string{"--write", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "output descriptor and file",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--output-fd=1", "--output-file=out.m", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "invalid output format",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--check", "--output=checkstyle", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "strict blocks",
+			files:  map[string]string{"a.m": "if x\ny=1;\n", "b.m": "z=2;\n"},
+			args:   []string{"--write", "--strict-blocks", "a.m", "b.m"},
+			status: exitDiagnostic,
+			want:   map[string]string{"a.m": "if x\ny=1;\n", "b.m": "z = 2;\n"},
+		},
+		{
+			name:   "missing file",
+			files:  map[string]string{"b.m": "z=2;\n"},
+			args:   []string{"--write", "a.m", "b.m"},
+			status: exitIO,
+			want:   map[string]string{"b.m": "z = 2;\n"},
+		},
+		{
+			name:   "invalid configuration file",
+			files:  map[string]string{".matlabformatter.toml": "[format\n", "a.m": "x=1;\n"},
+			args:   []string{"--write", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+		{
+			name:   "section by index",
+			files:  map[string]string{"a.m": "%% One\nx=1;\n%% Two\ny=2;\n"},
+			args:   []string{"--write", "--section=2", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "%% One\nx=1;\n%% Two\ny = 2;\n"},
+		},
+		{
+			name:   "section by title",
+			files:  map[string]string{"a.m": "%% One\nx=1;\n%% Two\ny=2;\n"},
+			args:   []string{"--write", "--section=one", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "%% One\nx = 1;\n%% Two\ny=2;\n"},
+		},
+		{
+			name:   "missing section",
+			files:  map[string]string{"a.m": "%% One\nx=1;\n"},
+			args:   []string{"--write", "--section=3", "a.m"},
+			status: exitDiagnostic,
+			want:   map[string]string{"a.m": "%% One\nx=1;\n"},
+		},
+		{
+			name:   "configuration file",
+			files:  map[string]string{".matlabformatter.toml": "[format]\nindentWidth = 3\n", "a.m": "if x\ny=1;\nend\n"},
+			args:   []string{"--write", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "if x\n   y = 1;\nend\n"},
+		},
+		{
+			name:   "environment over configuration file",
+			files:  map[string]string{".matlabformatter.toml": "[format]\nindentWidth = 3\n", "a.m": "if x\ny=1;\nend\n"},
+			env:    map[string]string{"MATLABFORMATTER_INDENT_WIDTH": "2"},
+			args:   []string{"--write", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "if x\n  y = 1;\nend\n"},
+		},
+		{
+			name:   "flag over environment",
+			files:  map[string]string{".matlabformatter.toml": "[format]\nindentWidth = 3\n", "a.m": "if x\ny=1;\nend\n"},
+			env:    map[string]string{"MATLABFORMATTER_INDENT_WIDTH": "2"},
+			args:   []string{"--write", "--indent-width=8", "a.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "if x\n        y = 1;\nend\n"},
+		},
+		{
+			name:   "nearest configuration file",
+			files:  map[string]string{".matlabformatter.toml": "[format]\nindentWidth = 3\n", "sub/.matlabformatter.toml": "[format]\nindentWidth = 2\n", "a.m": "if x\ny=1;\nend\n", "sub/b.m": "if x\ny=1;\nend\n"},
+			args:   []string{"--write", "a.m", "sub/b.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": "if x\n   y = 1;\nend\n", "sub/b.m": "if x\n  y = 1;\nend\n"},
+		},
+		{
+			name:   "timeout",
+			files:  map[string]string{"a.m": largeSource(50000)},
+			args:   []string{"--write", "--timeout-per-file=1ns", "a.m"},
+			status: exitDiagnostic,
+			want:   map[string]string{"a.m": largeSource(50000)},
+		},
+		{
+			name:   "file size limit",
+			files:  map[string]string{"a.m": largeSource(300000), "b.m": "z=2;\n"},
+			args:   []string{"--write", "--max-file-size=1", "a.m", "b.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": largeSource(300000), "b.m": "z = 2;\n"},
+		},
+		{
+			name:   "memory limit",
+			files:  map[string]string{"a.m": largeSource(20000), "b.m": "z=2;\n"},
+			args:   []string{"--write", "--max-memory=1", "a.m", "b.m"},
+			status: exitOK,
+			want:   map[string]string{"a.m": largeSource(20000), "b.m": "z = 2;\n"},
+		},
+		{
+			name:   "negative limit",
+			files:  map[string]string{"a.m": "x=1;\n"},
+			args:   []string{"--write", "--max-memory=-1", "a.m"},
+			status: exitUsage,
+			want:   map[string]string{"a.m": "x=1;\n"},
+		},
+	}
+	// --max-memory also sets the memory limit of the runtime.
+	t.Cleanup(func() { debug.SetMemoryLimit(math.MaxInt64) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				mode := tt.mode[name]
+				if mode == 0 {
+					mode = 0o644
+				}
+				if err := os.WriteFile(path, []byte(content), mode); err != nil {
+					t.Fatal(err)
+				}
+				// The permissions are not subject to the umask.
+				if err := os.Chmod(path, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				if !strings.HasPrefix(arg, "-") {
+					arg = filepath.Join(dir, arg)
+				}
+				args[i] = arg
+			}
+
+			if status := runFormat(args); status != tt.status {
+				t.Errorf("got status %d, want %d", status, tt.status)
+			}
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: got %q, want %q", name, abbreviate(string(got)), abbreviate(want))
+				}
+			}
+			for name, want := range tt.mode {
+				info, err := os.Stat(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := info.Mode().Perm(); got != want {
+					t.Errorf("%s: got mode %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// abbreviate shortens the large sources in test failures.
+func abbreviate(s string) string {
+	if len(s) > 80 {
+		return s[:80] + "..."
+	}
+	return s
+}
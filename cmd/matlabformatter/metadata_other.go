@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// preserveOwner does nothing on platforms without Unix ownership.
+func preserveOwner(f *os.File, info fs.FileInfo) {}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/koyashimano/matlab-formatter/internal/metrics"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+func runMetrics(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter metrics", flag.ExitOnError)
+	format := fs.String("format", "json", "Output format: json, csv")
+	addAlias(fs, "output", "format", false)
+
+	filenames, err := parseFilenames(fs, args)
+	if err != nil {
+		if errors.Is(err, errMissingFilename) {
+			printMetricsUsage()
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
+	}
+	if *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "invalid format %q (valid values: json, csv)\n", *format)
+		return exitUsage
+	}
+
+	status := exitOK
+	results := []metrics.File{}
+	for _, filename := range filenames {
+		lines, err := readFileLines(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			status = exitIO
+			continue
+		}
+		results = append(results, metrics.Compute(filename, lines))
+	}
+
+	if *format == "csv" {
+		err = writeMetricsCSV(os.Stdout, results)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return status
+}
+
+// writeMetricsCSV writes one row per file followed by one row per function.
+func writeMetricsCSV(w io.Writer, results []metrics.File) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "path", "name", "start_line", "end_line", "lines", "sloc", "comment_lines", "comment_ratio", "functions", "inputs", "outputs"})
+
+	ratio := func(r float64) string { return strconv.FormatFloat(r, 'f', 3, 64) }
+	for _, m := range results {
+		cw.Write([]string{
+			"file", m.Path, "", "1", strconv.Itoa(m.Lines), strconv.Itoa(m.Lines),
+			strconv.Itoa(m.SLOC), strconv.Itoa(m.CommentLines), ratio(m.CommentRatio),
+			strconv.Itoa(len(m.Functions)), "", "",
+		})
+		for _, fn := range m.Functions {
+			cw.Write([]string{
+				"function", m.Path, fn.Name, strconv.Itoa(fn.StartLine), strconv.Itoa(fn.EndLine), strconv.Itoa(fn.Lines),
+				strconv.Itoa(fn.SLOC), strconv.Itoa(fn.CommentLines), ratio(fn.CommentRatio),
+				"", strconv.Itoa(fn.Inputs), strconv.Itoa(fn.Outputs),
+			})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// readSource returns the content of the file at filename, or of stdin for
+// "-".
+func readSource(filename string) ([]byte, error) {
+	if filename == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(filename)
+}
+
+// readFileLines reads the lines of a file, or of stdin for "-".
+func readFileLines(filename string) ([]string, error) {
+	if filename == "-" {
+		return formatter.ReadLines(os.Stdin)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return formatter.ReadLines(file)
+}
+
+func printMetricsUsage() {
+	fmt.Fprintf(os.Stderr, "usage: matlabformatter metrics [options...] <file...>\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --format=string (default json) - Output format: json, csv\n")
+	fmt.Fprintf(os.Stderr, "    --output=string - Alias of --format\n")
+}
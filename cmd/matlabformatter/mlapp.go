@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/mlapp"
+)
+
+// appExtension is the extension of App Designer apps, whose class code is
+// formatted instead of the container.
+const appExtension = ".mlapp"
+
+// mlappModes lists the accepted values of --mlapp.
+var mlappModes = map[string]bool{
+	"extract": true,
+	"repack":  true,
+}
+
+// extractedApp is an app whose formatted code was written to the file code.
+type extractedApp struct {
+	app, code string
+}
+
+func isApp(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), appExtension)
+}
+
+// readApp returns the class code of the app at filename together with the
+// contents of the container.
+func readApp(filename string) ([]string, []byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines, err := mlapp.Extract(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lines, data, nil
+}
+
+// writeApp writes the formatted class code of the app at filename, whose
+// container holds data. In repack mode the app itself is rewritten when the
+// code can be replaced safely; otherwise, and in extract mode, the code is
+// joined by join and written to filename+".m" next to the app for review. It
+// returns the path written.
+func writeApp(filename string, data []byte, formatted []string, join func([]string) string, mode string, rewrite rewriteOptions) (string, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+	if mode == "repack" {
+		repacked, err := mlapp.Repack(data, formatted)
+		switch {
+		case err == nil:
+			return filename, rewriteFile(filename, repacked, rewrite)
+		case !errors.Is(err, mlapp.ErrUnsafe):
+			return "", err
+		}
+	}
+	path := filename + sourceExtension
+	return path, os.WriteFile(path, []byte(join(formatted)), info.Mode())
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/koyashimano/matlab-formatter/internal/config"
+	"github.com/koyashimano/matlab-formatter/internal/editorconfig"
+)
+
+// envPrefix starts the names of the environment variables setting options.
+const envPrefix = "MATLABFORMATTER_"
+
+// optionSource describes where the value of an option came from.
+type optionSource struct {
+	// Kind is one of default, flag, env, config, editorconfig and, in the
+	// language server, editor.
+	Kind string `json:"kind"`
+	// Name is the flag or environment variable that set the option.
+	Name string `json:"name,omitempty"`
+	// Path and Line locate the setting of a configuration or .editorconfig
+	// file.
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// optionSources records where the flags not left at their default got their
+// value from. Values are applied in order of precedence: command line,
+// environment, configuration file, .editorconfig files; a flag is only set by
+// the first.
+type optionSources map[string]optionSource
+
+// commandLineSources returns the sources of the flags given on the command
+// line.
+func commandLineSources(fs *flag.FlagSet) optionSources {
+	sources := make(optionSources)
+	fs.Visit(func(f *flag.Flag) {
+		sources[canonicalFlag(fs, f.Name)] = optionSource{Kind: "flag", Name: "--" + f.Name}
+	})
+	return sources
+}
+
+// envName returns the environment variable setting the named flag, for
+// example MATLABFORMATTER_INDENT_WIDTH for indentWidth or tab-width.
+func envName(flagName string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range flagName {
+		switch {
+		case r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// applyEnv sets the named flags from their environment variables.
+func (s optionSources) applyEnv(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if _, ok := s[name]; ok {
+			continue
+		}
+		env := envName(name)
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("%s: invalid value %q: %v", env, v, err)
+		}
+		s[name] = optionSource{Kind: "env", Name: env}
+	}
+	return nil
+}
+
+// applyFormatConfig sets the flags of the formatting options of cfg, which are
+// the kebab-case spellings of the option names.
+func (s optionSources) applyFormatConfig(fs *flag.FlagSet, cfg *config.Config) error {
+	names := make([]string, 0, len(cfg.Format))
+	for name := range cfg.Format {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flagName := kebabCase(name)
+		if _, ok := s[flagName]; ok {
+			continue
+		}
+		line := cfg.Line("format." + name)
+		if err := fs.Set(flagName, cfg.Format[name]); err != nil {
+			return fmt.Errorf("%s:%d: format.%s: invalid value %q: %v", cfg.Path, line, name, cfg.Format[name], err)
+		}
+		s[flagName] = optionSource{Kind: "config", Path: cfg.Path, Line: line}
+	}
+	return nil
+}
+
+// editorconfigFlags maps the EditorConfig properties read by the formatter to
+// the flags they set, with the values each accepts.
+var editorconfigFlags = []struct {
+	property, flag string
+	values         []string
+}{
+	{"indent_style", "indent-style", []string{"space", "tab"}},
+	{"indent_size", "indent-width", nil},
+	{"end_of_line", "line-ending", []string{"lf", "crlf", "cr"}},
+	{"insert_final_newline", "final-newline", []string{"true", "false"}},
+}
+
+// lookupEditorconfig returns the EditorConfig properties read by the
+// formatter that apply to filename. Stdin has none.
+func lookupEditorconfig(filename string) (map[string]editorconfig.Property, error) {
+	if filename == "-" {
+		return nil, nil
+	}
+	all, err := editorconfig.Lookup(filename)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]editorconfig.Property)
+	for _, e := range editorconfigFlags {
+		if p, ok := all[e.property]; ok {
+			props[e.property] = p
+		}
+	}
+	// An indent_size of tab uses the width of tabs.
+	if p, ok := props["indent_size"]; ok && p.Value == "tab" {
+		if tw, ok := all["tab_width"]; ok {
+			props["indent_size"] = tw
+		} else {
+			delete(props, "indent_size")
+		}
+	}
+	return props, nil
+}
+
+// applyEditorconfig sets the flags of the EditorConfig properties in props.
+// Values the formatter does not support are ignored with a warning, as
+// editors ignore them.
+func (s optionSources) applyEditorconfig(fs *flag.FlagSet, props map[string]editorconfig.Property) {
+	for _, e := range editorconfigFlags {
+		p, ok := props[e.property]
+		if !ok {
+			continue
+		}
+		if _, ok := s[e.flag]; ok {
+			continue
+		}
+		var valid bool
+		if e.values == nil {
+			n, err := strconv.Atoi(p.Value)
+			valid = err == nil && n > 0
+		} else {
+			valid = slices.Contains(e.values, p.Value)
+		}
+		if !valid {
+			logger.Warn(fmt.Sprintf("%s:%d: ignoring unsupported %s %q", p.Path, p.Line, e.property, p.Value))
+			continue
+		}
+		fs.Set(e.flag, p.Value)
+		s[e.flag] = optionSource{Kind: "editorconfig", Path: p.Path, Line: p.Line}
+	}
+}
+
+// forFile returns a copy of s extended by the settings of the configuration
+// file at path, if any, with profile selected, and by the .editorconfig
+// settings props. The flags of the formatting options set by neither are
+// reset to their defaults first.
+func (s optionSources) forFile(fs *flag.FlagSet, path, profile string, props map[string]editorconfig.Property) (optionSources, error) {
+	cfg, err := loadConfig(path, profile)
+	if err != nil {
+		return nil, err
+	}
+	s = maps.Clone(s)
+	s.resetFormatFlags(fs)
+	if cfg != nil {
+		logger.Debug("loaded configuration", "path", cfg.Path)
+		if err := s.applyFormatConfig(fs, cfg); err != nil {
+			return nil, err
+		}
+	}
+	s.applyEditorconfig(fs, props)
+	return s, nil
+}
+
+// resetFormatFlags sets the flags of the formatting options not set by s
+// back to their defaults, undoing the settings of an earlier configuration
+// file.
+func (s optionSources) resetFormatFlags(fs *flag.FlagSet) {
+	for _, name := range formatOptionFlags() {
+		if _, ok := s[name]; !ok {
+			f := fs.Lookup(name)
+			f.Value.Set(f.DefValue)
+		}
+	}
+}
+
+// configFinder finds the configuration files of the formatted files when no
+// --config is given, caching them by directory.
+type configFinder map[string]string
+
+// find returns the path of the configuration file applying to filename, or ""
+// when there is none. Stdin uses the working directory.
+func (c configFinder) find(filename string) (string, error) {
+	dir := "."
+	if filename != "-" {
+		dir = filepath.Dir(filename)
+	}
+	if path, ok := c[dir]; ok {
+		return path, nil
+	}
+	path, err := config.Find(dir)
+	if err != nil {
+		return "", err
+	}
+	c[dir] = path
+	return path, nil
+}
+
+// explainOptions writes the effective values of the named flags together
+// with their sources as JSON.
+func (s optionSources) explainOptions(w io.Writer, fs *flag.FlagSet, names ...string) error {
+	type option struct {
+		Name   string       `json:"name"`
+		Value  string       `json:"value"`
+		Source optionSource `json:"source"`
+	}
+	options := make([]option, 0, len(names))
+	for _, name := range names {
+		source, ok := s[name]
+		if !ok {
+			source = optionSource{Kind: "default"}
+		}
+		options = append(options, option{Name: name, Value: fs.Lookup(name).Value.String(), Source: source})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(options)
+}
+
+// loadConfig reads the configuration file at path and selects profile, if
+// set. It returns nil when neither is given.
+func loadConfig(path, profile string) (*config.Config, error) {
+	if path == "" {
+		if profile != "" {
+			return nil, errors.New("--profile requires --config or a " + config.FileName + " file")
+		}
+		return nil, nil
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		return cfg.WithProfile(profile)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+)
+
+// fileFindings groups the findings reported for one file.
+type fileFindings struct {
+	Path     string
+	Findings []lint.Finding
+}
+
+// writeFindings writes findings in the requested output format.
+func writeFindings(w io.Writer, format string, results []fileFindings) error {
+	if format == "checkstyle" {
+		return writeCheckstyle(w, results)
+	}
+
+	for _, r := range results {
+		for _, f := range r.Findings {
+			if _, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s: %s\n", r.Path, f.Line, f.Column, f.Severity, f.Rule, f.Message); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// writeCheckstyle writes findings as Checkstyle XML, which CI servers such as
+// Jenkins and GitLab can display without a custom converter. Every checked
+// file is listed, including files without findings.
+func writeCheckstyle(w io.Writer, results []fileFindings) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, r := range results {
+		file := checkstyleFile{Name: r.Path}
+		for _, f := range r.Findings {
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     f.Line,
+				Column:   f.Column,
+				Severity: f.Severity.String(),
+				Message:  f.Message,
+				Source:   "matlabformatter." + f.Rule,
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
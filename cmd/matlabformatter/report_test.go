@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+)
+
+func TestWriteCheckstyle(t *testing.T) {
+	results := []fileFindings{
+		{
+			Path: "src/a&b.m",
+			Findings: []lint.Finding{
+				{Line: 1, Column: 5, Rule: "unbalanced-block", Severity: lint.SeverityError, Message: `"if" without end <here>`},
+				{Line: 3, Column: 1, Rule: "else-if", Severity: lint.SeverityWarning, Message: "use 'elseif' & save a block"},
+				{Line: 7, Column: 12, Rule: "trailing-whitespace", Severity: lint.SeverityInfo, Message: "trailing whitespace"},
+			},
+		},
+		{Path: "src/clean.m"},
+	}
+	var out strings.Builder
+	if err := writeFindings(&out, "checkstyle", results); err != nil {
+		t.Fatalf("writeFindings: %v", err)
+	}
+
+	// Files without findings are listed too.
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="src/a&amp;b.m">
+    <error line="1" column="5" severity="error" message="&#34;if&#34; without end &lt;here&gt;" source="matlabformatter.unbalanced-block"></error>
+    <error line="3" column="1" severity="warning" message="use &#39;elseif&#39; &amp; save a block" source="matlabformatter.else-if"></error>
+    <error line="7" column="12" severity="info" message="trailing whitespace" source="matlabformatter.trailing-whitespace"></error>
+  </file>
+  <file name="src/clean.m"></file>
+</checkstyle>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("checkstyle mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// ruleInfo is the listing entry of a formatting or lint rule. Severity is
+// only set for lint rules.
+type ruleInfo struct {
+	ID          string       `json:"id"`
+	Kind        string       `json:"kind"`
+	Description string       `json:"description"`
+	Fixable     bool         `json:"fixable"`
+	Severity    string       `json:"severity,omitempty"`
+	Options     []ruleOption `json:"options"`
+}
+
+// ruleOption is a rule option together with the command-line flag setting
+// it, if any, and the flag's deprecated aliases.
+type ruleOption struct {
+	formatter.RuleOption
+	Flag    string   `json:"flag,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// ruleOptions adds the flags to options. Options of configuration file
+// sections, such as header.template, have no flag.
+func ruleOptions(options []formatter.RuleOption) []ruleOption {
+	result := make([]ruleOption, len(options))
+	for i, o := range options {
+		result[i].RuleOption = o
+		if strings.Contains(o.Name, ".") {
+			continue
+		}
+		name := kebabCase(o.Name)
+		result[i].Flag = "--" + name
+		for _, alias := range flagAliases(name) {
+			result[i].Aliases = append(result[i].Aliases, "--"+alias)
+		}
+	}
+	return result
+}
+
+func runRules(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter rules", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the rules as JSON")
+	fs.Usage = printRulesUsage
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		printRulesUsage()
+		return exitUsage
+	}
+
+	if err := writeRules(os.Stdout, allRules(), *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// allRules lists the formatting rules followed by the lint rules.
+func allRules() []ruleInfo {
+	var rules []ruleInfo
+	for _, r := range formatter.Rules() {
+		rules = append(rules, ruleInfo{
+			ID:          r.ID,
+			Kind:        "format",
+			Description: r.Description,
+			Fixable:     true,
+			Options:     ruleOptions(r.Options),
+		})
+	}
+	for _, r := range lint.Rules() {
+		rules = append(rules, ruleInfo{
+			ID:          r.ID,
+			Kind:        "lint",
+			Description: r.Description,
+			Fixable:     r.Fixable,
+			Severity:    r.Severity.String(),
+			Options:     ruleOptions(r.Options),
+		})
+	}
+	rules = append(rules, ruleInfo{
+		ID:          lint.UnusedSuppression,
+		Kind:        "lint",
+		Description: "Suppression comment that silences nothing",
+		Severity:    lint.SeverityWarning.String(),
+	}, ruleInfo{
+		ID:          lint.ClassFolderRule,
+		Kind:        "lint",
+		Description: classFolderDescription,
+		Severity:    lint.SeverityWarning.String(),
+	})
+
+	for i := range rules {
+		if rules[i].Options == nil {
+			rules[i].Options = []ruleOption{}
+		}
+	}
+	return rules
+}
+
+func writeRules(w io.Writer, rules []ruleInfo, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rules)
+	}
+
+	for _, r := range rules {
+		attrs := r.Kind
+		if r.Severity != "" {
+			attrs += ", " + r.Severity
+		}
+		if r.Fixable {
+			attrs += ", fixable"
+		}
+		if _, err := fmt.Fprintf(w, "%s (%s) - %s\n", r.ID, attrs, r.Description); err != nil {
+			return err
+		}
+		for _, o := range r.Options {
+			name := o.Name
+			if o.Flag != "" {
+				name = o.Flag
+			}
+			if _, err := fmt.Fprintf(w, "    %s=%s (default %v)\n", name, o.Type, o.Default); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func printRulesUsage() {
+	fmt.Fprintf(os.Stderr, "usage: matlabformatter rules [options...]\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --json (default false) - Print the rules as JSON\n")
+}
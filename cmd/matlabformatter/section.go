@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// sectionRange returns the lines of the "%%" section selected by its 1-based
+// index or, for other selectors, by its title compared case-insensitively.
+// The range includes the section's "%%" line.
+func sectionRange(lines []string, selector string) (formatter.LineRange, error) {
+	sections := syntax.Parse(lines).Sections
+	if i, err := strconv.Atoi(selector); err == nil {
+		if i < 1 || i > len(sections) {
+			return formatter.LineRange{}, fmt.Errorf("no section %d (the file has %d sections)", i, len(sections))
+		}
+		s := sections[i-1]
+		return formatter.NewLineRange(s.Start.Line, s.End), nil
+	}
+	for _, s := range sections {
+		if strings.EqualFold(s.Title, strings.TrimSpace(selector)) {
+			return formatter.NewLineRange(s.Start.Line, s.End), nil
+		}
+	}
+	return formatter.LineRange{}, fmt.Errorf("no section titled %q", selector)
+}
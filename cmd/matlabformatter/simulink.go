@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+	"github.com/koyashimano/matlab-formatter/internal/simulink"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// isModel reports whether filename is a Simulink model, whose callbacks are
+// formatted instead of the file.
+func isModel(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".slx" || ext == ".mdl"
+}
+
+// formatModel formats each callback of the Simulink model at filename as a
+// file of its own, named filename:Name such as model.slx:Gain1/OpenFcn, and
+// keeps the changes of the classes in keep, or all changes when keep is nil.
+// With showDiff it returns the diffs of the callbacks; otherwise, with write,
+// it writes the callbacks back into the model, keeping the metadata selected
+// by rewrite, or prints the formatted callbacks to w, each after a comment
+// line naming it. It reports whether any callback changed, and stops with
+// the error of ctx when ctx is done.
+func formatModel(ctx context.Context, w io.Writer, f *formatter.Formatter, filename string, keep map[diff.Class]bool, showDiff, write bool, rewrite rewriteOptions) ([]fileDiff, bool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	model, err := simulink.Read(filename, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var diffs []fileDiff
+	changed := false
+	for i := range model.Callbacks {
+		c := &model.Callbacks[i]
+		name := filename + ":" + c.Name
+		formatted, err := f.FormatLinesContext(ctx, c.Lines)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if keep != nil {
+			formatted = diff.Apply(c.Lines, formatted, func(ch diff.Change) bool { return keep[ch.Class] })
+		}
+		switch {
+		case showDiff:
+			diffs = append(diffs, fileDiff{Path: name, Hunks: diff.Hunks(c.Lines, formatted, diffContext)})
+		case !write:
+			if _, err := io.WriteString(w, "% "+name+"\n"+joinLines(formatted)); err != nil {
+				return nil, false, err
+			}
+		}
+		changed = changed || !slices.Equal(c.Lines, formatted)
+		c.Lines = formatted
+	}
+
+	if !write || showDiff || !changed {
+		return diffs, changed, nil
+	}
+	out, err := model.Bytes()
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, changed, rewriteFile(filename, out, rewrite)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// File statuses reported by --status.
+const (
+	statusChanged   = "changed"
+	statusUnchanged = "unchanged"
+	statusSkipped   = "skipped"
+	statusError     = "error"
+)
+
+// fileStatus is the outcome of formatting one file. Detail gives the reason
+// a file was skipped or the error message.
+type fileStatus struct {
+	Path   string
+	Status string
+	Detail string
+}
+
+func changedStatus(path string, changed bool) fileStatus {
+	if changed {
+		return fileStatus{Path: path, Status: statusChanged}
+	}
+	return fileStatus{Path: path, Status: statusUnchanged}
+}
+
+// writeStatuses writes one line per file, such as "a.m: skipped (generated)"
+// or "b.m: error: ...". With porcelain the lines are "status\tpath\tdetail"
+// instead, with an empty detail where there is none.
+func writeStatuses(w io.Writer, statuses []fileStatus, porcelain bool) error {
+	for _, s := range statuses {
+		var err error
+		switch {
+		case porcelain:
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", s.Status, s.Path, s.Detail)
+		case s.Status == statusError:
+			_, err = fmt.Fprintf(w, "%s: %s: %s\n", s.Path, s.Status, s.Detail)
+		case s.Detail != "":
+			_, err = fmt.Fprintf(w, "%s: %s (%s)\n", s.Path, s.Status, s.Detail)
+		default:
+			_, err = fmt.Fprintf(w, "%s: %s\n", s.Path, s.Status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChanged writes the paths of the changed files, one per line, as
+// gofmt -l does.
+func writeChanged(w io.Writer, statuses []fileStatus) error {
+	for _, s := range statuses {
+		if s.Status != statusChanged {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, s.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
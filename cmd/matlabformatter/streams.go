@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// redirectStreams replaces stdin by the file descriptor inputFD and stdout by
+// the file descriptor outputFD or the file or named pipe at outputFile, so
+// the source named "-" is read from and the output written to them. Negative
+// descriptors and an empty outputFile leave the streams unchanged.
+func redirectStreams(inputFD, outputFD int, outputFile string) error {
+	if outputFD >= 0 && outputFile != "" {
+		return errors.New("--output-fd and --output-file cannot be combined")
+	}
+	if inputFD >= 0 {
+		in, err := openFD(inputFD, "input")
+		if err != nil {
+			return err
+		}
+		os.Stdin = in
+	}
+	switch {
+	case outputFD >= 0:
+		out, err := openFD(outputFD, "output")
+		if err != nil {
+			return err
+		}
+		os.Stdout = out
+	case outputFile != "":
+		// Opening a named pipe for writing waits for its reader.
+		out, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		if err != nil {
+			return err
+		}
+		os.Stdout = out
+	}
+	return nil
+}
+
+// openFD returns the open file descriptor fd, named after its use.
+func openFD(fd int, use string) (*os.File, error) {
+	f := os.NewFile(uintptr(fd), use+"-fd-"+strconv.Itoa(fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid %s file descriptor %d", use, fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("invalid %s file descriptor %d: %v", use, fd, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/koyashimano/matlab-formatter/internal/outline"
+)
+
+// fileSymbols is the outline of one file as printed by the symbols command.
+type fileSymbols struct {
+	Path    string           `json:"path"`
+	Symbols []outline.Symbol `json:"symbols"`
+}
+
+func runSymbols(args []string) int {
+	fs := flag.NewFlagSet("matlabformatter symbols", flag.ExitOnError)
+
+	filenames, err := parseFilenames(fs, args)
+	if err != nil {
+		if errors.Is(err, errMissingFilename) {
+			fmt.Fprintf(os.Stderr, "usage: matlabformatter symbols <file...>\n")
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
+	}
+
+	status := exitOK
+	results := []fileSymbols{}
+	for _, filename := range filenames {
+		lines, err := readFileLines(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			status = exitIO
+			continue
+		}
+		symbols := outline.Symbols(lines)
+		if symbols == nil {
+			symbols = []outline.Symbol{}
+		}
+		results = append(results, fileSymbols{Path: filename, Symbols: symbols})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return status
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errTimedOut is returned for files whose formatting takes longer than
+// --timeout-per-file, and errInterrupted for the file being formatted when
+// the command is interrupted.
+var (
+	errTimedOut    = errors.New("formatting timed out")
+	errInterrupted = errors.New("formatting interrupted; the file was left unchanged")
+)
+
+// withTimeout returns a context of ctx cancelled after timeout, so that the
+// formatting it is passed to stops. A zero or negative timeout returns ctx.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// formatError returns err, reported as errTimedOut when it is the deadline
+// of a context of withTimeout passing and as errInterrupted when the context
+// was cancelled.
+func formatError(err error, timeout time.Duration) error {
+	switch {
+	case timeout > 0 && errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w after %v; the file was left unchanged", errTimedOut, timeout)
+	case errors.Is(err, context.Canceled):
+		return errInterrupted
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+func TestFormatWithTimeout(t *testing.T) {
+	f, err := formatter.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := make([]string, 50000)
+	for i := range lines {
+		lines[i] = "x=1;"
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// interrupted cancels the context passed in.
+		interrupted bool
+		want        error
+	}{
+		{"no limit", 0, false, nil},
+		{"within the limit", time.Minute, false, nil},
+		{"timed out", time.Nanosecond, false, errTimedOut},
+		{"interrupted", time.Minute, true, errInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.interrupted {
+				cancel()
+			}
+			defer cancel()
+			goroutines := runtime.NumGoroutine()
+			formatted, _, err := formatWithTimeout(ctx, f, lines, nil, "", false, tt.timeout)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if err == nil && formatted[0] != "x = 1;" {
+				t.Errorf("got %q, want %q", formatted[0], "x = 1;")
+			}
+			// The formatting stops instead of running on in the background.
+			if n := runtime.NumGoroutine(); n > goroutines {
+				t.Errorf("%d goroutines left running, want %d", n, goroutines)
+			}
+		})
+	}
+}
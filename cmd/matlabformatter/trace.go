@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// fileTrace is the --trace output for one file.
+type fileTrace struct {
+	Path  string     `json:"path"`
+	Lines lineTraces `json:"lines"`
+}
+
+// lineTraces encodes as an object keyed by line number, in line order.
+type lineTraces []formatter.LineTrace
+
+func (t lineTraces) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, lt := range t {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		entry, err := json.Marshal(struct {
+			Class string   `json:"class"`
+			Rules []string `json:"rules,omitempty"`
+		}{lt.Class, lt.Rules})
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(strconv.Quote(strconv.Itoa(lt.Line)))
+		b.WriteByte(':')
+		b.Write(entry)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func writeTraces(w io.Writer, traces []fileTrace) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(traces)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// whitespaceMarkers makes spaces and tabs visible in writeWhitespace output.
+var whitespaceMarkers = strings.NewReplacer(" ", "·", "\t", "→")
+
+// writeWhitespace writes lines with spaces shown as "·", tabs as "→" and line
+// ends as "$", preceded by a header naming the file.
+func writeWhitespace(w io.Writer, filename string, lines []string) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "==> %s <==\n", filename)
+	for _, line := range lines {
+		fmt.Fprintf(b, "%s$\n", whitespaceMarkers.Replace(line))
+	}
+	return b.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of the file src to dst. Missing
+// support of the filesystem and attributes the user may not set, such as
+// those of the trusted namespace, are skipped.
+func copyXattrs(src, dst string) error {
+	names, err := xattr(func(buf []byte) (int, error) { return syscall.Listxattr(src, buf) })
+	if err != nil {
+		return ignoreXattrError(err)
+	}
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := xattr(func(buf []byte) (int, error) { return syscall.Getxattr(src, string(name), buf) })
+		if err == nil {
+			err = syscall.Setxattr(dst, string(name), value, 0)
+		}
+		if err := ignoreXattrError(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xattr returns the data read by get, which fills buf and returns the size
+// of the data, or its size when buf is empty.
+func xattr(get func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := get(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := get(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			// The data grew in between; try again.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func ignoreXattrError(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.ENODATA) {
+		return nil
+	}
+	return err
+}
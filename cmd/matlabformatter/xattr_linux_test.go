@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.m")
+	if err := os.WriteFile(path, []byte("x=1;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := syscall.Setxattr(path, "user.matlabformatter.test", []byte("kept"), 0)
+	if errors.Is(err, syscall.ENOTSUP) {
+		t.Skip("the file system has no extended attributes")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rewriteFile(path, []byte("x = 1;\n"), rewriteOptions{}); err != nil {
+		t.Fatalf("rewriteFile: %v", err)
+	}
+	buf := make([]byte, 16)
+	n, err := syscall.Getxattr(path, "user.matlabformatter.test", buf)
+	if err != nil {
+		t.Fatalf("Getxattr: %v", err)
+	}
+	if got := string(buf[:n]); got != "kept" {
+		t.Errorf("got attribute %q, want %q", got, "kept")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// copyXattrs does nothing on platforms whose extended attributes are not
+// supported.
+func copyXattrs(src, dst string) error {
+	return nil
+}
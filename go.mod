@@ -1,3 +1,10 @@
 module github.com/koyashimano/matlab-formatter
 
 go 1.22
+
+require (
+	github.com/google/go-cmp v0.5.1
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+)
+
+require golang.org/x/sys v0.20.0 // indirect
@@ -0,0 +1,75 @@
+// Package batch implements the framed protocol used to format many buffers
+// through a single stream.
+//
+// Each frame is a header line "<kind> <length> <name>" followed by exactly
+// length bytes of content. Requests are "file" frames holding the source to
+// format under its file name. Each request is answered, in order, by a
+// "file" frame holding the result or an "error" frame holding the message.
+// The name is the rest of the header line and may contain spaces.
+package batch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Frame kinds.
+const (
+	KindFile  = "file"
+	KindError = "error"
+)
+
+// Frame is a frame of the protocol.
+type Frame struct {
+	Kind    string
+	Name    string
+	Content []byte
+}
+
+// Read reads the next frame from r. It returns io.EOF when r ends before a
+// header, and io.ErrUnexpectedEOF when it ends within a frame.
+func Read(r *bufio.Reader) (Frame, error) {
+	header, err := r.ReadString('\n')
+	if err == io.EOF && header == "" {
+		return Frame{}, io.EOF
+	}
+	if err == io.EOF {
+		return Frame{}, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return Frame{}, err
+	}
+	header = strings.TrimSuffix(strings.TrimSuffix(header, "\n"), "\r")
+	fields := strings.SplitN(header, " ", 3)
+	if len(fields) != 3 || fields[0] == "" || fields[2] == "" {
+		return Frame{}, fmt.Errorf("invalid frame header %q (want \"<kind> <length> <name>\")", header)
+	}
+	length, err := strconv.Atoi(fields[1])
+	if err != nil || length < 0 {
+		return Frame{}, fmt.Errorf("invalid frame length %q", fields[1])
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	return Frame{Kind: fields[0], Name: fields[2], Content: content}, nil
+}
+
+// Write writes frame to w.
+func Write(w io.Writer, frame Frame) error {
+	if strings.ContainsAny(frame.Name, "\r\n") {
+		return fmt.Errorf("invalid frame name %q", frame.Name)
+	}
+	if _, err := fmt.Fprintf(w, "%s %d %s\n", frame.Kind, len(frame.Content), frame.Name); err != nil {
+		return err
+	}
+	_, err := w.Write(frame.Content)
+	return err
+}
@@ -0,0 +1,68 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadWrite(t *testing.T) {
+	frames := []Frame{
+		{Kind: KindFile, Name: "src/a b.m", Content: []byte("x=1;\n")},
+		{Kind: KindFile, Name: "empty.m", Content: []byte{}},
+		{Kind: KindError, Name: "c.m", Content: []byte("unbalanced end\n")},
+	}
+	var buf bytes.Buffer
+	for _, f := range frames {
+		if err := Write(&buf, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "file 5 src/a b.m\nx=1;\nfile 0 empty.m\nerror 15 c.m\nunbalanced end\n"; buf.String() != want {
+		t.Errorf("Write() = %q, want %q", buf.String(), want)
+	}
+
+	r := bufio.NewReader(&buf)
+	var got []Frame
+	for {
+		f, err := Read(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f)
+	}
+	if diff := cmp.Diff(frames, got); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, input := range []string{
+		"file 10 a.m\nx=1;\n",
+		"file 5 a.m",
+	} {
+		if _, err := Read(bufio.NewReader(strings.NewReader(input))); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Read(%q) error = %v, want io.ErrUnexpectedEOF", input, err)
+		}
+	}
+	for _, input := range []string{
+		"file a.m\n",
+		"file -1 a.m\n",
+		"file 1\n",
+	} {
+		if _, err := Read(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("Read(%q) succeeded", input)
+		}
+	}
+	if err := Write(io.Discard, Frame{Kind: KindFile, Name: "a\n.m"}); err == nil {
+		t.Error("Write() of a name with a line break succeeded")
+	}
+}
@@ -0,0 +1,500 @@
+// Package config reads matlabformatter configuration files.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// Config holds the settings read from a configuration file.
+type Config struct {
+	// Path is the file the configuration was read from.
+	Path string
+	// Format maps formatting option names, such as indentWidth, to their
+	// values in command-line form.
+	Format map[string]string
+	// Lint holds the settings of the lint subcommand.
+	Lint Lint
+	// Profiles holds the named [profiles.NAME] sections, which override the
+	// settings above when selected with WithProfile.
+	Profiles map[string]*Profile
+
+	lines map[string]int
+}
+
+// Profile is a named set of settings layered over the rest of the file.
+type Profile struct {
+	Format map[string]string
+	Lint   Lint
+}
+
+// Lint holds the [lint] section of a configuration file.
+type Lint struct {
+	// Rules maps rule IDs to a severity name: off, info, warning or error.
+	Rules map[string]string
+	// LocalFunctionOrder is the order enforced by the local-function-order
+	// rule.
+	LocalFunctionOrder string
+	// Header is the file header enforced by the file-header rule, or nil when
+	// none is configured.
+	Header *Header
+	// Custom lists the user-defined regular expression rules.
+	Custom []CustomRule
+	// Scripts lists the rules implemented as Starlark scripts.
+	Scripts []ScriptRule
+}
+
+// ScriptRule is a [[lint.script]] entry naming a Starlark rule script.
+type ScriptRule struct {
+	ID string
+	// File is the script path. Relative paths are resolved against the
+	// directory of the configuration file.
+	File string
+	// Severity is empty when the entry does not set one.
+	Severity string
+	// Line is the line of the entry's table header.
+	Line int
+}
+
+// CustomRule is a [[lint.custom]] entry defining a regular expression rule.
+type CustomRule struct {
+	ID      string
+	Pattern string
+	Message string
+	// Severity is empty when the entry does not set one.
+	Severity string
+	// Replacement is the fix applied to each match, with $1-style group
+	// references. HasReplacement distinguishes an empty replacement from none.
+	Replacement    string
+	HasReplacement bool
+	// Line is the line of the entry's table header.
+	Line int
+}
+
+// Header holds the [lint.header] section of a configuration file.
+type Header struct {
+	// Template is the expected header text. "{year}" stands for a year or a
+	// year range such as 2019-2024.
+	Template string
+	// UpdateYear extends year ranges ending before the current year.
+	UpdateYear bool
+}
+
+// FileName is the name of the configuration files found by Find.
+const FileName = ".matlabformatter.toml"
+
+// Find returns the path of the configuration file applying to the files in
+// dir: the FileName in dir or in the nearest of its parents that has one, as
+// .clang-format files are found. It returns "" when there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and parses the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses configuration data. path is only used in error messages and
+// recorded in the result.
+func Parse(path string, data []byte) (*Config, error) {
+	root, err := parseTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	c := &Config{Path: path, lines: make(map[string]int)}
+	if err := c.decode(root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Line returns the line on which the dotted key was set, or 0 when the key is
+// not present in the file.
+func (c *Config) Line(key string) int {
+	return c.lines[key]
+}
+
+// WithProfile returns the configuration with the settings of the named
+// profile applied. Format options, rule severities and the other lint
+// settings of the profile replace those of the file; its custom and script
+// rules are added to the file's. Line then reports the lines within the
+// profile for the keys it sets.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("%s: unknown profile %q (the file defines no profiles)", c.Path, name)
+		}
+		return nil, fmt.Errorf("%s: unknown profile %q (defined profiles: %s)", c.Path, name, strings.Join(names, ", "))
+	}
+
+	merged := &Config{
+		Path:     c.Path,
+		Format:   make(map[string]string, len(c.Format)+len(p.Format)),
+		Lint:     c.Lint,
+		Profiles: c.Profiles,
+		lines:    make(map[string]int, len(c.lines)),
+	}
+	for k, line := range c.lines {
+		merged.lines[k] = line
+	}
+	prefix := "profiles." + name + "."
+	for k, line := range c.lines {
+		if strings.HasPrefix(k, prefix) {
+			merged.lines[strings.TrimPrefix(k, prefix)] = line
+		}
+	}
+
+	for k, v := range c.Format {
+		merged.Format[k] = v
+	}
+	for k, v := range p.Format {
+		merged.Format[k] = v
+	}
+
+	if len(p.Lint.Rules) > 0 {
+		merged.Lint.Rules = make(map[string]string, len(c.Lint.Rules)+len(p.Lint.Rules))
+		for id, s := range c.Lint.Rules {
+			merged.Lint.Rules[id] = s
+		}
+		for id, s := range p.Lint.Rules {
+			merged.Lint.Rules[id] = s
+		}
+	}
+	if p.Lint.LocalFunctionOrder != "" {
+		merged.Lint.LocalFunctionOrder = p.Lint.LocalFunctionOrder
+	}
+	if p.Lint.Header != nil {
+		merged.Lint.Header = p.Lint.Header
+	}
+	merged.Lint.Custom = append(append([]CustomRule(nil), c.Lint.Custom...), p.Lint.Custom...)
+	merged.Lint.Scripts = append(append([]ScriptRule(nil), c.Lint.Scripts...), p.Lint.Scripts...)
+	return merged, nil
+}
+
+func (c *Config) decode(root *table) error {
+	if err := checkKeys(root, "", "format", "lint", "profiles"); err != nil {
+		return err
+	}
+
+	if format, ok := root.tables["format"]; ok {
+		var err error
+		if c.Format, err = c.decodeFormat(format, "format."); err != nil {
+			return err
+		}
+	}
+	if lint, ok := root.tables["lint"]; ok {
+		if err := c.decodeLint(lint, "lint.", &c.Lint); err != nil {
+			return err
+		}
+	}
+	if profiles, ok := root.tables["profiles"]; ok {
+		if len(profiles.values) > 0 || len(profiles.arrays) > 0 {
+			return fmt.Errorf("line %d: profiles may only contain profile tables", profiles.line)
+		}
+		c.Profiles = make(map[string]*Profile, len(profiles.tables))
+		for name, t := range profiles.tables {
+			p, err := c.decodeProfile(t, "profiles."+name+".")
+			if err != nil {
+				return err
+			}
+			c.Profiles[name] = p
+		}
+	}
+	return nil
+}
+
+func (c *Config) decodeProfile(t *table, prefix string) (*Profile, error) {
+	if err := checkKeys(t, prefix, "format", "lint"); err != nil {
+		return nil, err
+	}
+	p := &Profile{}
+	if format, ok := t.tables["format"]; ok {
+		var err error
+		if p.Format, err = c.decodeFormat(format, prefix+"format."); err != nil {
+			return nil, err
+		}
+	}
+	if lint, ok := t.tables["lint"]; ok {
+		if err := c.decodeLint(lint, prefix+"lint.", &p.Lint); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// decodeFormat converts the formatting options of t, which are named after
+// the options of the formatting rules.
+func (c *Config) decodeFormat(t *table, prefix string) (map[string]string, error) {
+	types := make(map[string]string)
+	for _, r := range formatter.Rules() {
+		for _, o := range r.Options {
+			types[o.Name] = o.Type
+		}
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	if err := checkKeys(t, prefix, names...); err != nil {
+		return nil, err
+	}
+	if len(t.tables) > 0 || len(t.arrays) > 0 {
+		return nil, fmt.Errorf("line %d: %s may only contain option values", t.line, strings.TrimSuffix(prefix, "."))
+	}
+
+	format := make(map[string]string, len(t.values))
+	for name, v := range t.values {
+		var s string
+		ok := false
+		switch x := v.v.(type) {
+		case int64:
+			s, ok = strconv.FormatInt(x, 10), types[name] == "int"
+		case bool:
+			s, ok = strconv.FormatBool(x), types[name] == "bool"
+		case string:
+			s, ok = x, types[name] == "string"
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: %s%s must be a %s", v.line, prefix, name, typeName(types[name]))
+		}
+		format[name] = s
+		c.lines[prefix+name] = v.line
+	}
+	return format, nil
+}
+
+// typeName returns the TOML name of an option type.
+func typeName(t string) string {
+	switch t {
+	case "int":
+		return "integer"
+	case "bool":
+		return "boolean"
+	}
+	return t
+}
+
+func (c *Config) decodeLint(t *table, prefix string, l *Lint) error {
+	if err := checkKeys(t, prefix, "rules", "localFunctionOrder", "header", "custom", "script"); err != nil {
+		return err
+	}
+	if v, ok := t.values["localFunctionOrder"]; ok {
+		s, err := stringValue(v, prefix+"localFunctionOrder")
+		if err != nil {
+			return err
+		}
+		l.LocalFunctionOrder = s
+		c.lines[prefix+"localFunctionOrder"] = v.line
+	}
+	if rules, ok := t.tables["rules"]; ok {
+		if len(rules.tables) > 0 || len(rules.arrays) > 0 {
+			return fmt.Errorf("line %d: %srules may only contain rule severities", rules.line, prefix)
+		}
+		l.Rules = make(map[string]string, len(rules.values))
+		for id, v := range rules.values {
+			s, err := stringValue(v, prefix+"rules."+id)
+			if err != nil {
+				return err
+			}
+			l.Rules[id] = s
+			c.lines[prefix+"rules."+id] = v.line
+		}
+	}
+	if header, ok := t.tables["header"]; ok {
+		if err := c.decodeHeader(header, prefix+"header", l); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"custom", "script"} {
+		if v, ok := t.values[key]; ok {
+			return fmt.Errorf("line %d: %s%s must be an array of tables", v.line, prefix, key)
+		}
+		if sub, ok := t.tables[key]; ok {
+			return fmt.Errorf("line %d: %s%s must be an array of tables", sub.line, prefix, key)
+		}
+	}
+	for _, entry := range t.arrays["custom"] {
+		if err := decodeCustomRule(entry, prefix+"custom", l); err != nil {
+			return err
+		}
+	}
+	for _, entry := range t.arrays["script"] {
+		if err := c.decodeScriptRule(entry, prefix+"script", l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) decodeHeader(t *table, section string, l *Lint) error {
+	if err := checkKeys(t, section+".", "template", "updateYear"); err != nil {
+		return err
+	}
+	v, ok := t.values["template"]
+	if !ok {
+		return fmt.Errorf("line %d: %s requires a template", t.line, section)
+	}
+	template, err := stringValue(v, section+".template")
+	if err != nil {
+		return err
+	}
+	l.Header = &Header{Template: template, UpdateYear: true}
+	c.lines[section+".template"] = v.line
+
+	if v, ok := t.values["updateYear"]; ok {
+		b, ok := v.v.(bool)
+		if !ok {
+			return fmt.Errorf("line %d: %s.updateYear must be a boolean", v.line, section)
+		}
+		l.Header.UpdateYear = b
+		c.lines[section+".updateYear"] = v.line
+	}
+	return nil
+}
+
+func decodeCustomRule(t *table, section string, l *Lint) error {
+	if err := checkKeys(t, section+".", "id", "pattern", "message", "severity", "replacement"); err != nil {
+		return err
+	}
+	r := CustomRule{Line: t.line}
+	err := decodeStrings(t, section, []stringField{
+		{"id", &r.ID, true},
+		{"pattern", &r.Pattern, true},
+		{"message", &r.Message, false},
+		{"severity", &r.Severity, false},
+		{"replacement", &r.Replacement, false},
+	})
+	if err != nil {
+		return err
+	}
+	_, r.HasReplacement = t.values["replacement"]
+	l.Custom = append(l.Custom, r)
+	return nil
+}
+
+func (c *Config) decodeScriptRule(t *table, section string, l *Lint) error {
+	if err := checkKeys(t, section+".", "id", "file", "severity"); err != nil {
+		return err
+	}
+	r := ScriptRule{Line: t.line}
+	err := decodeStrings(t, section, []stringField{
+		{"id", &r.ID, true},
+		{"file", &r.File, true},
+		{"severity", &r.Severity, false},
+	})
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(r.File) {
+		r.File = filepath.Join(filepath.Dir(c.Path), r.File)
+	}
+	l.Scripts = append(l.Scripts, r)
+	return nil
+}
+
+// stringField describes a string key of an array-of-tables entry.
+type stringField struct {
+	key      string
+	dst      *string
+	required bool
+}
+
+// decodeStrings stores the string values of the entry t of the array named
+// section in the fields.
+func decodeStrings(t *table, section string, fields []stringField) error {
+	for _, field := range fields {
+		v, ok := t.values[field.key]
+		if !ok {
+			if field.required {
+				return fmt.Errorf("line %d: %s entry requires %s", t.line, section, field.key)
+			}
+			continue
+		}
+		s, err := stringValue(v, section+"."+field.key)
+		if err != nil {
+			return err
+		}
+		*field.dst = s
+	}
+	return nil
+}
+
+// checkKeys rejects keys of t that are not listed in allowed so typos in
+// configuration files are reported instead of silently ignored.
+func checkKeys(t *table, prefix string, allowed ...string) error {
+	known := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		known[key] = true
+	}
+
+	type entry struct {
+		key  string
+		line int
+	}
+	var unknown []entry
+	for key, v := range t.values {
+		if !known[key] {
+			unknown = append(unknown, entry{key, v.line})
+		}
+	}
+	for key, sub := range t.tables {
+		if !known[key] {
+			unknown = append(unknown, entry{key, sub.line})
+		}
+	}
+	for key, arr := range t.arrays {
+		if !known[key] {
+			unknown = append(unknown, entry{key, arr[0].line})
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].line < unknown[j].line })
+	return fmt.Errorf("line %d: unknown key %q", unknown[0].line, prefix+unknown[0].key)
+}
+
+func stringValue(v *value, key string) (string, error) {
+	s, ok := v.v.(string)
+	if !ok {
+		return "", fmt.Errorf("line %d: %s must be a string", v.line, key)
+	}
+	return s, nil
+}
@@ -0,0 +1,279 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLintRules(t *testing.T) {
+	data := []byte(`# shared style
+[lint.rules]
+missing-semicolon = "error"   # CI gate
+"comment-space" = 'off'
+`)
+
+	cfg, err := Parse("style.toml", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := map[string]string{
+		"missing-semicolon": "error",
+		"comment-space":     "off",
+	}
+	if !reflect.DeepEqual(cfg.Lint.Rules, want) {
+		t.Fatalf("unexpected rules: got %v want %v", cfg.Lint.Rules, want)
+	}
+	if got := cfg.Line("lint.rules.comment-space"); got != 4 {
+		t.Fatalf("unexpected line for comment-space: got %d want 4", got)
+	}
+}
+
+func TestParseRejectsInvalidInput(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":     "indent = 4\n",
+		"duplicate key":   "[lint.rules]\na = \"x\"\na = \"y\"\n",
+		"non-string":      "[lint.rules]\na = 1\n",
+		"unterminated":    "[lint.rules]\na = \"x\n",
+		"trailing junk":   "[lint.rules]\na = \"x\" y\n",
+		"duplicate table": "[lint]\n[lint]\n",
+	}
+
+	for name, input := range tests {
+		if _, err := Parse("bad.toml", []byte(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "bad.toml: line ") {
+			t.Errorf("%s: error lacks position: %v", name, err)
+		}
+	}
+}
+
+func TestParseTOMLValues(t *testing.T) {
+	data := []byte(`
+a = [1, -2,
+  3_000,]
+b = true
+c = """
+first
+second"""
+d = "tab\tquote\" é"
+e = """say "hi"""""
+f = "\b\f"
+[[x.y]]
+n = 1
+[[x.y]]
+n = 2
+`)
+
+	root, err := parseTOML(data)
+	if err != nil {
+		t.Fatalf("parseTOML: %v", err)
+	}
+
+	if got, want := root.values["a"].v, []any{int64(1), int64(-2), int64(3000)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("a: got %#v want %#v", got, want)
+	}
+	if got := root.values["b"].v; got != true {
+		t.Errorf("b: got %#v", got)
+	}
+	if got := root.values["c"].v; got != "first\nsecond" {
+		t.Errorf("c: got %q", got)
+	}
+	if got := root.values["d"].v; got != "tab\tquote\" é" {
+		t.Errorf("d: got %q", got)
+	}
+	if got := root.values["e"].v; got != `say "hi""` {
+		t.Errorf("e: got %q", got)
+	}
+	if got := root.values["f"].v; got != "\b\f" {
+		t.Errorf("f: got %q", got)
+	}
+	if arr := root.tables["x"].arrays["y"]; len(arr) != 2 || arr[1].values["n"].v != int64(2) || arr[1].line != 13 {
+		t.Errorf("x.y: unexpected array of tables %#v", arr)
+	}
+}
+
+func TestParseTOMLRejectsUnsupported(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"inline table", "a = 1\nb = {c = 1}\n", "line 2: inline tables are not supported"},
+		{"inline table in array", "a = [\n  1,\n  {c = 1},\n]\n", "line 3: inline tables are not supported"},
+		{"float", "a = 3.5\n", `line 1: unsupported value "3.5"`},
+		{"exponent", "a = 1e3\n", `line 1: unsupported value "1e3"`},
+		{"infinity", "a = inf\n", `line 1: unsupported value "inf"`},
+		{"date", "\na = 1979-05-27\n", `line 2: unsupported value "1979-05-27"`},
+		{"hexadecimal", "a = 0x1F\n", `line 1: unsupported value "0x1F"`},
+		{"leading zero", "a = 01\n", `line 1: unsupported value "01"`},
+		{"trailing underscore", "a = 1_\n", `line 1: unsupported value "1_"`},
+		{"double underscore", "a = 1__0\n", `line 1: unsupported value "1__0"`},
+		{"double sign", "a = +-1\n", `line 1: unsupported value "+-1"`},
+		{"bare word", "a = trueish\n", `line 1: unsupported value "trueish"`},
+		{"too many quotes", "a = \"\"\"x\"\"\"\"\"\"\n", "line 1: too many quotes"},
+		{"table after array of tables", "[[a]]\n[a]\n", `line 2: key "a" is already defined as an array of tables`},
+		{"value after array of tables", "[[a.b]]\n[a]\nb = 1\n", `line 3: key "b" is already defined as an array of tables`},
+		{"dotted keys into array of tables", "[[a.b]]\n[a]\nb.c = 1\n", `line 3: key "b" is already defined as an array of tables`},
+		{"header after dotted keys", "a.b = 1\n[a]\n", `line 2: table "a" is already defined by dotted keys`},
+		{"dotted keys into header", "[a.b]\n[c]\n[a]\nb.c = 1\n", `line 4: table "b" is already defined by its header`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML([]byte(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLintHeader(t *testing.T) {
+	data := []byte(`[lint.header]
+template = """
+% Copyright {year} Example Corp.
+% SPDX-License-Identifier: MIT
+"""
+updateYear = false
+`)
+
+	cfg, err := Parse("style.toml", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := &Header{
+		Template:   "% Copyright {year} Example Corp.\n% SPDX-License-Identifier: MIT\n",
+		UpdateYear: false,
+	}
+	if !reflect.DeepEqual(cfg.Lint.Header, want) {
+		t.Fatalf("unexpected header: got %+v want %+v", cfg.Lint.Header, want)
+	}
+
+	if _, err := Parse("bad.toml", []byte("[lint.header]\nupdateYear = true\n")); err == nil {
+		t.Fatal("expected error for header without template")
+	}
+}
+
+func TestParseCustomRules(t *testing.T) {
+	data := []byte(`[[lint.custom]]
+id = "no-eval"
+pattern = 'eval\s*\('
+message = "eval hides code from analysis"
+severity = "error"
+
+[[lint.custom]]
+id = "no-global"
+pattern = '^\s*global\b'
+replacement = ""
+`)
+
+	cfg, err := Parse("style.toml", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []CustomRule{
+		{ID: "no-eval", Pattern: `eval\s*\(`, Message: "eval hides code from analysis", Severity: "error", Line: 1},
+		{ID: "no-global", Pattern: `^\s*global\b`, HasReplacement: true, Line: 7},
+	}
+	if !reflect.DeepEqual(cfg.Lint.Custom, want) {
+		t.Fatalf("unexpected custom rules:\ngot  %+v\nwant %+v", cfg.Lint.Custom, want)
+	}
+
+	if _, err := Parse("bad.toml", []byte("[[lint.custom]]\nid = \"x\"\n")); err == nil {
+		t.Fatal("expected error for custom rule without pattern")
+	}
+}
+
+func TestParseScriptRules(t *testing.T) {
+	data := []byte(`[[lint.script]]
+id = "long-function"
+file = "rules/long.star"
+severity = "info"
+`)
+
+	cfg, err := Parse("/project/.matlabformatter.toml", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []ScriptRule{{ID: "long-function", File: "/project/rules/long.star", Severity: "info", Line: 1}}
+	if !reflect.DeepEqual(cfg.Lint.Scripts, want) {
+		t.Fatalf("unexpected script rules: %+v", cfg.Lint.Scripts)
+	}
+}
+
+func TestParseFormatAndProfiles(t *testing.T) {
+	data := []byte(`[format]
+indentWidth = 2
+indentMode = "classic"
+
+[lint.rules]
+comment-space = "off"
+
+[profiles.strict.format]
+indentWidth = 4
+sortImports = true
+
+[profiles.strict.lint.rules]
+missing-semicolon = "error"
+`)
+
+	cfg, err := Parse("style.toml", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := map[string]string{"indentWidth": "2", "indentMode": "classic"}; !reflect.DeepEqual(cfg.Format, want) {
+		t.Fatalf("unexpected format options: %v", cfg.Format)
+	}
+
+	strict, err := cfg.WithProfile("strict")
+	if err != nil {
+		t.Fatalf("WithProfile: %v", err)
+	}
+	if want := map[string]string{"indentWidth": "4", "indentMode": "classic", "sortImports": "true"}; !reflect.DeepEqual(strict.Format, want) {
+		t.Errorf("unexpected profile format options: %v", strict.Format)
+	}
+	if want := map[string]string{"comment-space": "off", "missing-semicolon": "error"}; !reflect.DeepEqual(strict.Lint.Rules, want) {
+		t.Errorf("unexpected profile rules: %v", strict.Lint.Rules)
+	}
+	if got := strict.Line("format.indentWidth"); got != 9 {
+		t.Errorf("unexpected line for indentWidth: got %d want 9", got)
+	}
+	if got := strict.Line("format.indentMode"); got != 3 {
+		t.Errorf("unexpected line for indentMode: got %d want 3", got)
+	}
+	if len(cfg.Lint.Rules) != 1 {
+		t.Errorf("WithProfile modified the base rules: %v", cfg.Lint.Rules)
+	}
+
+	if _, err := cfg.WithProfile("review"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+	for _, input := range []string{"[format]\nindentWidth = \"2\"\n", "[format]\nindent = 2\n", "[profiles.x]\nindentWidth = 2\n"} {
+		if _, err := Parse("bad.toml", []byte(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Find(sub); err != nil || got != "" {
+		t.Fatalf("Find without a file = %q, %v", got, err)
+	}
+
+	want := filepath.Join(root, "a", FileName)
+	if err := os.WriteFile(want, []byte("[format]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Find(sub); err != nil || got != want {
+		t.Fatalf("Find = %q, %v, want %q", got, err, want)
+	}
+	if got, err := Find(root); err != nil || got != "" {
+		t.Fatalf("Find above the file = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,528 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements the subset of TOML used by configuration files:
+// comments, standard tables, arrays of tables, dotted keys, basic and literal
+// strings (including their multi-line forms), decimal integers, booleans and
+// arrays. The rest of TOML, such as inline tables, floats and dates, is
+// rejected with the line it is on rather than misread.
+
+// value is a parsed scalar or array together with the line it was defined on.
+type value struct {
+	v    any
+	line int
+}
+
+// table is a parsed TOML table.
+type table struct {
+	line   int
+	values map[string]*value
+	tables map[string]*table
+	arrays map[string][]*table
+	// explicit is set once the table has been opened by its own header.
+	explicit bool
+	// dotted is set when the table was created by a dotted key, after which
+	// it cannot be opened by a header.
+	dotted bool
+}
+
+func newTable(line int) *table {
+	return &table{
+		line:   line,
+		values: make(map[string]*value),
+		tables: make(map[string]*table),
+		arrays: make(map[string][]*table),
+	}
+}
+
+type tomlParser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+// parseTOML parses data into its root table.
+func parseTOML(data []byte) (*table, error) {
+	p := &tomlParser{data: data, line: 1}
+	root := newTable(1)
+	current := root
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		p.skipComment()
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.errorf("unexpected %q after value", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.data) }
+
+func (p *tomlParser) peek() byte { return p.data[p.pos] }
+
+func (p *tomlParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(p.data[p.pos:]), s)
+}
+
+func (p *tomlParser) advance() byte {
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipSpace skips spaces and tabs on the current line.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if !p.eof() && p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.eof() || p.peek() != '\n' {
+			return
+		}
+		p.advance()
+	}
+}
+
+func (p *tomlParser) parseHeader(root *table) (*table, error) {
+	line := p.line
+	array := p.hasPrefix("[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	p.skipSpace()
+	if !p.hasPrefix(closing) {
+		return nil, p.errorf("expected %q to close table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(root, keys[:len(keys)-1], line, false)
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+
+	if array {
+		if _, ok := parent.values[last]; ok {
+			return nil, p.errorf("key %q is already defined", last)
+		}
+		if _, ok := parent.tables[last]; ok {
+			return nil, p.errorf("key %q is already defined as a table", last)
+		}
+		t := newTable(line)
+		t.explicit = true
+		parent.arrays[last] = append(parent.arrays[last], t)
+		return t, nil
+	}
+
+	if _, ok := parent.values[last]; ok {
+		return nil, p.errorf("key %q is already defined", last)
+	}
+	if _, ok := parent.arrays[last]; ok {
+		return nil, p.errorf("key %q is already defined as an array of tables", last)
+	}
+	t, ok := parent.tables[last]
+	switch {
+	case !ok:
+		t = newTable(line)
+		parent.tables[last] = t
+	case t.explicit:
+		return nil, p.errorf("table %q is defined more than once", strings.Join(keys, "."))
+	case t.dotted:
+		return nil, p.errorf("table %q is already defined by dotted keys", strings.Join(keys, "."))
+	}
+	t.explicit = true
+	t.line = line
+	return t, nil
+}
+
+// descend walks to the table named by keys, creating implicit tables and
+// following the last element of arrays of tables. The dotted keys of a
+// key/value pair may neither extend arrays of tables nor the tables opened by
+// other headers.
+func (p *tomlParser) descend(t *table, keys []string, line int, dotted bool) (*table, error) {
+	for _, key := range keys {
+		if arr, ok := t.arrays[key]; ok {
+			if dotted {
+				return nil, p.errorf("key %q is already defined as an array of tables", key)
+			}
+			t = arr[len(arr)-1]
+			continue
+		}
+		if _, ok := t.values[key]; ok {
+			return nil, p.errorf("key %q is already defined as a value", key)
+		}
+		next, ok := t.tables[key]
+		switch {
+		case !ok:
+			next = newTable(line)
+			next.dotted = dotted
+			t.tables[key] = next
+		case dotted && next.explicit:
+			return nil, p.errorf("table %q is already defined by its header", key)
+		}
+		t = next
+	}
+	return t, nil
+}
+
+func (p *tomlParser) parseKeyValue(t *table) error {
+	line := p.line
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.eof() || p.peek() != '=' {
+		return p.errorf("expected \"=\" after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace()
+
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.descend(t, keys[:len(keys)-1], line, true)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent.values[last]; ok {
+		return p.errorf("key %q is defined more than once", strings.Join(keys, "."))
+	}
+	if _, ok := parent.tables[last]; ok {
+		return p.errorf("key %q is already defined as a table", strings.Join(keys, "."))
+	}
+	if _, ok := parent.arrays[last]; ok {
+		return p.errorf("key %q is already defined as an array of tables", strings.Join(keys, "."))
+	}
+	parent.values[last] = &value{v: v, line: line}
+	return nil
+}
+
+// parseKey parses a possibly dotted key made of bare or quoted parts.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("expected key")
+		}
+
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("invalid character %q in key", c)
+			}
+			key = string(p.data[start:p.pos])
+		}
+		keys = append(keys, key)
+
+		p.skipSpace()
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	if p.eof() {
+		return nil, p.errorf("expected value")
+	}
+
+	switch c := p.peek(); {
+	case p.hasPrefix(`"""`):
+		return p.parseMultilineString(`"""`, true)
+	case p.hasPrefix(`'''`):
+		return p.parseMultilineString(`'''`, false)
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return nil, p.errorf("inline tables are not supported; define the table under its own [header]")
+	}
+
+	start := p.pos
+	for !p.eof() && isBareValueChar(p.peek()) {
+		p.pos++
+	}
+	text := string(p.data[start:p.pos])
+	switch {
+	case text == "true":
+		return true, nil
+	case text == "false":
+		return false, nil
+	case text == "":
+		return nil, p.errorf("unsupported value starting with %q", p.peek())
+	case !isDecimalInteger(text):
+		return nil, p.errorf("unsupported value %q; only strings, decimal integers, booleans and arrays are supported", text)
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid integer %q", text)
+	}
+	return n, nil
+}
+
+// isBareValueChar reports whether c may be part of an unquoted value, such
+// as a boolean, a number or a date.
+func isBareValueChar(c byte) bool {
+	return isBareKeyChar(c) || c == '+' || c == '.' || c == ':'
+}
+
+// isDecimalInteger reports whether text is a TOML decimal integer: an
+// optional sign and digits without leading zeros, which single underscores
+// may separate.
+func isDecimalInteger(text string) bool {
+	if strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-") {
+		text = text[1:]
+	}
+	if len(text) == 0 || len(text) > 1 && text[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '_':
+			if i == 0 || i == len(text)-1 || text[i+1] == '_' {
+				return false
+			}
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return true
+}
+
+func (p *tomlParser) parseArray() (any, error) {
+	p.pos++
+	items := []any{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return items, nil
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected \",\" or \"]\" in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != '\'' {
+		if p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("unterminated string")
+	}
+	s := string(p.data[start:p.pos])
+	p.pos++
+	return s, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.advance()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) parseMultilineString(delim string, escapes bool) (string, error) {
+	p.pos += len(delim)
+	if p.hasPrefix("\r\n") {
+		p.pos++
+	}
+	if p.hasPrefix("\n") {
+		p.advance()
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if p.hasPrefix(delim) {
+			// Up to two quotes may come before the closing delimiter.
+			n := len(delim)
+			for p.pos+n < len(p.data) && p.data[p.pos+n] == delim[0] {
+				n++
+			}
+			if n > len(delim)+2 {
+				return "", p.errorf("too many quotes closing multi-line string")
+			}
+			b.WriteString(delim[:n-len(delim)])
+			p.pos += n
+			return strings.ReplaceAll(b.String(), "\r\n", "\n"), nil
+		}
+		c := p.advance()
+		if escapes && c == '\\' {
+			// A backslash at the end of a line trims the following whitespace.
+			rest := strings.TrimLeft(string(p.data[p.pos:]), " \t\r")
+			if strings.HasPrefix(rest, "\n") {
+				for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+					p.advance()
+				}
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	switch c := p.advance(); c {
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.data) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape")
+		}
+		p.pos += n
+		b.WriteRune(rune(code))
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Package deps extracts a best-effort, name-based call graph from MATLAB
+// source files.
+package deps
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// Source is a file to analyze.
+type Source struct {
+	Path  string
+	Lines []string
+}
+
+// Unit kinds.
+const (
+	// KindScript is a script file.
+	KindScript = "script"
+	// KindFunction is the main function of a function file.
+	KindFunction = "function"
+	// KindClass is a classdef file.
+	KindClass = "class"
+	// KindLocal is a local, nested or method function inside a file.
+	KindLocal = "local"
+	// KindExternal is a called name that is not defined in the analyzed
+	// files, such as a toolbox or built-in function.
+	KindExternal = "external"
+)
+
+// Unit is a node of the call graph. ID is the file's base name for scripts,
+// main functions and classes, "file>name" for functions inside a file and the
+// called name for external functions.
+type Unit struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// Call is an edge of the call graph. Kind is the kind of the callee's
+// resolution: "local" for functions of the same file, "project" for other
+// analyzed files and "external" otherwise.
+type Call struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+	Line int    `json:"line"`
+}
+
+// Graph is the call graph of a set of files.
+type Graph struct {
+	Units []Unit `json:"units"`
+	Calls []Call `json:"calls"`
+}
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "classdef": true, "continue": true,
+	"else": true, "elseif": true, "end": true, "for": true, "function": true,
+	"global": true, "if": true, "otherwise": true, "parfor": true, "persistent": true,
+	"return": true, "spmd": true, "switch": true, "try": true, "while": true,
+	"properties": true, "methods": true, "events": true, "enumeration": true, "arguments": true,
+}
+
+// scope is a function, or the script body, together with the names that are
+// variables inside it.
+type scope struct {
+	unit  Unit
+	node  *syntax.Node
+	vars  map[string]bool
+	local map[string]string
+}
+
+type fileInfo struct {
+	source Source
+	parsed *syntax.File
+	base   string
+	scopes []*scope
+}
+
+// Build analyzes the sources and returns their call graph.
+func Build(sources []Source) *Graph {
+	g := &Graph{}
+	project := make(map[string]bool)
+	var files []*fileInfo
+
+	for _, src := range sources {
+		base := strings.TrimSuffix(filepath.Base(src.Path), filepath.Ext(src.Path))
+		fi := &fileInfo{source: src, parsed: syntax.Parse(src.Lines), base: base}
+		fi.collectScopes()
+		for _, s := range fi.scopes {
+			g.Units = append(g.Units, s.unit)
+		}
+		project[base] = true
+		files = append(files, fi)
+	}
+
+	external := make(map[string]bool)
+	seen := make(map[[2]string]bool)
+	for _, fi := range files {
+		for _, st := range fi.parsed.Statements {
+			s := fi.scopeAt(st.Pos.Line)
+			for _, name := range calledNames(st.Text, s.vars) {
+				call := Call{From: s.unit.ID, Line: st.Pos.Line}
+				switch {
+				case s.local[name] != "":
+					call.To, call.Kind = s.local[name], "local"
+				case project[name]:
+					call.To, call.Kind = name, "project"
+				default:
+					call.To, call.Kind = name, KindExternal
+					if !external[name] {
+						external[name] = true
+						g.Units = append(g.Units, Unit{ID: name, Name: name, Kind: KindExternal})
+					}
+				}
+				key := [2]string{call.From, call.To}
+				if call.From == call.To || seen[key] {
+					continue
+				}
+				seen[key] = true
+				g.Calls = append(g.Calls, call)
+			}
+		}
+	}
+
+	sort.SliceStable(g.Units, func(i, j int) bool {
+		return g.Units[i].Kind != KindExternal && g.Units[j].Kind == KindExternal
+	})
+	return g
+}
+
+// collectScopes creates a scope for the file body and for every function.
+func (fi *fileInfo) collectScopes() {
+	kind := KindScript
+	if !fi.parsed.IsScript() {
+		kind = KindFunction
+		if fi.parsed.Nodes[0].Kind == syntax.KindClassdef {
+			kind = KindClass
+		}
+	}
+
+	local := make(map[string]string)
+	fi.scopes = []*scope{{
+		unit:  Unit{ID: fi.base, Name: fi.base, Kind: kind, File: fi.source.Path, Line: 1},
+		vars:  make(map[string]bool),
+		local: local,
+	}}
+
+	for i, fn := range fi.parsed.Functions() {
+		s := &scope{
+			unit:  Unit{ID: fi.base + ">" + fn.Name, Name: fn.Name, Kind: KindLocal, File: fi.source.Path, Line: fn.Start.Line},
+			node:  fn,
+			vars:  make(map[string]bool),
+			local: local,
+		}
+		if i == 0 && kind == KindFunction {
+			// The first function of a function file is the file itself.
+			s.unit.ID, s.unit.Kind = fi.base, KindFunction
+			fi.scopes[0] = s
+		} else {
+			fi.scopes = append(fi.scopes, s)
+		}
+		if _, ok := local[fn.Name]; !ok {
+			local[fn.Name] = s.unit.ID
+		}
+		for _, name := range fn.Signature.Inputs {
+			s.vars[name] = true
+		}
+		for _, name := range fn.Signature.Outputs {
+			s.vars[name] = true
+		}
+	}
+
+	for _, st := range fi.parsed.Statements {
+		s := fi.scopeAt(st.Pos.Line)
+		for _, name := range assignedNames(st.Text) {
+			s.vars[name] = true
+		}
+	}
+}
+
+// scopeAt returns the innermost function scope containing line, or the file
+// body scope.
+func (fi *fileInfo) scopeAt(line int) *scope {
+	best := fi.scopes[0]
+	for _, s := range fi.scopes {
+		if s.node == nil || line < s.node.Start.Line || line > s.node.End.Line {
+			continue
+		}
+		if best.node == nil || s.node.Start.Line > best.node.Start.Line || line < best.node.Start.Line || line > best.node.End.Line {
+			best = s
+		}
+	}
+	return best
+}
+
+// assignedNames returns the variables a statement defines: the targets of an
+// assignment, a for loop variable and global or persistent declarations.
+func assignedNames(text string) []string {
+	word := syntax.FirstWord(text)
+	switch word {
+	case "global", "persistent":
+		return strings.Fields(text)[1:]
+	case "for", "parfor":
+		text = strings.TrimLeft(strings.TrimSpace(text[len(word):]), "(")
+	case "function":
+		return nil
+	}
+
+	eq := assignmentIndex(text)
+	if eq < 0 {
+		return nil
+	}
+	lhs := strings.TrimSpace(text[:eq])
+	if strings.HasPrefix(lhs, "[") {
+		var names []string
+		for _, target := range strings.FieldsFunc(strings.Trim(lhs, "[]"), func(r rune) bool { return r == ',' || r == ' ' }) {
+			if name := syntax.FirstWord(target); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	if name := syntax.FirstWord(lhs); name != "" {
+		return []string{name}
+	}
+	return nil
+}
+
+// assignmentIndex returns the index of the top-level "=" of an assignment, or
+// -1 when the statement is not an assignment.
+func assignmentIndex(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '=':
+			if depth != 0 {
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '=' || i > 0 && strings.IndexByte("=~<>!", text[i-1]) >= 0 {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// calledNames returns the names a statement calls: identifiers followed by
+// an opening parenthesis, function handles and command-syntax calls, skipping
+// variables, keywords and field names.
+func calledNames(text string, vars map[string]bool) []string {
+	if syntax.FirstWord(text) == "function" {
+		return nil
+	}
+
+	var names []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		if !syntax.IsIdentChar(c) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(text) && syntax.IsIdentChar(text[i]) {
+			i++
+		}
+		name := text[start:i]
+		if !isLetter(name[0]) || keywords[name] || vars[name] {
+			continue
+		}
+		prev := strings.TrimRight(text[:start], " \t")
+		if strings.HasSuffix(prev, ".") {
+			continue
+		}
+		rest := strings.TrimLeft(text[i:], " \t")
+		switch {
+		case strings.HasSuffix(prev, "@"):
+			names = append(names, name)
+		case strings.HasPrefix(rest, "("):
+			names = append(names, name)
+		case start == 0 && isCommandSyntax(rest):
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isCommandSyntax reports whether rest, the text following the first word of
+// a statement, makes the statement a command-syntax call such as "hold on".
+func isCommandSyntax(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	return isLetter(rest[0]) || rest[0] == '\'' || rest[0] == '-'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,59 @@
+package deps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	sources := []Source{
+		{Path: "scripts/run_all.m", Lines: []string{
+			"clc",
+			"data = load_data('x.csv');",
+			"result = process(data);",
+			"plot(result)",
+		}},
+		{Path: "lib/process.m", Lines: []string{
+			"function out = process(in)",
+			"out = helper(in) + numel(in);",
+			"cb = @helper;",
+			"s.plot = 3;",
+			"out = s.plot + cb(out);",
+			"end",
+			"function y = helper(x)",
+			"y = sum(x);",
+			"end",
+		}},
+	}
+
+	g := Build(sources)
+
+	var units []string
+	for _, u := range g.Units {
+		units = append(units, u.ID+":"+u.Kind)
+	}
+	wantUnits := []string{
+		"run_all:script", "process:function", "process>helper:local",
+		"clc:external", "load_data:external", "plot:external", "numel:external", "sum:external",
+	}
+	if !reflect.DeepEqual(units, wantUnits) {
+		t.Errorf("unexpected units:\ngot  %v\nwant %v", units, wantUnits)
+	}
+
+	var calls []string
+	for _, c := range g.Calls {
+		calls = append(calls, c.From+"->"+c.To+":"+c.Kind)
+	}
+	wantCalls := []string{
+		"run_all->clc:external",
+		"run_all->load_data:external",
+		"run_all->process:project",
+		"run_all->plot:external",
+		"process->process>helper:local",
+		"process->numel:external",
+		"process>helper->sum:external",
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("unexpected calls:\ngot  %v\nwant %v", calls, wantCalls)
+	}
+}
@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// Class describes what kind of formatting a change represents. Classes are
+// ordered by significance.
+type Class int
+
+const (
+	// ClassIndentation changes only the leading whitespace of lines.
+	ClassIndentation Class = iota
+	// ClassSpacing changes whitespace between tokens, possibly together
+	// with indentation.
+	ClassSpacing
+	// ClassStructural changes tokens or the number of lines, for example by
+	// inserting blank lines or wrapping statements.
+	ClassStructural
+)
+
+var classNames = map[Class]string{
+	ClassIndentation: "indentation",
+	ClassSpacing:     "spacing",
+	ClassStructural:  "structural",
+}
+
+// ParseClass converts a class name as used on the command line.
+func ParseClass(name string) (Class, bool) {
+	for c, n := range classNames {
+		if n == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func (c Class) String() string {
+	return classNames[c]
+}
+
+// MarshalJSON encodes the class by name.
+func (c Class) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// classify compares the old and new lines of a change token by token.
+func classify(old, new []string) Class {
+	if len(old) != len(new) {
+		return ClassStructural
+	}
+	class := ClassIndentation
+	for i := range old {
+		if strings.TrimLeft(old[i], " \t") == strings.TrimLeft(new[i], " \t") {
+			continue
+		}
+		if tokens(old[i]) != tokens(new[i]) {
+			return ClassStructural
+		}
+		class = ClassSpacing
+	}
+	return class
+}
+
+// tokens returns line with the whitespace between tokens removed. Whitespace
+// inside strings and comments is kept.
+func tokens(line string) string {
+	code, comment := syntax.ScanLine(line)
+	var b strings.Builder
+	for i := 0; i < len(code); i++ {
+		if code[i] != ' ' && code[i] != '\t' {
+			b.WriteByte(line[i])
+		}
+	}
+	if comment >= 0 {
+		b.WriteString(strings.TrimSpace(line[comment:]))
+	}
+	return b.String()
+}
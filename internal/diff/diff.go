@@ -0,0 +1,389 @@
+// Package diff compares the lines of a file before and after formatting,
+// groups the differences into hunks and classifies each change by what kind
+// of formatting it represents.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OpKind is the kind of a line in an edit script.
+type OpKind int
+
+const (
+	// Equal marks a line present in both versions.
+	Equal OpKind = iota
+	// Delete marks a line only present in the old version.
+	Delete
+	// Insert marks a line only present in the new version.
+	Insert
+)
+
+// Op is a single line of an edit script.
+type Op struct {
+	Kind OpKind
+	Text string
+}
+
+// Change is a contiguous block of deleted and inserted lines. Starts are
+// 1-based; a block without old lines starts before line OldStart. Rules lists
+// the IDs of the formatting rules responsible for the change.
+type Change struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Class    Class    `json:"class"`
+	Rules    []string `json:"rules,omitempty"`
+}
+
+// Hunk is a group of nearby changes together with surrounding context lines,
+// as shown in a unified diff. Class is the most significant class of its
+// changes and Rules combines their rules.
+type Hunk struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Class    Class    `json:"class"`
+	Rules    []string `json:"rules,omitempty"`
+	Changes  []Change `json:"changes"`
+	Lines    []Op     `json:"-"`
+}
+
+// Lines computes a shortest edit script turning a into b using Myers'
+// algorithm.
+func Lines(a, b []string) []Op {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the script. trace[d] holds the
+	// furthest reaching paths with d-1 differences.
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, Op{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, Op{Insert, b[y]})
+		} else {
+			x--
+			ops = append(ops, Op{Delete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, Op{Equal, a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Changes returns the classified change blocks turning a into b.
+func Changes(a, b []string) []Change {
+	ops := Lines(a, b)
+	var changes []Change
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		c := Change{OldStart: oldLine, NewStart: newLine}
+		for ; i < len(ops) && ops[i].Kind != Equal; i++ {
+			if ops[i].Kind == Delete {
+				c.OldLines++
+			} else {
+				c.NewLines++
+			}
+		}
+		changes = append(changes, split(a, b, c)...)
+		oldLine += c.OldLines
+		newLine += c.NewLines
+	}
+	return changes
+}
+
+// split classifies the change block c. When the block only adds or removes
+// blank lines besides rewriting lines one for one, those parts become
+// separate changes so each can be classified and applied on its own.
+func split(a, b []string, c Change) []Change {
+	old := a[c.OldStart-1 : c.OldStart-1+c.OldLines]
+	new := b[c.NewStart-1 : c.NewStart-1+c.NewLines]
+	if nonBlank(old) != nonBlank(new) {
+		c.Class = classify(old, new)
+		c.Rules = rules(old, new)
+		return []Change{c}
+	}
+
+	var parts []Change
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		part := Change{OldStart: c.OldStart + i, NewStart: c.NewStart + j}
+		switch {
+		case i < len(old) && isBlank(old[i]):
+			for i < len(old) && isBlank(old[i]) {
+				i++
+				part.OldLines++
+			}
+		case j < len(new) && isBlank(new[j]):
+			for j < len(new) && isBlank(new[j]) {
+				j++
+				part.NewLines++
+			}
+		default:
+			part.OldLines, part.NewLines = 1, 1
+			i++
+			j++
+		}
+		part.Class = classify(old[i-part.OldLines:i], new[j-part.NewLines:j])
+		part.Rules = rules(old[i-part.OldLines:i], new[j-part.NewLines:j])
+		if n := len(parts); n > 0 && adjacent(parts[n-1], part) && parts[n-1].Class == part.Class && part.OldLines == part.NewLines && parts[n-1].OldLines == parts[n-1].NewLines {
+			parts[n-1].OldLines += part.OldLines
+			parts[n-1].NewLines += part.NewLines
+			parts[n-1].Rules = mergeRules(parts[n-1].Rules, part.Rules)
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// adjacent reports whether next directly follows prev in both versions.
+func adjacent(prev, next Change) bool {
+	return prev.OldStart+prev.OldLines == next.OldStart && prev.NewStart+prev.NewLines == next.NewStart
+}
+
+func nonBlank(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if !isBlank(line) {
+			n++
+		}
+	}
+	return n
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// Hunks groups the changes turning a into b into hunks with the given number
+// of context lines.
+func Hunks(a, b []string, context int) []Hunk {
+	changes := Changes(a, b)
+	var hunks []Hunk
+	for i := 0; i < len(changes); {
+		first := changes[i]
+		// The context before the first change is the same in both versions.
+		lead := min(first.OldStart-1, context)
+		h := Hunk{OldStart: first.OldStart - lead, NewStart: first.NewStart - lead}
+
+		j := i
+		for j+1 < len(changes) && changes[j+1].OldStart-(changes[j].OldStart+changes[j].OldLines) <= 2*context {
+			j++
+		}
+		last := changes[j]
+		oldEnd := min(last.OldStart+last.OldLines-1+context, len(a))
+		newEnd := last.NewStart + last.NewLines - 1 + (oldEnd - (last.OldStart + last.OldLines - 1))
+
+		h.OldLines = oldEnd - h.OldStart + 1
+		h.NewLines = newEnd - h.NewStart + 1
+		h.Changes = append([]Change(nil), changes[i:j+1]...)
+		// Lines moving across blank lines split into unrelated changes, so
+		// the hunk as a whole is attributed as well.
+		h.Rules = rules(a[h.OldStart-1:oldEnd], b[h.NewStart-1:newEnd])
+		for _, c := range h.Changes {
+			if c.Class > h.Class {
+				h.Class = c.Class
+			}
+			h.Rules = mergeRules(h.Rules, c.Rules)
+		}
+
+		oldLine := h.OldStart
+		for _, c := range h.Changes {
+			for ; oldLine < c.OldStart; oldLine++ {
+				h.Lines = append(h.Lines, Op{Equal, a[oldLine-1]})
+			}
+			for k := 0; k < c.OldLines; k++ {
+				h.Lines = append(h.Lines, Op{Delete, a[c.OldStart-1+k]})
+			}
+			for k := 0; k < c.NewLines; k++ {
+				h.Lines = append(h.Lines, Op{Insert, b[c.NewStart-1+k]})
+			}
+			oldLine = c.OldStart + c.OldLines
+		}
+		for ; oldLine <= oldEnd; oldLine++ {
+			h.Lines = append(h.Lines, Op{Equal, a[oldLine-1]})
+		}
+
+		hunks = append(hunks, h)
+		i = j + 1
+	}
+	return hunks
+}
+
+// Apply returns a with the changes turning it into b applied only where keep
+// returns true.
+func Apply(a, b []string, keep func(Change) bool) []string {
+	var result []string
+	oldLine := 1
+	for _, c := range Changes(a, b) {
+		result = append(result, a[oldLine-1:c.OldStart-1]...)
+		if keep(c) {
+			result = append(result, b[c.NewStart-1:c.NewStart-1+c.NewLines]...)
+		} else {
+			result = append(result, a[c.OldStart-1:c.OldStart-1+c.OldLines]...)
+		}
+		oldLine = c.OldStart + c.OldLines
+	}
+	return append(result, a[oldLine-1:]...)
+}
+
+// WriteUnified writes the hunks as a unified diff between the files named
+// oldName and newName. The class and rules of each hunk follow its range
+// header.
+func WriteUnified(w io.Writer, oldName, newName string, hunks []Hunk) error {
+	if len(hunks) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName); err != nil {
+		return err
+	}
+	for _, h := range hunks {
+		note := h.Class.String()
+		if len(h.Rules) > 0 {
+			note += " (" + strings.Join(h.Rules, ", ") + ")"
+		}
+		if _, err := fmt.Fprintf(w, "@@ -%s +%s @@ %s\n", rangeSpec(h.OldStart, h.OldLines), rangeSpec(h.NewStart, h.NewLines), note); err != nil {
+			return err
+		}
+		for _, op := range h.Lines {
+			prefix := " "
+			switch op.Kind {
+			case Delete:
+				prefix = "-"
+			case Insert:
+				prefix = "+"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", prefix, op.Text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rangeSpec formats a hunk range. Empty ranges refer to the line before
+// start, as in GNU diff.
+func rangeSpec(start, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, lines)
+	}
+}
+
+// WritePatch writes the changes turning the content old of the file name into
+// new as the part of a multi-file patch in the format of git diff, which git
+// apply and patch -p1 apply. Unlike WriteUnified it compares the lines with
+// their line endings, marks a last line without one, and leaves the hunk
+// headers without notes. Nothing is written when the contents are equal.
+func WritePatch(w io.Writer, name string, old, new []byte) error {
+	hunks := Hunks(splitLinesKeepEnds(string(old)), splitLinesKeepEnds(string(new)), 3)
+	if len(hunks) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", name, name, name, name); err != nil {
+		return err
+	}
+	for _, h := range hunks {
+		if _, err := fmt.Fprintf(w, "@@ -%s +%s @@\n", rangeSpec(h.OldStart, h.OldLines), rangeSpec(h.NewStart, h.NewLines)); err != nil {
+			return err
+		}
+		for _, op := range h.Lines {
+			prefix := " "
+			switch op.Kind {
+			case Delete:
+				prefix = "-"
+			case Insert:
+				prefix = "+"
+			}
+			line, ended := strings.CutSuffix(op.Text, "\n")
+			if _, err := fmt.Fprintf(w, "%s%s\n", prefix, line); err != nil {
+				return err
+			}
+			if !ended {
+				if _, err := io.WriteString(w, "\\ No newline at end of file\n"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// splitLinesKeepEnds splits s after each newline, keeping the line endings.
+func splitLinesKeepEnds(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
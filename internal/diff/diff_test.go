@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+func TestLinesProducesMinimalScript(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+
+	ops := Lines(a, b)
+	var old, new []string
+	edits := 0
+	for _, op := range ops {
+		switch op.Kind {
+		case Equal:
+			old = append(old, op.Text)
+			new = append(new, op.Text)
+		case Delete:
+			old = append(old, op.Text)
+			edits++
+		case Insert:
+			new = append(new, op.Text)
+			edits++
+		}
+	}
+	if !reflect.DeepEqual(old, a) || !reflect.DeepEqual(new, b) {
+		t.Fatalf("script does not reproduce inputs: %+v", ops)
+	}
+	if edits != 5 {
+		t.Fatalf("expected 5 edits, got %d", edits)
+	}
+}
+
+func TestChangesAreClassified(t *testing.T) {
+	a := []string{
+		"if x",
+		"y=1;",
+		"end",
+		"s = 'a  b';",
+		"w = 0;",
+		"z = [1 2];",
+	}
+	b := []string{
+		"if x",
+		"    y = 1;",
+		"end",
+		"",
+		"s = 'a b';",
+		"w = 0;",
+		"    z = [1 2];",
+	}
+
+	var classes []Class
+	for _, c := range Changes(a, b) {
+		classes = append(classes, c.Class)
+	}
+	want := []Class{ClassSpacing, ClassStructural, ClassStructural, ClassIndentation}
+	if !reflect.DeepEqual(classes, want) {
+		t.Fatalf("unexpected classes: got %v want %v", classes, want)
+	}
+
+	kept := Apply(a, b, func(c Change) bool { return c.Class == ClassIndentation })
+	if !reflect.DeepEqual(kept, []string{"if x", "y=1;", "end", "s = 'a  b';", "w = 0;", "    z = [1 2];"}) {
+		t.Fatalf("unexpected partial application: %q", kept)
+	}
+}
+
+func TestWriteUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}
+	b := []string{"1", "2", "3", "four", "5", "6", "7", "8", "9", "10", "11", "12"}
+
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, "a/x.m", "b/x.m", Hunks(a, b, 3)); err != nil {
+		t.Fatalf("WriteUnified: %v", err)
+	}
+	want := strings.Join([]string{
+		"--- a/x.m",
+		"+++ b/x.m",
+		"@@ -1,7 +1,7 @@ structural",
+		" 1", " 2", " 3", "-4", "+four", " 5", " 6", " 7",
+		"@@ -9,3 +9,4 @@ structural",
+		" 9", " 10", " 11", "+12",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestChangesSplitBlankLines(t *testing.T) {
+	a := []string{"end", "z=2;", "w = 3;"}
+	b := []string{"end", "", "z = 2;", "    w = 3;"}
+
+	got := Changes(a, b)
+	want := []Change{
+		{OldStart: 2, OldLines: 0, NewStart: 2, NewLines: 1, Class: ClassStructural, Rules: []string{"block-separation"}},
+		{OldStart: 2, OldLines: 1, NewStart: 3, NewLines: 1, Class: ClassSpacing, Rules: []string{"operator-spacing"}},
+		{OldStart: 3, OldLines: 1, NewStart: 4, NewLines: 1, Class: ClassIndentation, Rules: []string{"indent"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	kept := Apply(a, b, func(c Change) bool { return c.Class != ClassStructural })
+	if !reflect.DeepEqual(kept, []string{"end", "z = 2;", "    w = 3;"}) {
+		t.Fatalf("unexpected partial application: %q", kept)
+	}
+}
+
+func TestHunkRules(t *testing.T) {
+	a := []string{"import b.*", "import a.*", "if x", "y=1;", "end", "z = 'a  b';"}
+	b := []string{"import a.*", "import b.*", "if x", "    y = 1;", "end", "", "z = 'a b';"}
+
+	hunks := Hunks(a, b, 3)
+	if len(hunks) != 1 {
+		t.Fatalf("expected one hunk, got %d", len(hunks))
+	}
+	want := []string{"indent", "operator-spacing", "block-separation", "sort-imports"}
+	if !reflect.DeepEqual(hunks[0].Rules, want) {
+		t.Fatalf("unexpected hunk rules: got %q want %q", hunks[0].Rules, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, "a/x.m", "b/x.m", hunks); err != nil {
+		t.Fatalf("WriteUnified: %v", err)
+	}
+	if header := strings.Split(buf.String(), "\n")[2]; !strings.HasSuffix(header, "@@ structural (indent, operator-spacing, block-separation, sort-imports)") {
+		t.Errorf("unexpected hunk header %q", header)
+	}
+}
+
+func TestWritePatch(t *testing.T) {
+	old := "a=1;\r\nb = 2;\r\nc = 3;"
+	new := "a = 1;\nb = 2;\nc = 3;\n"
+
+	var buf bytes.Buffer
+	if err := WritePatch(&buf, "src/x.m", []byte(old), []byte(new)); err != nil {
+		t.Fatalf("WritePatch: %v", err)
+	}
+	want := strings.Join([]string{
+		"diff --git a/src/x.m b/src/x.m",
+		"--- a/src/x.m",
+		"+++ b/src/x.m",
+		"@@ -1,3 +1,3 @@",
+		"-a=1;\r", "-b = 2;\r", "-c = 3;", `\ No newline at end of file`,
+		"+a = 1;", "+b = 2;", "+c = 3;",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("unexpected patch:\n%q\nwant:\n%q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WritePatch(&buf, "x.m", []byte(new), []byte(new)); err != nil || buf.Len() != 0 {
+		t.Fatalf("WritePatch of equal contents = %q, %v", buf.String(), err)
+	}
+}
+
+func TestTextEdits(t *testing.T) {
+	pos := func(line, column int) syntax.Pos { return syntax.Pos{Line: line, Column: column} }
+	tests := []struct {
+		old, new string
+		want     []TextEdit
+	}{
+		{
+			old:  "if x\ny=1;\nend\n",
+			new:  "if x\n    y = 1;\nend\n",
+			want: []TextEdit{{Start: pos(2, 1), End: pos(2, 3), NewText: "    y = "}},
+		},
+		{
+			// Lines are replaced as a whole when the count changes.
+			old:  "a=1;\nb=2;\n",
+			new:  "a = 1;\n\nb = 2;\n",
+			want: []TextEdit{{Start: pos(1, 2), End: pos(2, 3), NewText: " = 1;\n\nb = "}},
+		},
+		{
+			// A \r\n line ending is not split, and the end of a document
+			// without a final line ending is the end of its last line.
+			old:  "é=1;\r\nb",
+			new:  "é=1;\nb\n",
+			want: []TextEdit{{Start: pos(1, 6), End: pos(2, 1), NewText: "\n"}, {Start: pos(2, 2), End: pos(2, 2), NewText: "\n"}},
+		},
+		{old: "same\n", new: "same\n", want: []TextEdit{}},
+	}
+	for _, tt := range tests {
+		got := TextEdits(tt.old, tt.new)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TextEdits(%q, %q) = %+v, want %+v", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
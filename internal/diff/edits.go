@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// TextEdit replaces the text from Start up to End by NewText. Positions are
+// 1-based and columns count bytes; End is exclusive. The end of a document
+// ending with a line ending is column 1 of the line after its last.
+type TextEdit struct {
+	Start   syntax.Pos `json:"start"`
+	End     syntax.Pos `json:"end"`
+	NewText string     `json:"newText"`
+}
+
+// TextEdits returns the edits turning old into new, in document order. Line
+// endings are compared too. Each changed line replaced by one line yields an
+// edit of the differing part of the line only, so text around it, such as
+// the cursor of an editor, stays in place; other changes yield one edit of
+// the differing part of their lines.
+func TextEdits(old, new string) []TextEdit {
+	a, b := SplitLines(old), SplitLines(new)
+	starts := make([]int, len(a))
+	offset := 0
+	for i, line := range a {
+		starts[i] = offset
+		offset += len(line)
+	}
+	pos := func(offset int) syntax.Pos {
+		// The line containing offset is the last starting at or before it.
+		i := sort.SearchInts(starts, offset+1) - 1
+		if i < 0 {
+			return syntax.Pos{Line: 1, Column: 1}
+		}
+		if offset-starts[i] == len(a[i]) && isLineEnd(a[i]) {
+			return syntax.Pos{Line: i + 2, Column: 1}
+		}
+		return syntax.Pos{Line: i + 1, Column: offset - starts[i] + 1}
+	}
+	lineOffset := func(i int) int {
+		if i < len(starts) {
+			return starts[i]
+		}
+		return len(old)
+	}
+
+	edits := []TextEdit{}
+	edit := func(offset int, from, to string) {
+		p, s := commonAffixes(from, to)
+		edits = append(edits, TextEdit{
+			Start:   pos(offset + p),
+			End:     pos(offset + len(from) - s),
+			NewText: to[p : len(to)-s],
+		})
+	}
+	for _, c := range mergeAdjacent(Changes(a, b)) {
+		oldLines := a[c.OldStart-1 : c.OldStart-1+c.OldLines]
+		newLines := b[c.NewStart-1 : c.NewStart-1+c.NewLines]
+		if len(oldLines) == len(newLines) {
+			for i := range oldLines {
+				edit(lineOffset(c.OldStart-1+i), oldLines[i], newLines[i])
+			}
+			continue
+		}
+		edit(lineOffset(c.OldStart-1), strings.Join(oldLines, ""), strings.Join(newLines, ""))
+	}
+	return edits
+}
+
+// commonAffixes returns the lengths of the longest common prefix and suffix
+// of a and b, not overlapping and not splitting a character or a \r\n line
+// ending of a.
+func commonAffixes(a, b string) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for prefix > 0 && prefix < len(a) && (!utf8.RuneStart(a[prefix]) || a[prefix-1] == '\r' && a[prefix] == '\n') {
+		prefix--
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 {
+		i := len(a) - suffix
+		if utf8.RuneStart(a[i]) && !(i > 0 && a[i-1] == '\r' && a[i] == '\n') {
+			break
+		}
+		suffix--
+	}
+	return prefix, suffix
+}
+
+// mergeAdjacent joins the changes directly following each other, which
+// Changes keeps apart when they differ in class.
+func mergeAdjacent(changes []Change) []Change {
+	var merged []Change
+	for _, c := range changes {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			if prev.OldStart+prev.OldLines == c.OldStart && prev.NewStart+prev.NewLines == c.NewStart {
+				prev.OldLines += c.OldLines
+				prev.NewLines += c.NewLines
+				continue
+			}
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// SplitLines splits s after each line ending, \n, \r\n or \r, keeping the
+// endings.
+func SplitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		end := i + 1
+		if s[i] == '\r' && end < len(s) && s[end] == '\n' {
+			end++
+		}
+		lines = append(lines, s[:end])
+		s = s[end:]
+	}
+	return lines
+}
+
+func isLineEnd(line string) bool {
+	return strings.HasSuffix(line, "\n") || strings.HasSuffix(line, "\r")
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule IDs attributed to changes, in the order of the formatting rules.
+var ruleOrder = []string{"indent", "operator-spacing", "block-separation", "sort-imports"}
+
+var importStatement = regexp.MustCompile(`^\s*import\s`)
+
+// rules returns the IDs of the formatting rules responsible for turning the
+// old lines of a change into the new ones. Changes no rule explains, such as
+// edits within strings, have none.
+func rules(old, new []string) []string {
+	found := make(map[string]bool)
+	oldCode, newCode := codeLines(old), codeLines(new)
+	// Blank lines were inserted or removed, or moved between unchanged
+	// statements.
+	if len(old)-len(oldCode) != len(new)-len(newCode) || len(oldCode) == len(newCode) && !sameBlanks(old, new) {
+		found["block-separation"] = true
+	}
+
+	if len(oldCode) == len(newCode) {
+		for i := range oldCode {
+			o, n := oldCode[i], newCode[i]
+			if o == n {
+				continue
+			}
+			if leading(o) != leading(n) {
+				found["indent"] = true
+			}
+			switch {
+			case strings.TrimLeft(o, " \t") == strings.TrimLeft(n, " \t"):
+			case tokens(o) == tokens(n):
+				found["operator-spacing"] = true
+			case importStatement.MatchString(o) && importStatement.MatchString(n):
+				found["sort-imports"] = true
+			}
+		}
+	} else if allImports(oldCode) && allImports(newCode) {
+		found["sort-imports"] = true
+	}
+
+	var ids []string
+	for _, id := range ruleOrder {
+		if found[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// mergeRules adds the IDs of more to ids, keeping the rule order.
+func mergeRules(ids, more []string) []string {
+	found := make(map[string]bool)
+	for _, id := range append(ids, more...) {
+		found[id] = true
+	}
+	var merged []string
+	for _, id := range ruleOrder {
+		if found[id] {
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
+
+func codeLines(lines []string) []string {
+	var code []string
+	for _, line := range lines {
+		if !isBlank(line) {
+			code = append(code, line)
+		}
+	}
+	return code
+}
+
+// sameBlanks reports whether the blank lines of old and new are at the same
+// positions.
+func sameBlanks(old, new []string) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	for i := range old {
+		if isBlank(old[i]) != isBlank(new[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func allImports(lines []string) bool {
+	for _, line := range lines {
+		if !importStatement.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+func leading(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
@@ -0,0 +1,283 @@
+// Package editorconfig reads the EditorConfig properties that apply to a
+// file from the .editorconfig files of its directory and its parents.
+package editorconfig
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of EditorConfig files.
+const FileName = ".editorconfig"
+
+// Property is the value of a property and the place it was set.
+type Property struct {
+	Value string
+	// Path and Line locate the setting in an .editorconfig file.
+	Path string
+	Line int
+}
+
+// section is a [glob] section of an .editorconfig file.
+type section struct {
+	re         *regexp.Regexp
+	properties map[string]Property
+}
+
+// file is a parsed .editorconfig file.
+type file struct {
+	root     bool
+	sections []section
+}
+
+// Lookup returns the properties that apply to the file at name, keyed by
+// their lowercase names. The .editorconfig files are read from the directory
+// of name upwards until one declares root = true; nearer files and later
+// sections take precedence. Values are lowercased, as they are
+// case-insensitive, and properties set to "unset" are left out.
+func Lookup(name string) (map[string]Property, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	target := filepath.ToSlash(abs)
+
+	// Collect the files from the nearest one up to the root.
+	var dirs []string
+	var files []*file
+	for dir := filepath.Dir(abs); ; {
+		f, err := parseFile(filepath.Join(dir, FileName))
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			dirs = append(dirs, dir)
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	properties := make(map[string]Property)
+	for i := len(files) - 1; i >= 0; i-- {
+		rel := strings.TrimPrefix(target, strings.TrimSuffix(filepath.ToSlash(dirs[i]), "/")+"/")
+		for _, s := range files[i].sections {
+			if !s.re.MatchString(rel) {
+				continue
+			}
+			for k, p := range s.properties {
+				if p.Value == "unset" {
+					delete(properties, k)
+				} else {
+					properties[k] = p
+				}
+			}
+		}
+	}
+	return properties, nil
+}
+
+// parseFile reads the .editorconfig file at path. It returns nil when there
+// is none. Lines that are neither sections nor properties are ignored, as by
+// editors.
+func parseFile(path string) (*file, error) {
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	f := &file{}
+	var current *section
+	s := bufio.NewScanner(fh)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		switch {
+		case text == "" || text[0] == '#' || text[0] == ';':
+		case text[0] == '[' && strings.HasSuffix(text, "]"):
+			re, err := regexp.Compile(globRegexp(text[1 : len(text)-1]))
+			if err != nil {
+				current = nil
+				continue
+			}
+			f.sections = append(f.sections, section{re: re, properties: make(map[string]Property)})
+			current = &f.sections[len(f.sections)-1]
+		default:
+			key, value, ok := strings.Cut(text, "=")
+			if !ok {
+				continue
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.ToLower(strings.TrimSpace(value))
+			switch {
+			case current != nil:
+				current.properties[key] = Property{Value: value, Path: path, Line: line}
+			case key == "root":
+				f.root = value == "true"
+			}
+		}
+	}
+	return f, s.Err()
+}
+
+// numericRange matches the {num1..num2} form of globs.
+var numericRange = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// maxRange is the largest numeric range expanded into alternatives.
+const maxRange = 1000
+
+// globRegexp translates an EditorConfig glob into a regular expression
+// matching paths relative to the directory of its file. Globs without a
+// slash match the file name in any directory.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	switch {
+	case strings.HasPrefix(glob, "/"):
+		glob = glob[1:]
+	case !strings.Contains(glob, "/"):
+		b.WriteString("(?:.*/)?")
+	}
+	translateGlob(&b, glob)
+	b.WriteString("$")
+	return b.String()
+}
+
+func translateGlob(b *strings.Builder, glob string) {
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '{':
+			end := closingBrace(glob, i)
+			if end < 0 {
+				b.WriteString(`\{`)
+				continue
+			}
+			translateBraces(b, glob[i+1:end])
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+}
+
+// translateBraces translates the contents of a {...} group: a numeric range
+// or alternatives separated by commas. A group without a comma matches
+// itself literally, braces included.
+func translateBraces(b *strings.Builder, inner string) {
+	if m := numericRange.FindStringSubmatch(inner); m != nil {
+		lo, err1 := strconv.Atoi(m[1])
+		hi, err2 := strconv.Atoi(m[2])
+		if err1 == nil && err2 == nil {
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if hi-lo <= maxRange {
+				b.WriteString("(?:")
+				for n := lo; n <= hi; n++ {
+					if n > lo {
+						b.WriteString("|")
+					}
+					b.WriteString(regexp.QuoteMeta(strconv.Itoa(n)))
+				}
+				b.WriteString(")")
+				return
+			}
+		}
+		b.WriteString(`[+-]?\d+`)
+		return
+	}
+
+	parts := splitAlternatives(inner)
+	if len(parts) < 2 {
+		b.WriteString(`\{`)
+		translateGlob(b, inner)
+		b.WriteString(`\}`)
+		return
+	}
+	b.WriteString("(?:")
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteString("|")
+		}
+		translateGlob(b, p)
+	}
+	b.WriteString(")")
+}
+
+// closingBrace returns the index of the brace closing the one at glob[open],
+// or -1 when it is not closed.
+func closingBrace(glob string, open int) int {
+	depth := 0
+	for i := open; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits s at the commas outside nested braces.
+func splitAlternatives(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
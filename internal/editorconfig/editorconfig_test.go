@@ -0,0 +1,119 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLookup(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".editorconfig": `root = true
+
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = LF
+
+[*.{m,mlx}]
+indent_size = 2
+
+[lib/**.m]
+insert_final_newline = false
+`,
+		"lib/.editorconfig": `# no root, so the parent file applies too
+[gen/*.m]
+indent_style = tab
+indent_size = unset
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values := func(name string) map[string]string {
+		t.Helper()
+		props, err := Lookup(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := make(map[string]string)
+		for k, p := range props {
+			v[k] = p.Value
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		want map[string]string
+	}{
+		{"a.m", map[string]string{"indent_style": "space", "indent_size": "2", "end_of_line": "lf"}},
+		{"notes.txt", map[string]string{"indent_style": "space", "indent_size": "4", "end_of_line": "lf"}},
+		{"lib/x/f.m", map[string]string{"indent_style": "space", "indent_size": "2", "end_of_line": "lf", "insert_final_newline": "false"}},
+		{"lib/gen/f.m", map[string]string{"indent_style": "tab", "end_of_line": "lf", "insert_final_newline": "false"}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, values(tt.name)); diff != "" {
+			t.Errorf("Lookup(%s) mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+
+	props, err := Lookup(filepath.Join(root, "lib", "gen", "f.m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := props["indent_style"], (Property{Value: "tab", Path: filepath.Join(root, "lib", FileName), Line: 3}); got != want {
+		t.Errorf("indent_style = %+v, want %+v", got, want)
+	}
+}
+
+func TestGlobs(t *testing.T) {
+	tests := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{"*.m", "a/b/c.m", true},
+		{"*.m", "c.mlx", false},
+		{"/top.m", "top.m", true},
+		{"/top.m", "sub/top.m", false},
+		{"src/*.m", "src/a.m", true},
+		{"src/*.m", "src/x/a.m", false},
+		{"src/**.m", "src/x/a.m", true},
+		{"file?.m", "file1.m", true},
+		{"[abc].m", "b.m", true},
+		{"[!abc].m", "b.m", false},
+		{"*.{m,mlx}", "a.mlx", true},
+		{"*.{m,mlx}", "a.txt", false},
+		{"v{1..12}.m", "v12.m", true},
+		{"v{1..12}.m", "v13.m", false},
+		{"{single}.m", "{single}.m", true},
+		{`\*.m`, "*.m", true},
+	}
+	for _, tt := range tests {
+		re := globRegexp(tt.glob)
+		if got := regexpMatch(t, re, tt.path); got != tt.want {
+			t.Errorf("glob %q (%s): match(%q) = %v, want %v", tt.glob, re, tt.path, got, tt.want)
+		}
+	}
+}
+
+func regexpMatch(t *testing.T, re, s string) bool {
+	t.Helper()
+	r, err := regexp.Compile(re)
+	if err != nil {
+		t.Fatalf("compile %q: %v", re, err)
+	}
+	return r.MatchString(s)
+}
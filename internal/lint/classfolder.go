@@ -0,0 +1,146 @@
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// ClassFolderRule is the rule ID of the findings of CheckClassFolder.
+const ClassFolderRule = "class-folder"
+
+// methodDeclaration is a method signature in a methods block of a classdef,
+// declaring a method defined in a file of its own.
+type methodDeclaration struct {
+	sig *syntax.Signature
+	pos syntax.Pos
+}
+
+// CheckClassFolder compares the classdef file of the @ClassName folder dir
+// with the method files next to it. files maps the paths of the MATLAB files
+// of the folder to their lines. It reports method files without a declaration
+// in the classdef, methods defined both in the classdef and in a file of
+// their own, method files whose signature differs from the declaration, and
+// declarations without a method file. The findings are grouped by path.
+// Folders without a classdef file, as used by old-style classes, have none.
+func CheckClassFolder(dir string, files map[string][]string, opts Options) map[string][]Finding {
+	severity := opts.severity(ClassFolderRule, SeverityWarning)
+	name := strings.TrimPrefix(filepath.Base(dir), "@")
+	classPath := filepath.Join(dir, name+".m")
+	classLines, ok := files[classPath]
+	if severity == SeverityOff || !ok {
+		return nil
+	}
+	parsed := syntax.Parse(classLines)
+	if len(parsed.Nodes) == 0 || parsed.Nodes[0].Kind != syntax.KindClassdef {
+		return nil
+	}
+	declared, defined := classMethods(parsed)
+
+	findings := make(map[string][]Finding)
+	report := func(path string, pos syntax.Pos, format string, args ...any) {
+		findings[path] = append(findings[path], Finding{
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Rule:     ClassFolderRule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hasFile := make(map[string]bool)
+	for _, path := range paths {
+		if path == classPath {
+			continue
+		}
+		method := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		nodes := syntax.Parse(files[path]).Nodes
+		if len(nodes) == 0 || nodes[0].Kind != syntax.KindFunction || nodes[0].Name != method {
+			continue
+		}
+		n := nodes[0]
+		hasFile[method] = true
+		decl, ok := declared[method]
+		switch {
+		case defined[method]:
+			report(path, n.Start, "method %s is also defined in the classdef of %s", method, name)
+		case !ok:
+			report(path, n.Start, "method %s is not declared in the classdef of %s", method, name)
+		case !slices.Equal(decl.sig.Inputs, n.Signature.Inputs) || !slices.Equal(decl.sig.Outputs, n.Signature.Outputs):
+			report(path, n.Start, "signature %s differs from the declaration %s at %s:%d", signature(n.Signature), signature(decl.sig), filepath.Base(classPath), decl.pos.Line)
+		}
+	}
+
+	methods := make([]string, 0, len(declared))
+	for method := range declared {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		if !hasFile[method] && !defined[method] {
+			report(classPath, declared[method].pos, "method %s is declared but has no method file", method)
+		}
+	}
+	return findings
+}
+
+// classMethods returns the method declarations of the methods blocks of a
+// classdef and the names of the methods it defines.
+func classMethods(parsed *syntax.File) (declared map[string]methodDeclaration, defined map[string]bool) {
+	declared = make(map[string]methodDeclaration)
+	defined = make(map[string]bool)
+	for _, block := range parsed.Nodes[0].Children {
+		if block.Keyword != "methods" {
+			continue
+		}
+		for _, fn := range block.Children {
+			if fn.Kind == syntax.KindFunction {
+				defined[fn.Name] = true
+			}
+		}
+		for _, s := range parsed.Statements {
+			if s.Pos.Line <= block.Start.Line || s.Pos.Line >= block.End.Line || inFunction(block, s.Pos.Line) {
+				continue
+			}
+			if word := syntax.FirstWord(s.Text); word == "function" || word == "end" {
+				continue
+			}
+			if sig := syntax.ParseSignature(s.Text); sig.Name != "" {
+				declared[sig.Name] = methodDeclaration{sig: sig, pos: s.Pos}
+			}
+		}
+	}
+	return declared, defined
+}
+
+// inFunction reports whether line belongs to a function of block.
+func inFunction(block *syntax.Node, line int) bool {
+	for _, fn := range block.Children {
+		if fn.Kind == syntax.KindFunction && line >= fn.Start.Line && line <= fn.End.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// signature renders sig as "[a, b] = name(x, y)".
+func signature(sig *syntax.Signature) string {
+	s := sig.Name + "(" + strings.Join(sig.Inputs, ", ") + ")"
+	switch len(sig.Outputs) {
+	case 0:
+		return s
+	case 1:
+		return sig.Outputs[0] + " = " + s
+	}
+	return "[" + strings.Join(sig.Outputs, ", ") + "] = " + s
+}
@@ -0,0 +1,54 @@
+package lint
+
+import "regexp"
+
+// CustomRule is a user-defined rule reporting every match of Pattern in the
+// code of a line. Comments are removed and string contents masked before
+// matching, so patterns only see code.
+type CustomRule struct {
+	ID       string
+	Pattern  *regexp.Regexp
+	Message  string
+	Severity Severity
+	// Replacement, when set, is the fix for each match. It may refer to
+	// capture groups as in regexp.Regexp.Expand.
+	Replacement *string
+}
+
+func (r CustomRule) check(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip || l.code == "" {
+			continue
+		}
+		var matches [][]int
+		// Empty matches such as those of "^" would report every line.
+		for _, m := range r.Pattern.FindAllStringSubmatchIndex(l.code, -1) {
+			if m[0] != m[1] {
+				matches = append(matches, m)
+			}
+		}
+		for _, m := range matches {
+			finding := Finding{Line: i + 1, Column: m[0] + 1, Message: r.Message}
+			if r.Replacement != nil {
+				finding.Fix = replaceLine(i+1, replaceMatches(r, l, matches))
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// replaceMatches applies the replacement of r to every match on the line.
+// Matches are found in the masked code, but since masking keeps offsets the
+// replacement expands groups from the original text.
+func replaceMatches(r CustomRule, l line, matches [][]int) string {
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		out = append(out, l.text[last:m[0]]...)
+		out = r.Pattern.ExpandString(out, *r.Replacement, l.text, m)
+		last = m[1]
+	}
+	return string(append(out, l.text[last:]...))
+}
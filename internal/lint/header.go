@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// yearPlaceholder stands for a year or a year range in header templates.
+const yearPlaceholder = "{year}"
+
+var headerKeywords = regexp.MustCompile(`(?i)copyright|SPDX-License-Identifier|\(c\)`)
+
+// headerPattern converts a template line into a regexp. When the line
+// contains the year placeholder, the first and last year of the range are
+// captured.
+func headerPattern(template string) *regexp.Regexp {
+	parts := strings.Split(strings.TrimRight(template, " \t"), yearPlaceholder)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile(`^` + strings.Join(parts, `(\d{4})(?:\s*-\s*(\d{4}))?`) + `\s*$`)
+}
+
+// headerStart returns the 0-based index of the line the header belongs on,
+// which is after a shebang line.
+func headerStart(f *file) int {
+	if len(f.lines) > 0 && strings.HasPrefix(f.lines[0].text, "#!") {
+		return 1
+	}
+	return 0
+}
+
+func checkFileHeader(f *file) []Finding {
+	template := f.opts.HeaderTemplate
+	if len(template) == 0 {
+		return nil
+	}
+	year := f.opts.Year
+	if year == 0 {
+		year = time.Now().Year()
+	}
+	start := headerStart(f)
+
+	var findings []Finding
+	for i, t := range template {
+		n := start + i
+		var m []string
+		if n < len(f.lines) {
+			m = headerPattern(t).FindStringSubmatch(f.lines[n].text)
+		}
+		if m == nil {
+			return []Finding{missingHeader(f, start, year)}
+		}
+		if len(m) < 2 || !f.opts.HeaderUpdateYear {
+			continue
+		}
+
+		first, _ := strconv.Atoi(m[1])
+		last := first
+		if m[2] != "" {
+			last, _ = strconv.Atoi(m[2])
+		}
+		if last >= year {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    n + 1,
+			Column:  1,
+			Message: fmt.Sprintf("copyright year %d is out of date, expected range ending in %d", last, year),
+			Fix:     replaceLine(n+1, renderHeaderLine(t, fmt.Sprintf("%d-%d", first, year))),
+		})
+	}
+	return findings
+}
+
+// missingHeader reports a file lacking the configured header. The fix inserts
+// the header with the current year, separated from the following code or
+// help text by a blank line. A different header already at the top is not
+// replaced automatically.
+func missingHeader(f *file, start, year int) Finding {
+	finding := Finding{Line: start + 1, Column: 1, Message: "file does not start with the configured header"}
+	if start < len(f.lines) && f.lines[start].comment >= 0 && headerKeywords.MatchString(f.lines[start].text) {
+		finding.Message = "file header does not match the configured template"
+		return finding
+	}
+
+	var header []string
+	for _, t := range f.opts.HeaderTemplate {
+		header = append(header, renderHeaderLine(t, strconv.Itoa(year)))
+	}
+	if start < len(f.lines) && strings.TrimSpace(f.lines[start].text) != "" {
+		header = append(header, "")
+	}
+	finding.Fix = &formatter.Edit{StartLine: start + 1, EndLine: start, Lines: header}
+	return finding
+}
+
+func renderHeaderLine(template, year string) string {
+	return strings.TrimRight(strings.ReplaceAll(template, yearPlaceholder, year), " \t")
+}
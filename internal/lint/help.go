@@ -0,0 +1,103 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// h1Line matches the text of an H1 comment: the leading word and the rest,
+// separated by optional punctuation such as "-" or ":".
+var h1Line = regexp.MustCompile(`^%\s*([A-Za-z]\w*)(?:\s*[-:]\s*|\s+|$)(.*)$`)
+
+// helpTarget returns the declaration the help text of a function or class
+// file documents, or nil for scripts.
+func helpTarget(f *file) *syntax.Node {
+	if f.parsed.IsScript() || len(f.parsed.Nodes) == 0 {
+		return nil
+	}
+	return f.parsed.Nodes[0]
+}
+
+// h1Index returns the 0-based index of the first help line of n, or -1 when
+// the declaration is not followed by a comment. Blank lines between the
+// declaration and the comment are allowed.
+func h1Index(f *file, n *syntax.Node) int {
+	last := n.Start.Line
+	if s, ok := statementAt(f, n.Start); ok {
+		last = s.EndLine
+	}
+	for i := last; i < len(f.lines); i++ {
+		l := f.parsed.Lines[i]
+		switch {
+		case l.HasCode():
+			return -1
+		case l.HasComment():
+			return i
+		}
+	}
+	return -1
+}
+
+func checkMissingH1(f *file) []Finding {
+	n := helpTarget(f)
+	if n == nil || h1Index(f, n) >= 0 {
+		return nil
+	}
+	return []Finding{{
+		Line:    n.Start.Line,
+		Column:  n.Start.Column,
+		Message: fmt.Sprintf("%s %s has no H1 help line", n.Keyword, n.Name),
+	}}
+}
+
+// checkH1Format reports H1 lines not of the form "% NAME Summary", where NAME
+// is the upper-case function or class name. The fix normalizes the spacing
+// and capitalization and inserts a missing name.
+func checkH1Format(f *file) []Finding {
+	n := helpTarget(f)
+	if n == nil || n.Name == "" {
+		return nil
+	}
+	i := h1Index(f, n)
+	if i < 0 || f.parsed.Lines[i].BlockComment || f.lines[i].skip {
+		return nil
+	}
+	l := f.lines[i]
+	if strings.TrimSpace(l.code) != "" || strings.HasPrefix(l.text[l.comment:], "%%") {
+		return nil
+	}
+
+	comment := strings.TrimRight(l.text[l.comment:], " \t")
+	summary := strings.TrimSpace(strings.TrimLeft(comment, "%"))
+	if m := h1Line.FindStringSubmatch(comment); m != nil && strings.EqualFold(m[1], n.Name) {
+		summary = strings.TrimSpace(m[2])
+	}
+	if summary == "" {
+		return []Finding{{
+			Line:    i + 1,
+			Column:  l.comment + 1,
+			Message: "H1 help line lacks a one-line summary",
+		}}
+	}
+
+	want := "% " + strings.ToUpper(n.Name) + " " + capitalize(summary)
+	if comment == want {
+		return nil
+	}
+	return []Finding{{
+		Line:    i + 1,
+		Column:  l.comment + 1,
+		Message: fmt.Sprintf("H1 help line should read %q", want),
+		Fix:     replaceLine(i+1, l.text[:l.comment]+want),
+	}}
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
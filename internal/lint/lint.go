@@ -0,0 +1,273 @@
+// Package lint reports non-formatting issues in MATLAB source and applies the
+// deterministic fixes some rules provide.
+package lint
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// Finding is a single issue reported by a rule. Line and Column are 1-based.
+// Fix is set when the rule knows how to repair the issue.
+type Finding struct {
+	Line     int
+	Column   int
+	Rule     string
+	Severity Severity
+	Message  string
+	Fix      *formatter.Edit
+}
+
+// Options configures a lint run. StartLine and EndLine restrict the reported
+// findings with the same semantics as the formatter options.
+type Options struct {
+	StartLine int
+	EndLine   int
+	// Severities overrides the default severity of rules by ID. Rules set to
+	// SeverityOff are not run.
+	Severities map[string]Severity
+	// Path is the name of the linted file. Rules comparing function names
+	// with the file name are skipped when it is empty or "-".
+	Path string
+	// LocalFunctionOrder is the order enforced by the local-function-order
+	// rule: "alphabetical" or "first-use".
+	LocalFunctionOrder string
+	// HeaderTemplate lists the lines every file must start with, after an
+	// optional shebang line. "{year}" matches a year or a year range. The
+	// file-header rule is skipped when it is empty.
+	HeaderTemplate []string
+	// HeaderUpdateYear makes file-header report year ranges ending before
+	// Year.
+	HeaderUpdateYear bool
+	// CustomRules are run after the built-in rules. Severities also applies
+	// to them.
+	CustomRules []CustomRule
+	// ExternalRules are rules implemented outside of this package, such as
+	// script rules. Severities also applies to them.
+	ExternalRules []ExternalRule
+	// TabWidth is the tab stop distance the mixed-indentation fix converts
+	// tabs with.
+	TabWidth int
+	// Year is the current year used by file-header, or 0 for the system
+	// clock.
+	Year int
+}
+
+// DefaultOptions returns options covering the whole file.
+func DefaultOptions() Options {
+	return Options{StartLine: 1, EndLine: 0, LocalFunctionOrder: "alphabetical", HeaderUpdateYear: true, TabWidth: 4}
+}
+
+// ExternalRule is a rule implemented outside of this package. Check receives
+// the file name from Options.Path, the lines and their parsed structure.
+type ExternalRule struct {
+	ID       string
+	Severity Severity
+	Check    func(path string, lines []string, parsed *syntax.File) ([]Finding, error)
+}
+
+// maxFixPasses bounds how often Fix re-runs the rules to resolve fixes that
+// touch the same line.
+const maxFixPasses = 10
+
+var (
+	blockCommentOpen  = regexp.MustCompile(`^\s*%\{\s*$`)
+	blockCommentClose = regexp.MustCompile(`^\s*%\}\s*$`)
+)
+
+// line holds the pre-computed view of a source line shared by all rules.
+type line struct {
+	text string
+	// code is the line without its comment, with string contents masked.
+	code string
+	// comment is the byte offset of the comment marker or -1.
+	comment int
+	// skip is set for lines that rules must not inspect: lines inside block
+	// comments and lines covered by a formatter ignore directive.
+	skip bool
+	// continued is set when the previous line ended with "...".
+	continued bool
+	// depth is the bracket nesting at the start of the line.
+	depth int
+}
+
+type file struct {
+	lines  []line
+	parsed *syntax.File
+	opts   Options
+}
+
+func newFile(lines []string, opts Options) *file {
+	ignored := formatter.IgnoredLines(lines)
+	f := &file{lines: make([]line, len(lines)), parsed: syntax.Parse(lines), opts: opts}
+
+	inBlock := false
+	continued := false
+	depth := 0
+	for i, text := range lines {
+		l := line{text: text, comment: -1, depth: depth, continued: continued}
+
+		switch {
+		case blockCommentOpen.MatchString(text):
+			inBlock = true
+			l.skip = true
+		case inBlock:
+			l.skip = true
+			if blockCommentClose.MatchString(text) {
+				inBlock = false
+			}
+		default:
+			l.code, l.comment = syntax.ScanLine(text)
+			l.skip = ignored[i]
+			continued = strings.HasSuffix(strings.TrimRight(l.code, " \t"), "...")
+			depth += syntax.BracketDelta(l.code)
+			if depth < 0 {
+				depth = 0
+			}
+		}
+
+		f.lines[i] = l
+	}
+
+	return f
+}
+
+// Run checks lines with every rule and returns the findings inside the
+// configured range, ordered by position.
+func Run(lines []string, opts Options) []Finding {
+	f := newFile(lines, opts)
+	start, end := lineRange(opts, len(lines))
+
+	var all []Finding
+	for _, r := range rules {
+		severity := opts.severity(r.ID, r.Severity)
+		if severity == SeverityOff {
+			continue
+		}
+		for _, finding := range r.check(f) {
+			finding.Rule = r.ID
+			finding.Severity = severity
+			all = append(all, finding)
+		}
+	}
+
+	for _, r := range opts.CustomRules {
+		severity := opts.severity(r.ID, r.Severity)
+		if severity == SeverityOff {
+			continue
+		}
+		for _, finding := range r.check(f) {
+			finding.Rule = r.ID
+			finding.Severity = severity
+			all = append(all, finding)
+		}
+	}
+
+	for _, r := range opts.ExternalRules {
+		severity := opts.severity(r.ID, r.Severity)
+		if severity == SeverityOff {
+			continue
+		}
+		findings, err := r.Check(opts.Path, lines, f.parsed)
+		if err != nil {
+			// A failing rule is reported instead of silently passing.
+			findings = []Finding{{Line: 1, Column: 1, Message: "rule failed: " + err.Error()}}
+			severity = SeverityError
+		}
+		for _, finding := range findings {
+			finding.Rule = r.ID
+			finding.Severity = severity
+			all = append(all, finding)
+		}
+	}
+
+	suppressions := parseSuppressions(f)
+	all = suppress(all, suppressions)
+	if severity := opts.severity(UnusedSuppression, SeverityWarning); severity != SeverityOff {
+		for _, finding := range unusedSuppressions(suppressions, opts) {
+			finding.Severity = severity
+			all = append(all, finding)
+		}
+	}
+
+	var findings []Finding
+	for _, finding := range all {
+		if finding.Line >= start && finding.Line <= end {
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings
+}
+
+// Fix applies every available fix inside the configured range and returns the
+// updated lines together with the findings that remain afterwards.
+func Fix(lines []string, opts Options) ([]string, []Finding, error) {
+	current := lines
+	for pass := 0; pass < maxFixPasses; pass++ {
+		var edits []formatter.Edit
+		for _, finding := range Run(current, opts) {
+			if finding.Fix != nil && !overlaps(edits, *finding.Fix) {
+				edits = append(edits, *finding.Fix)
+			}
+		}
+		if len(edits) == 0 {
+			break
+		}
+
+		next, err := formatter.ApplyEdits(current, edits)
+		if err != nil {
+			return nil, nil, err
+		}
+		current = next
+	}
+
+	return current, Run(current, opts), nil
+}
+
+// overlaps reports whether e touches a line already changed by edits. Such
+// fixes are deferred to the next pass of Fix.
+func overlaps(edits []formatter.Edit, e formatter.Edit) bool {
+	for _, other := range edits {
+		if e.StartLine <= other.EndLine && other.StartLine <= e.EndLine ||
+			e.StartLine == other.StartLine {
+			return true
+		}
+	}
+	return false
+}
+
+// severity returns the configured severity of a rule.
+func (o Options) severity(id string, fallback Severity) Severity {
+	if s, ok := o.Severities[id]; ok {
+		return s
+	}
+	return fallback
+}
+
+func lineRange(opts Options, n int) (int, int) {
+	start := opts.StartLine
+	if start < 1 {
+		start = 1
+	}
+	end := opts.EndLine
+	if end <= 0 || end > n {
+		end = n
+	}
+	return start, end
+}
+
+// replaceLine returns an edit substituting the text of the 1-based line n.
+func replaceLine(n int, text string) *formatter.Edit {
+	return &formatter.Edit{StartLine: n, EndLine: n, Lines: []string{text}}
+}
@@ -0,0 +1,558 @@
+package lint
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRunReportsFindings(t *testing.T) {
+	lines := []string{
+		"function y=foo(x)",
+		"%compute",
+		"y=x+1  % note",
+		"s = 'a=b';",
+		"z == 3",
+		"M = [1 2",
+		"3 4]",
+		"% formatter ignore 1",
+		"q = 1",
+		"endfunction",
+	}
+
+	got := Run(lines, DefaultOptions())
+
+	type pos struct {
+		line, col int
+		rule      string
+	}
+	var positions []pos
+	for _, f := range got {
+		positions = append(positions, pos{f.Line, f.Column, f.Rule})
+	}
+
+	want := []pos{
+		{2, 1, "comment-space"},
+		{3, 6, "missing-semicolon"},
+		{10, 1, "octave-end"},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("unexpected findings: got %v want %v", positions, want)
+	}
+}
+
+func TestFixAppliesEditsWithinRange(t *testing.T) {
+	lines := []string{
+		"x = 1 %first",
+		"if x",
+		"y = 2 %second",
+		"endif",
+	}
+
+	opts := DefaultOptions()
+	opts.StartLine = 2
+
+	got, remaining, err := Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+
+	want := []string{
+		"x = 1 %first",
+		"if x",
+		"y = 2; % second",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fixed lines: got %#v want %#v", got, want)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("expected no remaining findings, got %v", remaining)
+	}
+}
+
+func TestSuppressionComments(t *testing.T) {
+	lines := []string{
+		"a = 1 % matlabformatter:disable-line missing-semicolon",
+		"% matlabformatter:disable-next-line",
+		"b = 2",
+		"% matlabformatter:disable missing-semicolon, octave-end -- legacy block",
+		"c = 3",
+		"if c",
+		"endif",
+		"% matlabformatter:enable",
+		"d = 4",
+		"e = 5; % matlabformatter:disable-line comment-space",
+	}
+
+	got := Run(lines, DefaultOptions())
+
+	type pos struct {
+		line int
+		rule string
+	}
+	var positions []pos
+	for _, f := range got {
+		positions = append(positions, pos{f.Line, f.Rule})
+	}
+
+	want := []pos{
+		{9, "missing-semicolon"},
+		{10, UnusedSuppression},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("unexpected findings: got %v want %v", positions, want)
+	}
+}
+
+func TestSeverityOverrides(t *testing.T) {
+	lines := []string{
+		"x = 1 %note",
+		"if x",
+		"endif",
+	}
+
+	opts := DefaultOptions()
+	opts.Severities = map[string]Severity{
+		"missing-semicolon": SeverityError,
+		"comment-space":     SeverityOff,
+	}
+
+	got := Run(lines, opts)
+	if len(got) != 2 {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+	if got[0].Rule != "missing-semicolon" || got[0].Severity != SeverityError {
+		t.Errorf("unexpected first finding: %+v", got[0])
+	}
+	if got[1].Rule != "octave-end" || got[1].Severity != SeverityWarning {
+		t.Errorf("unexpected second finding: %+v", got[1])
+	}
+}
+
+func TestFunctionOrderFixes(t *testing.T) {
+	lines := []string{
+		"% Copyright notice",
+		"function r = zeta(x)",
+		"r = x;",
+		"end",
+		"",
+		"function y = tool(x)",
+		"% TOOL main entry",
+		"y = zeta(x) + alpha(x);",
+		"end",
+		"",
+		"% Adds one.",
+		"function r = alpha(x)",
+		"r = x + 1;",
+		"end",
+	}
+
+	opts := DefaultOptions()
+	opts.Path = "lib/tool.m"
+	opts.Severities = map[string]Severity{"local-function-order": SeverityWarning}
+
+	findings := Run(lines, opts)
+	if len(findings) != 1 || findings[0].Rule != "main-function-first" || findings[0].Line != 2 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+
+	got, remaining, err := Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	want := []string{
+		"% Copyright notice",
+		"function y = tool(x)",
+		"% TOOL main entry",
+		"y = zeta(x) + alpha(x);",
+		"end",
+		"",
+		"% Adds one.",
+		"function r = alpha(x)",
+		"r = x + 1;",
+		"end",
+		"",
+		"function r = zeta(x)",
+		"r = x;",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fixed lines:\ngot  %#v\nwant %#v", got, want)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("expected no remaining findings, got %+v", remaining)
+	}
+
+	opts.LocalFunctionOrder = "first-use"
+	got, _, err = Fix(want, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if got[6] != "function r = zeta(x)" || got[10] != "% Adds one." || got[11] != "function r = alpha(x)" {
+		t.Fatalf("unexpected first-use order: %#v", got)
+	}
+}
+
+func TestScriptFunctionMix(t *testing.T) {
+	lines := []string{
+		"x = helper(1);",
+		"function y = helper(x)",
+		"y = x;",
+		"end",
+		"disp(x)",
+	}
+	findings := Run(lines, DefaultOptions())
+	var got []int
+	for _, f := range findings {
+		if f.Rule == "script-function-mix" {
+			got = append(got, f.Line)
+		}
+	}
+	if !reflect.DeepEqual(got, []int{2, 5}) {
+		t.Fatalf("unexpected script-function-mix lines: %v (%+v)", got, findings)
+	}
+
+	lines = []string{
+		"function main()",
+		"end",
+		"x = 1;",
+		"y = 2;",
+	}
+	findings = Run(lines, DefaultOptions())
+	if len(findings) != 1 || findings[0].Rule != "script-function-mix" || findings[0].Line != 3 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestFunctionEndFix(t *testing.T) {
+	lines := []string{
+		"function main()",
+		"if true",
+		"    helper(1, ...",
+		"        2);",
+		"end",
+		"",
+		"% Helper comment.",
+		"function helper(a, b)",
+		"disp(a + b);",
+		"end",
+	}
+	findings := Run(lines, DefaultOptions())
+	if len(findings) != 1 || findings[0].Rule != "function-end" || findings[0].Line != 1 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+
+	got, remaining, err := Fix(lines, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	want := []string{
+		"function main()",
+		"if true",
+		"    helper(1, ...",
+		"        2);",
+		"end",
+		"end",
+		"",
+		"% Helper comment.",
+		"function helper(a, b)",
+		"disp(a + b);",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fixed lines:\ngot  %#v\nwant %#v", got, want)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("expected no remaining findings, got %+v", remaining)
+	}
+}
+
+func TestSwitchChecks(t *testing.T) {
+	lines := []string{
+		"switch mode",
+		"    case 'a b'",
+		"        x = 1;",
+		"    case {'c', 'a b '}",
+		"        x = 5;",
+		"    case { 'c' }",
+		"        x = 2;",
+		"    case  'a b'",
+		"    case 3 % nothing to do",
+		"    otherwise",
+		"        x = 0;",
+		"    case 4, x = 4;",
+		"end",
+	}
+
+	type pos struct {
+		line int
+		rule string
+	}
+	var got []pos
+	for _, f := range Run(lines, DefaultOptions()) {
+		got = append(got, pos{f.Line, f.Rule})
+	}
+	want := []pos{
+		{6, "duplicate-case"},
+		{8, "duplicate-case"},
+		{8, "empty-case"},
+		{10, "otherwise-not-last"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected findings: got %v want %v", got, want)
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	opts := DefaultOptions()
+	opts.HeaderTemplate = []string{"% Copyright {year} Example Corp.", "% SPDX-License-Identifier: MIT"}
+	opts.Year = 2024
+
+	lines := []string{
+		"#!/usr/bin/env octave",
+		"% RUN Runs the tool.",
+		"run_tool();",
+	}
+	got, remaining, err := Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	want := []string{
+		"#!/usr/bin/env octave",
+		"% Copyright 2024 Example Corp.",
+		"% SPDX-License-Identifier: MIT",
+		"",
+		"% RUN Runs the tool.",
+		"run_tool();",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fixed lines:\ngot  %#v\nwant %#v", got, want)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("expected no remaining findings, got %+v", remaining)
+	}
+
+	lines = []string{
+		"% Copyright 2019 - 2021 Example Corp.",
+		"% SPDX-License-Identifier: MIT",
+		"function run()",
+		"end",
+	}
+	got, _, err = Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if got[0] != "% Copyright 2019-2024 Example Corp." {
+		t.Fatalf("year range not updated: %#v", got)
+	}
+
+	opts.HeaderUpdateYear = false
+	if findings := Run(lines, opts); len(findings) != 0 {
+		t.Fatalf("unexpected findings without year updates: %+v", findings)
+	}
+
+	lines[0] = "% Copyright 2019 Other Corp."
+	findings := Run(lines, opts)
+	if len(findings) != 1 || findings[0].Rule != "file-header" || findings[0].Fix != nil {
+		t.Fatalf("expected unfixable header mismatch, got %+v", findings)
+	}
+}
+
+func TestH1HelpLine(t *testing.T) {
+	tests := []struct {
+		lines []string
+		rule  string
+		fixed string
+	}{
+		{[]string{"function y = area(r)", "y = pi * r^2;", "end"}, "missing-h1", ""},
+		{[]string{"function y = area(r)", "%area -  computes the area.", "y = pi * r^2;"}, "h1-format", "% AREA Computes the area."},
+		{[]string{"function y = area(r)", "", "    % Computes the area.", "y = pi * r^2;"}, "h1-format", "    % AREA Computes the area."},
+		{[]string{"classdef Shape", "    % SHAPE", "end"}, "h1-format", ""},
+		{[]string{"function y = area(r)", "% AREA Computes the area.", "y = pi * r^2;"}, "", ""},
+	}
+
+	opts := DefaultOptions()
+	opts.Severities = map[string]Severity{"missing-h1": SeverityWarning, "h1-format": SeverityInfo}
+	for _, tt := range tests {
+		findings := Run(tt.lines, opts)
+		var rules []string
+		for _, f := range findings {
+			if f.Rule != "comment-space" {
+				rules = append(rules, f.Rule)
+			}
+		}
+		if tt.rule == "" {
+			if len(rules) != 0 {
+				t.Errorf("%q: unexpected findings %v", tt.lines, rules)
+			}
+			continue
+		}
+		if len(rules) != 1 || rules[0] != tt.rule {
+			t.Errorf("%q: got %v want [%s]", tt.lines, rules, tt.rule)
+			continue
+		}
+		if tt.fixed == "" {
+			continue
+		}
+		got, _, err := Fix(tt.lines, opts)
+		if err != nil {
+			t.Fatalf("Fix: %v", err)
+		}
+		found := false
+		for _, l := range got {
+			found = found || l == tt.fixed
+		}
+		if !found {
+			t.Errorf("%q: fixed lines %q lack %q", tt.lines, got, tt.fixed)
+		}
+	}
+}
+
+func TestWildcardImport(t *testing.T) {
+	lines := []string{
+		"import pkg.sub.*",
+		"import pkg.fn;",
+		"% import other.*",
+	}
+	findings := Run(lines, DefaultOptions())
+	if len(findings) != 1 || findings[0].Rule != "wildcard-import" || findings[0].Line != 1 || findings[0].Column != 8 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestMixedIndentation(t *testing.T) {
+	lines := []string{
+		"if x",
+		"\t  y = 1;",
+		"\tz = 2;",
+		"end",
+	}
+	got, remaining, err := Fix(lines, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if got[1] != "      y = 1;" || got[2] != "\tz = 2;" || len(remaining) != 0 {
+		t.Fatalf("unexpected result: %#v %+v", got, remaining)
+	}
+}
+
+func TestTrailingWhitespace(t *testing.T) {
+	lines := []string{"x = 1;  ", "y = 2;", "% note\t", "% formatter ignore 1", "z = 3; "}
+	got, remaining, err := Fix(lines, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	want := []string{"x = 1;", "y = 2;", "% note", "% formatter ignore 1", "z = 3; "}
+	if !reflect.DeepEqual(got, want) || len(remaining) != 0 {
+		t.Fatalf("unexpected result: %#v %+v", got, remaining)
+	}
+}
+
+func TestBlockStructure(t *testing.T) {
+	lines := []string{
+		"if a",
+		"    x = 1;",
+		"else if b",
+		"    x = 2;",
+		"end",
+		"end",
+		"end",
+		"while c",
+	}
+	type pos struct {
+		line, col int
+		rule      string
+	}
+	var got []pos
+	for _, f := range Run(lines, DefaultOptions()) {
+		got = append(got, pos{f.Line, f.Column, f.Rule})
+	}
+	want := []pos{{3, 1, "else-if"}, {7, 1, "unbalanced-block"}, {8, 1, "unbalanced-block"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected findings: got %v want %v", got, want)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	replacement := "fprintf($1)"
+	opts := DefaultOptions()
+	opts.CustomRules = []CustomRule{
+		{ID: "no-eval", Pattern: regexp.MustCompile(`\beval\s*\(`), Message: "avoid eval", Severity: SeverityError},
+		{ID: "use-fprintf", Pattern: regexp.MustCompile(`\bdisp\((.*?)\)`), Message: "use fprintf", Severity: SeverityInfo, Replacement: &replacement},
+	}
+
+	lines := []string{
+		"eval('x = 1');",
+		"s = 'eval(1)'; % eval(2)",
+		"disp('a b'); disp(x);",
+	}
+	findings := Run(lines, opts)
+	type pos struct {
+		line, col int
+		rule      string
+	}
+	var got []pos
+	for _, f := range findings {
+		got = append(got, pos{f.Line, f.Column, f.Rule})
+	}
+	want := []pos{{1, 1, "no-eval"}, {3, 1, "use-fprintf"}, {3, 14, "use-fprintf"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected findings: got %v want %v", got, want)
+	}
+
+	fixed, _, err := Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if fixed[2] != "fprintf('a b'); fprintf(x);" {
+		t.Fatalf("unexpected fixed line: %q", fixed[2])
+	}
+}
+
+func TestCheckClassFolder(t *testing.T) {
+	files := map[string][]string{
+		"@Foo/Foo.m": {
+			"classdef Foo",
+			"    methods",
+			"        r = bar(obj, x)",
+			"        baz(obj)",
+			"        function obj = Foo()",
+			"        end",
+			"    end",
+			"end",
+		},
+		"@Foo/bar.m":  {"function r = bar(obj, x, y)", "r = x;", "end"},
+		"@Foo/qux.m":  {"function qux(obj)", "end"},
+		"@Foo/Foo2.m": {"function obj = Foo()", "end"},
+	}
+	got := CheckClassFolder("@Foo", files, DefaultOptions())
+
+	messages := map[string][]string{}
+	for path, findings := range got {
+		for _, f := range findings {
+			if f.Rule != ClassFolderRule {
+				t.Errorf("unexpected rule %q", f.Rule)
+			}
+			messages[path] = append(messages[path], f.Message)
+		}
+	}
+	want := map[string][]string{
+		"@Foo/Foo.m": {"method baz is declared but has no method file"},
+		"@Foo/bar.m": {"signature r = bar(obj, x, y) differs from the declaration r = bar(obj, x) at Foo.m:3"},
+		"@Foo/qux.m": {"method qux is not declared in the classdef of Foo"},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Fatalf("unexpected findings:\n got %q\nwant %q", messages, want)
+	}
+
+	if got := Run(files["@Foo/Foo.m"], DefaultOptions()); len(got) != 0 {
+		t.Fatalf("method declarations reported: %+v", got)
+	}
+
+	opts := DefaultOptions()
+	opts.Severities = map[string]Severity{ClassFolderRule: SeverityOff}
+	if got := CheckClassFolder("@Foo", files, opts); got != nil {
+		t.Fatalf("disabled rule reported %+v", got)
+	}
+}
@@ -0,0 +1,228 @@
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// chunk is a top-level function together with the comment lines directly
+// above it, which move with the function when functions are reordered. start
+// and end are 1-based, inclusive lines.
+type chunk struct {
+	node  *syntax.Node
+	start int
+	end   int
+}
+
+// functionChunks returns the top-level functions of a function file or
+// script. It returns nil for classdef files and files with unbalanced blocks,
+// whose function boundaries are unreliable.
+func functionChunks(f *file) []chunk {
+	if len(f.parsed.Errors) > 0 {
+		return nil
+	}
+
+	var chunks []chunk
+	for _, n := range f.parsed.Nodes {
+		if n.Kind == syntax.KindClassdef {
+			return nil
+		}
+		if n.Kind != syntax.KindFunction {
+			continue
+		}
+		c := chunk{node: n, start: n.Start.Line, end: n.End.Line}
+		// Comments above the first function belong to the file.
+		if len(chunks) > 0 {
+			for c.start > 1 && isCommentOnly(f.lines[c.start-2]) {
+				c.start--
+			}
+		}
+		chunks = append(chunks, c)
+	}
+
+	for i := range chunks {
+		if i+1 < len(chunks) && chunks[i].end >= chunks[i+1].start {
+			chunks[i].end = chunks[i+1].start - 1
+		}
+		for chunks[i].end > chunks[i].start && strings.TrimSpace(f.lines[chunks[i].end-1].text) == "" {
+			chunks[i].end--
+		}
+	}
+	return chunks
+}
+
+func isCommentOnly(l line) bool {
+	return strings.TrimSpace(l.code) == "" && (l.comment >= 0 || l.skip && strings.TrimSpace(l.text) != "")
+}
+
+// reorder returns an edit placing the chunks in the given order. The lines
+// between chunks stay where they are so blank-line separation is preserved.
+func reorder(f *file, chunks []chunk, order []int) *formatter.Edit {
+	var out []string
+	for pos, idx := range order {
+		c := chunks[idx]
+		for n := c.start; n <= c.end; n++ {
+			out = append(out, f.lines[n-1].text)
+		}
+		if pos+1 < len(chunks) {
+			for n := chunks[pos].end + 1; n < chunks[pos+1].start; n++ {
+				out = append(out, f.lines[n-1].text)
+			}
+		}
+	}
+	return &formatter.Edit{StartLine: chunks[0].start, EndLine: chunks[len(chunks)-1].end, Lines: out}
+}
+
+func checkMainFunctionFirst(f *file) []Finding {
+	if f.opts.Path == "" || f.opts.Path == "-" || f.parsed.IsScript() {
+		return nil
+	}
+	chunks := functionChunks(f)
+	base := strings.TrimSuffix(filepath.Base(f.opts.Path), filepath.Ext(f.opts.Path))
+
+	for k, c := range chunks {
+		if c.node.Name != base {
+			continue
+		}
+		if k == 0 {
+			return nil
+		}
+		order := []int{k}
+		for i := range chunks {
+			if i != k {
+				order = append(order, i)
+			}
+		}
+		return []Finding{{
+			Line:    chunks[0].node.Start.Line,
+			Column:  chunks[0].node.Start.Column,
+			Message: fmt.Sprintf("main function %s should be the first function in the file", base),
+			Fix:     reorder(f, chunks, order),
+		}}
+	}
+	return nil
+}
+
+func checkLocalFunctionOrder(f *file) []Finding {
+	chunks := functionChunks(f)
+	// The first function of a function file is the main function and stays
+	// in place; every function of a script is a local function.
+	first := 0
+	if !f.parsed.IsScript() {
+		first = 1
+	}
+	if len(chunks)-first < 2 || first == 1 && len(checkMainFunctionFirst(f)) > 0 {
+		// Wait until main-function-first has moved the main function up.
+		return nil
+	}
+
+	local := make([]int, 0, len(chunks)-first)
+	for i := first; i < len(chunks); i++ {
+		local = append(local, i)
+	}
+
+	var desired []int
+	if f.opts.LocalFunctionOrder == "first-use" {
+		desired = firstUseOrder(f, chunks, local, first == 0)
+	} else {
+		desired = append([]int{}, local...)
+		sort.SliceStable(desired, func(i, j int) bool {
+			return strings.ToLower(chunks[desired[i]].node.Name) < strings.ToLower(chunks[desired[j]].node.Name)
+		})
+	}
+
+	for pos, idx := range desired {
+		if idx == local[pos] {
+			continue
+		}
+		var order []int
+		for i := 0; i < first; i++ {
+			order = append(order, i)
+		}
+		order = append(order, desired...)
+
+		misplaced := chunks[local[pos]].node
+		return []Finding{{
+			Line:   misplaced.Start.Line,
+			Column: misplaced.Start.Column,
+			Message: fmt.Sprintf("local function %s should come before %s (%s order)",
+				chunks[idx].node.Name, misplaced.Name, f.opts.LocalFunctionOrder),
+			Fix: reorder(f, chunks, order),
+		}}
+	}
+	return nil
+}
+
+// firstUseOrder orders local functions by their first reference, reading the
+// main function (or the script body) first and then each local function in
+// the order it was reached. Unreferenced functions keep their relative order
+// at the end.
+func firstUseOrder(f *file, chunks []chunk, local []int, script bool) []int {
+	byName := make(map[string]int, len(local))
+	for _, idx := range local {
+		if _, ok := byName[chunks[idx].node.Name]; !ok {
+			byName[chunks[idx].node.Name] = idx
+		}
+	}
+
+	var order []int
+	placed := make(map[int]bool)
+	scan := func(from, to int) {
+		for _, s := range f.parsed.Statements {
+			if s.Pos.Line < from || s.Pos.Line > to {
+				continue
+			}
+			for _, name := range identifiers(s.Text) {
+				if idx, ok := byName[name]; ok && !placed[idx] {
+					placed[idx] = true
+					order = append(order, idx)
+				}
+			}
+		}
+	}
+
+	if script {
+		scan(1, chunks[0].start-1)
+	} else {
+		scan(chunks[0].start, chunks[0].end)
+	}
+	for i := 0; i < len(order); i++ {
+		scan(chunks[order[i]].start, chunks[order[i]].end)
+	}
+	for _, idx := range local {
+		if !placed[idx] {
+			order = append(order, idx)
+		}
+	}
+	return order
+}
+
+// identifiers returns the identifiers of masked code in order of appearance,
+// skipping field names.
+func identifiers(code string) []string {
+	var names []string
+	for i := 0; i < len(code); {
+		if !syntax.IsIdentChar(code[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(code) && syntax.IsIdentChar(code[i]) {
+			i++
+		}
+		c := code[start]
+		if c >= '0' && c <= '9' || c == '_' {
+			continue
+		}
+		if strings.HasSuffix(strings.TrimRight(code[:start], " \t"), ".") {
+			continue
+		}
+		names = append(names, code[start:i])
+	}
+	return names
+}
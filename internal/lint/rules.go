@@ -0,0 +1,380 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// Rule describes a single lint check.
+type Rule struct {
+	ID          string
+	Description string
+	Fixable     bool
+	// Severity is used unless Options.Severities overrides it.
+	Severity Severity
+	// Options lists the settings of the rule.
+	Options []formatter.RuleOption
+
+	check func(f *file) []Finding
+}
+
+var rules = []Rule{
+	{
+		ID:          "missing-semicolon",
+		Description: "Assignment statement is not terminated with a semicolon and will print its result",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		check:       checkMissingSemicolon,
+	},
+	{
+		ID:          "octave-end",
+		Description: "Octave-style block terminator such as endif or endfor instead of end",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		check:       checkOctaveEnd,
+	},
+	{
+		ID:          "comment-space",
+		Description: "Comment marker is not followed by a space",
+		Fixable:     true,
+		Severity:    SeverityInfo,
+		check:       checkCommentSpace,
+	},
+	{
+		ID:          "mixed-indentation",
+		Description: "Leading whitespace mixes tabs and spaces",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		Options:     []formatter.RuleOption{{Name: "tab-width", Type: "int", Default: 4}},
+		check:       checkMixedIndentation,
+	},
+	{
+		ID:          "trailing-whitespace",
+		Description: "Line ends with spaces or tabs",
+		Fixable:     true,
+		Severity:    SeverityInfo,
+		check:       checkTrailingWhitespace,
+	},
+	{
+		ID:          "unbalanced-block",
+		Description: "Block without its end, or end, branch or until without the block it belongs to",
+		Severity:    SeverityError,
+		check:       checkUnbalancedBlock,
+	},
+	{
+		ID:          "else-if",
+		Description: "else if opens an if block nested in the else branch instead of continuing with elseif",
+		Severity:    SeverityWarning,
+		check:       checkElseIf,
+	},
+	{
+		ID:          "main-function-first",
+		Description: "Function named after the file is not the first function of a function file",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		check:       checkMainFunctionFirst,
+	},
+	{
+		ID:          "local-function-order",
+		Description: "Local functions are not in the configured order (alphabetical or first-use); off by default",
+		Fixable:     true,
+		Severity:    SeverityOff,
+		Options: []formatter.RuleOption{
+			{Name: "localFunctionOrder", Type: "string", Default: "alphabetical", Values: []string{"alphabetical", "first-use"}},
+		},
+		check: checkLocalFunctionOrder,
+	},
+	{
+		ID:          "script-function-mix",
+		Description: "Script defines local functions, or top-level code appears outside of the functions",
+		Severity:    SeverityWarning,
+		check:       checkScriptFunctionMix,
+	},
+	{
+		ID:          "function-end",
+		Description: "Function lacks the terminating end that other functions of the file (or every local function of a script) have",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		check:       checkFunctionEnd,
+	},
+	{
+		ID:          "file-header",
+		Description: "File does not start with the configured header, or its copyright year is out of date",
+		Fixable:     true,
+		Severity:    SeverityWarning,
+		Options: []formatter.RuleOption{
+			{Name: "header.template", Type: "string", Default: ""},
+			{Name: "header.updateYear", Type: "bool", Default: true},
+		},
+		check: checkFileHeader,
+	},
+	{
+		ID:          "missing-h1",
+		Description: "Function or class file without an H1 help line after its declaration; off by default",
+		Severity:    SeverityOff,
+		check:       checkMissingH1,
+	},
+	{
+		ID:          "h1-format",
+		Description: "H1 help line does not read \"% NAME Summary\" with the upper-case name; off by default",
+		Fixable:     true,
+		Severity:    SeverityOff,
+		check:       checkH1Format,
+	},
+	{
+		ID:          "wildcard-import",
+		Description: "Import statement imports every member of a package with .*",
+		Severity:    SeverityInfo,
+		check:       checkWildcardImport,
+	},
+	{
+		ID:          "duplicate-case",
+		Description: "Case value already handled by an earlier case of the same switch",
+		Severity:    SeverityWarning,
+		check:       checkDuplicateCase,
+	},
+	{
+		ID:          "otherwise-not-last",
+		Description: "Otherwise branch followed by further case branches",
+		Severity:    SeverityError,
+		check:       checkOtherwiseLast,
+	},
+	{
+		ID:          "empty-case",
+		Description: "Switch branch without statements or an explaining comment",
+		Severity:    SeverityInfo,
+		check:       checkEmptyCase,
+	},
+}
+
+// Rules returns the available lint rules.
+func Rules() []Rule {
+	return append([]Rule{}, rules...)
+}
+
+// statementKeywords start lines that are never plain assignments even when
+// they contain "=".
+var statementKeywords = map[string]bool{
+	"if": true, "elseif": true, "while": true, "for": true, "parfor": true,
+	"switch": true, "case": true, "function": true, "classdef": true,
+	"properties": true, "methods": true, "events": true, "enumeration": true,
+	"arguments": true, "spmd": true, "until": true,
+}
+
+func checkMissingSemicolon(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip || l.continued || l.depth > 0 {
+			continue
+		}
+		code := strings.TrimRight(l.code, " \t")
+		if code == "" || strings.HasSuffix(code, ";") || strings.HasSuffix(code, "...") {
+			continue
+		}
+		if syntax.BracketDelta(code) != 0 || statementKeywords[syntax.FirstWord(code)] {
+			continue
+		}
+		if !isAssignment(lastStatement(code)) {
+			continue
+		}
+		if n := innermostNode(f.parsed, i+1); n != nil && n.Kind == syntax.KindBlock && n.Parent != nil && n.Parent.Kind == syntax.KindClassdef {
+			// Property defaults and method declarations print nothing.
+			continue
+		}
+
+		fixed := l.text[:len(code)] + ";" + l.text[len(code):]
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  len(code) + 1,
+			Message: "terminate assignment with a semicolon to suppress output",
+			Fix:     replaceLine(i+1, fixed),
+		})
+	}
+	return findings
+}
+
+// lastStatement returns the final statement of a line containing several
+// statements separated by top-level commas or semicolons.
+func lastStatement(code string) string {
+	depth := 0
+	start := 0
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',', ';':
+			if depth == 0 {
+				start = i + 1
+			}
+		}
+	}
+	return code[start:]
+}
+
+// isAssignment reports whether stmt contains a top-level "=" that is not part
+// of a comparison operator.
+func isAssignment(stmt string) bool {
+	depth := 0
+	for i := 0; i < len(stmt); i++ {
+		switch stmt[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '=':
+			if depth != 0 {
+				continue
+			}
+			if i+1 < len(stmt) && stmt[i+1] == '=' {
+				return false
+			}
+			if i > 0 && strings.IndexByte("=~<>!", stmt[i-1]) >= 0 {
+				return false
+			}
+			return syntax.FirstWord(stmt) != "" || strings.HasPrefix(strings.TrimSpace(stmt), "[")
+		}
+	}
+	return false
+}
+
+var octaveEnd = regexp.MustCompile(`^(\s*)(endif|endwhile|endfor|endfunction|endswitch|end_try_catch)\b`)
+
+func checkOctaveEnd(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip {
+			continue
+		}
+		m := octaveEnd.FindStringSubmatchIndex(l.code)
+		if m == nil {
+			continue
+		}
+		keyword := l.text[m[4]:m[5]]
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  m[4] + 1,
+			Message: "use end instead of " + keyword,
+			Fix:     replaceLine(i+1, l.text[:m[4]]+"end"+l.text[m[5]:]),
+		})
+	}
+	return findings
+}
+
+func checkCommentSpace(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip || l.comment < 0 || l.comment >= len(l.text) || l.text[l.comment] != '%' {
+			continue
+		}
+		rest := l.text[l.comment+1:]
+		if rest == "" || strings.IndexByte(" \t%{}#!", rest[0]) >= 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  l.comment + 1,
+			Message: "add a space after the comment marker",
+			Fix:     replaceLine(i+1, l.text[:l.comment+1]+" "+rest),
+		})
+	}
+	return findings
+}
+
+func checkMixedIndentation(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		indent := l.text[:len(l.text)-len(strings.TrimLeft(l.text, " \t"))]
+		if indent == "" || !strings.Contains(indent, "\t") || !strings.Contains(indent, " ") {
+			continue
+		}
+		finding := Finding{
+			Line:    i + 1,
+			Column:  1,
+			Message: "indentation mixes tabs and spaces",
+		}
+		if f.opts.TabWidth > 0 {
+			finding.Fix = replaceLine(i+1, formatter.ExpandIndent(l.text, f.opts.TabWidth))
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+func checkTrailingWhitespace(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		trimmed := strings.TrimRight(l.text, " \t")
+		if l.skip || len(trimmed) == len(l.text) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  len(trimmed) + 1,
+			Message: "remove trailing whitespace",
+			Fix:     replaceLine(i+1, trimmed),
+		})
+	}
+	return findings
+}
+
+func checkUnbalancedBlock(f *file) []Finding {
+	var findings []Finding
+	for _, e := range f.parsed.Errors {
+		if f.lines[e.Pos.Line-1].skip {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    e.Pos.Line,
+			Column:  e.Pos.Column,
+			Message: e.Message,
+		})
+	}
+	return findings
+}
+
+var elseIf = regexp.MustCompile(`^(\s*)else\s+if\b`)
+
+func checkElseIf(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip {
+			continue
+		}
+		m := elseIf.FindStringSubmatchIndex(l.code)
+		if m == nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  m[3] + 1,
+			Message: "else if nests an if block that needs its own end; use elseif",
+		})
+	}
+	return findings
+}
+
+var wildcardImport = regexp.MustCompile(`^\s*import\s+([\w.]+\.\*)`)
+
+func checkWildcardImport(f *file) []Finding {
+	var findings []Finding
+	for i, l := range f.lines {
+		if l.skip {
+			continue
+		}
+		m := wildcardImport.FindStringSubmatchIndex(l.code)
+		if m == nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:    i + 1,
+			Column:  m[2] + 1,
+			Message: "wildcard import of " + l.code[m[2]:m[3]] + " hides where names come from",
+		})
+	}
+	return findings
+}
@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// checkScriptFunctionMix reports local functions defined in scripts, which
+// MATLAB only accepts since R2016b, and top-level code that is not part of any
+// function: after the local functions of a script or after a terminated
+// function in a function file.
+func checkScriptFunctionMix(f *file) []Finding {
+	if len(f.parsed.Errors) > 0 || len(f.parsed.Functions()) == 0 {
+		return nil
+	}
+
+	var findings []Finding
+	script := f.parsed.IsScript()
+	if script {
+		for _, n := range f.parsed.Nodes {
+			if n.Kind == syntax.KindFunction {
+				findings = append(findings, Finding{
+					Line:    n.Start.Line,
+					Column:  n.Start.Column,
+					Message: "script defines local functions, which requires R2016b or later",
+				})
+				break
+			}
+		}
+	}
+
+	seenFunction := false
+	inGap := false
+	for _, s := range f.parsed.Statements {
+		n := topLevelNode(f.parsed, s.Pos.Line)
+		if n != nil {
+			seenFunction = seenFunction || n.Kind == syntax.KindFunction
+			inGap = false
+			continue
+		}
+		if !seenFunction || inGap {
+			continue
+		}
+		inGap = true
+		message := "code outside of a function in a function file"
+		if script {
+			message = "script code after local function definitions"
+		}
+		findings = append(findings, Finding{Line: s.Pos.Line, Column: s.Pos.Column, Message: message})
+	}
+	return findings
+}
+
+// topLevelNode returns the top-level node spanning line, if any.
+func topLevelNode(parsed *syntax.File, line int) *syntax.Node {
+	for _, n := range parsed.Nodes {
+		if line >= n.Start.Line && line <= n.End.Line {
+			return n
+		}
+	}
+	return nil
+}
+
+// innermostNode returns the innermost node spanning line, if any.
+func innermostNode(parsed *syntax.File, line int) *syntax.Node {
+	var inner *syntax.Node
+	nodes := parsed.Nodes
+	for {
+		var next *syntax.Node
+		for _, n := range nodes {
+			if line >= n.Start.Line && line <= n.End.Line {
+				next = n
+				break
+			}
+		}
+		if next == nil {
+			return inner
+		}
+		inner, nodes = next, next.Children
+	}
+}
+
+// checkFunctionEnd reports functions without a terminating end in files where
+// other functions have one, and in scripts, whose local functions always need
+// one. The fix inserts end after the last code line of the function.
+func checkFunctionEnd(f *file) []Finding {
+	if len(f.parsed.Errors) > 0 {
+		return nil
+	}
+
+	var closed, open []*syntax.Node
+	for _, n := range f.parsed.Nodes {
+		if n.Kind != syntax.KindFunction {
+			continue
+		}
+		if n.Closed {
+			closed = append(closed, n)
+		} else {
+			open = append(open, n)
+		}
+	}
+	script := f.parsed.IsScript()
+	if len(open) == 0 || len(closed) == 0 && !script {
+		return nil
+	}
+
+	message := "function %s is not terminated with end while other functions are"
+	if script {
+		message = "local function %s of a script is not terminated with end"
+	}
+
+	findings := make([]Finding, 0, len(open))
+	for _, n := range open {
+		last := n.Start.Line
+		for i := n.End.Line; i > n.Start.Line; i-- {
+			if f.parsed.Lines[i-1].HasCode() {
+				last = i
+				break
+			}
+		}
+		// A statement continued over several lines ends on a later line.
+		for _, s := range f.parsed.Statements {
+			if s.Pos.Line <= last && s.EndLine > last {
+				last = s.EndLine
+			}
+		}
+
+		text := f.lines[n.Start.Line-1].text
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		findings = append(findings, Finding{
+			Line:    n.Start.Line,
+			Column:  n.Start.Column,
+			Message: fmt.Sprintf(message, n.Name),
+			Fix:     &formatter.Edit{StartLine: last + 1, EndLine: last, Lines: []string{indent + "end"}},
+		})
+	}
+	return findings
+}
@@ -0,0 +1,144 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UnusedSuppression is the rule ID of the meta-finding reported for
+// suppression comments that did not silence anything.
+const UnusedSuppression = "unused-suppression"
+
+var suppressionDirective = regexp.MustCompile(`^%\s*matlabformatter:(disable-next-line|disable-line|disable|enable)\b\s*(.*)$`)
+
+// suppression silences one rule, or all rules when rule is empty, on the
+// lines from..to (1-based, inclusive).
+type suppression struct {
+	line   int
+	column int
+	rule   string
+	from   int
+	to     int
+	used   bool
+}
+
+// parseSuppressions collects the suppression directives found in comments.
+// Block suppressions opened with "disable" extend until a matching "enable"
+// or the end of the file.
+func parseSuppressions(f *file) []*suppression {
+	var (
+		result []*suppression
+		open   []*suppression
+	)
+	n := len(f.lines)
+
+	for i, l := range f.lines {
+		if l.comment < 0 || l.comment >= len(l.text) {
+			continue
+		}
+		m := suppressionDirective.FindStringSubmatch(l.text[l.comment:])
+		if m == nil {
+			continue
+		}
+		lineNo := i + 1
+		ids := suppressionRules(m[2])
+
+		if m[1] == "enable" {
+			remaining := open[:0]
+			for _, s := range open {
+				if len(ids) == 0 || containsRule(ids, s.rule) {
+					s.to = lineNo
+					continue
+				}
+				remaining = append(remaining, s)
+			}
+			open = remaining
+			continue
+		}
+
+		if len(ids) == 0 {
+			ids = []string{""}
+		}
+		for _, id := range ids {
+			s := &suppression{line: lineNo, column: l.comment + 1, rule: id}
+			switch m[1] {
+			case "disable-line":
+				s.from, s.to = lineNo, lineNo
+			case "disable-next-line":
+				s.from, s.to = lineNo+1, lineNo+1
+			case "disable":
+				s.from, s.to = lineNo, n
+				open = append(open, s)
+			}
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+// suppressionRules splits the rule list of a directive. Rule IDs may be
+// separated by commas or whitespace; anything after "--" is a free-form
+// justification.
+func suppressionRules(list string) []string {
+	if idx := strings.Index(list, "--"); idx >= 0 {
+		list = list[:idx]
+	}
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func containsRule(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// suppress drops the findings silenced by suppressions and marks the
+// suppressions that were used.
+func suppress(findings []Finding, suppressions []*suppression) []Finding {
+	kept := findings[:0]
+	for _, finding := range findings {
+		silenced := false
+		for _, s := range suppressions {
+			if finding.Line < s.from || finding.Line > s.to {
+				continue
+			}
+			if s.rule != "" && s.rule != finding.Rule {
+				continue
+			}
+			s.used = true
+			silenced = true
+		}
+		if !silenced {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
+
+// unusedSuppressions reports the suppressions that did not silence any
+// finding. Suppressions of rules that are switched off are not reported.
+func unusedSuppressions(suppressions []*suppression, opts Options) []Finding {
+	var findings []Finding
+	for _, s := range suppressions {
+		if s.used || s.rule != "" && opts.severity(s.rule, SeverityWarning) == SeverityOff {
+			continue
+		}
+		message := "suppression comment does not silence any finding"
+		if s.rule != "" {
+			message = "suppression of " + s.rule + " does not silence any finding"
+		}
+		findings = append(findings, Finding{
+			Line:    s.line,
+			Column:  s.column,
+			Rule:    UnusedSuppression,
+			Message: message,
+		})
+	}
+	return findings
+}
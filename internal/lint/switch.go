@@ -0,0 +1,180 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// switchBlocks returns every switch block of the file in source order.
+func switchBlocks(f *file) []*syntax.Node {
+	var result []*syntax.Node
+	var walk func(nodes []*syntax.Node)
+	walk = func(nodes []*syntax.Node) {
+		for _, n := range nodes {
+			if n.Keyword == "switch" {
+				result = append(result, n)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(f.parsed.Nodes)
+	return result
+}
+
+// statementAt returns the statement starting at pos.
+func statementAt(f *file, pos syntax.Pos) (syntax.Statement, bool) {
+	for _, s := range f.parsed.Statements {
+		if s.Pos == pos {
+			return s, true
+		}
+	}
+	return syntax.Statement{}, false
+}
+
+// caseValues returns the values a case branch matches with whitespace
+// removed, expanding cell arrays of values. It returns nil for expressions
+// spanning several lines.
+func caseValues(f *file, pos syntax.Pos) []string {
+	s, ok := statementAt(f, pos)
+	if !ok || s.EndLine != s.Pos.Line {
+		return nil
+	}
+	// Strings are masked in the statement text, so take the expression from
+	// the source line, which has the same length.
+	text := f.lines[s.Pos.Line-1].text
+	start := s.Pos.Column - 1
+	expr := text[start+len("case") : start+len(s.Text)]
+	masked := s.Text[len("case"):]
+	trimmed := strings.TrimLeft(masked, " \t")
+	expr, masked = expr[len(masked)-len(trimmed):], trimmed
+	trimmed = strings.TrimRight(masked, " \t")
+	expr, masked = expr[:len(trimmed)], trimmed
+	if expr == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(masked, "{") && strings.HasSuffix(masked, "}") && syntax.BracketDelta(masked[1:len(masked)-1]) == 0 {
+		var values []string
+		inner, innerMasked := expr[1:len(expr)-1], masked[1:len(masked)-1]
+		from, depth := 0, 0
+		for i := 0; i <= len(innerMasked); i++ {
+			if i < len(innerMasked) {
+				switch innerMasked[i] {
+				case '(', '[', '{':
+					depth++
+				case ')', ']', '}':
+					depth--
+				}
+				if innerMasked[i] != ',' || depth != 0 {
+					continue
+				}
+			}
+			if v := compact(inner[from:i], innerMasked[from:i]); v != "" {
+				values = append(values, v)
+			}
+			from = i + 1
+		}
+		return values
+	}
+	return []string{compact(expr, masked)}
+}
+
+// compact removes spaces and tabs outside of strings so values differing only
+// in spacing compare equal. masked is s with string contents masked.
+func compact(s, masked string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if masked[i] != ' ' && masked[i] != '\t' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func checkDuplicateCase(f *file) []Finding {
+	var findings []Finding
+	for _, n := range switchBlocks(f) {
+		seen := make(map[string]int)
+		for _, b := range n.Branches {
+			if b.Keyword != "case" {
+				continue
+			}
+			for _, v := range caseValues(f, b.Pos) {
+				if first, ok := seen[v]; ok {
+					findings = append(findings, Finding{
+						Line:    b.Pos.Line,
+						Column:  b.Pos.Column,
+						Message: fmt.Sprintf("case value %s duplicates the case on line %d", v, first),
+					})
+					continue
+				}
+				seen[v] = b.Pos.Line
+			}
+		}
+	}
+	return findings
+}
+
+func checkOtherwiseLast(f *file) []Finding {
+	var findings []Finding
+	for _, n := range switchBlocks(f) {
+		for i, b := range n.Branches {
+			if b.Keyword == "otherwise" && i+1 < len(n.Branches) {
+				findings = append(findings, Finding{
+					Line:    b.Pos.Line,
+					Column:  b.Pos.Column,
+					Message: "otherwise must be the last branch of a switch block",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkEmptyCase reports branches of a switch block without any statement or
+// comment. A comment explaining why nothing happens makes the branch count as
+// intentional.
+func checkEmptyCase(f *file) []Finding {
+	var findings []Finding
+	for _, n := range switchBlocks(f) {
+		if !n.Closed {
+			continue
+		}
+		for i, b := range n.Branches {
+			next := n.End
+			if i+1 < len(n.Branches) {
+				next = n.Branches[i+1].Pos
+			}
+			head, ok := statementAt(f, b.Pos)
+			if !ok || !branchEmpty(f, head, next) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Line:    b.Pos.Line,
+				Column:  b.Pos.Column,
+				Message: "empty " + b.Keyword + " branch",
+			})
+		}
+	}
+	return findings
+}
+
+// branchEmpty reports whether nothing but blank lines lies between the branch
+// statement head and the position next.
+func branchEmpty(f *file, head syntax.Statement, next syntax.Pos) bool {
+	for _, s := range f.parsed.Statements {
+		after := s.Pos.Line > head.Pos.Line || s.Pos.Line == head.Pos.Line && s.Pos.Column > head.Pos.Column
+		before := s.Pos.Line < next.Line || s.Pos.Line == next.Line && s.Pos.Column < next.Column
+		if after && before {
+			return false
+		}
+	}
+	for line := head.Pos.Line; line < next.Line; line++ {
+		if f.parsed.Lines[line-1].HasComment() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,349 @@
+// Package lsp implements a language server speaking the Language Server
+// Protocol over a stream, such as stdio, that formats documents with the
+// formatter. It supports the textDocument/formatting,
+// textDocument/rangeFormatting, textDocument/documentSymbol and
+// textDocument/foldingRange requests on documents synchronized in full.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// ErrNoShutdown is returned by Serve when the client sends exit without
+// requesting a shutdown first.
+var ErrNoShutdown = errors.New("exit before shutdown")
+
+// Position is a zero-based line and UTF-16 character offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is the text from Start up to End.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// TextEdit replaces the text of Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// FormattingOptions are the editor settings sent with formatting requests.
+type FormattingOptions struct {
+	TabSize      int  `json:"tabSize"`
+	InsertSpaces bool `json:"insertSpaces"`
+}
+
+// FormatterFunc returns the formatter for the file at path, which is "" for
+// documents that are not files, given the settings of the editor.
+type FormatterFunc func(path string, opts FormattingOptions) (*formatter.Formatter, error)
+
+// Server is a language server formatting the documents of one client.
+type Server struct {
+	formatterFor FormatterFunc
+	// documents holds the text of the open documents by URI.
+	documents   map[string]string
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a server formatting documents with the formatters
+// returned by formatterFor.
+func NewServer(formatterFor FormatterFunc) *Server {
+	return &Server{formatterFor: formatterFor, documents: make(map[string]string)}
+}
+
+// Serve reads the messages of the client from r and writes the responses to
+// w until the client sends exit or r ends. Requests are handled in order.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	for {
+		content, err := readMessage(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			if err := writeError(w, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications are not answered.
+			continue
+		}
+		if rerr != nil {
+			err = writeError(w, msg.ID, rerr)
+		} else {
+			err = writeResult(w, msg.ID, result)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type textDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type didOpenParams struct {
+	TextDocument textDocumentItem `json:"textDocument"`
+}
+
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type didCloseParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type textDocumentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type formattingParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Range        *Range                 `json:"range"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+// handle returns the result of the request or notification msg.
+func (s *Server) handle(msg message) (any, *responseError) {
+	switch {
+	case msg.Method == "initialize":
+		s.initialized = true
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Documents are synchronized by sending their full text.
+				"textDocumentSync":                map[string]any{"openClose": true, "change": 1},
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+				"documentSymbolProvider":          true,
+				"foldingRangeProvider":            true,
+			},
+			"serverInfo": map[string]any{"name": "matlabformatter"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{codeServerNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{codeInvalidRequest, "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.documents[p.TextDocument.URI] = p.TextDocument.Text
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.documents[p.TextDocument.URI] = p.ContentChanges[n-1].Text
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/formatting", "textDocument/rangeFormatting":
+		var p formattingParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if msg.Method == "textDocument/rangeFormatting" && p.Range == nil {
+			return nil, &responseError{codeInvalidParams, "missing range"}
+		}
+		edits, err := s.format(p)
+		if err != nil {
+			return nil, &responseError{codeRequestFailed, err.Error()}
+		}
+		return edits, nil
+	case "textDocument/documentSymbol":
+		var p textDocumentParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		symbols, err := s.documentSymbols(p.TextDocument.URI)
+		if err != nil {
+			return nil, &responseError{codeRequestFailed, err.Error()}
+		}
+		return symbols, nil
+	case "textDocument/foldingRange":
+		var p textDocumentParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		ranges, err := s.foldingRanges(p.TextDocument.URI)
+		if err != nil {
+			return nil, &responseError{codeRequestFailed, err.Error()}
+		}
+		return ranges, nil
+	}
+	if strings.HasPrefix(msg.Method, "$/") {
+		// Optional notifications such as $/cancelRequest are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method not found: %s", msg.Method)}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{codeInvalidParams, err.Error()}
+}
+
+// format returns the edits formatting the document of p, or the lines of its
+// range.
+func (s *Server) format(p formattingParams) ([]TextEdit, error) {
+	path, text, err := s.text(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.formatterFor(path, p.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := formatter.ReadLines(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	first, last := 1, len(lines)
+	var formatted []string
+	if p.Range == nil {
+		formatted, err = f.FormatLines(lines)
+	} else {
+		first, last = rangeLines(*p.Range)
+		formatted, err = f.FormatRanges(lines, []formatter.LineRange{formatter.NewLineRange(first, last)})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return textEdits(text, f.JoinLinesLike(formatted, []byte(text)), first, last), nil
+}
+
+// text returns the path of the file of the document of uri, "" if it is not
+// a file, and its text: that of the open document, or else that read from
+// the file.
+func (s *Server) text(uri string) (path, text string, err error) {
+	path = uriPath(uri)
+	if text, ok := s.documents[uri]; ok {
+		return path, text, nil
+	}
+	if path == "" {
+		return "", "", fmt.Errorf("%s: document is not open", uri)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	return path, string(data), nil
+}
+
+// rangeLines returns the first and last 1-based lines of r. A range ending at
+// the start of a line does not include that line.
+func rangeLines(r Range) (first, last int) {
+	first, last = r.Start.Line+1, r.End.Line+1
+	if r.End.Character == 0 && r.End.Line > r.Start.Line {
+		last--
+	}
+	return first, last
+}
+
+// textEdits returns the edits turning old into new. Only the edits touching
+// the lines first through last are returned, so line endings converted
+// outside of a formatted range are left alone.
+func textEdits(old, new string, first, last int) []TextEdit {
+	lines := diff.SplitLines(old)
+	edits := []TextEdit{}
+	for _, e := range diff.TextEdits(old, new) {
+		lo, hi := e.Start.Line, e.End.Line
+		if e.End.Column == 1 && hi > lo {
+			hi--
+		}
+		if e.Start == e.End && e.Start.Column == 1 {
+			// An insertion between lines touches the line above too.
+			lo--
+		}
+		if lo > last || hi < first {
+			continue
+		}
+		edits = append(edits, TextEdit{
+			Range:   Range{Start: position(lines, e.Start), End: position(lines, e.End)},
+			NewText: e.NewText,
+		})
+	}
+	return edits
+}
+
+// position returns the LSP position of p in the document of lines, counting
+// the characters of the line in UTF-16 code units.
+func position(lines []string, p syntax.Pos) Position {
+	if p.Line > len(lines) {
+		return Position{Line: p.Line - 1}
+	}
+	return Position{Line: p.Line - 1, Character: len(utf16.Encode([]rune(lines[p.Line-1][:p.Column-1])))}
+}
+
+// uriPath returns the path of the file of a file URI, or "" for other URIs.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	// Windows paths are written as file:///C:/dir/file.m.
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
@@ -0,0 +1,215 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// response is a message written by the server.
+type response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// serve runs a server on the messages and returns its responses.
+func serve(t *testing.T, messages ...any) ([]response, error) {
+	t.Helper()
+	var in bytes.Buffer
+	for _, m := range messages {
+		if err := writeMessage(&in, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(func(path string, opts FormattingOptions) (*formatter.Formatter, error) {
+		o := formatter.DefaultOptions()
+		o.IndentWidth = opts.TabSize
+		return formatter.New(o)
+	})
+	var out bytes.Buffer
+	serveErr := s.Serve(&in, &out)
+
+	var responses []response
+	r := bufio.NewReader(&out)
+	for {
+		content, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp response
+		if err := json.Unmarshal(content, &resp); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses, serveErr
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func TestServeFormatting(t *testing.T) {
+	const uri = "untitled:Untitled-1"
+	options := map[string]any{"tabSize": 2, "insertSpaces": true}
+	responses, err := serve(t,
+		request(1, "initialize", map[string]any{}),
+		notification("initialized", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "matlab", "version": 1, "text": "x=1;\r\nif x\r\ny=2;\r\nend"}}),
+		request(2, "textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}, "options": options}),
+		notification("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 2}, "contentChanges": []any{map[string]any{"text": "x=1;\nif x\ny=2;\nend\n"}}}),
+		request(3, "textDocument/rangeFormatting", map[string]any{"textDocument": map[string]any{"uri": uri}, "options": options, "range": Range{Start: Position{Line: 2}, End: Position{Line: 3}}}),
+		request(4, "textDocument/hover", map[string]any{}),
+		request(5, "shutdown", nil),
+		notification("exit", nil),
+	)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if len(responses) != 5 {
+		t.Fatalf("got %d responses, want 5", len(responses))
+	}
+
+	var edits []TextEdit
+	if err := json.Unmarshal(responses[1].Result, &edits); err != nil {
+		t.Fatal(err)
+	}
+	// The CRLF line endings of the document are kept.
+	want := []TextEdit{
+		{Range: Range{Start: Position{Line: 0, Character: 1}, End: Position{Line: 0, Character: 4}}, NewText: " = 1;\r\n"},
+		{Range: Range{Start: Position{Line: 2}, End: Position{Line: 2, Character: 2}}, NewText: "  y = "},
+		{Range: Range{Start: Position{Line: 3, Character: 3}, End: Position{Line: 3, Character: 3}}, NewText: "\r\n"},
+	}
+	if diff := cmp.Diff(want, edits); diff != "" {
+		t.Errorf("formatting mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only the line of the range is formatted, as by --lines, at the level
+	// of the block enclosing it.
+	edits = nil
+	if err := json.Unmarshal(responses[2].Result, &edits); err != nil {
+		t.Fatal(err)
+	}
+	want = []TextEdit{
+		{Range: Range{Start: Position{Line: 2, Character: 0}, End: Position{Line: 2, Character: 2}}, NewText: "  y = "},
+	}
+	if diff := cmp.Diff(want, edits); diff != "" {
+		t.Errorf("rangeFormatting mismatch (-want +got):\n%s", diff)
+	}
+
+	if e := responses[3].Error; e == nil || e.Code != codeMethodNotFound {
+		t.Errorf("hover: got error %v, want code %d", e, codeMethodNotFound)
+	}
+	if responses[4].ID != 5 || responses[4].Error != nil {
+		t.Errorf("shutdown: got %+v", responses[4])
+	}
+}
+
+func TestServeRequiresInitialize(t *testing.T) {
+	responses, err := serve(t, request(1, "textDocument/formatting", map[string]any{}), notification("exit", nil))
+	if err != ErrNoShutdown {
+		t.Errorf("Serve: got %v, want %v", err, ErrNoShutdown)
+	}
+	if len(responses) != 1 || responses[0].Error == nil || responses[0].Error.Code != codeServerNotInitialized {
+		t.Errorf("got %+v, want a server not initialized error", responses)
+	}
+}
+
+func TestTextEditsAtEndOfDocument(t *testing.T) {
+	// Characters count UTF-16 code units.
+	got := textEdits("a\néb", "a\nébc\n", 1, 2)
+	want := []TextEdit{
+		{Range: Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 1, Character: 2}}, NewText: "c\n"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("textEdits mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServeOutline(t *testing.T) {
+	const uri = "untitled:Untitled-1"
+	text := "%% Setup\r\nfunction y = f(x)\r\n%{\r\nnotes\r\n%}\r\nif x\r\n  y = 1;\r\nend\r\nend\r\n"
+	document := map[string]any{"textDocument": map[string]any{"uri": uri}}
+	responses, err := serve(t,
+		request(1, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "matlab", "version": 1, "text": text}}),
+		request(2, "textDocument/documentSymbol", document),
+		request(3, "textDocument/foldingRange", document),
+		request(4, "textDocument/foldingRange", map[string]any{"textDocument": map[string]any{"uri": "untitled:Untitled-2"}}),
+	)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if len(responses) != 4 {
+		t.Fatalf("got %d responses, want 4", len(responses))
+	}
+
+	var initialize struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	if err := json.Unmarshal(responses[0].Result, &initialize); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"documentSymbolProvider", "foldingRangeProvider"} {
+		if initialize.Capabilities[c] != true {
+			t.Errorf("initialize: %s is %v, want true", c, initialize.Capabilities[c])
+		}
+	}
+
+	var symbols []DocumentSymbol
+	if err := json.Unmarshal(responses[1].Result, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	// Characters count to the end of the line without its line ending.
+	wantSymbols := []DocumentSymbol{
+		{
+			Name:           "Setup",
+			Kind:           3,
+			Range:          Range{Start: Position{Line: 0}, End: Position{Line: 8, Character: 3}},
+			SelectionRange: Range{Start: Position{Line: 0}, End: Position{Line: 0, Character: 8}},
+		},
+		{
+			Name:           "f",
+			Detail:         "y = f(x)",
+			Kind:           12,
+			Range:          Range{Start: Position{Line: 1}, End: Position{Line: 8, Character: 3}},
+			SelectionRange: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 17}},
+		},
+	}
+	if diff := cmp.Diff(wantSymbols, symbols); diff != "" {
+		t.Errorf("documentSymbol mismatch (-want +got):\n%s", diff)
+	}
+
+	var ranges []FoldingRange
+	if err := json.Unmarshal(responses[2].Result, &ranges); err != nil {
+		t.Fatal(err)
+	}
+	// Lines are zero-based, and code blocks have no kind in the protocol.
+	wantRanges := []FoldingRange{
+		{StartLine: 0, EndLine: 8, Kind: "region"},
+		{StartLine: 1, EndLine: 7},
+		{StartLine: 2, EndLine: 4, Kind: "comment"},
+		{StartLine: 5, EndLine: 6},
+	}
+	if diff := cmp.Diff(wantRanges, ranges); diff != "" {
+		t.Errorf("foldingRange mismatch (-want +got):\n%s", diff)
+	}
+
+	if e := responses[3].Error; e == nil || e.Code != codeRequestFailed {
+		t.Errorf("foldingRange of a closed document: got error %v, want code %d", e, codeRequestFailed)
+	}
+}
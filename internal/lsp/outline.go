@@ -0,0 +1,94 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+	"github.com/koyashimano/matlab-formatter/internal/outline"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// DocumentSymbol is an entry of the outline of a document. Range covers the
+// whole definition while SelectionRange covers the line that declares it.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// FoldingRange is a range of zero-based lines an editor can collapse.
+type FoldingRange struct {
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Kind      string `json:"kind,omitempty"`
+}
+
+// symbolKinds maps the outline symbol kinds to the SymbolKind values of the
+// protocol. Sections have no kind of their own and are shown as namespaces.
+var symbolKinds = map[string]int{
+	outline.KindClass:      5,
+	outline.KindMethod:     6,
+	outline.KindProperty:   7,
+	outline.KindFunction:   12,
+	outline.KindEnumMember: 22,
+	outline.KindEvent:      24,
+	outline.KindSection:    3,
+}
+
+// foldingKinds maps the outline folding kinds to the FoldingRangeKind values
+// of the protocol, which has none for code blocks and literals.
+var foldingKinds = map[string]string{
+	outline.FoldComment: "comment",
+	outline.FoldSection: "region",
+}
+
+// documentSymbols returns the outline of the document of uri.
+func (s *Server) documentSymbols(uri string) ([]DocumentSymbol, error) {
+	_, text, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := formatter.ReadLines(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return documentSymbols(diff.SplitLines(text), outline.Symbols(lines)), nil
+}
+
+func documentSymbols(lines []string, symbols []outline.Symbol) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	for _, sym := range symbols {
+		d := DocumentSymbol{
+			Name:           sym.Name,
+			Detail:         sym.Detail,
+			Kind:           symbolKinds[sym.Kind],
+			Range:          Range{Start: position(lines, sym.Range.Start), End: position(lines, sym.Range.End)},
+			SelectionRange: Range{Start: position(lines, sym.Selection.Start), End: position(lines, sym.Selection.End)},
+		}
+		if len(sym.Children) > 0 {
+			d.Children = documentSymbols(lines, sym.Children)
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// foldingRanges returns the folding ranges of the document of uri.
+func (s *Server) foldingRanges(uri string) ([]FoldingRange, error) {
+	_, text, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := formatter.ReadLines(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	result := []FoldingRange{}
+	for _, r := range outline.FoldingRanges(lines) {
+		result = append(result, FoldingRange{StartLine: r.StartLine - 1, EndLine: r.EndLine - 1, Kind: foldingKinds[r.Kind]})
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+// Package metrics computes size and structure statistics of MATLAB source
+// files from their parsed structure.
+package metrics
+
+import (
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// File holds the statistics of a single source file.
+type File struct {
+	Path         string     `json:"path"`
+	Lines        int        `json:"lines"`
+	SLOC         int        `json:"sloc"`
+	CommentLines int        `json:"commentLines"`
+	BlankLines   int        `json:"blankLines"`
+	CommentRatio float64    `json:"commentRatio"`
+	Functions    []Function `json:"functions"`
+	// LongestFunction is the name of the function spanning the most lines.
+	LongestFunction string `json:"longestFunction,omitempty"`
+}
+
+// Function holds the statistics of a single function. Nested functions are
+// reported separately and are also counted in the lines of their parent.
+type Function struct {
+	Name         string  `json:"name"`
+	StartLine    int     `json:"startLine"`
+	EndLine      int     `json:"endLine"`
+	Lines        int     `json:"lines"`
+	SLOC         int     `json:"sloc"`
+	CommentLines int     `json:"commentLines"`
+	CommentRatio float64 `json:"commentRatio"`
+	Inputs       int     `json:"inputs"`
+	Outputs      int     `json:"outputs"`
+	Nested       bool    `json:"nested"`
+}
+
+// Compute returns the statistics of the given source lines.
+func Compute(path string, lines []string) File {
+	parsed := syntax.Parse(lines)
+
+	m := File{Path: path, Lines: len(lines), Functions: []Function{}}
+	m.SLOC, m.CommentLines, m.BlankLines = count(parsed.Lines)
+	m.CommentRatio = ratio(m.CommentLines, m.Lines-m.BlankLines)
+
+	longest := 0
+	for _, fn := range parsed.Functions() {
+		start, end := fn.Start.Line, fn.End.Line
+		sloc, comments, blank := count(parsed.Lines[start-1 : end])
+		fm := Function{
+			Name:         fn.Name,
+			StartLine:    start,
+			EndLine:      end,
+			Lines:        end - start + 1,
+			SLOC:         sloc,
+			CommentLines: comments,
+			CommentRatio: ratio(comments, end-start+1-blank),
+			Inputs:       len(fn.Signature.Inputs),
+			Outputs:      len(fn.Signature.Outputs),
+			Nested:       fn.Parent != nil && fn.Parent.Kind == syntax.KindFunction,
+		}
+		if fm.Lines > longest {
+			longest = fm.Lines
+			m.LongestFunction = fm.Name
+		}
+		m.Functions = append(m.Functions, fm)
+	}
+
+	return m
+}
+
+// count returns the number of code, comment and blank lines. A line holding
+// both code and a trailing comment counts as both.
+func count(lines []syntax.Line) (sloc, comments, blank int) {
+	for _, l := range lines {
+		hasCode, hasComment := l.HasCode(), l.HasComment()
+		if hasCode {
+			sloc++
+		}
+		if hasComment {
+			comments++
+		}
+		if !hasCode && !hasComment {
+			blank++
+		}
+	}
+	return sloc, comments, blank
+}
+
+// ratio returns the fraction of non-blank lines that hold a comment.
+func ratio(comments, nonBlank int) float64 {
+	if nonBlank == 0 {
+		return 0
+	}
+	return float64(comments) / float64(nonBlank)
+}
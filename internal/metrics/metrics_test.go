@@ -0,0 +1,37 @@
+package metrics
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	lines := []string{
+		"function y = outer(a, b, c)",
+		"% OUTER adds things",
+		"",
+		"y = inner(a) + b; % sum",
+		"    function z = inner(x)",
+		"        z = x;",
+		"    end",
+		"end",
+	}
+
+	m := Compute("outer.m", lines)
+
+	if m.SLOC != 6 || m.CommentLines != 2 || m.BlankLines != 1 {
+		t.Fatalf("unexpected line counts: %+v", m)
+	}
+	if len(m.Functions) != 2 {
+		t.Fatalf("expected 2 functions, got %+v", m.Functions)
+	}
+
+	outer := m.Functions[0]
+	if outer.Lines != 8 || outer.Inputs != 3 || outer.Outputs != 1 || outer.Nested {
+		t.Errorf("unexpected outer metrics: %+v", outer)
+	}
+	inner := m.Functions[1]
+	if inner.StartLine != 5 || inner.EndLine != 7 || !inner.Nested {
+		t.Errorf("unexpected inner metrics: %+v", inner)
+	}
+	if m.LongestFunction != "outer" {
+		t.Errorf("unexpected longest function %q", m.LongestFunction)
+	}
+}
@@ -0,0 +1,132 @@
+// Package mlapp reads and rewrites the class code of App Designer apps.
+//
+// An .mlapp file is a zip container whose matlab/document.xml part holds the
+// code of the app class in the text of a w:t element, usually as a single
+// CDATA section.
+package mlapp
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DocumentPart is the name of the container part holding the class code.
+const DocumentPart = "matlab/document.xml"
+
+// ErrUnsafe is returned by Repack when the code cannot be written back
+// without risking the rest of the document.
+var ErrUnsafe = errors.New("code cannot be repacked safely")
+
+var (
+	textStart = regexp.MustCompile(`<w:t(?:\s[^>]*)?>`)
+	textEnd   = regexp.MustCompile(`</w:t>`)
+)
+
+// Extract returns the lines of the class code of the .mlapp container data.
+func Extract(data []byte) ([]string, error) {
+	doc, err := document(data)
+	if err != nil {
+		return nil, err
+	}
+	var code strings.Builder
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	inText := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", DocumentPart, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inText = t.Name.Local == "t"
+		case xml.EndElement:
+			inText = false
+		case xml.CharData:
+			if inText {
+				code.Write(t)
+			}
+		}
+	}
+	return splitCode(code.String()), nil
+}
+
+// Repack returns a copy of the .mlapp container data whose class code is
+// replaced by lines. The other parts are copied unchanged. It returns
+// ErrUnsafe unless the document holds the code in exactly one w:t element
+// and lines can be stored in a CDATA section.
+func Repack(data []byte, lines []string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := document(data)
+	if err != nil {
+		return nil, err
+	}
+	code := strings.Join(lines, "\n")
+	starts := textStart.FindAllIndex(doc, -1)
+	ends := textEnd.FindAllIndex(doc, -1)
+	if len(starts) != 1 || len(ends) != 1 || ends[0][0] < starts[0][1] || strings.Contains(code, "]]>") {
+		return nil, ErrUnsafe
+	}
+	var replaced bytes.Buffer
+	replaced.Write(doc[:starts[0][1]])
+	replaced.WriteString("<![CDATA[" + code + "]]>")
+	replaced.Write(doc[ends[0][0]:])
+
+	var out bytes.Buffer
+	w := zip.NewWriter(&out)
+	for _, file := range r.File {
+		if file.Name != DocumentPart {
+			if err := w.Copy(file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		header := file.FileHeader
+		part, err := w.CreateHeader(&header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(replaced.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// document returns the contents of the document part of the container data.
+func document(data []byte) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an .mlapp container: %w", err)
+	}
+	part, err := r.Open(DocumentPart)
+	if err != nil {
+		return nil, fmt.Errorf("not an .mlapp container: %w", err)
+	}
+	defer part.Close()
+	return io.ReadAll(part)
+}
+
+// splitCode splits code into lines, accepting both line ending styles.
+func splitCode(code string) []string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	lines := strings.Split(code, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
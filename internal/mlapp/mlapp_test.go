@@ -0,0 +1,99 @@
+package mlapp
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func container(t *testing.T, doc string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types/>`},
+		{DocumentPart, doc},
+		{"appdesigner/appModel.mat", "binary"},
+	} {
+		f, err := w.Create(part.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractAndRepack(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t><![CDATA[classdef app < matlab.apps.AppBase
+methods
+function x=f(a)
+x=a+1;
+end
+end
+end]]></w:t></w:r></w:p></w:body></w:document>`
+	data := container(t, doc)
+
+	lines, err := Extract(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 7 || lines[3] != "x=a+1;" {
+		t.Fatalf("Extract() = %q", lines)
+	}
+
+	formatted := []string{"classdef app < matlab.apps.AppBase", "    methods", "        function x = f(a)", "            x = a + 1;", "        end", "    end", "end"}
+	repacked, err := Repack(data, formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Extract(repacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(formatted, got); diff != "" {
+		t.Errorf("Extract(Repack()) mismatch (-want +got):\n%s", diff)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(repacked), int64(len(repacked)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if diff := cmp.Diff([]string{"[Content_Types].xml", DocumentPart, "appdesigner/appModel.mat"}, names); diff != "" {
+		t.Errorf("parts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRepackUnsafe(t *testing.T) {
+	for name, doc := range map[string]string{
+		"several text runs": `<w:document xmlns:w="w"><w:t>a = 1;</w:t><w:t>b = 2;</w:t></w:document>`,
+		"no text run":       `<w:document xmlns:w="w"></w:document>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Repack(container(t, doc), []string{"a = 1;"}); !errors.Is(err, ErrUnsafe) {
+				t.Errorf("Repack() error = %v, want ErrUnsafe", err)
+			}
+		})
+	}
+
+	doc := `<w:document xmlns:w="w"><w:t>s = 'a';</w:t></w:document>`
+	if _, err := Repack(container(t, doc), []string{"s = ']]>';"}); !errors.Is(err, ErrUnsafe) {
+		t.Errorf("Repack() error = %v, want ErrUnsafe", err)
+	}
+	if _, err := Extract([]byte("classdef app\nend\n")); err == nil {
+		t.Error("Extract() of a plain file succeeded")
+	}
+}
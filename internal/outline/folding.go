@@ -0,0 +1,108 @@
+package outline
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// Folding range kinds.
+const (
+	FoldFunction = "function"
+	FoldBlock    = "block"
+	FoldSection  = "section"
+	FoldComment  = "comment"
+	FoldLiteral  = "literal"
+)
+
+// FoldingRange is a range of lines an editor can collapse. StartLine stays
+// visible while the lines after it up to EndLine (1-based, inclusive) are
+// hidden. Closing end keywords and brackets on their own line are kept
+// outside the range so they remain visible.
+type FoldingRange struct {
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Kind      string `json:"kind"`
+}
+
+// FoldingRanges returns the folding ranges of the given source lines: function
+// bodies, control blocks and their branches, %% sections, block comments and
+// multi-line matrix and cell array literals.
+func FoldingRanges(lines []string) []FoldingRange {
+	f := syntax.Parse(lines)
+	var ranges []FoldingRange
+	add := func(start, end int, kind string) {
+		if end > start {
+			ranges = append(ranges, FoldingRange{StartLine: start, EndLine: end, Kind: kind})
+		}
+	}
+
+	var walk func(nodes []*syntax.Node)
+	walk = func(nodes []*syntax.Node) {
+		for _, n := range nodes {
+			kind := FoldBlock
+			if n.Kind == syntax.KindFunction {
+				kind = FoldFunction
+			}
+			end := n.End.Line
+			if n.Closed {
+				end--
+			}
+			// Each branch of an if, switch or try block folds separately.
+			start := n.Start.Line
+			for _, b := range n.Branches {
+				add(start, b.Pos.Line-1, kind)
+				start = b.Pos.Line
+			}
+			add(start, end, kind)
+			walk(n.Children)
+		}
+	}
+	walk(f.Nodes)
+
+	for _, sec := range f.Sections {
+		end := sec.End
+		for end > sec.Start.Line && strings.TrimSpace(f.Lines[end-1].Text) == "" {
+			end--
+		}
+		add(sec.Start.Line, end, FoldSection)
+	}
+
+	for i := 0; i < len(f.Lines); i++ {
+		if !f.Lines[i].BlockComment {
+			continue
+		}
+		start := i
+		for i+1 < len(f.Lines) && f.Lines[i+1].BlockComment {
+			i++
+		}
+		add(start+1, i+1, FoldComment)
+	}
+
+	for _, lit := range f.Literals {
+		if lit.Open == '(' {
+			continue
+		}
+		end := lit.End.Line
+		if strings.TrimSpace(f.Lines[end-1].Code)[0] == closing(lit.Open) {
+			end--
+		}
+		add(lit.Start.Line, end, FoldLiteral)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].StartLine != ranges[j].StartLine {
+			return ranges[i].StartLine < ranges[j].StartLine
+		}
+		return ranges[i].EndLine > ranges[j].EndLine
+	})
+	return ranges
+}
+
+func closing(open byte) byte {
+	if open == '[' {
+		return ']'
+	}
+	return '}'
+}
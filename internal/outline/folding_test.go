@@ -0,0 +1,39 @@
+package outline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldingRanges(t *testing.T) {
+	lines := []string{
+		"%% Data",
+		"function y = foo(x)",
+		"if x > 0",
+		"    y = 1;",
+		"else",
+		"    y = [1, 2;",
+		"         3, 4",
+		"        ];",
+		"end",
+		"%{",
+		"  notes",
+		"%}",
+		"end",
+		"",
+	}
+
+	got := FoldingRanges(lines)
+
+	want := []FoldingRange{
+		{StartLine: 1, EndLine: 13, Kind: FoldSection},
+		{StartLine: 2, EndLine: 12, Kind: FoldFunction},
+		{StartLine: 3, EndLine: 4, Kind: FoldBlock},
+		{StartLine: 5, EndLine: 8, Kind: FoldBlock},
+		{StartLine: 6, EndLine: 7, Kind: FoldLiteral},
+		{StartLine: 10, EndLine: 12, Kind: FoldComment},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected ranges:\ngot  %+v\nwant %+v", got, want)
+	}
+}
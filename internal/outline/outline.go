@@ -0,0 +1,176 @@
+// Package outline derives the document symbols of a MATLAB source file:
+// classes and their members, functions, nested functions and %% sections.
+package outline
+
+import (
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// Symbol kinds.
+const (
+	KindClass      = "class"
+	KindFunction   = "function"
+	KindMethod     = "method"
+	KindProperty   = "property"
+	KindEvent      = "event"
+	KindEnumMember = "enumMember"
+	KindSection    = "section"
+)
+
+// Range spans from Start to End, both 1-based; End.Column is exclusive.
+type Range struct {
+	Start syntax.Pos `json:"start"`
+	End   syntax.Pos `json:"end"`
+}
+
+// Symbol is an entry of the document outline. Range covers the whole
+// definition while Selection covers the line that declares it.
+type Symbol struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`
+	Detail    string   `json:"detail,omitempty"`
+	Range     Range    `json:"range"`
+	Selection Range    `json:"selectionRange"`
+	Children  []Symbol `json:"children,omitempty"`
+}
+
+// Symbols returns the outline of the given source lines.
+func Symbols(lines []string) []Symbol {
+	f := syntax.Parse(lines)
+	b := &builder{file: f}
+	symbols := b.nodes(f.Nodes)
+	return b.addSections(symbols, f.Sections)
+}
+
+type builder struct {
+	file *syntax.File
+}
+
+func (b *builder) nodes(nodes []*syntax.Node) []Symbol {
+	var result []Symbol
+	for _, n := range nodes {
+		switch {
+		case n.Kind == syntax.KindClassdef:
+			result = append(result, b.symbol(n, n.Name, KindClass, "", b.nodes(n.Children)))
+		case n.Kind == syntax.KindFunction:
+			kind := KindFunction
+			if n.Parent != nil && n.Parent.Keyword == "methods" {
+				kind = KindMethod
+			}
+			result = append(result, b.symbol(n, n.Name, kind, signature(n.Signature), b.nodes(n.Children)))
+		case n.Keyword == "properties":
+			result = append(result, b.members(n, KindProperty)...)
+		case n.Keyword == "events":
+			result = append(result, b.members(n, KindEvent)...)
+		case n.Keyword == "enumeration":
+			result = append(result, b.members(n, KindEnumMember)...)
+		default:
+			// Control blocks only matter for the functions nested in them.
+			result = append(result, b.nodes(n.Children)...)
+		}
+	}
+	return result
+}
+
+func (b *builder) symbol(n *syntax.Node, name, kind, detail string, children []Symbol) Symbol {
+	return Symbol{
+		Name:      name,
+		Kind:      kind,
+		Detail:    detail,
+		Range:     Range{Start: n.Start, End: b.lineEnd(n.End.Line)},
+		Selection: Range{Start: n.Start, End: b.lineEnd(n.Start.Line)},
+		Children:  children,
+	}
+}
+
+// members lists the declarations of a properties, events or enumeration
+// block, one per statement.
+func (b *builder) members(n *syntax.Node, kind string) []Symbol {
+	var result []Symbol
+	for _, s := range b.file.Statements {
+		if s.Pos.Line <= n.Start.Line || s.Pos.Line >= n.End.Line {
+			continue
+		}
+		name := syntax.FirstWord(s.Text)
+		if name == "" {
+			continue
+		}
+		r := Range{Start: s.Pos, End: b.lineEnd(s.EndLine)}
+		result = append(result, Symbol{Name: name, Kind: kind, Range: r, Selection: r})
+	}
+	return result
+}
+
+// addSections places each section inside the innermost symbol containing its
+// marker, clipping the section to that symbol.
+func (b *builder) addSections(symbols []Symbol, sections []syntax.Section) []Symbol {
+	for _, sec := range sections {
+		symbols = b.insertSection(symbols, sec, len(b.file.Lines))
+	}
+	return symbols
+}
+
+func (b *builder) insertSection(symbols []Symbol, sec syntax.Section, limit int) []Symbol {
+	line := sec.Start.Line
+	for i := range symbols {
+		s := &symbols[i]
+		if s.Kind == KindSection || line <= s.Range.Start.Line || line >= s.Range.End.Line {
+			continue
+		}
+		s.Children = b.insertSection(s.Children, sec, s.Range.End.Line)
+		return symbols
+	}
+
+	end := sec.End
+	if end > limit {
+		end = limit
+	}
+	title := sec.Title
+	if title == "" {
+		title = "(untitled)"
+	}
+	symbol := Symbol{
+		Name:      title,
+		Kind:      KindSection,
+		Range:     Range{Start: sec.Start, End: b.lineEnd(end)},
+		Selection: Range{Start: sec.Start, End: b.lineEnd(line)},
+	}
+
+	// Keep symbols in source order.
+	idx := len(symbols)
+	for i, s := range symbols {
+		if s.Range.Start.Line > line {
+			idx = i
+			break
+		}
+	}
+	symbols = append(symbols, Symbol{})
+	copy(symbols[idx+1:], symbols[idx:])
+	symbols[idx] = symbol
+	return symbols
+}
+
+// lineEnd returns the position just past the last character of line.
+func (b *builder) lineEnd(line int) syntax.Pos {
+	if line < 1 || line > len(b.file.Lines) {
+		return syntax.Pos{Line: line, Column: 1}
+	}
+	return syntax.Pos{Line: line, Column: len(b.file.Lines[line-1].Text) + 1}
+}
+
+// signature renders a function declaration such as "[a, b] = foo(x, y)".
+func signature(sig *syntax.Signature) string {
+	var sb strings.Builder
+	switch len(sig.Outputs) {
+	case 0:
+	case 1:
+		sb.WriteString(sig.Outputs[0] + " = ")
+	default:
+		sb.WriteString("[" + strings.Join(sig.Outputs, ", ") + "] = ")
+	}
+	sb.WriteString(sig.Name)
+	sb.WriteString("(" + strings.Join(sig.Inputs, ", ") + ")")
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package outline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	lines := []string{
+		"classdef Account < handle",
+		"    properties (Access = private)",
+		"        Balance (1,1) double = 0",
+		"    end",
+		"    events",
+		"        Changed",
+		"    end",
+		"    methods",
+		"        %% Constructor",
+		"        function obj = Account(b)",
+		"            obj.Balance = b;",
+		"        end",
+		"    end",
+		"end",
+		"%% Trailer",
+	}
+
+	got := Symbols(lines)
+
+	var flat []string
+	var walk func(symbols []Symbol, depth int)
+	walk = func(symbols []Symbol, depth int) {
+		for _, s := range symbols {
+			flat = append(flat, strings.Repeat(" ", depth)+s.Kind+":"+s.Name+":"+s.Detail)
+			walk(s.Children, depth+1)
+		}
+	}
+	walk(got, 0)
+
+	want := []string{
+		"class:Account:",
+		" property:Balance:",
+		" event:Changed:",
+		" section:Constructor:",
+		" method:Account:obj = Account(b)",
+		"section:Trailer:",
+	}
+	if strings.Join(flat, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected outline:\ngot:\n%s\nwant:\n%s", strings.Join(flat, "\n"), strings.Join(want, "\n"))
+	}
+
+	class := got[0]
+	if class.Range.Start.Line != 1 || class.Range.End.Line != 14 || class.Range.End.Column != 4 {
+		t.Errorf("unexpected class range: %+v", class.Range)
+	}
+	if sec := class.Children[2]; sec.Range.End.Line != 14 {
+		t.Errorf("section not clipped to class: %+v", sec.Range)
+	}
+}
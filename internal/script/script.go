@@ -0,0 +1,246 @@
+// Package script runs lint rules written in Starlark. A rule script defines a
+// check(file) function that receives the parsed structure of each file and
+// reports findings, optionally with fixes, through the report builtin.
+//
+// Scripts run sandboxed: Starlark offers no access to files, the network or
+// the clock, load statements are rejected, and every call is bounded by a
+// step budget.
+package script
+
+import (
+	"fmt"
+	"os"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+	"github.com/koyashimano/matlab-formatter/pkg/formatter"
+)
+
+// MaxSteps bounds the Starlark computation steps of a single script run.
+const MaxSteps = 10_000_000
+
+// Load compiles the script at path and returns a lint rule calling its check
+// function.
+func Load(id, path string, severity lint.Severity) (lint.ExternalRule, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return lint.ExternalRule{}, err
+	}
+	return Compile(id, path, src, severity)
+}
+
+// Compile is like Load but takes the script source. path is only used in
+// error messages.
+func Compile(id, path string, src []byte, severity lint.Severity) (lint.ExternalRule, error) {
+	thread := newThread(path)
+	globals, err := starlark.ExecFile(thread, path, src, starlark.StringDict{"report": report})
+	if err != nil {
+		return lint.ExternalRule{}, fmt.Errorf("%s: %v", path, err)
+	}
+	check, ok := globals["check"].(starlark.Callable)
+	if !ok {
+		return lint.ExternalRule{}, fmt.Errorf("%s: script does not define a check function", path)
+	}
+
+	return lint.ExternalRule{
+		ID:       id,
+		Severity: severity,
+		Check: func(filename string, lines []string, parsed *syntax.File) ([]lint.Finding, error) {
+			return run(path, check, filename, lines, parsed)
+		},
+	}, nil
+}
+
+func newThread(path string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: path,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load of %q is not allowed", module)
+		},
+	}
+	thread.SetMaxExecutionSteps(MaxSteps)
+	return thread
+}
+
+// sink collects the findings reported during one run of a check function.
+type sink struct {
+	lines    int
+	findings []lint.Finding
+}
+
+// report is the builtin scripts call to emit a finding:
+//
+//	report(line, message, column=1, end_line=None, replacement=None)
+//
+// With replacement, a list of strings, the finding carries a fix replacing
+// lines line through end_line.
+var report = starlark.NewBuiltin("report", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	out, ok := thread.Local("sink").(*sink)
+	if !ok {
+		return nil, fmt.Errorf("%s: only available while check runs", b.Name())
+	}
+
+	var (
+		line, column = 0, 1
+		message      string
+		endLine      = starlark.Value(starlark.None)
+		replacement  = starlark.Value(starlark.None)
+	)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"line", &line, "message", &message, "column?", &column,
+		"end_line?", &endLine, "replacement?", &replacement); err != nil {
+		return nil, err
+	}
+	if line < 1 || line > out.lines+1 {
+		return nil, fmt.Errorf("%s: line %d out of range", b.Name(), line)
+	}
+
+	finding := lint.Finding{Line: line, Column: column, Message: message}
+	if replacement != starlark.None {
+		edit, err := fixEdit(b.Name(), line, endLine, replacement, out.lines)
+		if err != nil {
+			return nil, err
+		}
+		finding.Fix = edit
+	}
+	out.findings = append(out.findings, finding)
+	return starlark.None, nil
+})
+
+func run(path string, check starlark.Callable, filename string, lines []string, parsed *syntax.File) ([]lint.Finding, error) {
+	out := &sink{lines: len(lines)}
+	thread := newThread(path)
+	thread.SetLocal("sink", out)
+	if _, err := starlark.Call(thread, check, starlark.Tuple{fileValue(filename, lines, parsed)}, nil); err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, fmt.Errorf("%s", evalErr.Backtrace())
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return out.findings, nil
+}
+
+// fixEdit converts the fix arguments of report. The replacement lines
+// substitute lines line through endLine, which defaults to line; an endLine
+// of line-1 inserts before line.
+func fixEdit(name string, line int, endLine, replacement starlark.Value, n int) (*formatter.Edit, error) {
+	end := line
+	if endLine != starlark.None {
+		v, err := starlark.AsInt32(endLine)
+		if err != nil {
+			return nil, fmt.Errorf("%s: end_line: %v", name, err)
+		}
+		end = v
+	}
+	if end < line-1 || end > n {
+		return nil, fmt.Errorf("%s: end_line %d out of range", name, end)
+	}
+
+	list, ok := replacement.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("%s: replacement must be a list of strings", name)
+	}
+	var text []string
+	iter := list.Iterate()
+	defer iter.Done()
+	var v starlark.Value
+	for iter.Next(&v) {
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return nil, fmt.Errorf("%s: replacement must be a list of strings", name)
+		}
+		text = append(text, s)
+	}
+	return &formatter.Edit{StartLine: line, EndLine: end, Lines: text}, nil
+}
+
+// fileValue exposes a parsed file to scripts.
+func fileValue(filename string, lines []string, parsed *syntax.File) starlark.Value {
+	text := make([]starlark.Value, len(lines))
+	code := make([]starlark.Value, len(lines))
+	for i, l := range parsed.Lines {
+		text[i] = starlark.String(l.Text)
+		code[i] = starlark.String(l.Code)
+	}
+
+	statements := make([]starlark.Value, len(parsed.Statements))
+	for i, s := range parsed.Statements {
+		statements[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"line":     starlark.MakeInt(s.Pos.Line),
+			"column":   starlark.MakeInt(s.Pos.Column),
+			"end_line": starlark.MakeInt(s.EndLine),
+			"text":     starlark.String(s.Text),
+		})
+	}
+
+	errors := make([]starlark.Value, len(parsed.Errors))
+	for i, e := range parsed.Errors {
+		errors[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"line":    starlark.MakeInt(e.Pos.Line),
+			"column":  starlark.MakeInt(e.Pos.Column),
+			"message": starlark.String(e.Message),
+		})
+	}
+
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"path":       starlark.String(filename),
+		"lines":      starlark.NewList(text),
+		"code":       starlark.NewList(code),
+		"statements": starlark.NewList(statements),
+		"nodes":      nodeList(parsed.Nodes),
+		"errors":     starlark.NewList(errors),
+		"is_script":  starlark.Bool(parsed.IsScript()),
+	})
+}
+
+var kindNames = map[syntax.Kind]string{
+	syntax.KindFunction: "function",
+	syntax.KindClassdef: "classdef",
+	syntax.KindBlock:    "block",
+}
+
+func nodeList(nodes []*syntax.Node) *starlark.List {
+	values := make([]starlark.Value, len(nodes))
+	for i, n := range nodes {
+		fields := starlark.StringDict{
+			"kind":         starlark.String(kindNames[n.Kind]),
+			"keyword":      starlark.String(n.Keyword),
+			"name":         starlark.String(n.Name),
+			"line":         starlark.MakeInt(n.Start.Line),
+			"column":       starlark.MakeInt(n.Start.Column),
+			"end_line":     starlark.MakeInt(n.End.Line),
+			"closed":       starlark.Bool(n.Closed),
+			"attributes":   starlark.String(n.Attributes),
+			"children":     nodeList(n.Children),
+			"branch_lines": branchLines(n.Branches),
+		}
+		if n.Signature != nil {
+			fields["inputs"] = stringList(n.Signature.Inputs)
+			fields["outputs"] = stringList(n.Signature.Outputs)
+		}
+		values[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, fields)
+	}
+	return starlark.NewList(values)
+}
+
+func branchLines(branches []syntax.Branch) *starlark.List {
+	values := make([]starlark.Value, len(branches))
+	for i, b := range branches {
+		values[i] = starlark.MakeInt(b.Pos.Line)
+	}
+	return starlark.NewList(values)
+}
+
+func stringList(items []string) *starlark.List {
+	values := make([]starlark.Value, len(items))
+	for i, s := range items {
+		values[i] = starlark.String(s)
+	}
+	return starlark.NewList(values)
+}
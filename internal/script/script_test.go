@@ -0,0 +1,73 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koyashimano/matlab-formatter/internal/lint"
+)
+
+const longFunctions = `
+def check(file):
+    for node in file.nodes:
+        if node.kind == "function" and node.end_line - node.line > 2:
+            report(node.line, "function %s is too long" % node.name, column=node.column)
+    for i, text in enumerate(file.lines):
+        if text.rstrip() != text:
+            report(i + 1, "trailing whitespace", replacement=[text.rstrip()])
+`
+
+func TestScriptRuleReportsAndFixes(t *testing.T) {
+	rule, err := Compile("long-function", "long.star", []byte(longFunctions), lint.SeverityWarning)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	lines := []string{
+		"function y = f(x)",
+		"y = x;  ",
+		"y = y + 1;",
+		"end",
+	}
+	opts := lint.DefaultOptions()
+	opts.ExternalRules = []lint.ExternalRule{rule}
+	// The script rule duplicates the built-in rule.
+	opts.Severities = map[string]lint.Severity{"trailing-whitespace": lint.SeverityOff}
+
+	findings := lint.Run(lines, opts)
+	if len(findings) != 2 || findings[0].Rule != "long-function" || findings[0].Line != 1 || findings[1].Line != 2 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+
+	fixed, _, err := lint.Fix(lines, opts)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if fixed[1] != "y = x;" {
+		t.Fatalf("unexpected fixed line: %q", fixed[1])
+	}
+}
+
+func TestScriptSandbox(t *testing.T) {
+	tests := map[string]string{
+		"load":       "load('other.star', 'x')\ndef check(file):\n    pass\n",
+		"no check":   "x = 1\n",
+		"top report": "report(1, 'x')\ndef check(file):\n    pass\n",
+	}
+	for name, src := range tests {
+		if _, err := Compile("x", "x.star", []byte(src), lint.SeverityWarning); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	rule, err := Compile("spin", "spin.star", []byte("def check(file):\n    for i in range(100000000):\n        pass\n"), lint.SeverityWarning)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	opts := lint.DefaultOptions()
+	opts.ExternalRules = []lint.ExternalRule{rule}
+	findings := lint.Run([]string{"x = 1;"}, opts)
+	if len(findings) != 1 || findings[0].Severity != lint.SeverityError || !strings.Contains(findings[0].Message, "too many steps") {
+		t.Fatalf("expected step limit failure, got %+v", findings)
+	}
+}
@@ -0,0 +1,318 @@
+// Package simulink reads and rewrites the MATLAB callbacks embedded in
+// Simulink models, such as the InitFcn of a model or the OpenFcn of a block.
+//
+// Models saved as .slx are zip containers whose XML parts store callbacks as
+// <P Name="InitFcn">...</P> parameters. Models saved as .mdl are either in
+// the classic text format, where callbacks are quoted strings such as
+// InitFcn "a = 1;\nb = 2;", or hold the same XML parts as .slx files one
+// after another.
+package simulink
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Callback is a callback of a model.
+type Callback struct {
+	// Name identifies the callback within the model, such as "InitFcn" for
+	// a model callback or "Gain1/OpenFcn" for a block callback.
+	Name  string
+	Lines []string
+
+	part       string
+	start, end int
+	mdl        bool
+	trailing   bool
+	indent     string
+	newline    string
+}
+
+// Model is a Simulink model read by Read.
+type Model struct {
+	Callbacks []Callback
+
+	data  []byte
+	slx   bool
+	parts map[string][]byte
+}
+
+// opcMarker starts the parts of .mdl files saved in the XML-based format.
+const opcMarker = "__MWOPC_PART_BEGIN__"
+
+var (
+	xmlCallback = regexp.MustCompile(`<P Name="(\w*Fcn)"(?:\s[^>]*)?>([^<]*)</P>`)
+	xmlBlock    = regexp.MustCompile(`<Block\s[^>]*?\bName="([^"]*)"[^>]*>|</Block>`)
+
+	mdlCallback     = regexp.MustCompile(`^(\s*)(\w*Fcn)(\s+)("(?:[^"\\]|\\.)*")\s*$`)
+	mdlContinuation = regexp.MustCompile(`^(\s*)("(?:[^"\\]|\\.)*")\s*$`)
+	mdlSection      = regexp.MustCompile(`^\s*(\w+)\s*\{\s*$`)
+	mdlName         = regexp.MustCompile(`^\s*Name\s+("(?:[^"\\]|\\.)*")\s*$`)
+)
+
+// Read returns the callbacks of the model data, read from a file named name.
+// The extension of name selects the format: .slx or .mdl.
+func Read(name string, data []byte) (*Model, error) {
+	m := &Model{data: data}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".slx":
+		m.slx = true
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("not an .slx model: %w", err)
+		}
+		m.parts = make(map[string][]byte)
+		for _, file := range r.File {
+			if path.Ext(file.Name) != ".xml" {
+				continue
+			}
+			part, err := readPart(file)
+			if err != nil {
+				return nil, err
+			}
+			m.parts[file.Name] = part
+		}
+		names := make([]string, 0, len(m.parts))
+		for name := range m.parts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m.Callbacks = append(m.Callbacks, xmlCallbacks(name, m.parts[name])...)
+		}
+	case ".mdl":
+		if bytes.Contains(data, []byte(opcMarker)) {
+			m.Callbacks = xmlCallbacks("", data)
+		} else {
+			m.Callbacks = mdlCallbacks(data)
+		}
+	default:
+		return nil, fmt.Errorf("%s: not a Simulink model", name)
+	}
+	return m, nil
+}
+
+// Bytes returns the model with its callbacks replaced by the Lines of
+// m.Callbacks. The rest of the model is copied unchanged.
+func (m *Model) Bytes() ([]byte, error) {
+	if !m.slx {
+		return replace(m.data, "", m.Callbacks), nil
+	}
+	r, err := zip.NewReader(bytes.NewReader(m.data), int64(len(m.data)))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	w := zip.NewWriter(&out)
+	for _, file := range r.File {
+		replaced := replace(m.parts[file.Name], file.Name, m.Callbacks)
+		if _, ok := m.parts[file.Name]; !ok || bytes.Equal(replaced, m.parts[file.Name]) {
+			if err := w.Copy(file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		header := file.FileHeader
+		part, err := w.CreateHeader(&header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(replaced); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func readPart(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// xmlCallbacks returns the callback parameters of the XML part data. Block
+// callbacks are named after their block.
+func xmlCallbacks(part string, data []byte) []Callback {
+	blocks := xmlBlock.FindAllSubmatchIndex(data, -1)
+	var callbacks []Callback
+	for _, m := range xmlCallback.FindAllSubmatchIndex(data, -1) {
+		code, err := unescapeXML(data[m[4]:m[5]])
+		if err != nil {
+			continue
+		}
+		name := string(data[m[2]:m[3]])
+		if block := enclosingBlock(data, blocks, m[0]); block != "" {
+			name = block + "/" + name
+		}
+		c := Callback{Name: name, part: part, start: m[4], end: m[5]}
+		c.Lines, c.trailing = splitCode(code)
+		if len(c.Lines) > 0 {
+			callbacks = append(callbacks, c)
+		}
+	}
+	return callbacks
+}
+
+// enclosingBlock returns the name of the block whose element contains the
+// offset i of data, or "" for model parameters.
+func enclosingBlock(data []byte, blocks [][]int, i int) string {
+	name := ""
+	for _, b := range blocks {
+		if b[0] > i {
+			break
+		}
+		if b[2] < 0 {
+			name = ""
+		} else {
+			name, _ = unescapeXML(data[b[2]:b[3]])
+		}
+	}
+	return name
+}
+
+// mdlCallbacks returns the callback parameters of a model in the classic
+// text format, whose strings may continue on the following lines.
+func mdlCallbacks(data []byte) []Callback {
+	lines := strings.SplitAfter(string(data), "\n")
+	newline := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	var callbacks []Callback
+	// sections holds the open sections, such as Block, and their names.
+	type section struct{ kind, name string }
+	var sections []section
+	offset := 0
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		start := offset
+		offset += len(lines[i])
+		switch {
+		case mdlSection.MatchString(line):
+			sections = append(sections, section{kind: mdlSection.FindStringSubmatch(line)[1]})
+			continue
+		case strings.TrimSpace(line) == "}":
+			if len(sections) > 0 {
+				sections = sections[:len(sections)-1]
+			}
+			continue
+		case mdlName.MatchString(line):
+			if len(sections) > 0 {
+				sections[len(sections)-1].name = unquoteMDL(mdlName.FindStringSubmatch(line)[1])
+			}
+			continue
+		}
+		m := mdlCallback.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		code := unquoteMDL(line[m[8]:m[9]])
+		c := Callback{Name: line[m[4]:m[5]], mdl: true, newline: newline, start: start + m[8], end: start + len(line)}
+		for i+1 < len(lines) && mdlContinuation.MatchString(strings.TrimRight(lines[i+1], "\r\n")) {
+			i++
+			next := strings.TrimRight(lines[i], "\r\n")
+			cm := mdlContinuation.FindStringSubmatch(next)
+			if c.indent == "" {
+				c.indent = cm[1]
+			}
+			code += unquoteMDL(cm[2])
+			c.end = offset + len(next)
+			offset += len(lines[i])
+		}
+		if len(sections) > 0 && sections[len(sections)-1].kind == "Block" && sections[len(sections)-1].name != "" {
+			c.Name = sections[len(sections)-1].name + "/" + c.Name
+		}
+		c.Lines, c.trailing = splitCode(code)
+		if len(c.Lines) > 0 {
+			callbacks = append(callbacks, c)
+		}
+	}
+	return callbacks
+}
+
+// replace returns data with the callbacks of part replaced by their lines.
+func replace(data []byte, part string, callbacks []Callback) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, c := range callbacks {
+		if c.part != part {
+			continue
+		}
+		out.Write(data[last:c.start])
+		out.WriteString(c.encode())
+		last = c.end
+	}
+	out.Write(data[last:])
+	return out.Bytes()
+}
+
+// encode returns the callback in the syntax of its model format.
+func (c *Callback) encode() string {
+	code := strings.Join(c.Lines, "\n")
+	if c.trailing {
+		code += "\n"
+	}
+	if !c.mdl {
+		// Simulink stores quotes and line breaks literally.
+		return xmlEscaper.Replace(code)
+	}
+	var b strings.Builder
+	for i, line := range strings.SplitAfter(code, "\n") {
+		if line == "" {
+			continue
+		}
+		if i > 0 {
+			b.WriteString(c.newline + c.indent)
+		}
+		b.WriteString(quoteMDL(line))
+	}
+	if b.Len() == 0 {
+		return `""`
+	}
+	return b.String()
+}
+
+func unescapeXML(text []byte) (string, error) {
+	var s string
+	err := xml.Unmarshal(append(append([]byte("<t>"), text...), "</t>"...), &s)
+	return s, err
+}
+
+var (
+	xmlEscaper  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	mdlUnquoter = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+	mdlQuoter   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+)
+
+func unquoteMDL(s string) string {
+	return mdlUnquoter.Replace(s[1 : len(s)-1])
+}
+
+func quoteMDL(s string) string {
+	return `"` + mdlQuoter.Replace(s) + `"`
+}
+
+// splitCode splits code into lines, reporting whether it ends in a line
+// break.
+func splitCode(code string) ([]string, bool) {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	trailing := strings.HasSuffix(code, "\n")
+	code = strings.TrimSuffix(code, "\n")
+	if code == "" {
+		return nil, trailing
+	}
+	return strings.Split(code, "\n"), trailing
+}
@@ -0,0 +1,134 @@
+package simulink
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func slx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"[Content_Types].xml", "simulink/blockdiagram.xml", "simulink/systems/system_root.xml"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(f, parts[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func names(callbacks []Callback) []string {
+	var names []string
+	for _, c := range callbacks {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestSLX(t *testing.T) {
+	data := slx(t, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"simulink/blockdiagram.xml": `<ModelInformation><Model Name="m">
+<P Name="InitFcn">if a&gt;1
+x=1;
+end</P>
+<P Name="StopFcn"></P>
+<P Name="Solver">ode45</P>
+</Model></ModelInformation>`,
+		"simulink/systems/system_root.xml": `<System><Block BlockType="Gain" Name="Gain1" SID="1">
+<P Name="OpenFcn">disp(&apos;open&apos;)</P>
+</Block></System>`,
+	})
+
+	m, err := Read("m.slx", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"InitFcn", "Gain1/OpenFcn"}, names(m.Callbacks)); diff != "" {
+		t.Fatalf("callbacks mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"if a>1", "x=1;", "end"}, m.Callbacks[0].Lines); diff != "" {
+		t.Errorf("InitFcn mismatch (-want +got):\n%s", diff)
+	}
+
+	m.Callbacks[0].Lines = []string{"if a > 1", "    x = 1;", "end"}
+	out, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = Read("m.slx", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"if a > 1", "    x = 1;", "end"}, m.Callbacks[0].Lines); diff != "" {
+		t.Errorf("repacked InitFcn mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"disp('open')"}, m.Callbacks[1].Lines); diff != "" {
+		t.Errorf("repacked OpenFcn mismatch (-want +got):\n%s", diff)
+	}
+	if !bytes.Contains(m.parts["simulink/blockdiagram.xml"], []byte("<P Name=\"InitFcn\">if a &gt; 1\n    x = 1;\nend</P>")) {
+		t.Errorf("blockdiagram.xml = %s", m.parts["simulink/blockdiagram.xml"])
+	}
+}
+
+func TestMDL(t *testing.T) {
+	data := []byte(`Model {
+  Name			  "m"
+  InitFcn		  "a=1;\n"
+"b=\"x\";"
+  System {
+    Name		    "m"
+    Block {
+      BlockType		      Gain
+      Name		      "Gain1"
+      OpenFcn		      "disp(1)"
+    }
+  }
+}
+`)
+	m, err := Read("m.mdl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"InitFcn", "Gain1/OpenFcn"}, names(m.Callbacks)); diff != "" {
+		t.Fatalf("callbacks mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"a=1;", `b="x";`}, m.Callbacks[0].Lines); diff != "" {
+		t.Errorf("InitFcn mismatch (-want +got):\n%s", diff)
+	}
+
+	m.Callbacks[0].Lines = []string{"a = 1;", `b = "x";`}
+	m.Callbacks[1].Lines = []string{"disp(1);"}
+	out, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Model {
+  Name			  "m"
+  InitFcn		  "a = 1;\n"
+"b = \"x\";"
+  System {
+    Name		    "m"
+    Block {
+      BlockType		      Gain
+      Name		      "Gain1"
+      OpenFcn		      "disp(1);"
+    }
+  }
+}
+`
+	if diff := cmp.Diff(want, string(out)); diff != "" {
+		t.Errorf("Bytes() mismatch (-want +got):\n%s", diff)
+	}
+}
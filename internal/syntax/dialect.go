@@ -0,0 +1,53 @@
+package syntax
+
+import "strings"
+
+// Dialect is the language a file is written in, which decides how its
+// comments and strings are read.
+type Dialect int
+
+const (
+	// MATLAB reads comments starting with % and block comments between %{
+	// and %} lines.
+	MATLAB Dialect = iota
+	// Octave also reads comments starting with #, block comments between
+	// #{ and #} lines, and backslash escapes in double-quoted strings.
+	Octave
+)
+
+// octaveStatements are the statements of a single word that only Octave
+// accepts.
+var octaveStatements = map[string]bool{
+	"endfunction": true, "endif": true, "endwhile": true, "endfor": true,
+	"endswitch": true, "end_try_catch": true, "unwind_protect": true,
+	"unwind_protect_cleanup": true, "end_unwind_protect": true,
+}
+
+// DetectDialect returns Octave when lines use syntax that only Octave
+// accepts, such as # comments, %! test blocks or endif, and MATLAB
+// otherwise.
+func DetectDialect(lines []string) Dialect {
+	for _, line := range lines {
+		code, comment := ScanLine(line)
+		switch {
+		case strings.Contains(code, "#"):
+			return Octave
+		case comment == 0 && strings.HasPrefix(line, "%!"):
+			return Octave
+		case octaveStatements[strings.TrimRight(strings.TrimSpace(code), ";,")]:
+			return Octave
+		}
+	}
+	return MATLAB
+}
+
+// isComment reports whether c starts a comment in d.
+func (d Dialect) isComment(c byte) bool {
+	return c == '%' || c == '#' && d == Octave
+}
+
+// escapes reports whether a backslash escapes the character following it in
+// string literals delimited by quote.
+func (d Dialect) escapes(quote byte) bool {
+	return quote == '"' && d == Octave
+}
@@ -0,0 +1,44 @@
+package syntax
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectDialect(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  Dialect
+	}{
+		{[]string{"x = 1; % one", "disp('# not a comment')"}, MATLAB},
+		{[]string{"x = 1; # one"}, Octave},
+		{[]string{"function f", "endfunction"}, Octave},
+		{[]string{"x = 1;", "%!assert (x, 1)"}, Octave},
+		{[]string{"endif = 1;"}, MATLAB},
+	}
+	for _, tt := range tests {
+		if got := DetectDialect(tt.lines); got != tt.want {
+			t.Errorf("DetectDialect(%q) = %v, want %v", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestParseOctaveBlockComments(t *testing.T) {
+	lines := []string{"#{", "if x", "#}", "y = 1; # (", "z = 2;"}
+
+	f := Octave.Parse(lines)
+
+	var block []bool
+	for _, l := range f.Lines {
+		block = append(block, l.BlockComment)
+	}
+	if want := []bool{true, true, true, false, false}; !reflect.DeepEqual(block, want) {
+		t.Errorf("block comment lines: got %v, want %v", block, want)
+	}
+	if len(f.Errors) > 0 || len(f.Nodes) > 0 || len(f.Literals) > 0 {
+		t.Errorf("comments read as code: errors %v, nodes %d, literals %v", f.Errors, len(f.Nodes), f.Literals)
+	}
+	if got := f.Lines[3].Comment; got != 7 {
+		t.Errorf("comment of line 4 at %d, want 7", got)
+	}
+}
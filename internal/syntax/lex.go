@@ -0,0 +1,287 @@
+package syntax
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind classifies a Token.
+type TokenKind int
+
+const (
+	// TokenSpace is a run of spaces and tabs.
+	TokenSpace TokenKind = iota + 1
+	// TokenIdentifier is a name. Keywords used as names, such as end inside
+	// an index or a field named like a keyword, are identifiers too.
+	TokenIdentifier
+	// TokenKeyword is a reserved word such as if or end, or a block keyword
+	// such as properties or arguments.
+	TokenKeyword
+	// TokenNumber is a numeric literal, including its exponent, imaginary
+	// unit and type suffix.
+	TokenNumber
+	// TokenString is a character array or string literal including its
+	// quotes. A literal not closed on its line extends to the end of the
+	// line.
+	TokenString
+	// TokenOperator is an operator such as +, .* or ==, including the
+	// transposes ' and .', the field access dot, the colon, @ and ?.
+	TokenOperator
+	// TokenPunctuation is a bracket, a comma or a semicolon.
+	TokenPunctuation
+	// TokenComment is a comment from its % to the end of the line, or the
+	// text following a continuation.
+	TokenComment
+	// TokenContinuation is the ... continuing a statement on the next line.
+	TokenContinuation
+	// TokenOther is a character that starts no other token.
+	TokenOther
+)
+
+// Token is a lexical element of a line of MATLAB code.
+type Token struct {
+	Kind TokenKind
+	Text string
+	// Offset is the byte offset of the token in its line.
+	Offset int
+}
+
+// IsOpen reports whether t is an opening bracket.
+func (t Token) IsOpen() bool {
+	return t.Kind == TokenPunctuation && strings.Contains("([{", t.Text)
+}
+
+// IsClose reports whether t is a closing bracket.
+func (t Token) IsClose() bool {
+	return t.Kind == TokenPunctuation && strings.Contains(")]}", t.Text)
+}
+
+// IsTranspose reports whether t is a transpose operator.
+func (t Token) IsTranspose() bool {
+	return t.Kind == TokenOperator && (t.Text == "'" || t.Text == ".'")
+}
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "classdef": true,
+	"continue": true, "else": true, "elseif": true, "end": true,
+	"for": true, "function": true, "global": true, "if": true,
+	"otherwise": true, "parfor": true, "persistent": true, "return": true,
+	"spmd": true, "switch": true, "try": true, "while": true,
+	// Block keywords of classdef files and functions.
+	"arguments": true, "enumeration": true, "events": true, "methods": true,
+	"properties": true,
+	// Block ends accepted by Octave.
+	"endfunction": true, "endif": true, "endwhile": true, "endfor": true,
+	"endswitch": true,
+}
+
+// operators lists the operators of two characters; every other operator is
+// a single character of operatorChars.
+var operators = []string{
+	"==", "~=", "!=", "<=", ">=", "&&", "||",
+	".*", "./", `.\`, ".^", ".'",
+	"+=", "-=", "*=", "/=", "^=",
+}
+
+const operatorChars = `+-*/\^'=<>&|~!:.@?`
+
+// Tokenize splits a line of MATLAB code into tokens whose texts concatenate
+// to the line. A quote is a transpose when it directly follows a name, a
+// number, a closing bracket or another transpose, and starts a character
+// array otherwise. Keywords are identifiers when they are used as names:
+// end inside brackets, words following a field access dot and words
+// assigned to.
+func Tokenize(line string) []Token {
+	return MATLAB.Tokenize(line)
+}
+
+// Tokenize is like the function Tokenize for code written in d.
+func (d Dialect) Tokenize(line string) []Token {
+	var toks []Token
+	depth := 0
+	add := func(kind TokenKind, start, end int) {
+		toks = append(toks, Token{Kind: kind, Text: line[start:end], Offset: start})
+	}
+	for i := 0; i < len(line); {
+		start := i
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i = skipBlanks(line, i)
+			add(TokenSpace, start, i)
+		case d.isComment(c):
+			i = len(line)
+			add(TokenComment, start, i)
+		case strings.HasPrefix(line[i:], "..."):
+			i += 3
+			add(TokenContinuation, start, i)
+			if j := skipBlanks(line, i); j > i {
+				add(TokenSpace, i, j)
+				i = j
+			}
+			if i < len(line) {
+				add(TokenComment, i, len(line))
+				i = len(line)
+			}
+		case c == '"' || c == '\'' && !transposes(toks):
+			i = d.scanString(line, i)
+			add(TokenString, start, i)
+		case isDigit(c) || c == '.' && i+1 < len(line) && isDigit(line[i+1]):
+			i = scanNumber(line, i)
+			add(TokenNumber, start, i)
+		case IsIdentChar(c):
+			for i < len(line) && IsIdentChar(line[i]) {
+				i++
+			}
+			kind := TokenIdentifier
+			if keywords[line[start:i]] && !usedAsName(line, toks, start, i, depth) {
+				kind = TokenKeyword
+			}
+			add(kind, start, i)
+		case strings.IndexByte("([{", c) >= 0:
+			depth++
+			i++
+			add(TokenPunctuation, start, i)
+		case strings.IndexByte(")]}", c) >= 0:
+			if depth > 0 {
+				depth--
+			}
+			i++
+			add(TokenPunctuation, start, i)
+		case c == ',' || c == ';':
+			i++
+			add(TokenPunctuation, start, i)
+		default:
+			if n := operatorLen(line, i); n > 0 {
+				i += n
+				add(TokenOperator, start, i)
+				continue
+			}
+			_, n := utf8.DecodeRuneInString(line[i:])
+			i += n
+			add(TokenOther, start, i)
+		}
+	}
+	return toks
+}
+
+// transposes reports whether a quote following toks is a transpose.
+func transposes(toks []Token) bool {
+	if len(toks) == 0 {
+		return false
+	}
+	t := toks[len(toks)-1]
+	switch t.Kind {
+	case TokenIdentifier, TokenNumber:
+		return true
+	case TokenKeyword:
+		return t.Text == "end"
+	}
+	return t.IsClose() || t.IsTranspose()
+}
+
+// usedAsName reports whether the keyword line[start:end] is used as a name.
+func usedAsName(line string, toks []Token, start, end, depth int) bool {
+	if depth > 0 && line[start:end] == "end" {
+		return true
+	}
+	if n := len(toks); n > 0 && toks[n-1].Kind == TokenOperator && toks[n-1].Text == "." {
+		return true
+	}
+	rest := line[skipBlanks(line, end):]
+	return strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "==")
+}
+
+// scanString returns the end of the string literal starting with the quote
+// at i. Doubled quotes stand for a quote inside the literal.
+func (d Dialect) scanString(line string, i int) int {
+	quote := line[i]
+	for i++; i < len(line); i++ {
+		if line[i] == '\\' && d.escapes(quote) {
+			i++
+			continue
+		}
+		if line[i] != quote {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(line)
+}
+
+// scanNumber returns the end of the numeric literal starting at i.
+func scanNumber(line string, i int) int {
+	if line[i] == '0' && i+2 < len(line) && (line[i+1]|0x20 == 'x' && isHexDigit(line[i+2]) || line[i+1]|0x20 == 'b' && (line[i+2] == '0' || line[i+2] == '1')) {
+		// Hexadecimal and binary literals, with suffixes such as u8.
+		for i += 2; i < len(line) && IsIdentChar(line[i]); i++ {
+		}
+		return i
+	}
+	i = skipDigitsFrom(line, i)
+	// A dot followed by an operator character belongs to the operator, as
+	// in 1./x, and three dots are a continuation.
+	if i < len(line) && line[i] == '.' && !strings.HasPrefix(line[i:], "...") && (i+1 == len(line) || strings.IndexByte(`*/\^'`, line[i+1]) < 0) {
+		i = skipDigitsFrom(line, i+1)
+	}
+	if i < len(line) && strings.IndexByte("eEdD", line[i]) >= 0 {
+		j := i + 1
+		if j < len(line) && (line[j] == '+' || line[j] == '-') {
+			j++
+		}
+		if j < len(line) && isDigit(line[j]) {
+			i = skipDigitsFrom(line, j)
+		}
+	}
+	if i < len(line) && strings.IndexByte("ijIJ", line[i]) >= 0 && (i+1 == len(line) || !IsIdentChar(line[i+1])) {
+		i++
+	}
+	return i
+}
+
+// operatorLen returns the length of the operator at i, or 0 if there is none.
+func operatorLen(line string, i int) int {
+	rest := line[i:]
+	if strings.HasPrefix(rest, "++") || strings.HasPrefix(rest, "--") {
+		// Increments such as i++ are followed by the end of the statement.
+		j := skipBlanks(line, i+2)
+		if j == len(line) || strings.IndexByte(")]},;%", line[j]) >= 0 {
+			return 2
+		}
+		return 1
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			return len(op)
+		}
+	}
+	if strings.IndexByte(operatorChars, line[i]) >= 0 {
+		return 1
+	}
+	return 0
+}
+
+func skipBlanks(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+func skipDigitsFrom(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c|0x20 >= 'a' && c|0x20 <= 'f'
+}
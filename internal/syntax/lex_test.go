@@ -0,0 +1,95 @@
+package syntax
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// kinds returns the tokens of line as "kind:text" pairs, leaving out
+// whitespace.
+func kinds(line string) []string {
+	return dialectKinds(MATLAB, line)
+}
+
+// dialectKinds is like kinds for a line written in d.
+func dialectKinds(d Dialect, line string) []string {
+	names := map[TokenKind]string{
+		TokenIdentifier:   "id",
+		TokenKeyword:      "kw",
+		TokenNumber:       "num",
+		TokenString:       "str",
+		TokenOperator:     "op",
+		TokenPunctuation:  "punct",
+		TokenComment:      "comment",
+		TokenContinuation: "cont",
+		TokenOther:        "other",
+	}
+	var got []string
+	for _, t := range d.Tokenize(line) {
+		if t.Kind != TokenSpace {
+			got = append(got, names[t.Kind]+":"+t.Text)
+		}
+	}
+	return got
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"y = x' * z.';", []string{"id:y", "op:=", "id:x", "op:'", "op:*", "id:z", "op:.'", "punct:;"}},
+		{"s = ['a' 'b'''];", []string{"id:s", "op:=", "punct:[", "str:'a'", "str:'b'''", "punct:]", "punct:;"}},
+		{"r = [a' b'];", []string{"id:r", "op:=", "punct:[", "id:a", "op:'", "id:b", "op:'", "punct:]", "punct:;"}},
+		{`t = "50% ""done""" % note`, []string{"id:t", "op:=", `str:"50% ""done"""`, "comment:% note"}},
+		{"disp('it''s % not')", []string{"id:disp", "punct:(", "str:'it''s % not'", "punct:)"}},
+		{"if x(end) > 0, y = 1; end", []string{"kw:if", "id:x", "punct:(", "id:end", "punct:)", "op:>", "num:0", "punct:,", "id:y", "op:=", "num:1", "punct:;", "kw:end"}},
+		{"events = s.end;", []string{"id:events", "op:=", "id:s", "op:.", "id:end", "punct:;"}},
+		{"a == 1e-3 + .5i - 0x1Fu8", []string{"id:a", "op:==", "num:1e-3", "op:+", "num:.5i", "op:-", "num:0x1Fu8"}},
+		{"b = 1./x + 2.^-y", []string{"id:b", "op:=", "num:1", "op:./", "id:x", "op:+", "num:2", "op:.^", "op:-", "id:y"}},
+		{"x = 1... rest 'of' line", []string{"id:x", "op:=", "num:1", "cont:...", "comment:rest 'of' line"}},
+		{"i++; j--", []string{"id:i", "op:++", "punct:;", "id:j", "op:--"}},
+		{"k = a--b", []string{"id:k", "op:=", "id:a", "op:-", "op:-", "id:b"}},
+		{"f = @(x) ~x", []string{"id:f", "op:=", "op:@", "punct:(", "id:x", "punct:)", "op:~", "id:x"}},
+		{"s = 'open", []string{"id:s", "op:=", "str:'open"}},
+	}
+	for _, tt := range tests {
+		if got := kinds(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q):\n got %q\nwant %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeOctave(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"x = 1 # one", []string{"id:x", "op:=", "num:1", "comment:# one"}},
+		{`s = "a\"b # c" % d`, []string{"id:s", "op:=", `str:"a\"b # c"`, "comment:% d"}},
+		{`y = 'a\' # e`, []string{"id:y", "op:=", `str:'a\'`, "comment:# e"}},
+	}
+	for _, tt := range tests {
+		if got := dialectKinds(Octave, tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Octave.Tokenize(%q):\n got %q\nwant %q", tt.line, got, tt.want)
+		}
+	}
+	if got, want := kinds("x = 1 # one"), []string{"id:x", "op:=", "num:1", "other:#", "id:one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize read # as a comment: got %q, want %q", got, want)
+	}
+}
+
+func TestTokenizeCoversLine(t *testing.T) {
+	line := "  x(2:end) = {'a', \"b\"}; % done ... é"
+	var b strings.Builder
+	for _, tok := range Tokenize(line) {
+		if tok.Offset != b.Len() {
+			t.Errorf("token %q at offset %d, want %d", tok.Text, tok.Offset, b.Len())
+		}
+		b.WriteString(tok.Text)
+	}
+	if b.String() != line {
+		t.Errorf("tokens join to %q, want %q", b.String(), line)
+	}
+}
@@ -0,0 +1,548 @@
+// Package syntax recovers the structure of MATLAB source files: the tokens,
+// comments and strings of each line, statements, and the tree of functions,
+// classdef member blocks and control blocks.
+package syntax
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pos is a 1-based line and column position.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Kind classifies a Node.
+type Kind int
+
+const (
+	// KindFunction is a function definition, including nested functions and
+	// methods.
+	KindFunction Kind = iota + 1
+	// KindClassdef is a class definition.
+	KindClassdef
+	// KindBlock is a control block such as if or for, a classdef member block
+	// such as properties or methods, or a function arguments block.
+	KindBlock
+)
+
+// Node is a block of code opened by a keyword and usually closed by end.
+type Node struct {
+	Kind    Kind
+	Keyword string
+	// Start is the position of the opening keyword.
+	Start Pos
+	// End is the position of the closing end keyword. For blocks without an
+	// explicit end it is the start of the last non-blank line of the block.
+	End Pos
+	// Closed reports whether the block is terminated by an end keyword.
+	Closed bool
+	// Name is the function or class name of function and classdef nodes.
+	Name string
+	// Signature is set for function nodes.
+	Signature *Signature
+	// Attributes holds the text inside the parentheses following keywords
+	// such as methods or properties, if any.
+	Attributes string
+	// Branches lists continuation keywords such as else, case and catch.
+	Branches []Branch
+	// Literals lists the bracketed expressions spanning several lines of
+	// the body of the node, outside of its children.
+	Literals []Literal
+	Parent   *Node
+	Children []*Node
+}
+
+// Branch is a continuation keyword inside a control block.
+type Branch struct {
+	Keyword string
+	Pos     Pos
+}
+
+// Signature describes the declaration line of a function.
+type Signature struct {
+	Name    string
+	Inputs  []string
+	Outputs []string
+}
+
+// Section is a code section started by a "%%" comment. End is the last line
+// belonging to the section.
+type Section struct {
+	Title string
+	Start Pos
+	End   int
+}
+
+// Statement is a single statement. Text is the masked code of the statement
+// with continuation lines joined and "..." markers removed.
+type Statement struct {
+	Pos     Pos
+	EndLine int
+	Text    string
+}
+
+// Literal is a bracketed expression such as a matrix or cell array literal.
+// Open is the opening bracket ('(', '[' or '{'), Start and End the positions
+// of the opening and closing brackets.
+type Literal struct {
+	Open  byte
+	Start Pos
+	End   Pos
+}
+
+// Error is a structural problem found while parsing.
+type Error struct {
+	Pos Pos
+	// Keyword is the keyword left unmatched, such as if, end or else.
+	Keyword string
+	Message string
+}
+
+// Line is the scanned form of a source line.
+type Line struct {
+	Text string
+	// Code is the line without its comment, with string contents masked.
+	Code string
+	// Comment is the byte offset of the comment marker or -1.
+	Comment int
+	// BlockComment is set for lines belonging to a %{ ... %} block comment,
+	// including the delimiters.
+	BlockComment bool
+}
+
+// HasCode reports whether the line contains code.
+func (l Line) HasCode() bool {
+	return !l.BlockComment && strings.TrimSpace(l.Code) != ""
+}
+
+// HasComment reports whether the line contains a comment.
+func (l Line) HasComment() bool {
+	return l.BlockComment || l.Comment >= 0 && strings.TrimSpace(l.Text[l.Comment:]) != ""
+}
+
+// File is the parsed structure of a source file.
+type File struct {
+	Lines      []Line
+	Statements []Statement
+	Nodes      []*Node
+	Sections   []Section
+	// Literals lists the bracketed expressions spanning several lines.
+	Literals []Literal
+	Errors   []Error
+	// FunctionEnds reports whether the functions of the file are terminated
+	// with end. Functions without end cannot be nested.
+	FunctionEnds bool
+}
+
+var (
+	blockCommentOpen  = regexp.MustCompile(`^\s*%\{\s*$`)
+	blockCommentClose = regexp.MustCompile(`^\s*%\}\s*$`)
+	// Octave also accepts block comments between #{ and #} lines.
+	octaveCommentOpen  = regexp.MustCompile(`^\s*[%#]\{\s*$`)
+	octaveCommentClose = regexp.MustCompile(`^\s*[%#]\}\s*$`)
+	sectionMarker      = regexp.MustCompile(`^\s*%%(\s.*|$)`)
+	argumentsBlock     = regexp.MustCompile(`^arguments\s*(\(.*\))?\s*$`)
+	memberAttributes   = regexp.MustCompile(`^\w+\s*\((.*)\)\s*$`)
+	abstractAttribute  = regexp.MustCompile(`(?i)(^|[\s,])Abstract(\s*=\s*true)?\s*($|,)`)
+)
+
+var (
+	controlKeywords = map[string]bool{
+		"if": true, "for": true, "parfor": true, "while": true,
+		"switch": true, "try": true, "spmd": true,
+	}
+	memberKeywords = map[string]bool{
+		"properties": true, "methods": true, "events": true, "enumeration": true,
+	}
+	// octaveBlocks are the blocks Octave opens with a statement of a single
+	// word; do blocks are closed by an until statement.
+	octaveBlocks = map[string]bool{
+		"do": true, "unwind_protect": true,
+	}
+	branchKeywords = map[string]string{
+		"elseif": "if", "else": "if", "case": "switch", "otherwise": "switch", "catch": "try",
+		"unwind_protect_cleanup": "unwind_protect",
+	}
+	endKeywords = map[string]bool{
+		"end": true, "endfunction": true, "endif": true, "endwhile": true,
+		"endfor": true, "endswitch": true, "end_try_catch": true, "end_unwind_protect": true,
+	}
+)
+
+// Parse recovers the structure of the given source lines.
+func Parse(lines []string) *File {
+	return MATLAB.Parse(lines)
+}
+
+// Parse is like the function Parse for lines written in d.
+func (d Dialect) Parse(lines []string) *File {
+	f := &File{}
+	f.scanLines(lines, d)
+	f.splitStatements()
+	f.buildTree()
+	f.placeLiterals()
+	f.findSections()
+	return f
+}
+
+func (f *File) scanLines(lines []string, d Dialect) {
+	f.Lines = make([]Line, len(lines))
+	opening, closing := blockCommentOpen, blockCommentClose
+	if d == Octave {
+		opening, closing = octaveCommentOpen, octaveCommentClose
+	}
+	blockDepth := 0
+	for i, text := range lines {
+		l := Line{Text: text, Comment: -1}
+		switch {
+		case opening.MatchString(text):
+			blockDepth++
+			l.BlockComment = true
+		case blockDepth > 0:
+			l.BlockComment = true
+			if closing.MatchString(text) {
+				blockDepth--
+			}
+		default:
+			l.Code, l.Comment = d.ScanLine(text)
+		}
+		f.Lines[i] = l
+	}
+}
+
+// splitStatements splits the code into statements at top-level commas and
+// semicolons, joining lines continued with "..." or open brackets.
+func (f *File) splitStatements() {
+	depth := 0
+	continued := false
+	var open []Literal
+	for i, l := range f.Lines {
+		if l.BlockComment {
+			continue
+		}
+
+		joining := continued || depth > 0
+		start := 0
+		emit := func(end int) {
+			segment := strings.TrimSuffix(strings.TrimRight(l.Code[start:end], " \t"), "...")
+			trimmed := strings.TrimSpace(segment)
+			switch {
+			case joining && len(f.Statements) > 0:
+				s := &f.Statements[len(f.Statements)-1]
+				if trimmed != "" {
+					s.Text = strings.TrimSpace(s.Text + " " + trimmed)
+				}
+				s.EndLine = i + 1
+			case trimmed != "":
+				column := start + len(segment) - len(strings.TrimLeft(segment, " \t")) + 1
+				f.Statements = append(f.Statements, Statement{
+					Pos:     Pos{Line: i + 1, Column: column},
+					EndLine: i + 1,
+					Text:    trimmed,
+				})
+			}
+			joining = false
+		}
+
+		for j := 0; j < len(l.Code); j++ {
+			switch c := l.Code[j]; c {
+			case '(', '[', '{':
+				depth++
+				open = append(open, Literal{Open: c, Start: Pos{Line: i + 1, Column: j + 1}})
+			case ')', ']', '}':
+				if depth > 0 {
+					depth--
+				}
+				if n := len(open); n > 0 {
+					lit := open[n-1]
+					open = open[:n-1]
+					if lit.Start.Line != i+1 {
+						lit.End = Pos{Line: i + 1, Column: j + 1}
+						f.Literals = append(f.Literals, lit)
+					}
+				}
+			case ',', ';':
+				if depth == 0 {
+					emit(j)
+					start = j + 1
+				}
+			}
+		}
+		emit(len(l.Code))
+		continued = strings.HasSuffix(strings.TrimRight(l.Code, " \t"), "...")
+	}
+}
+
+// functionsHaveEnd decides whether the functions of the file are terminated
+// with end by comparing the number of end keywords with the number of blocks.
+func (f *File) functionsHaveEnd() bool {
+	functions, blocks, ends := 0, 0, 0
+	for _, s := range f.Statements {
+		word := FirstWord(s.Text)
+		switch {
+		case word == "classdef":
+			return true
+		case word == "function":
+			functions++
+		case controlKeywords[word] || s.Text == "unwind_protect" || argumentsBlock.MatchString(s.Text) || elseIf(s.Text):
+			blocks++
+		case endKeywords[word]:
+			ends++
+		}
+	}
+	return functions > 0 && ends >= blocks+functions
+}
+
+func (f *File) buildTree() {
+	withEnd := f.functionsHaveEnd()
+	f.FunctionEnds = withEnd
+	var stack []*Node
+
+	top := func() *Node {
+		if len(stack) == 0 {
+			return nil
+		}
+		return stack[len(stack)-1]
+	}
+	push := func(n *Node) {
+		if parent := top(); parent != nil {
+			n.Parent = parent
+			parent.Children = append(parent.Children, n)
+		} else {
+			f.Nodes = append(f.Nodes, n)
+		}
+		stack = append(stack, n)
+	}
+	// closeImplicitly pops every open block, ending them before line.
+	closeImplicitly := func(line int) {
+		end := f.lastNonBlank(line - 1)
+		for len(stack) > 0 {
+			n := top()
+			if n.Kind != KindFunction || withEnd {
+				f.Errors = append(f.Errors, Error{Pos: n.Start, Keyword: n.Keyword, Message: "missing end for " + n.Keyword})
+			}
+			n.End = end
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for _, s := range f.Statements {
+		word := FirstWord(s.Text)
+		parent := top()
+		switch {
+		case word == "function":
+			n := &Node{Kind: KindFunction, Keyword: word, Start: s.Pos, End: s.Pos}
+			n.Signature = ParseSignature(s.Text)
+			n.Name = n.Signature.Name
+			if parent != nil && parent.Keyword == "methods" && abstractAttribute.MatchString(parent.Attributes) {
+				// Abstract method declarations have no body.
+				n.Parent = parent
+				parent.Children = append(parent.Children, n)
+				continue
+			}
+			if !withEnd {
+				closeImplicitly(s.Pos.Line)
+			}
+			push(n)
+		case word == "classdef":
+			push(&Node{Kind: KindClassdef, Keyword: word, Start: s.Pos, End: s.Pos, Name: className(s.Text)})
+		case controlKeywords[word], octaveBlocks[s.Text],
+			memberKeywords[word] && parent != nil && parent.Kind == KindClassdef,
+			word == "arguments" && parent != nil && parent.Kind == KindFunction && argumentsBlock.MatchString(s.Text):
+			n := &Node{Kind: KindBlock, Keyword: word, Start: s.Pos, End: s.Pos}
+			if m := memberAttributes.FindStringSubmatch(s.Text); m != nil && !controlKeywords[word] {
+				n.Attributes = strings.TrimSpace(m[1])
+			}
+			push(n)
+		case branchKeywords[word] != "":
+			if parent == nil || parent.Keyword != branchKeywords[word] {
+				f.Errors = append(f.Errors, Error{Pos: s.Pos, Keyword: word, Message: word + " outside of " + branchKeywords[word] + " block"})
+				continue
+			}
+			parent.Branches = append(parent.Branches, Branch{Keyword: word, Pos: s.Pos})
+			if elseIf(s.Text) {
+				// else if opens an if block nested in the else branch.
+				push(&Node{Kind: KindBlock, Keyword: "if", Start: s.Pos, End: s.Pos})
+			}
+		case endKeywords[word], word == "until" && parent != nil && parent.Keyword == "do":
+			if parent == nil {
+				f.Errors = append(f.Errors, Error{Pos: s.Pos, Keyword: word, Message: "unmatched " + word})
+				continue
+			}
+			parent.End = s.Pos
+			parent.Closed = true
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	closeImplicitly(len(f.Lines) + 1)
+}
+
+// elseIf reports whether the statement text is an else branch starting with
+// an if statement, written else if instead of elseif.
+func elseIf(text string) bool {
+	return FirstWord(text) == "else" && FirstWord(text[len("else"):]) == "if"
+}
+
+// placeLiterals adds each literal to the innermost node containing it.
+func (f *File) placeLiterals() {
+	for _, lit := range f.Literals {
+		if nodes := f.Enclosing(lit.Start.Line); len(nodes) > 0 {
+			n := nodes[len(nodes)-1]
+			n.Literals = append(n.Literals, lit)
+		}
+	}
+}
+
+// Enclosing returns the nodes whose body contains line, from the outermost
+// to the innermost. The line of a closing end belongs to the body of its
+// node; the line of an opening keyword does not.
+func (f *File) Enclosing(line int) []*Node {
+	var chain []*Node
+	nodes := f.Nodes
+	for {
+		var inner *Node
+		for _, n := range nodes {
+			if n.Start.Line < line && line <= n.End.Line {
+				inner = n
+			}
+		}
+		if inner == nil {
+			return chain
+		}
+		chain = append(chain, inner)
+		nodes = inner.Children
+	}
+}
+
+// Continues reports whether line continues a statement started on an
+// earlier line, after a "..." or inside brackets.
+func (f *File) Continues(line int) bool {
+	for _, s := range f.Statements {
+		if s.Pos.Line < line && line <= s.EndLine {
+			return true
+		}
+	}
+	return false
+}
+
+// lastNonBlank returns the start of the last non-blank line at or before line.
+func (f *File) lastNonBlank(line int) Pos {
+	for i := line; i >= 1; i-- {
+		text := f.Lines[i-1].Text
+		if trimmed := strings.TrimLeft(text, " \t"); trimmed != "" {
+			return Pos{Line: i, Column: len(text) - len(trimmed) + 1}
+		}
+	}
+	return Pos{Line: 1, Column: 1}
+}
+
+func (f *File) findSections() {
+	for i, l := range f.Lines {
+		if l.BlockComment {
+			continue
+		}
+		m := sectionMarker.FindStringSubmatch(l.Text)
+		if m == nil {
+			continue
+		}
+		if n := len(f.Sections); n > 0 {
+			f.Sections[n-1].End = i
+		}
+		column := strings.Index(l.Text, "%%") + 1
+		f.Sections = append(f.Sections, Section{
+			Title: strings.TrimSpace(m[1]),
+			Start: Pos{Line: i + 1, Column: column},
+			End:   len(f.Lines),
+		})
+	}
+}
+
+// ParseSignature parses the text of a function declaration statement. The
+// function keyword is optional, as in the method declarations of a classdef.
+func ParseSignature(text string) *Signature {
+	decl := strings.TrimSpace(strings.TrimPrefix(text, "function"))
+	sig := &Signature{}
+
+	if eq := topLevelIndex(decl, '='); eq >= 0 {
+		sig.Outputs = splitNames(strings.Trim(strings.TrimSpace(decl[:eq]), "[]"))
+		decl = strings.TrimSpace(decl[eq+1:])
+	}
+
+	if open := strings.IndexByte(decl, '('); open >= 0 {
+		sig.Name = strings.TrimSpace(decl[:open])
+		args := decl[open+1:]
+		if close := strings.LastIndexByte(args, ')'); close >= 0 {
+			args = args[:close]
+		}
+		sig.Inputs = splitNames(args)
+	} else {
+		sig.Name = strings.TrimSpace(decl)
+	}
+	return sig
+}
+
+// topLevelIndex returns the index of the first c outside brackets.
+func topLevelIndex(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case c:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func splitNames(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// className extracts the class name from a classdef statement.
+func className(text string) string {
+	decl := strings.TrimSpace(strings.TrimPrefix(text, "classdef"))
+	if strings.HasPrefix(decl, "(") {
+		if close := strings.IndexByte(decl, ')'); close >= 0 {
+			decl = strings.TrimSpace(decl[close+1:])
+		}
+	}
+	return FirstWord(decl)
+}
+
+// Functions returns every function of the file in source order, including
+// nested functions and methods.
+func (f *File) Functions() []*Node {
+	var result []*Node
+	var walk func(nodes []*Node)
+	walk = func(nodes []*Node) {
+		for _, n := range nodes {
+			if n.Kind == KindFunction {
+				result = append(result, n)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(f.Nodes)
+	return result
+}
+
+// IsScript reports whether the file is a script, that is, whether its first
+// statement is neither a function nor a classdef declaration.
+func (f *File) IsScript() bool {
+	if len(f.Statements) == 0 {
+		return true
+	}
+	word := FirstWord(f.Statements[0].Text)
+	return word != "function" && word != "classdef"
+}
@@ -0,0 +1,190 @@
+package syntax
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseFunctionsWithoutEnd(t *testing.T) {
+	lines := []string{
+		"%% Setup",
+		"function [a, b] = foo(x, ...",
+		"    y)",
+		"% FOO does things",
+		"if x, a = 1, end",
+		"switch y",
+		"    case 1",
+		"        b = x(end);",
+		"    otherwise",
+		"        b = 'end';",
+		"end",
+		"",
+		"function c = bar",
+		"c = 3;",
+	}
+
+	f := Parse(lines)
+
+	if len(f.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", f.Errors)
+	}
+	fns := f.Functions()
+	if len(fns) != 2 {
+		t.Fatalf("expected 2 functions, got %d", len(fns))
+	}
+
+	foo := fns[0]
+	wantSig := &Signature{Name: "foo", Inputs: []string{"x", "y"}, Outputs: []string{"a", "b"}}
+	if !reflect.DeepEqual(foo.Signature, wantSig) {
+		t.Errorf("foo signature: got %+v want %+v", foo.Signature, wantSig)
+	}
+	if foo.Closed || foo.Start.Line != 2 || foo.End.Line != 11 {
+		t.Errorf("foo range: got %v-%v closed=%v", foo.Start, foo.End, foo.Closed)
+	}
+	if len(foo.Children) != 2 || foo.Children[1].Keyword != "switch" || len(foo.Children[1].Branches) != 2 {
+		t.Errorf("unexpected children of foo: %+v", foo.Children)
+	}
+	if fns[1].Name != "bar" || fns[1].End.Line != 14 {
+		t.Errorf("bar: got %q ending at %v", fns[1].Name, fns[1].End)
+	}
+	if len(f.Sections) != 1 || f.Sections[0].Title != "Setup" || f.Sections[0].End != 14 {
+		t.Errorf("unexpected sections: %+v", f.Sections)
+	}
+	if f.IsScript() {
+		t.Errorf("function file reported as script")
+	}
+}
+
+func TestParseClassdef(t *testing.T) {
+	lines := []string{
+		"classdef (Sealed) Account < handle",
+		"    properties (Access = private)",
+		"        Balance = 0",
+		"    end",
+		"    methods",
+		"        function obj = Account(b)",
+		"            obj.Balance = b;",
+		"        end",
+		"    end",
+		"    methods (Abstract)",
+		"        r = report(obj)",
+		"        function validate(obj)",
+		"    end",
+		"end",
+	}
+
+	f := Parse(lines)
+
+	if len(f.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", f.Errors)
+	}
+	if len(f.Nodes) != 1 || f.Nodes[0].Name != "Account" || !f.Nodes[0].Closed {
+		t.Fatalf("unexpected classdef node: %+v", f.Nodes)
+	}
+	blocks := f.Nodes[0].Children
+	if len(blocks) != 3 || blocks[0].Attributes != "Access = private" || blocks[2].Attributes != "Abstract" {
+		t.Fatalf("unexpected member blocks: %+v", blocks)
+	}
+	if fns := f.Functions(); len(fns) != 2 || fns[1].Name != "validate" || fns[1].Closed {
+		t.Fatalf("unexpected functions: %+v", fns)
+	}
+}
+
+func TestParseReportsUnbalancedBlocks(t *testing.T) {
+	lines := []string{
+		"x = 1;",
+		"end",
+		"if x",
+		"    y = 2;",
+	}
+
+	f := Parse(lines)
+
+	want := []Error{
+		{Pos: Pos{Line: 2, Column: 1}, Keyword: "end", Message: "unmatched end"},
+		{Pos: Pos{Line: 3, Column: 1}, Keyword: "if", Message: "missing end for if"},
+	}
+	if !reflect.DeepEqual(f.Errors, want) {
+		t.Fatalf("unexpected errors: got %v want %v", f.Errors, want)
+	}
+	if !f.IsScript() {
+		t.Errorf("script reported as function file")
+	}
+}
+
+func TestParseOctaveBlocks(t *testing.T) {
+	lines := []string{
+		"function f(x)",
+		"do",
+		"    x++;",
+		"until x > 3",
+		"unwind_protect",
+		"    g(x);",
+		"unwind_protect_cleanup",
+		"    h(x);",
+		"end_unwind_protect",
+		"do = 1;",
+		"end",
+	}
+
+	f := Parse(lines)
+
+	if len(f.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", f.Errors)
+	}
+	var got []string
+	for _, n := range f.Nodes[0].Children {
+		got = append(got, fmt.Sprintf("%s %d-%d", n.Keyword, n.Start.Line, n.End.Line))
+	}
+	if want := []string{"do 2-4", "unwind_protect 5-9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("blocks: got %q, want %q", got, want)
+	}
+	if want := []Branch{{Keyword: "unwind_protect_cleanup", Pos: Pos{Line: 7, Column: 1}}}; !reflect.DeepEqual(f.Nodes[0].Children[1].Branches, want) {
+		t.Errorf("branches: got %+v, want %+v", f.Nodes[0].Children[1].Branches, want)
+	}
+	if !f.FunctionEnds {
+		t.Errorf("functions reported as not terminated with end")
+	}
+}
+
+func TestParseEnclosingBlocks(t *testing.T) {
+	lines := []string{
+		"function f(x)",
+		"for i = 1:3",
+		"    y = [1, ...",
+		"         2];",
+		"    if x, y = 2; end",
+		"end",
+		"end",
+	}
+
+	f := Parse(lines)
+
+	keywords := func(line int) []string {
+		var got []string
+		for _, n := range f.Enclosing(line) {
+			got = append(got, n.Keyword)
+		}
+		return got
+	}
+	if got := keywords(1); got != nil {
+		t.Errorf("line 1: got %q, want none", got)
+	}
+	if got, want := keywords(5), []string{"function", "for"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("line 5: got %q, want %q", got, want)
+	}
+	if got, want := keywords(7), []string{"function"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("line 7: got %q, want %q", got, want)
+	}
+	loop := f.Nodes[0].Children[0]
+	if want := []Literal{{Open: '[', Start: Pos{Line: 3, Column: 9}, End: Pos{Line: 4, Column: 11}}}; !reflect.DeepEqual(loop.Literals, want) {
+		t.Errorf("literals of the loop: got %+v, want %+v", loop.Literals, want)
+	}
+	if !f.Continues(4) || f.Continues(3) || f.Continues(5) {
+		t.Errorf("Continues: got %v %v %v for lines 3 to 5", f.Continues(3), f.Continues(4), f.Continues(5))
+	}
+	if !f.FunctionEnds {
+		t.Errorf("functions reported as not terminated with end")
+	}
+}
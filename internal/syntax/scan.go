@@ -0,0 +1,92 @@
+package syntax
+
+import "strings"
+
+// ScanLine separates the code of a single line from its trailing comment. The
+// returned code has the contents of string literals replaced by underscores so
+// that callers can search it for operators and keywords without false matches.
+// comment is the byte offset of the comment marker or -1 when the line has no
+// comment. Text following a "..." continuation marker is treated as comment.
+func ScanLine(line string) (code string, comment int) {
+	return MATLAB.ScanLine(line)
+}
+
+// ScanLine is like the function ScanLine for code written in d.
+func (d Dialect) ScanLine(line string) (code string, comment int) {
+	masked := []byte(line)
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if quote != 0 {
+			if c == '\\' && d.escapes(quote) && i+1 < len(line) {
+				masked[i] = '_'
+				masked[i+1] = '_'
+				i++
+				continue
+			}
+			if c == quote {
+				if i+1 < len(line) && line[i+1] == quote {
+					masked[i] = '_'
+					masked[i+1] = '_'
+					i++
+					continue
+				}
+				quote = 0
+				continue
+			}
+			masked[i] = '_'
+			continue
+		}
+
+		switch {
+		case d.isComment(c):
+			return string(masked[:i]), i
+		case c == '.' && strings.HasPrefix(line[i:], "..."):
+			return string(masked[:i+3]), i + 3
+		case c == '"':
+			quote = c
+		case c == '\'' && !isTranspose(line, i):
+			quote = c
+		}
+	}
+	return string(masked), -1
+}
+
+// isTranspose reports whether the quote at position i is a transpose operator
+// rather than the start of a character array.
+func isTranspose(line string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	prev := line[i-1]
+	return IsIdentChar(prev) || strings.IndexByte(")]}'.", prev) >= 0
+}
+
+// IsIdentChar reports whether c may appear in a MATLAB identifier.
+func IsIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// FirstWord returns the leading identifier of code, ignoring indentation.
+func FirstWord(code string) string {
+	code = strings.TrimLeft(code, " \t")
+	n := 0
+	for n < len(code) && IsIdentChar(code[n]) {
+		n++
+	}
+	return code[:n]
+}
+
+// BracketDelta returns the change in bracket nesting caused by code.
+func BracketDelta(code string) int {
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,148 @@
+package walk
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is a pattern of a .gitignore file.
+type ignorePattern struct {
+	// base is the directory the pattern is relative to, as a slash-separated
+	// absolute path.
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher holds the patterns of the .gitignore files that apply to a
+// directory, in increasing order of precedence.
+type ignoreMatcher struct {
+	patterns []ignorePattern
+}
+
+// with returns a matcher extended by the patterns of the lines of a
+// .gitignore file in the directory base, a slash-separated absolute path.
+// The receiver is left unchanged, so matchers can be shared between
+// directories walked concurrently.
+func (m *ignoreMatcher) with(base string, lines []string) *ignoreMatcher {
+	var patterns []ignorePattern
+	for _, line := range lines {
+		if p, ok := parseIgnorePattern(base, line); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return m
+	}
+	n := &ignoreMatcher{}
+	if m != nil {
+		n.patterns = append(n.patterns, m.patterns...)
+	}
+	n.patterns = append(n.patterns, patterns...)
+	return n
+}
+
+// ignored reports whether the file or directory at name, a slash-separated
+// absolute path, is ignored. The last matching pattern decides.
+func (m *ignoreMatcher) ignored(name string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		p := m.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := strings.CutPrefix(name, strings.TrimSuffix(p.base, "/")+"/")
+		if !ok {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			return !p.negate
+		}
+	}
+	return false
+}
+
+// parseIgnorePattern parses a line of a .gitignore file in base. It reports
+// false for blank lines and comments.
+func parseIgnorePattern(base, line string) (ignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+	p := ignorePattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// Patterns with a slash other than a trailing one are relative to base;
+	// others match at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	b.WriteString(globRegexp(line))
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// globRegexp translates the wildcards of a gitignore pattern into a regular
+// expression: "*" and "?" do not match slashes, "**" matches across them.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
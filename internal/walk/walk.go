@@ -0,0 +1,258 @@
+// Package walk finds the source files below directories, optionally
+// honoring .gitignore files, walking directories concurrently.
+package walk
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Options configures Files.
+type Options struct {
+	// Extension selects the files returned, such as ".m".
+	Extension string
+	// Gitignore skips the files and directories ignored by the .gitignore
+	// files of the walked directories and of their parents up to the root of
+	// the Git repository, and by its .git/info/exclude file.
+	Gitignore bool
+	// FollowSymlinks walks the directories symbolic links point to, which
+	// are skipped otherwise. Each directory is walked once, so links to a
+	// parent do not loop: the directories found without following links come
+	// first, then those of the links in the order of their paths.
+	FollowSymlinks bool
+}
+
+// Files replaces directory arguments with the files with opts.Extension found
+// below them. Hidden directories such as .git are skipped. Other arguments,
+// including "-", are returned unchanged. The files of each directory are
+// returned in lexical order, as by filepath.WalkDir.
+func Files(paths []string, opts Options) ([]string, error) {
+	var result []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if p == "-" || err != nil || !info.IsDir() {
+			result = append(result, p)
+			continue
+		}
+		files, err := walkDir(p, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, files...)
+	}
+	return result, nil
+}
+
+// walker collects the files below a directory. Directories are read
+// concurrently, by at most one goroutine per CPU at a time. When following
+// symbolic links, the walk goes in rounds: each round walks the directories
+// found without following links below those of the previous round, and
+// collects the links to directories for the next one.
+type walker struct {
+	opts  Options
+	sem   chan struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	files []string
+	// visited holds the resolved paths of the directories walked.
+	visited map[string]bool
+	// links holds the links to directories found by the current round.
+	links []pendingDir
+	err   error
+}
+
+// pendingDir is a directory to walk, to which the patterns of m apply.
+type pendingDir struct {
+	path string
+	m    *ignoreMatcher
+}
+
+func walkDir(root string, opts Options) ([]string, error) {
+	w := &walker{opts: opts, sem: make(chan struct{}, runtime.GOMAXPROCS(0)), visited: map[string]bool{}}
+	var m *ignoreMatcher
+	if opts.Gitignore {
+		var err error
+		if m, err = parentIgnores(root); err != nil {
+			return nil, err
+		}
+	}
+	// The links are claimed in order, so which of several paths to a
+	// directory is walked does not depend on the scheduling.
+	for next := []pendingDir{{root, m}}; len(next) > 0 && w.err == nil; {
+		for _, d := range next {
+			if w.visit(d.path) {
+				w.wg.Add(1)
+				go w.dir(d.path, d.m)
+			}
+		}
+		w.wg.Wait()
+		next, w.links = w.links, nil
+		slices.SortFunc(next, func(a, b pendingDir) int { return comparePaths(a.path, b.path) })
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	slices.SortFunc(w.files, comparePaths)
+	return w.files, nil
+}
+
+// visit reports whether the directory dir is to be walked, which it is
+// unless it was walked before when following symbolic links.
+func (w *walker) visit(dir string) bool {
+	if !w.opts.FollowSymlinks {
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		w.fail(err)
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited[resolved] {
+		return false
+	}
+	w.visited[resolved] = true
+	return true
+}
+
+// dir collects the files of the directory dir, to which the patterns of m
+// apply, and walks its subdirectories. Links to directories are left to the
+// next round.
+func (w *walker) dir(dir string, m *ignoreMatcher) {
+	defer w.wg.Done()
+	w.sem <- struct{}{}
+	entries, err := os.ReadDir(dir)
+	if err == nil && w.opts.Gitignore {
+		m, err = withIgnoreFile(m, dir, filepath.Join(dir, ".gitignore"))
+	}
+	<-w.sem
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	var files []string
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		isLink := w.opts.FollowSymlinks && e.Type()&fs.ModeSymlink != 0 && isDirLink(name)
+		isDir := e.IsDir() || isLink
+		if m != nil && m.ignored(absSlash(name), isDir) {
+			continue
+		}
+		switch {
+		case isDir && strings.HasPrefix(e.Name(), "."):
+		case isLink:
+			w.mu.Lock()
+			w.links = append(w.links, pendingDir{name, m})
+			w.mu.Unlock()
+		case isDir:
+			if w.visit(name) {
+				w.wg.Add(1)
+				go w.dir(name, m)
+			}
+		case filepath.Ext(name) == w.opts.Extension:
+			files = append(files, name)
+		}
+	}
+	w.mu.Lock()
+	w.files = append(w.files, files...)
+	w.mu.Unlock()
+}
+
+// isDirLink reports whether the symbolic link at name points to a directory.
+// Broken links do not.
+func isDirLink(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// parentIgnores returns the patterns that apply to root from outside of it:
+// those of .git/info/exclude and of the .gitignore files of the directories
+// from the root of its Git repository down to the parent of root. Outside of
+// a Git repository there are none.
+func parentIgnores(root string) (*ignoreMatcher, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	repo := ""
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			repo = dir
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if repo == "" {
+		return nil, nil
+	}
+
+	m, err := withIgnoreFile(nil, repo, filepath.Join(repo, ".git", "info", "exclude"))
+	if err != nil {
+		return nil, err
+	}
+	// dirs runs from root up to the repository; root itself is read by the
+	// walker.
+	for i := len(dirs) - 1; i > 0; i-- {
+		if m, err = withIgnoreFile(m, dirs[i], filepath.Join(dirs[i], ".gitignore")); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// withIgnoreFile returns m extended by the patterns of the ignore file at
+// name, which apply to the directory dir. Missing files add no patterns.
+func withIgnoreFile(m *ignoreMatcher, dir, name string) (*ignoreMatcher, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return m.with(absSlash(dir), lines), nil
+}
+
+// absSlash returns the absolute, slash-separated form of name, as matched by
+// ignore patterns.
+func absSlash(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	return filepath.ToSlash(name)
+}
+
+// comparePaths orders paths by their elements, so the files of a directory
+// come where filepath.WalkDir visits the directory.
+func comparePaths(a, b string) int {
+	return slices.Compare(strings.Split(a, string(filepath.Separator)), strings.Split(b, string(filepath.Separator)))
+}
@@ -0,0 +1,157 @@
+package walk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func relative(t *testing.T, root string, files []string) []string {
+	t.Helper()
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(root, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".git/info/exclude":     "scratch.m\n",
+		".gitignore":            "# build output\nbuild/\n*.asv\n/top.m\n",
+		"a.m":                   "",
+		"a/b.m":                 "",
+		"top.m":                 "",
+		"sub/top.m":             "",
+		"scratch.m":             "",
+		"build/gen.m":           "",
+		"toolbox/.gitignore":    "vendor/**\n!vendor/keep.m\n",
+		"toolbox/vendor/x/y.m":  "",
+		"toolbox/vendor/keep.m": "",
+		"toolbox/f.m":           "",
+		".hidden/h.m":           "",
+		"notes.txt":             "",
+	})
+
+	got, err := Files([]string{root}, Options{Extension: ".m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/b.m", "a.m", "build/gen.m", "scratch.m", "sub/top.m", "toolbox/f.m", "toolbox/vendor/keep.m", "toolbox/vendor/x/y.m", "top.m"}
+	if diff := cmp.Diff(want, relative(t, root, got)); diff != "" {
+		t.Errorf("Files() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = Files([]string{root}, Options{Extension: ".m", Gitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a/b.m", "a.m", "sub/top.m", "toolbox/f.m", "toolbox/vendor/keep.m"}
+	if diff := cmp.Diff(want, relative(t, root, got)); diff != "" {
+		t.Errorf("Files(Gitignore) mismatch (-want +got):\n%s", diff)
+	}
+
+	// The patterns of the repository apply below a subdirectory too.
+	got, err = Files([]string{filepath.Join(root, "toolbox"), "-"}, Options{Extension: ".m", Gitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{filepath.Join(root, "toolbox", "f.m"), filepath.Join(root, "toolbox", "vendor", "keep.m"), "-"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Files(subdirectory) mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFilesFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	shared := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.m": "", "real/b.m": ""})
+	writeFiles(t, shared, map[string]string{"lib.m": "", "sub/util.m": ""})
+	for link, target := range map[string]string{
+		"toolbox":  shared,
+		"alias":    filepath.Join(root, "real"),
+		"loop":     root,
+		"broken":   filepath.Join(root, "missing"),
+		"link.m":   filepath.Join(root, "a.m"),
+		"toolbox2": filepath.Join(root, "toolbox"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip(err)
+		}
+	}
+	if err := os.Symlink(root, filepath.Join(shared, "sub", "up")); err != nil {
+		t.Skip(err)
+	}
+
+	got, err := Files([]string{root}, Options{Extension: ".m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.m", "link.m", "real/b.m"}
+	if diff := cmp.Diff(want, relative(t, root, got)); diff != "" {
+		t.Errorf("Files() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = Files([]string{root}, Options{Extension: ".m", FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alias and toolbox2 lead to directories walked through real and
+	// toolbox, whose paths come first.
+	want = []string{"a.m", "link.m", "real/b.m", "toolbox/lib.m", "toolbox/sub/util.m"}
+	if diff := cmp.Diff(want, relative(t, root, got)); diff != "" {
+		t.Errorf("Files(FollowSymlinks) mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.asv", "a/b/c.asv", false, true},
+		{"*.asv", "c.m", false, false},
+		{"build/", "x/build", true, true},
+		{"build/", "build", false, false},
+		{"/top.m", "top.m", false, true},
+		{"/top.m", "sub/top.m", false, false},
+		{"doc/*.m", "doc/a.m", false, true},
+		{"doc/*.m", "doc/x/a.m", false, false},
+		{"**/tmp", "a/b/tmp", true, true},
+		{"a/**/b.m", "a/b.m", false, true},
+		{"a/**/b.m", "a/x/y/b.m", false, true},
+		{"file?.m", "file1.m", false, true},
+		{"file[0-9].m", "filex.m", false, false},
+		{"file[!0-9].m", "filex.m", false, true},
+		{`\#notes`, "#notes", false, true},
+		{"trailing.m   ", "trailing.m", false, true},
+	}
+	for _, tt := range tests {
+		m := (*ignoreMatcher)(nil).with("/repo", []string{tt.pattern})
+		if got := m.ignored("/repo/"+tt.path, tt.isDir); got != tt.want {
+			t.Errorf("pattern %q: ignored(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
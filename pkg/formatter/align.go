@@ -0,0 +1,380 @@
+package formatter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// matrixRow is a line of a multi-line matrix or cell array: its index in the
+// formatted output and its text in the input.
+type matrixRow struct {
+	out   int
+	input string
+	cell  bool
+}
+
+// alignRows aligns the rows of the multi-line literal that rows make up.
+func (f *Formatter) alignRows(output []string, rows []matrixRow) {
+	if len(rows) < 2 || f.opts.MatrixIndent == "preserve" {
+		return
+	}
+	switch {
+	case rows[0].cell && f.matrixIndent:
+		f.alignCellColumns(output, rows)
+	case !rows[0].cell && f.opts.AlignMatrixColumns:
+		f.alignMatrixColumns(output, rows)
+	}
+	if f.opts.CommentColumn == 0 || f.opts.Only == "indent" {
+		f.alignMatrixComments(output, rows, f.tabStop())
+	}
+}
+
+// isAssignment reports whether line, formatted from line n of file, is a
+// line of code holding one assignment aligned by AlignAssignments.
+func (s *session) isAssignment(file *syntax.File, n int, line string) bool {
+	if !s.opts.AlignAssignments || s.opts.Only == "indent" || s.class != "code" || file.Continues(n) {
+		return false
+	}
+	return s.assignmentAt(line) >= 0
+}
+
+// assignmentAt returns the byte offset of the = of the single assignment on
+// line, or -1 when line holds no assignment, several statements or a
+// statement continued on the next line.
+func (f *Formatter) assignmentAt(line string) int {
+	at, depth := -1, 0
+	for _, t := range f.dialect.Tokenize(line) {
+		switch {
+		case t.Kind == syntax.TokenContinuation:
+			return -1
+		case t.IsOpen():
+			depth++
+		case t.IsClose():
+			depth--
+		case depth > 0:
+		case t.Text == "=":
+			if at >= 0 {
+				return -1
+			}
+			at = t.Offset
+		case t.Text == "," || t.Text == ";":
+			if at < 0 {
+				return -1
+			}
+		}
+	}
+	return at
+}
+
+// alignAssignments pads the code before the = of the assignments on the
+// lines assigns of output so that their = line up one space after the
+// longest of them.
+func (s *session) alignAssignments(output []string, assigns []int) {
+	if len(assigns) < 2 {
+		return
+	}
+	width := 0
+	for _, i := range assigns {
+		lhs := strings.TrimRight(output[i][:s.assignmentAt(output[i])], " ")
+		width = max(width, utf8.RuneCountInString(lhs))
+	}
+	for _, i := range assigns {
+		line := output[i]
+		eq := s.assignmentAt(line)
+		lhs := strings.TrimRight(line[:eq], " ")
+		line = lhs + strings.Repeat(" ", width+1-utf8.RuneCountInString(lhs)) + line[eq:]
+		if s.opts.CommentColumn > 0 {
+			line = s.alignComment(line, s.opts.CommentColumn, s.tabStop())
+		}
+		output[i] = line
+	}
+}
+
+// placeComment moves the trailing comment of line, formatted from a line of
+// class s.class, to CommentColumn.
+func (s *session) placeComment(line string) string {
+	switch s.class {
+	case "ignored", "block-comment", "comment", "command":
+		return line
+	}
+	if s.opts.CommentColumn == 0 || s.opts.Only == "indent" {
+		return line
+	}
+	return s.alignComment(line, s.opts.CommentColumn, s.tabStop())
+}
+
+// alignComment pads the whitespace before the trailing % comment of line so
+// that the comment starts in column, counting from one, or to one space
+// when the code reaches that column. Tabs advance to the next multiple of
+// tabWidth columns.
+func (f *Formatter) alignComment(line string, column, tabWidth int) string {
+	_, c := f.dialect.ScanLine(line)
+	if c < 0 || line[c] != '%' && line[c] != '#' {
+		return line
+	}
+	code := strings.TrimRight(line[:c], " \t")
+	if strings.TrimSpace(code) == "" {
+		return line
+	}
+	pad := max(column-1-columnAt(code, len(code), tabWidth), 1)
+	return code + strings.Repeat(" ", pad) + line[c:]
+}
+
+// alignMatrixComments keeps the trailing comments of the rows of a multi-line
+// matrix aligned. When at least two rows had their comments in the same
+// column in the input, the comments are placed one space after the longest
+// of those rows in output. Tabs in the input advance to the next multiple of
+// tabWidth columns.
+func (f *Formatter) alignMatrixComments(output []string, rows []matrixRow, tabWidth int) {
+	var commented []matrixRow
+	column := -1
+	for _, r := range rows {
+		_, c := f.dialect.ScanLine(r.input)
+		if c < 0 || strings.TrimSpace(r.input[:c]) == "" {
+			continue
+		}
+		c = columnAt(r.input, c, tabWidth)
+		if column >= 0 && c != column {
+			return
+		}
+		column = c
+		commented = append(commented, r)
+	}
+	if len(commented) < 2 {
+		return
+	}
+
+	width := 0
+	for _, r := range commented {
+		_, c := f.dialect.ScanLine(output[r.out])
+		if c < 0 {
+			return
+		}
+		width = max(width, len(strings.TrimRight(output[r.out][:c], " \t")))
+	}
+	for _, r := range commented {
+		line := output[r.out]
+		_, c := f.dialect.ScanLine(line)
+		code := strings.TrimRight(line[:c], " \t")
+		output[r.out] = code + strings.Repeat(" ", width+1-len(code)) + line[c:]
+	}
+}
+
+// alignCellColumns pads the elements of the rows of a multi-line cell array
+// into columns. Rows are only aligned when their first elements start in the
+// same column and no row leaves a nested bracket open.
+func (f *Formatter) alignCellColumns(output []string, rows []matrixRow) {
+	elements := make([][][2]int, len(rows))
+	first := -1
+	for i, r := range rows {
+		spans, ok := f.rowElements(output[r.out], i > 0, '{')
+		if !ok {
+			return
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		if first >= 0 && spans[0][0] != first {
+			return
+		}
+		first = spans[0][0]
+		elements[i] = spans
+	}
+
+	var widths []int
+	for i, r := range rows {
+		spans := elements[i]
+		for k := 0; k+1 < len(spans); k++ {
+			w := utf8.RuneCountInString(output[r.out][spans[k][0]:spans[k][1]])
+			if k == len(widths) {
+				widths = append(widths, w)
+			}
+			widths[k] = max(widths[k], w)
+		}
+	}
+	if len(widths) == 0 {
+		return
+	}
+
+	for i, r := range rows {
+		spans := elements[i]
+		if len(spans) < 2 {
+			continue
+		}
+		line := output[r.out]
+		var b strings.Builder
+		b.WriteString(line[:spans[0][0]])
+		for k := 0; k+1 < len(spans); k++ {
+			text := line[spans[k][0]:spans[k][1]]
+			b.WriteString(text)
+			b.WriteString(line[spans[k][1]:spans[k+1][0]])
+			b.WriteString(strings.Repeat(" ", widths[k]-utf8.RuneCountInString(text)))
+		}
+		b.WriteString(line[spans[len(spans)-1][0]:])
+		output[r.out] = b.String()
+	}
+}
+
+// alignMatrixColumns pads the elements of the rows of a multi-line matrix
+// into columns as wide as their widest element, right-aligning numbers and
+// left-aligning other elements. Rows are only aligned when their first
+// elements start in the same column and no row leaves a nested bracket open.
+func (f *Formatter) alignMatrixColumns(output []string, rows []matrixRow) {
+	elements := make([][][2]int, len(rows))
+	first := -1
+	for i, r := range rows {
+		spans, ok := f.rowElements(output[r.out], i > 0, '[')
+		if !ok {
+			return
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		if first >= 0 && spans[0][0] != first {
+			return
+		}
+		first = spans[0][0]
+		elements[i] = spans
+	}
+
+	var widths []int
+	for i, r := range rows {
+		for k, span := range elements[i] {
+			w := utf8.RuneCountInString(output[r.out][span[0]:span[1]])
+			if k == len(widths) {
+				widths = append(widths, w)
+			}
+			widths[k] = max(widths[k], w)
+		}
+	}
+
+	for i, r := range rows {
+		spans := elements[i]
+		if len(spans) == 0 {
+			continue
+		}
+		line := output[r.out]
+		var b strings.Builder
+		b.WriteString(line[:spans[0][0]])
+		for k, span := range spans {
+			text := line[span[0]:span[1]]
+			pad := strings.Repeat(" ", widths[k]-utf8.RuneCountInString(text))
+			number := isNumber(text)
+			if number {
+				b.WriteString(pad)
+			}
+			b.WriteString(text)
+			if k+1 < len(spans) {
+				b.WriteString(line[span[1]:spans[k+1][0]])
+				if !number {
+					b.WriteString(pad)
+				}
+			}
+		}
+		b.WriteString(line[spans[len(spans)-1][1]:])
+		output[r.out] = b.String()
+	}
+}
+
+// isNumber reports whether the element text is a numeric literal, with an
+// optional sign.
+func isNumber(text string) bool {
+	var toks []syntax.Token
+	for _, t := range syntax.Tokenize(text) {
+		if t.Kind != syntax.TokenSpace {
+			toks = append(toks, t)
+		}
+	}
+	if len(toks) == 2 && (toks[0].Text == "-" || toks[0].Text == "+") {
+		toks = toks[1:]
+	}
+	return len(toks) == 1 && toks[0].Kind == syntax.TokenNumber
+}
+
+// openBracket returns the byte offset of the outermost bracket left open by
+// the code of line, or -1 when every bracket is closed.
+func (f *Formatter) openBracket(line string) int {
+	code, _ := f.dialect.ScanLine(line)
+	var opened []int
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '(', '[', '{':
+			opened = append(opened, i)
+		case ')', ']', '}':
+			if len(opened) > 0 {
+				opened = opened[:len(opened)-1]
+			}
+		}
+	}
+	if len(opened) == 0 {
+		return -1
+	}
+	return opened[0]
+}
+
+// rowElements returns the byte ranges of the elements of the outermost open
+// matrix or cell array, opened by open, on a row. inside reports whether the
+// row starts inside it. ok is false when the row cannot be split into
+// elements.
+func (f *Formatter) rowElements(line string, inside bool, open byte) (spans [][2]int, ok bool) {
+	code, _ := f.dialect.ScanLine(line)
+	code = strings.TrimSuffix(code, "...")
+	from := 0
+	if !inside {
+		// The row opens the cell array with its only unclosed bracket.
+		var opened []int
+		for i := 0; i < len(code); i++ {
+			switch code[i] {
+			case '(', '[', '{':
+				opened = append(opened, i)
+			case ')', ']', '}':
+				if len(opened) > 0 {
+					opened = opened[:len(opened)-1]
+				}
+			}
+		}
+		if len(opened) != 1 || code[opened[0]] != open {
+			return nil, false
+		}
+		from = opened[0] + 1
+	}
+
+	start := -1
+	end := func(i int) {
+		if start >= 0 {
+			spans = append(spans, [2]int{start, len(strings.TrimRight(line[:i], " \t"))})
+			start = -1
+		}
+	}
+	depth := 1
+	for i := from; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				end(i)
+				return spans, true
+			}
+		case depth == 1 && (c == ',' || c == ';'):
+			end(i)
+			continue
+		case depth == 1 && (c == ' ' || c == '\t'):
+			j := skipSpace(code, i+1)
+			if start >= 0 && elementBreak(strings.TrimRight(code[:i], " \t"), code, j) {
+				end(i)
+			}
+			i = j - 1
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	end(len(code))
+	return spans, depth == 1
+}
@@ -0,0 +1,63 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// BlockError is a block opened without its end, or an end or a branch such
+// as else outside of the block it belongs to. Line and Column count from
+// one.
+type BlockError struct {
+	Line   int
+	Column int
+	// Keyword is the keyword left unmatched, such as if, end or else.
+	Keyword string
+	Message string
+}
+
+func (e BlockError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// UnbalancedError is returned by CheckBlocks, and by the formatting methods
+// with StrictBlocks, for lines whose blocks do not match their ends. Errors
+// are ordered by position.
+type UnbalancedError struct {
+	Errors []BlockError
+}
+
+func (e *UnbalancedError) Error() string {
+	msg := "unbalanced blocks: " + e.Errors[0].Error()
+	if n := len(e.Errors) - 1; n > 0 {
+		msg += fmt.Sprintf(" and %d more", n)
+	}
+	return msg
+}
+
+// CheckBlocks returns an *UnbalancedError when the blocks of lines do not
+// match their ends, and nil otherwise.
+func (f *Formatter) CheckBlocks(lines []string) error {
+	return unbalanced(f.forLines(lines).dialect.Parse(lines))
+}
+
+// unbalanced returns the *UnbalancedError reporting the structural errors of
+// file, or nil when it has none.
+func unbalanced(file *syntax.File) error {
+	if len(file.Errors) == 0 {
+		return nil
+	}
+	errs := make([]BlockError, len(file.Errors))
+	for i, e := range file.Errors {
+		errs[i] = BlockError{Line: e.Pos.Line, Column: e.Pos.Column, Keyword: e.Keyword, Message: e.Message}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return &UnbalancedError{Errors: errs}
+}
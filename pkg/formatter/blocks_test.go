@@ -0,0 +1,49 @@
+package formatter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckBlocks(t *testing.T) {
+	f, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := f.CheckBlocks([]string{"if x", "    y = 1;", "end"}); err != nil {
+		t.Errorf("CheckBlocks of balanced blocks: %v", err)
+	}
+
+	err = f.CheckBlocks([]string{"while x", "  else", "end", "end", "for i = 1:3"})
+	var unbalanced *UnbalancedError
+	if !errors.As(err, &unbalanced) {
+		t.Fatalf("CheckBlocks: got %v, want an *UnbalancedError", err)
+	}
+	want := []BlockError{
+		{Line: 2, Column: 3, Keyword: "else", Message: "else outside of if block"},
+		{Line: 4, Column: 1, Keyword: "end", Message: "unmatched end"},
+		{Line: 5, Column: 1, Keyword: "for", Message: "missing end for for"},
+	}
+	if !reflect.DeepEqual(unbalanced.Errors, want) {
+		t.Errorf("errors:\n got %+v\nwant %+v", unbalanced.Errors, want)
+	}
+	if got, want := err.Error(), "unbalanced blocks: 2:3: else outside of if block and 2 more"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatLinesStrictBlocks(t *testing.T) {
+	lines := []string{"if x", "y=1;", "end", "end"}
+	f, err := New(WithStrictBlocks(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var unbalanced *UnbalancedError
+	if _, err := f.FormatLines(lines); !errors.As(err, &unbalanced) {
+		t.Errorf("FormatLines: got %v, want an *UnbalancedError", err)
+	}
+	if got, err := f.FormatLines(lines[:3]); err != nil || !reflect.DeepEqual(got, []string{"if x", "    y = 1;", "end"}) {
+		t.Errorf("FormatLines of balanced blocks: got %q, %v", got, err)
+	}
+}
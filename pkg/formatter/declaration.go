@@ -0,0 +1,50 @@
+package formatter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// declarationLine matches a property or argument declaration: the name, an
+// optional size specification, class and validator list, and the default.
+var declarationLine = regexp.MustCompile(`^(\w+(?:\.\w+)*)\s*(\([^()]*\))?\s*([A-Za-z][\w.]*)?\s*(\{.*\})?\s*(=.*)?$`)
+
+// formatDeclaration puts exactly one space between the parts of a
+// declaration in a properties or arguments block, one space after the commas
+// of its size specification and no padding inside the braces of its
+// validator list. Lines that are not declarations are returned unchanged.
+func (f *Formatter) formatDeclaration(line string) string {
+	code, comment := f.dialect.ScanLine(line)
+	if strings.HasSuffix(code, "...") {
+		return line
+	}
+	body := strings.TrimRight(line[:len(code)], " \t")
+	rest := line[len(body):]
+	if comment < 0 {
+		rest = ""
+	}
+	m := declarationLine.FindStringSubmatch(strings.TrimSpace(body))
+	if m == nil {
+		return line
+	}
+
+	parts := []string{m[1]}
+	if size := m[2]; size != "" {
+		dims := strings.Split(size[1:len(size)-1], ",")
+		for i, d := range dims {
+			dims[i] = strings.TrimSpace(d)
+		}
+		parts = append(parts, "("+strings.Join(dims, ", ")+")")
+	}
+	if m[3] != "" {
+		parts = append(parts, m[3])
+	}
+	if validators := m[4]; validators != "" {
+		parts = append(parts, "{"+strings.TrimSpace(validators[1:len(validators)-1])+"}")
+	}
+	if m[5] != "" {
+		parts = append(parts, "= "+strings.TrimSpace(m[5][1:]))
+	}
+	indent := body[:len(body)-len(strings.TrimLeft(body, " \t"))]
+	return indent + strings.Join(parts, " ") + rest
+}
@@ -0,0 +1,82 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// indentWidths are the indentation widths detectIndentation recognizes.
+var indentWidths = []int{2, 3, 4, 8}
+
+// forLines returns the formatter formatting lines: f itself or a formatter
+// using the dialect detected in lines when Dialect is "auto" and, with
+// DetectIndentation, the indentation detected in them.
+func (f *Formatter) forLines(lines []string) *Formatter {
+	o := f.opts
+	dialect := f.dialect
+	if o.Dialect == "auto" && syntax.DetectDialect(lines) == syntax.Octave {
+		o.Dialect = "octave"
+		dialect = syntax.Octave
+	}
+	if o.DetectIndentation {
+		if width, style, ok := detectIndentation(lines, dialect); ok {
+			o.IndentStyle = style
+			if style == "space" {
+				o.IndentWidth = width
+			}
+		}
+	}
+	if o == f.opts {
+		return f
+	}
+	detected, err := New(o)
+	if err != nil {
+		return f
+	}
+	return detected
+}
+
+// detectIndentation returns the indentation lines are indented with: the
+// style, "tab" or "space", of most indented lines and, for spaces, the most
+// frequent of indentWidths by which a line is indented more than the code
+// line before it. Continuation lines and comments are not counted. ok is
+// false when no line is indented.
+func detectIndentation(lines []string, d syntax.Dialect) (width int, style string, ok bool) {
+	tabs, spaces := 0, 0
+	steps := make(map[int]int)
+	prev := -1
+	for _, line := range lines {
+		toks := d.Tokenize(line)
+		first := firstToken(toks)
+		if first.Kind == 0 || first.Kind == syntax.TokenComment {
+			continue
+		}
+		lead := leadingSpace(line)
+		switch {
+		case strings.HasPrefix(lead, "\t"):
+			tabs++
+		case lead != "":
+			spaces++
+		}
+		if prev >= 0 && !strings.Contains(lead, "\t") && len(lead) > prev {
+			steps[len(lead)-prev]++
+		}
+		prev = -1
+		if !hasContinuation(toks) && !strings.Contains(lead, "\t") {
+			prev = len(lead)
+		}
+	}
+	if tabs+spaces == 0 {
+		return 0, "", false
+	}
+	if tabs > spaces {
+		return 0, "tab", true
+	}
+	for _, w := range indentWidths {
+		if steps[w] > steps[width] {
+			width = w
+		}
+	}
+	return width, "space", width > 0
+}
@@ -0,0 +1,52 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+func TestDetectIndentation(t *testing.T) {
+	tests := []struct {
+		lines []string
+		width int
+		style string
+		ok    bool
+	}{
+		{[]string{"function f()", "  if x", "    y = 1;", "  end", "end"}, 2, "space", true},
+		{[]string{"if x", "   % comment", "   y = a + ...", "         b;", "end"}, 3, "space", true},
+		{[]string{"function f()", "\tif x", "\t\ty = 1;", "\tend", "end"}, 0, "tab", true},
+		{[]string{"x = 1;", "y = 2;"}, 0, "", false},
+	}
+	for _, tt := range tests {
+		width, style, ok := detectIndentation(tt.lines, syntax.MATLAB)
+		if width != tt.width || style != tt.style || ok != tt.ok {
+			t.Errorf("detectIndentation(%q) = %d, %q, %t; want %d, %q, %t", tt.lines, width, style, ok, tt.width, tt.style, tt.ok)
+		}
+	}
+}
+
+func TestFormatLinesDetectIndentation(t *testing.T) {
+	f, err := New(WithDetectIndentation(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"function f()", "  if x", "y=1;", "  end", "end"}
+	got, err := f.FormatRanges(lines, []LineRange{NewLineRange(3, 3)})
+	if err != nil {
+		t.Fatalf("FormatRanges: %v", err)
+	}
+	want := []string{"function f()", "  if x", "    y = 1;", "  end", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	tabbed := []string{"if x", "\ty=1;", "end"}
+	if got, err = f.FormatLines(tabbed); err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if want := []string{"if x", "\ty = 1;", "end"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+// Package formatter formats MATLAB source code.
+//
+// A Formatter is created with New from the settings that differ from
+// DefaultOptions, given by functions such as WithIndentWidth and typed
+// constants such as IndentClassic:
+//
+//	f, err := formatter.New(formatter.WithIndentWidth(2), formatter.WithIndentMode(formatter.IndentClassic))
+//	if err != nil {
+//		return err
+//	}
+//	formatted, err := f.Format(src)
+//
+// An Options value is an option too, so that options read from
+// configuration files by name can be passed as a whole with New(opts).
+//
+// Format formats the content of a file held in memory, FormatReader a stream
+// and FormatFile a file on disk. Underneath, the formatter works on the lines
+// of a file without their line endings: ReadLines splits content accepting
+// \n, \r\n and \r, FormatLines formats a whole file or the lines between
+// Options.StartLine and Options.EndLine, FormatRanges formats several ranges,
+// and JoinLines writes the line ending selected by Options.LineEnding. With
+// the LineEnding auto, JoinLinesLike and the entry points reading content
+// keep the line ending most of its lines end with.
+// FormatFileContext, FormatLinesContext and FormatRangesContext stop with the
+// error of a context when it is cancelled or its deadline passes, so that
+// callers such as editors can give up on formatting long files.
+// FormatToEdits returns the changes FormatLines makes as the minimal edits of
+// runs of lines, for callers applying them incrementally, such as editors.
+// CheckBlocks reports blocks that do not match their ends as an
+// *UnbalancedError listing the line, column and keyword of each, which the
+// formatting methods return instead of formatting with Options.StrictBlocks.
+//
+// Each line is split into tokens, such as names, numbers, strings, operators
+// and comments, before it is formatted: the keyword starting a line selects
+// its indentation, and the spaces between tokens follow from their kinds, so
+// that strings and comments are kept as written and a quote is told apart
+// from a transpose.
+//
+// The conventions of function-based test files, detected by IsTestFile, apply
+// to whole files only and are a separate call, ApplyTestConventions.
+// QualifyImports needs the package of the file, passed to the formatting
+// methods with the InPackage call option. FormatLinesTrace and
+// FormatRangesTrace return how each line was classified and which spacing
+// rules applied to it along with the result. Files marked as generated, as reported by IsGenerated, are
+// usually left alone by callers.
+//
+// A configured Formatter may be shared by goroutines formatting different
+// files at once; each call keeps its state to itself.
+//
+// Rules describes the formatting rules and the options configuring them, by
+// the names used in configuration files.
+package formatter
@@ -0,0 +1,80 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/koyashimano/matlab-formatter/internal/diff"
+)
+
+// Edit replaces a contiguous range of lines with new content. StartLine is
+// 1-based and EndLine is inclusive, matching Options.StartLine and
+// Options.EndLine. An insertion before StartLine is expressed with EndLine set
+// to StartLine-1.
+type Edit struct {
+	StartLine int
+	EndLine   int
+	Lines     []string
+}
+
+// FormatToEdits formats lines like FormatLines and returns the changes as the
+// minimal edits of lines turning them into the result, in order. Each edit
+// replaces a run of changed lines, so unchanged lines are never part of one.
+// Applying the edits to lines with ApplyEdits yields the formatted lines; no
+// edits are returned when nothing changes.
+func (f *Formatter) FormatToEdits(lines []string, opts ...CallOption) ([]Edit, error) {
+	formatted, err := f.FormatLines(lines, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return lineEdits(lines, formatted), nil
+}
+
+// lineEdits returns the edits turning a into b, computed from the shortest
+// edit script.
+func lineEdits(a, b []string) []Edit {
+	var edits []Edit
+	var current *Edit
+	line := 1
+	for _, op := range diff.Lines(a, b) {
+		if op.Kind == diff.Equal {
+			current = nil
+			line++
+			continue
+		}
+		if current == nil {
+			edits = append(edits, Edit{StartLine: line, EndLine: line - 1})
+			current = &edits[len(edits)-1]
+		}
+		if op.Kind == diff.Delete {
+			current.EndLine++
+			line++
+		} else {
+			current.Lines = append(current.Lines, op.Text)
+		}
+	}
+	return edits
+}
+
+// ApplyEdits returns a copy of lines with the supplied edits applied. Edits
+// may be given in any order but must not overlap.
+func ApplyEdits(lines []string, edits []Edit) ([]string, error) {
+	sorted := append([]Edit{}, edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartLine < sorted[j].StartLine
+	})
+
+	result := make([]string, 0, len(lines))
+	next := 1
+	for _, e := range sorted {
+		if e.StartLine < next || e.EndLine < e.StartLine-1 || e.EndLine > len(lines) {
+			return nil, fmt.Errorf("invalid or overlapping edit for lines %d-%d", e.StartLine, e.EndLine)
+		}
+		result = append(result, lines[next-1:e.StartLine-1]...)
+		result = append(result, e.Lines...)
+		next = e.EndLine + 1
+	}
+	result = append(result, lines[next-1:]...)
+
+	return result, nil
+}
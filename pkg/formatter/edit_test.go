@@ -0,0 +1,75 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEditsReplacesAndInserts(t *testing.T) {
+	lines := []string{"a", "b", "c", "d"}
+	edits := []Edit{
+		{StartLine: 4, EndLine: 4, Lines: []string{"D"}},
+		{StartLine: 2, EndLine: 1, Lines: []string{"inserted"}},
+		{StartLine: 2, EndLine: 3, Lines: []string{"bc"}},
+	}
+
+	got, err := ApplyEdits(lines, edits)
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+
+	want := []string{"a", "inserted", "bc", "D"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %#v want %#v", got, want)
+	}
+}
+
+func TestApplyEditsRejectsOverlap(t *testing.T) {
+	lines := []string{"a", "b", "c"}
+	edits := []Edit{
+		{StartLine: 1, EndLine: 2, Lines: nil},
+		{StartLine: 2, EndLine: 3, Lines: nil},
+	}
+
+	if _, err := ApplyEdits(lines, edits); err == nil {
+		t.Fatalf("expected error for overlapping edits")
+	}
+}
+
+func TestFormatToEdits(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SeparateBlocks = false
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"x = 1;", "if x", "y=2;", "", "", "end", "z = 3;"}
+
+	edits, err := f.FormatToEdits(lines)
+	if err != nil {
+		t.Fatalf("FormatToEdits: %v", err)
+	}
+	want := []Edit{
+		{StartLine: 3, EndLine: 3, Lines: []string{"    y = 2;"}},
+		{StartLine: 5, EndLine: 5},
+	}
+	if !reflect.DeepEqual(edits, want) {
+		t.Fatalf("unexpected edits: got %#v want %#v", edits, want)
+	}
+
+	formatted, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	got, err := ApplyEdits(lines, edits)
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+	if !reflect.DeepEqual(got, formatted) {
+		t.Fatalf("applied edits: got %#v want %#v", got, formatted)
+	}
+
+	if edits, err := f.FormatToEdits(formatted); err != nil || edits != nil {
+		t.Fatalf("FormatToEdits of formatted lines = %#v, %v", edits, err)
+	}
+}
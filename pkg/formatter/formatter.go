@@ -0,0 +1,1741 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// Options captures the configuration for the formatter. Values mirror the
+// original VS Code extension to maintain compatibility. Start from
+// DefaultOptions: the zero value is not a valid configuration.
+type Options struct {
+	// StartLine and EndLine select the lines FormatLines formats, 1-based
+	// and inclusive; an EndLine of 0 stands for the last line. Lines outside
+	// the range are returned unchanged.
+	StartLine int
+	EndLine   int
+	// IndentWidth is the number of columns of each indentation level. It
+	// must be greater than zero.
+	IndentWidth int
+	// NestedIndentWidth is the number of spaces by which the bodies of
+	// functions nested in other functions are indented. Zero uses
+	// IndentWidth.
+	NestedIndentWidth int
+	// SeparateBlocks separates the blocks listed in SeparatedBlocks from the
+	// surrounding code by a blank line.
+	SeparateBlocks bool
+	// SeparatedBlocks is a comma-separated list of the kinds of blocks
+	// SeparateBlocks applies to: "function", "classdef" for classdef and
+	// its member blocks, "if", "loop" for for, parfor, while and do,
+	// "switch", "try" for try and unwind_protect, "spmd" and "arguments",
+	// or "all" for every kind. Unknown kinds are ignored.
+	SeparatedBlocks string
+	// FunctionBlankLines and NestedFunctionBlankLines are the numbers of
+	// blank lines written before each function declaration that follows
+	// other code, above the comments directly preceding it, for functions
+	// not nested in another function, such as local functions and methods,
+	// and for nested functions. Zero keeps the blank lines there.
+	FunctionBlankLines       int
+	NestedFunctionBlankLines int
+	// MaxBlankLines is the number of consecutive blank lines kept; longer
+	// runs are collapsed to it. Zero removes the blank lines of the input.
+	MaxBlankLines int
+	// IndentMode selects which function bodies are indented:
+	// "all_functions", "only_nested_functions" or "classic", which indents
+	// none. Unknown values use "all_functions".
+	IndentMode string
+	// AddSpaces selects the spaces around binary operators:
+	// "all_operators", "exclude_pow" to keep ^ and .^ tight, or "no_spaces".
+	// Unknown values use "exclude_pow".
+	AddSpaces string
+	// SpacedOperators is a comma-separated list of the classes of binary
+	// operators written with spaces around them, replacing AddSpaces when
+	// not empty: "assignment" for =, "relational", "arithmetic",
+	// "elementwise" for .*, ./ and .\, "power" for ^ and .^, and "logical",
+	// or "none". Unknown classes are ignored.
+	SpacedOperators string
+	// SpaceShortCircuit writes spaces around && and || whatever AddSpaces
+	// and SpacedOperators select.
+	SpaceShortCircuit bool
+	// ColonSpacing selects the spacing around the colons of ranges such as
+	// 1:0.1:10: "tight", "spaced" for 1 : 0.1 : 10, or "keep" to keep
+	// whether they were spaced. Colons standing for a whole dimension, as in
+	// a(:, 1), are always tight. Unknown values use "tight".
+	ColonSpacing string
+	// NormalizeEnds writes the keywords closing blocks as end, rewriting the
+	// Octave forms endif, endfor, endwhile, endswitch, endfunction and
+	// end_try_catch, and removes the semicolon ending a line after such a
+	// keyword, as in end;.
+	NormalizeEnds bool
+	// UnarySigns selects how a + or - with whitespace before but not after
+	// it, as in a -1, is read: "context" reads it as a sign only directly
+	// inside [...] and {...} literals, where it starts an element, and as a
+	// binary operator elsewhere, as MATLAB does; "whitespace" reads it as a
+	// sign everywhere, as the reference formatter does. Unknown values use
+	// "whitespace".
+	UnarySigns string
+	// SpaceAfterComma writes one space after the commas and semicolons
+	// followed by code, independently of AddSpaces; otherwise none.
+	SpaceAfterComma bool
+	// SpaceInParens, SpaceInBrackets and SpaceInBraces write one space
+	// inside the parentheses, square brackets and braces enclosing code,
+	// as in ( x + 1 ), [ 1, 2 ] and { 'a' }, instead of none.
+	SpaceInParens   bool
+	SpaceInBrackets bool
+	SpaceInBraces   bool
+	// MatrixIndent selects the indentation of the continuation rows of
+	// multi-line matrices and cell arrays: "aligned" with the first element,
+	// "simple" for one level, or "preserve" to keep the text of their lines
+	// and move their rows along with the line opening them. Unknown values
+	// use "aligned".
+	MatrixIndent string
+	// ClassdefIndent selects which levels a classdef adds: "all" indents the
+	// member blocks (properties, methods, events and enumeration) within the
+	// classdef and their contents within the blocks; "blocks" keeps the block
+	// keywords at the column of the classdef and indents their contents once;
+	// "classdef" indents the block keywords once and keeps their contents at
+	// the same level.
+	ClassdefIndent string
+	// DetectIndentation replaces IndentWidth and IndentStyle with the
+	// indentation most lines of the file are indented with, when it can be
+	// told, so that files keep their own style.
+	DetectIndentation bool
+	// IndentStyle selects the characters of indentation: "space", or "tab"
+	// to write each IndentWidth columns of leading whitespace as a tab.
+	IndentStyle string
+	// MatrixSeparator selects the separator between the elements of a row of
+	// a [...] literal: "comma", "space" or "keep" to leave them as written.
+	// Unless it is "keep", row separators are also followed by exactly one
+	// space.
+	MatrixSeparator string
+	// TrimMatrixSeparators removes separators directly before the closing
+	// bracket of a [...] literal.
+	TrimMatrixSeparators bool
+	// SortImports sorts and deduplicates consecutive import statements at the
+	// top of the file, a function or a classdef.
+	SortImports bool
+	// GroupImports sorts imports like SortImports and separates them by a
+	// blank line whenever their top-level package changes.
+	GroupImports bool
+	// QualifyImports rewrites imports relative to the package of the file,
+	// given by the InPackage call option, into fully-qualified form.
+	QualifyImports bool
+	// TabWidth is the column distance between tab stops used to convert tabs
+	// in leading whitespace to spaces before reindenting. Zero keeps tabs,
+	// which are then measured with tab stops IndentWidth columns apart.
+	TabWidth int
+	// TestFixturesFirst and TestFunctionSpacing select the conventions
+	// ApplyTestConventions applies to function-based test files.
+	TestFixturesFirst   bool
+	TestFunctionSpacing bool
+	// NormalizeNumbers writes numeric literals with a leading zero, such as
+	// 0.5 for .5, and without a decimal point that no digits follow, such as
+	// 5e3 for 5.e3.
+	NormalizeNumbers bool
+	// TrimNumberZeros removes the trailing zeros of the fractions of numeric
+	// literals, and the decimal point when no digits remain, such as 1.5 for
+	// 1.50 and 2 for 2.0.
+	TrimNumberZeros bool
+	// ExponentCase selects the exponent marker of numeric literals in
+	// scientific notation: "lower" for e, "upper" for E or "keep" to leave it
+	// as written.
+	ExponentCase string
+	// TrimExponents removes the plus sign and the leading zeros of the
+	// exponents of numeric literals, such as 1e3 for 1e+03.
+	TrimExponents bool
+	// ComplexSpacing selects the spacing around the operator of complex
+	// literals such as 3+4i: "tight", "spaced" for 3 + 4i, or "keep" to
+	// apply the operator spacing of AddSpaces.
+	ComplexSpacing string
+	// LineEnding selects the line ending JoinLines writes: "lf", "crlf",
+	// "cr", or "auto" for the line ending most lines of the source end with,
+	// as detected by DetectLineEnding when the source is known.
+	LineEnding string
+	// FinalNewline ends the last line written by JoinLines with a line
+	// ending too.
+	FinalNewline bool
+	// KeepTrailingLines keeps the blank lines at the end of a file formatted
+	// through its end, which are removed otherwise, and, when the content
+	// the lines were read from is known, whether it ends with a line ending
+	// instead of following FinalNewline.
+	KeepTrailingLines bool
+	// ContinuationIndent is the number of columns by which the lines
+	// continuing a statement after ... are indented beyond the statement.
+	// Zero uses IndentWidth.
+	ContinuationIndent int
+	// ContinuationStyle selects the indentation of the lines continuing a
+	// statement after ...: "indent" for one level more than the statement,
+	// or "aligned" to align them one column after the innermost bracket left
+	// open by the lines before, falling back to one level outside brackets.
+	// Unknown values use "indent".
+	ContinuationStyle string
+	// CommentColumn is the column, counting from one, in which the trailing
+	// comments of lines of code start. Comments of lines whose code reaches
+	// it follow the code after one space. Zero keeps one space before them,
+	// or aligns those of matrix rows with each other as they were.
+	CommentColumn int
+	// AlignMatrixColumns pads the elements of the rows of multi-line
+	// matrices into columns, right-aligning numbers.
+	AlignMatrixColumns bool
+	// AlignAssignments aligns the = of runs of consecutive lines holding
+	// one assignment each at the same indentation. Blank lines, comments and
+	// other statements end a run.
+	AlignAssignments bool
+	// MaxLineLength is the number of columns, counting indentation, that
+	// lines should fit in. Zero sets no limit.
+	MaxLineLength int
+	// JoinContinuations joins the lines of a statement continued with ...
+	// into one line when it fits in MaxLineLength columns. Statements with
+	// comments after a continuation are kept as they are.
+	JoinContinuations bool
+	// Dialect selects the language of the code: "matlab"; "octave", which
+	// also reads # comments, block comments between #{ and #} lines and
+	// backslash escapes in double-quoted strings, and keeps the lines of %!
+	// test blocks as they are; or "auto" for "octave" in files using syntax
+	// only Octave accepts and "matlab" otherwise. Unknown values use "auto".
+	Dialect string
+	// StrictBlocks makes formatting fail with an *UnbalancedError instead of
+	// indenting lines whose blocks do not match their ends, such as a file
+	// with an end too many.
+	StrictBlocks bool
+	// Only restricts formatting to one kind of change. "indent" adjusts
+	// indentation and blank lines but keeps the text of each line; "spacing"
+	// formats the text of each line but keeps its indentation and neither
+	// inserts nor removes lines. Empty applies every rule.
+	Only string
+}
+
+// DefaultOptions returns the default formatter configuration.
+func DefaultOptions() Options {
+	return Options{
+		StartLine:           1,
+		EndLine:             0,
+		IndentWidth:         4,
+		IndentStyle:         "space",
+		SeparateBlocks:      true,
+		SeparatedBlocks:     "all",
+		MaxBlankLines:       1,
+		IndentMode:          "all_functions",
+		AddSpaces:           "exclude_pow",
+		SpaceAfterComma:     true,
+		ColonSpacing:        "tight",
+		UnarySigns:          "whitespace",
+		MatrixIndent:        "aligned",
+		ClassdefIndent:      "all",
+		MatrixSeparator:     "keep",
+		ExponentCase:        "keep",
+		ComplexSpacing:      "keep",
+		ContinuationStyle:   "indent",
+		TabWidth:            4,
+		TestFixturesFirst:   true,
+		TestFunctionSpacing: true,
+		LineEnding:          "auto",
+		FinalNewline:        true,
+		Dialect:             "auto",
+	}
+}
+
+// Formatter applies MATLAB formatting rules ported from the VS Code extension.
+// A Formatter is safe for concurrent use by multiple goroutines.
+type Formatter struct {
+	opts       Options
+	indentMode int
+	// spacedOps holds the classes of binary operators written with spaces.
+	spacedOps       map[string]bool
+	matrixIndent    bool
+	matrixSeparator string
+	classdefIndent  string
+	iwidth          int
+	// separated holds the kinds of blocks separated by blank lines.
+	separated map[string]bool
+	dialect   syntax.Dialect
+
+	lineComment       *regexp.Regexp
+	blockCommentOpen  *regexp.Regexp
+	blockCommentClose *regexp.Regexp
+	ignoreCommand     *regexp.Regexp
+	initialIndent     *regexp.Regexp
+}
+
+// session is the state of one call formatting lines, so that calls on the
+// same Formatter do not share any.
+type session struct {
+	*Formatter
+	call
+	ctx context.Context
+
+	ilvl  int
+	istep []int
+	fstep []int
+	// funcs parallels fstep with the keyword that opened each function or
+	// classdef and the columns nestedCols changed by for its body.
+	// nestedCols adjusts the indentation of the current line in columns.
+	funcs      []funcBlock
+	nestedCols int
+	// members holds the levels of the open classdef member blocks whose
+	// contents are not indented.
+	members []int
+	// decls holds the levels of the open properties and arguments blocks.
+	decls          []int
+	matrix         int
+	cell           int
+	isBlockComment int
+	isLineComment  int
+	longLine       int
+	continueLine   int
+	ignoreLines    int
+	// brackets holds the columns following the brackets left open in the
+	// output by the lines of the statement being continued.
+	brackets []int
+	// functionEnds reports whether the functions of the file being
+	// formatted are terminated with end.
+	functionEnds bool
+
+	// literal is the bracket of the multi-line matrix or cell array the
+	// line being formatted starts inside, if any.
+	literal string
+	// doBlocks is the number of open do blocks, which until closes.
+	doBlocks int
+
+	// class is the classification of the line last formatted by formatLine.
+	class string
+	// tracing records the traces of the formatted lines, and fired the
+	// spacing rules applied to the current line.
+	tracing bool
+	fired   []string
+	traces  []LineTrace
+}
+
+// newSession returns the state of a call formatting lines until ctx is done.
+func (f *Formatter) newSession(ctx context.Context, lines []string, opts []CallOption) *session {
+	return &session{Formatter: f.forLines(lines), call: newCall(opts), ctx: ctx}
+}
+
+// funcBlock is an open function or classdef block.
+type funcBlock struct {
+	keyword string
+	cols    int
+}
+
+var (
+	indentModes = map[string]int{
+		"all_functions":         1,
+		"only_nested_functions": -1,
+		"classic":               0,
+	}
+	operatorSpaces = map[string]float64{
+		"all_operators": 1.0,
+		"exclude_pow":   0.5,
+		"no_spaces":     0.0,
+	}
+	colonSpacings = map[string]bool{
+		"tight":  true,
+		"spaced": true,
+		"keep":   true,
+	}
+	unarySigns = map[string]bool{
+		"context":    true,
+		"whitespace": true,
+	}
+	dialects = map[string]bool{
+		"auto":   true,
+		"matlab": true,
+		"octave": true,
+	}
+	// operatorClasses lists the classes of binary operators of
+	// SpacedOperators.
+	operatorClasses = map[string]bool{
+		"assignment":  true,
+		"relational":  true,
+		"arithmetic":  true,
+		"elementwise": true,
+		"power":       true,
+		"logical":     true,
+	}
+	classdefIndents = map[string]bool{
+		"all":      true,
+		"blocks":   true,
+		"classdef": true,
+	}
+	// blockKinds maps the keywords opening blocks to the kinds of blocks
+	// named in SeparatedBlocks.
+	blockKinds = map[string]string{
+		"function":       "function",
+		"classdef":       "classdef",
+		"properties":     "classdef",
+		"methods":        "classdef",
+		"events":         "classdef",
+		"enumeration":    "classdef",
+		"if":             "if",
+		"for":            "loop",
+		"parfor":         "loop",
+		"while":          "loop",
+		"do":             "loop",
+		"switch":         "switch",
+		"try":            "try",
+		"unwind_protect": "try",
+		"spmd":           "spmd",
+		"arguments":      "arguments",
+	}
+	// memberBlocks are the blocks of a classdef affected by ClassdefIndent.
+	memberBlocks = map[string]bool{
+		"properties":  true,
+		"methods":     true,
+		"events":      true,
+		"enumeration": true,
+	}
+	matrixIndentation = map[string]bool{
+		"aligned":  true,
+		"simple":   false,
+		"preserve": true,
+	}
+	continuationStyles = map[string]bool{
+		"indent":  true,
+		"aligned": true,
+	}
+	indentStyles = map[string]bool{
+		"space": true,
+		"tab":   true,
+	}
+	lineEndings = map[string]string{
+		"lf":   "\n",
+		"crlf": "\r\n",
+		"cr":   "\r",
+		// auto writes LF when the source is not known.
+		"auto": "\n",
+	}
+	onlyModes = map[string]bool{
+		"":        true,
+		"indent":  true,
+		"spacing": true,
+	}
+	// controlKeywords open the control blocks that can be closed on the line
+	// they start, as in "if x, y = 1; end".
+	controlKeywords = map[string]bool{
+		"if":     true,
+		"for":    true,
+		"parfor": true,
+		"while":  true,
+		"switch": true,
+		"try":    true,
+		"spmd":   true,
+	}
+	// blockKeywords open the blocks indented by one level.
+	blockKeywords = map[string]bool{
+		"if":             true,
+		"for":            true,
+		"parfor":         true,
+		"while":          true,
+		"try":            true,
+		"spmd":           true,
+		"methods":        true,
+		"properties":     true,
+		"events":         true,
+		"arguments":      true,
+		"enumeration":    true,
+		"do":             true,
+		"unwind_protect": true,
+	}
+	continueKeywords = map[string]bool{
+		"elseif":                 true,
+		"else":                   true,
+		"case":                   true,
+		"otherwise":              true,
+		"catch":                  true,
+		"unwind_protect_cleanup": true,
+	}
+	endKeywords = map[string]bool{
+		"end":                true,
+		"endfunction":        true,
+		"endif":              true,
+		"endwhile":           true,
+		"endfor":             true,
+		"endswitch":          true,
+		"until":              true,
+		"end_try_catch":      true,
+		"end_unwind_protect": true,
+	}
+	// octaveWords are the words Octave reserves for its blocks that MATLAB
+	// code may use as names.
+	octaveWords = map[string]bool{
+		"do":                     true,
+		"until":                  true,
+		"unwind_protect":         true,
+		"unwind_protect_cleanup": true,
+		"end_unwind_protect":     true,
+		"end_try_catch":          true,
+	}
+	// commandWords start the lines kept as they are, such as import pkg.*.
+	commandWords = map[string]bool{
+		"import":    true,
+		"clear":     true,
+		"clearvars": true,
+	}
+	blockCommentSentinel = 1 << 30
+
+	commentLine           = regexp.MustCompile(`^(\s*)%.*$`)
+	ignoreDirective       = regexp.MustCompile(`^.*formatter\s+ignore\s+(\d*).*$`)
+	blockCommentOpenLine  = regexp.MustCompile(`^(\s*)%\{\s*$`)
+	blockCommentCloseLine = regexp.MustCompile(`^(\s*)%\}\s*$`)
+	// Octave also accepts # comments, and keeps the lines of its test
+	// blocks, starting with %! or #!, in the first column.
+	octaveCommentLine      = regexp.MustCompile(`^(\s*)[%#].*$`)
+	octaveCommentOpenLine  = regexp.MustCompile(`^(\s*)[%#]\{\s*$`)
+	octaveCommentCloseLine = regexp.MustCompile(`^(\s*)[%#]\}\s*$`)
+	octaveTestLine         = regexp.MustCompile(`^[%#]!`)
+)
+
+// Validate reports the first invalid option of o: a width out of range or an
+// enumerated option set to an unknown value. Unlike New, it accepts no
+// unknown values, so that typos in configuration files are caught.
+func (o Options) Validate() error {
+	if err := o.validateRequired(); err != nil {
+		return err
+	}
+	enums := []struct {
+		name, value string
+		valid       []string
+	}{
+		{"indent mode", o.IndentMode, sortedKeys(indentModes)},
+		{"add spaces mode", o.AddSpaces, sortedKeys(operatorSpaces)},
+		{"matrix indent", o.MatrixIndent, sortedKeys(matrixIndentation)},
+		{"classdef indent", o.ClassdefIndent, sortedKeys(classdefIndents)},
+		{"matrix separator", o.MatrixSeparator, sortedKeys(matrixSeparators)},
+		{"exponent case", o.ExponentCase, sortedKeys(exponentCases)},
+		{"complex spacing", o.ComplexSpacing, sortedKeys(complexSpacings)},
+		{"colon spacing", o.ColonSpacing, sortedKeys(colonSpacings)},
+		{"unary signs", o.UnarySigns, sortedKeys(unarySigns)},
+		{"dialect", o.Dialect, sortedKeys(dialects)},
+		{"continuation style", o.ContinuationStyle, sortedKeys(continuationStyles)},
+	}
+	for _, e := range enums {
+		if !slices.Contains(e.valid, e.value) {
+			return fmt.Errorf("invalid %s %q (valid values: %s)", e.name, e.value, strings.Join(e.valid, ", "))
+		}
+	}
+	ops := append(sortedKeys(operatorClasses), "none")
+	for _, class := range splitKinds(o.SpacedOperators) {
+		if !slices.Contains(ops, class) {
+			return fmt.Errorf("invalid spaced operator class %q (valid values: %s)", class, strings.Join(ops, ", "))
+		}
+	}
+	valid := blockKindNames()
+	for _, kind := range splitKinds(o.SeparatedBlocks) {
+		if !slices.Contains(valid, kind) {
+			return fmt.Errorf("invalid separated block %q (valid values: %s)", kind, strings.Join(valid, ", "))
+		}
+	}
+	return nil
+}
+
+// validateRequired reports the first invalid option of o that New rejects.
+func (o Options) validateRequired() error {
+	if o.IndentWidth <= 0 {
+		return errors.New("indentWidth must be greater than zero")
+	}
+	if o.NestedIndentWidth < 0 {
+		return errors.New("nestedIndentWidth must not be negative")
+	}
+	if o.TabWidth < 0 {
+		return errors.New("tabWidth must not be negative")
+	}
+	if o.ContinuationIndent < 0 {
+		return errors.New("continuationIndent must not be negative")
+	}
+	if o.MaxLineLength < 0 {
+		return errors.New("maxLineLength must not be negative")
+	}
+	if o.CommentColumn < 0 {
+		return errors.New("commentColumn must not be negative")
+	}
+	if o.MaxBlankLines < 0 {
+		return errors.New("maxBlankLines must not be negative")
+	}
+	if o.FunctionBlankLines < 0 || o.NestedFunctionBlankLines < 0 {
+		return errors.New("functionBlankLines and nestedFunctionBlankLines must not be negative")
+	}
+	if !indentStyles[o.IndentStyle] {
+		return fmt.Errorf("invalid indent style %q (valid values: space, tab)", o.IndentStyle)
+	}
+	if _, ok := lineEndings[o.LineEnding]; !ok {
+		return fmt.Errorf("invalid line ending %q (valid values: auto, lf, crlf, cr)", o.LineEnding)
+	}
+	if !onlyModes[o.Only] {
+		return fmt.Errorf("invalid only mode %q (valid values: indent, spacing)", o.Only)
+	}
+	return nil
+}
+
+// New constructs a formatter with DefaultOptions changed by opts, applied in
+// order, such as New(WithIndentWidth(2)) or New(options). It returns an
+// error for invalid widths, indent styles, line endings and Only modes;
+// unknown values of the other enumerated options fall back to their
+// defaults. NewStrict rejects those too.
+func New(opts ...Option) (*Formatter, error) {
+	o := buildOptions(opts)
+	if err := o.validateRequired(); err != nil {
+		return nil, err
+	}
+
+	mode, ok := indentModes[o.IndentMode]
+	if !ok {
+		mode = indentModes["all_functions"]
+	}
+
+	operatorSep, ok := operatorSpaces[o.AddSpaces]
+	if !ok {
+		operatorSep = operatorSpaces["exclude_pow"]
+	}
+
+	matIndent, ok := matrixIndentation[o.MatrixIndent]
+	if !ok {
+		matIndent = matrixIndentation["aligned"]
+	}
+
+	if !classdefIndents[o.ClassdefIndent] {
+		o.ClassdefIndent = "all"
+	}
+	if !matrixSeparators[o.MatrixSeparator] {
+		o.MatrixSeparator = "keep"
+	}
+	if !exponentCases[o.ExponentCase] {
+		o.ExponentCase = "keep"
+	}
+	if !complexSpacings[o.ComplexSpacing] {
+		o.ComplexSpacing = "keep"
+	}
+	if !colonSpacings[o.ColonSpacing] {
+		o.ColonSpacing = "tight"
+	}
+	if !unarySigns[o.UnarySigns] {
+		o.UnarySigns = "whitespace"
+	}
+	if !continuationStyles[o.ContinuationStyle] {
+		o.ContinuationStyle = "indent"
+	}
+	if !dialects[o.Dialect] {
+		o.Dialect = "auto"
+	}
+	dialect := syntax.MATLAB
+	comment, commentOpen, commentClose := commentLine, blockCommentOpenLine, blockCommentCloseLine
+	if o.Dialect == "octave" {
+		dialect = syntax.Octave
+		comment, commentOpen, commentClose = octaveCommentLine, octaveCommentOpenLine, octaveCommentCloseLine
+	}
+
+	formatter := &Formatter{
+		opts:              o,
+		indentMode:        mode,
+		spacedOps:         spacedOperators(o, operatorSep),
+		matrixIndent:      matIndent,
+		matrixSeparator:   o.MatrixSeparator,
+		classdefIndent:    o.ClassdefIndent,
+		iwidth:            o.IndentWidth,
+		separated:         separatedKinds(o),
+		dialect:           dialect,
+		lineComment:       comment,
+		blockCommentOpen:  commentOpen,
+		blockCommentClose: commentClose,
+		ignoreCommand:     ignoreDirective,
+		initialIndent:     regexp.MustCompile(`^(\s*)(.*)$`),
+	}
+
+	return formatter, nil
+}
+
+// separatedKinds returns the kinds of blocks o separates by blank lines.
+func separatedKinds(o Options) map[string]bool {
+	kinds := make(map[string]bool)
+	if !o.SeparateBlocks {
+		return kinds
+	}
+	for _, kind := range splitKinds(o.SeparatedBlocks) {
+		if kind == "all" {
+			for _, k := range blockKinds {
+				kinds[k] = true
+			}
+		}
+		kinds[kind] = true
+	}
+	return kinds
+}
+
+// spacedOperators returns the classes of binary operators o writes with
+// spaces, by SpacedOperators or else by sep, the value of AddSpaces.
+func spacedOperators(o Options, sep float64) map[string]bool {
+	spaced := make(map[string]bool)
+	if classes := splitKinds(o.SpacedOperators); len(classes) > 0 {
+		for _, class := range classes {
+			spaced[class] = true
+		}
+		return spaced
+	}
+	for class := range operatorClasses {
+		spaced[class] = sep > 0
+	}
+	spaced["power"] = sep > 0.5
+	return spaced
+}
+
+// splitKinds returns the items of the comma-separated list s.
+func splitKinds(s string) []string {
+	var kinds []string
+	for _, kind := range strings.Split(s, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// blockKindNames returns the sorted values accepted in SeparatedBlocks.
+func blockKindNames() []string {
+	names := []string{"all"}
+	for _, kind := range blockKinds {
+		if !slices.Contains(names, kind) {
+			names = append(names, kind)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewStrict constructs a formatter like New, but returns the error of
+// Options.Validate for any invalid option instead of falling back to
+// defaults.
+func NewStrict(opts ...Option) (*Formatter, error) {
+	o := buildOptions(opts)
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return New(o)
+}
+
+// buildOptions returns DefaultOptions changed by opts.
+func buildOptions(opts []Option) Options {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return o
+}
+
+// FormatFile formats the requested range of the provided file and writes the
+// result to the supplied writer. A filename of "-" reads from stdin.
+func (f *Formatter) FormatFile(filename string, w io.Writer, opts ...CallOption) error {
+	return f.FormatFileContext(context.Background(), filename, w, opts...)
+}
+
+// FormatFileContext is FormatFile stopping with the error of ctx, without
+// writing anything, when ctx is cancelled or its deadline passes before the
+// file is formatted.
+func (f *Formatter) FormatFileContext(ctx context.Context, filename string, w io.Writer, opts ...CallOption) error {
+	if filename == "-" {
+		return f.formatReader(ctx, os.Stdin, w, opts)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return f.formatReader(ctx, file, w, opts)
+}
+
+// FormatReader formats the source read from r, as by Format, and writes the
+// result to w.
+func (f *Formatter) FormatReader(r io.Reader, w io.Writer, opts ...CallOption) error {
+	return f.formatReader(context.Background(), r, w, opts)
+}
+
+func (f *Formatter) formatReader(ctx context.Context, r io.Reader, w io.Writer, opts []CallOption) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	lines, err := ReadLines(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	formatted, err := f.FormatLinesContext(ctx, lines, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, f.JoinLinesLike(formatted, src))
+	return err
+}
+
+// Format formats the content of a source file: it splits src into lines as
+// ReadLines does, formats them with FormatLines and joins the result with
+// JoinLines, so line endings follow Options.LineEnding and
+// Options.FinalNewline.
+func (f *Formatter) Format(src []byte, opts ...CallOption) ([]byte, error) {
+	lines, err := ReadLines(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := f.FormatLines(lines, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(f.JoinLinesLike(formatted, src)), nil
+}
+
+// JoinLines returns the content of a file of lines, separated by the line
+// ending of the options and ending with one if FinalNewline is set. With the
+// LineEnding auto, whose source JoinLines does not know, lines are separated
+// by LF.
+func (f *Formatter) JoinLines(lines []string) string {
+	return f.joinLines(lines, lineEndings[f.opts.LineEnding], f.opts.FinalNewline)
+}
+
+// JoinLinesLike joins lines as JoinLines does, but with the LineEnding auto
+// separates them by the line ending detected in src, the content the lines
+// were read from, and with KeepTrailingLines ends them with a line ending
+// when src ends with one.
+func (f *Formatter) JoinLinesLike(lines []string, src []byte) string {
+	eol := lineEndings[f.opts.LineEnding]
+	if f.opts.LineEnding == "auto" {
+		eol = lineEndings[DetectLineEnding(src)]
+	}
+	final := f.opts.FinalNewline
+	if f.opts.KeepTrailingLines {
+		final = bytes.HasSuffix(src, []byte("\n")) || bytes.HasSuffix(src, []byte("\r"))
+	}
+	return f.joinLines(lines, eol, final)
+}
+
+// DetectLineEnding returns the line ending most lines of src end with: "lf",
+// "crlf" or "cr". It returns "lf" when src has no line ending and prefers lf,
+// then crlf, on ties.
+func DetectLineEnding(src []byte) string {
+	lf, crlf, cr := 0, 0, 0
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '\n':
+			lf++
+		case src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n':
+			crlf++
+			i++
+		case src[i] == '\r':
+			cr++
+		}
+	}
+	switch {
+	case crlf > lf && crlf >= cr:
+		return "crlf"
+	case cr > lf && cr > crlf:
+		return "cr"
+	}
+	return "lf"
+}
+
+func (f *Formatter) joinLines(lines []string, eol string, final bool) string {
+	var b strings.Builder
+	for i, line := range lines {
+		b.WriteString(line)
+		if i < len(lines)-1 || final {
+			b.WriteString(eol)
+		}
+	}
+	return b.String()
+}
+
+// FormatLines formats the lines of a file, without their line endings, from
+// StartLine through EndLine and returns all lines of the result. The input is
+// not modified.
+func (f *Formatter) FormatLines(lines []string, opts ...CallOption) ([]string, error) {
+	return f.FormatLinesContext(context.Background(), lines, opts...)
+}
+
+// FormatLinesContext is FormatLines stopping with the error of ctx when ctx
+// is cancelled or its deadline passes, which is checked every
+// cancelCheckLines lines.
+func (f *Formatter) FormatLinesContext(ctx context.Context, lines []string, opts ...CallOption) ([]string, error) {
+	return f.newSession(ctx, lines, opts).formatLines(lines)
+}
+
+// formatLines formats lines StartLine through EndLine.
+func (s *session) formatLines(lines []string) ([]string, error) {
+	return s.formatRange(lines, s.opts.StartLine, s.opts.EndLine)
+}
+
+// cancelCheckLines is the number of lines formatted between checks of the
+// context.
+const cancelCheckLines = 256
+
+// formatRange formats lines start through end, where an end of 0 stands for
+// the end of the file.
+func (s *session) formatRange(lines []string, start, end int) ([]string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if start < 1 {
+		start = 1
+	}
+
+	startIdx := start - 1
+	if startIdx > len(lines) {
+		startIdx = len(lines)
+	}
+
+	endIdx := len(lines)
+	if end > 0 && end <= len(lines) {
+		endIdx = end
+	}
+	if endIdx < startIdx {
+		endIdx = startIdx
+	}
+
+	if startIdx == endIdx {
+		copied := append([]string{}, lines...)
+		return copied, nil
+	}
+
+	segment := append([]string{}, lines[startIdx:endIdx]...)
+	if len(segment) == 0 {
+		segment = []string{""}
+	}
+	spacingOnly := s.opts.Only == "spacing"
+	if s.opts.TabWidth > 0 && !spacingOnly {
+		for i, line := range segment {
+			segment[i] = ExpandIndent(line, s.opts.TabWidth)
+		}
+	}
+	// source maps each line of segment to its 1-based line in lines, or 0
+	// for blank lines inserted between import groups.
+	source := make([]int, len(segment))
+	for i := range source {
+		source[i] = startIdx + i + 1
+	}
+	if s.opts.SortImports || s.opts.GroupImports || s.opts.QualifyImports {
+		var origins []int
+		segment, origins = s.normalizeImports(segment)
+		source = source[:0]
+		for _, o := range origins {
+			if o >= 0 {
+				o += startIdx + 1
+			} else {
+				o = 0
+			}
+			source = append(source, o)
+		}
+	}
+
+	s.resetState()
+	file := s.dialect.Parse(lines)
+	if s.opts.StrictBlocks {
+		if err := unbalanced(file); err != nil {
+			return nil, err
+		}
+	}
+	if len(segment) != endIdx-startIdx {
+		// Removed duplicate imports and blank lines inserted between import
+		// groups shift the lines after them, so the block structure is
+		// looked up in the lines with the normalized imports.
+		file = s.dialect.Parse(slices.Concat(lines[:startIdx], segment, lines[endIdx:]))
+	}
+	s.functionEnds = file.FunctionEnds
+
+	original := append([]string{}, segment...)
+	if startIdx > 0 && !file.Continues(startIdx+1) {
+		// A range inside the file starts at the level of the blocks
+		// enclosing it, as if the whole file was formatted.
+		for _, n := range file.Enclosing(startIdx + 1) {
+			offset := s.openBlock(n.Keyword)
+			s.ilvl += offset
+		}
+		segment[0] = strings.TrimLeft(segment[0], " \t")
+	} else if match := s.initialIndent.FindStringSubmatch(segment[0]); len(match) == 3 {
+		s.ilvl = columnAt(match[1], len(match[1]), s.tabStop()) / s.iwidth
+		segment[0] = match[2]
+	}
+
+	var output []string
+	// blanks counts the blank lines written since the last line. The start
+	// counts as more than allowed, so that leading blank lines are removed.
+	blanks := s.opts.MaxBlankLines + 1
+	// opened reports whether the last line other than a comment opened a
+	// block, or is the start, where no blank lines go before a function.
+	opened := true
+	// rows collects the lines of the multi-line matrix being formatted.
+	var rows []matrixRow
+	// shift is the number of columns the bracket opening the multi-line
+	// literal being formatted moved by, which its rows move by as well with
+	// MatrixIndent preserve.
+	shift := 0
+	// assigns collects the lines of output of the run of assignments being
+	// formatted.
+	var assigns []int
+	// code marks the lines of output holding code, which joinContinuations
+	// may join.
+	code := make(map[int]bool)
+
+	for i, rawLine := range segment {
+		if i%cancelCheckLines == cancelCheckLines-1 {
+			if err := s.ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if len(strings.TrimSpace(rawLine)) == 0 {
+			s.record(source[i], "blank")
+			s.alignAssignments(output, assigns)
+			assigns = nil
+			if spacingOnly || blanks < s.opts.MaxBlankLines {
+				output = append(output, "")
+				blanks++
+			}
+			continue
+		}
+
+		inMatrix := s.matrix != 0
+		offset, line := s.formatLine(rawLine)
+		switch s.class {
+		case "ignored", "block-comment", "comment", "command":
+		default:
+			if s.opts.Only != "indent" {
+				if separated := s.separateMatrix(line, inMatrix); separated != line {
+					s.fire("matrixSeparator")
+					line = separated
+				}
+				if normalized := s.normalizeNumbers(line); normalized != line {
+					s.fire("number")
+					line = normalized
+				}
+				if spaced := s.spaceComplex(line, inMatrix); spaced != line {
+					s.fire("complex")
+					line = spaced
+				}
+			}
+		}
+		s.record(source[i], s.class)
+		switch s.opts.Only {
+		case "indent":
+			// Keep the text of the line and only take over its indentation.
+			line = leadingSpace(line) + strings.TrimSpace(rawLine)
+		case "spacing":
+			// Keep the indentation of the line and only take over its text.
+			line = leadingSpace(original[i]) + strings.TrimLeft(line, " \t")
+		}
+		if s.opts.MatrixIndent == "preserve" {
+			indent := columnAt(original[i], len(leadingSpace(original[i])), s.tabStop())
+			switch s.class {
+			case "matrix", "cell":
+				// The code before the bracket is formatted as usual.
+				from, to := s.openBracket(original[i]), s.openBracket(line)
+				if from < 0 || to < 0 {
+					from, to = len(leadingSpace(original[i])), len(leadingSpace(line))
+				}
+				shift = columnAt(line, to, s.tabStop()) - columnAt(original[i], from, s.tabStop())
+				line = line[:to] + strings.TrimRight(original[i][from:], " \t")
+			case "matrix-continuation", "cell-continuation":
+				line = strings.Repeat(" ", max(indent+shift, 0)) + strings.TrimSpace(original[i])
+			}
+		}
+		s.ilvl += offset
+		if s.ilvl < 0 {
+			s.ilvl = 0
+		}
+
+		switch {
+		case s.opts.Only == "indent":
+		case s.class == "matrix" || s.class == "cell":
+			s.alignRows(output, rows)
+			rows = []matrixRow{{len(output), original[i], s.class == "cell"}}
+		case s.class == "matrix-continuation" || s.class == "cell-continuation":
+			rows = append(rows, matrixRow{len(output), original[i], rows != nil && rows[0].cell})
+		default:
+			s.alignRows(output, rows)
+			rows = nil
+		}
+
+		line = s.placeComment(line)
+		assignment := s.isAssignment(file, startIdx+i+1, line)
+		if !assignment || len(assigns) > 0 && leadingSpace(output[assigns[0]]) != leadingSpace(line) {
+			s.alignAssignments(output, assigns)
+			assigns = nil
+		}
+		if assignment {
+			assigns = append(assigns, len(output))
+		}
+		if spacingOnly {
+			output = append(output, strings.TrimRight(line, " \t\r\n"))
+			continue
+		}
+
+		if n := s.functionBlankLines(file, startIdx+i+1); n > 0 && !opened {
+			output = separateFunction(output, n)
+		} else if offset > 0 && blanks == 0 && s.isLineComment == 0 && s.separates(file, startIdx+i+1) {
+			output = append(output, "")
+		}
+
+		switch s.class {
+		case "ignored", "block-comment", "comment", "command":
+			s.brackets = s.brackets[:0]
+		default:
+			code[len(output)] = true
+			s.trackBrackets(line)
+		}
+		if s.class != "comment" && s.class != "block-comment" {
+			opened = offset > 0
+		}
+		output = append(output, strings.TrimRight(line, " \t\r\n"))
+
+		if offset < 0 && s.separates(file, startIdx+i+1) {
+			output = append(output, "")
+			blanks = 1
+		} else {
+			blanks = 0
+		}
+	}
+	s.alignRows(output, rows)
+	s.alignAssignments(output, assigns)
+	if s.opts.JoinContinuations && s.opts.MaxLineLength > 0 && s.opts.Only == "" {
+		output = s.joinContinuations(output, code)
+	}
+	if s.opts.IndentStyle == "tab" && !spacingOnly {
+		for i, line := range output {
+			output[i] = tabIndent(line, s.iwidth)
+		}
+	}
+
+	if endIdx == len(lines) && !spacingOnly {
+		for len(output) > 0 && output[len(output)-1] == "" {
+			output = output[:len(output)-1]
+		}
+		if s.opts.KeepTrailingLines && len(output) > 0 {
+			for i := len(segment) - 1; i >= 0 && strings.TrimSpace(segment[i]) == ""; i-- {
+				output = append(output, "")
+			}
+		}
+
+		if len(output) == 0 {
+			output = []string{""}
+		}
+	} else if len(output) == 0 {
+		output = []string{""}
+	}
+
+	result := make([]string, 0, len(lines[:startIdx])+len(output)+len(lines[endIdx:]))
+	result = append(result, lines[:startIdx]...)
+	result = append(result, output...)
+	result = append(result, lines[endIdx:]...)
+
+	return result, nil
+}
+
+// separates reports whether the block opened or closed on line of file is
+// separated by a blank line.
+func (s *session) separates(file *syntax.File, line int) bool {
+	if len(s.separated) == 0 {
+		return false
+	}
+	if chain := file.Enclosing(line + 1); len(chain) > 0 && chain[len(chain)-1].Start.Line == line {
+		return s.separated[blockKinds[chain[len(chain)-1].Keyword]]
+	}
+	if chain := file.Enclosing(line); len(chain) > 0 && chain[len(chain)-1].End.Line == line {
+		return s.separated[blockKinds[chain[len(chain)-1].Keyword]]
+	}
+	// The block is unknown when the blocks of the file are unbalanced.
+	return s.separated["all"]
+}
+
+// functionBlankLines returns the number of blank lines required before line
+// of file, the line last formatted, or 0 when it is not a function
+// declaration or the blank lines before it are kept.
+func (s *session) functionBlankLines(file *syntax.File, line int) int {
+	n := len(s.funcs)
+	if s.class != "fcnStart" || n == 0 || s.funcs[n-1].keyword != "function" {
+		return 0
+	}
+	for _, node := range file.Enclosing(line) {
+		if node.Kind == syntax.KindFunction {
+			return s.opts.NestedFunctionBlankLines
+		}
+	}
+	return s.opts.FunctionBlankLines
+}
+
+// separateFunction returns output, which a function declaration is about to
+// follow, with the blank lines above the comment lines at its end replaced
+// by n blank lines.
+func separateFunction(output []string, n int) []string {
+	end := len(output)
+	for end > 0 && strings.HasPrefix(strings.TrimSpace(output[end-1]), "%") {
+		end--
+	}
+	start := end
+	for start > 0 && output[start-1] == "" {
+		start--
+	}
+	comments := slices.Clone(output[end:])
+	output = output[:start]
+	for range n {
+		output = append(output, "")
+	}
+	return append(output, comments...)
+}
+
+func (s *session) resetState() {
+	s.ilvl = 0
+	s.istep = s.istep[:0]
+	s.fstep = s.fstep[:0]
+	s.funcs = s.funcs[:0]
+	s.nestedCols = 0
+	s.members = s.members[:0]
+	s.decls = s.decls[:0]
+	s.matrix = 0
+	s.cell = 0
+	s.isBlockComment = 0
+	s.isLineComment = 0
+	s.longLine = 0
+	s.continueLine = 0
+	s.ignoreLines = 0
+	s.brackets = s.brackets[:0]
+	s.doBlocks = 0
+}
+
+func (s *session) formatLine(line string) (int, string) {
+	if s.ignoreLines > 0 {
+		s.ignoreLines--
+		s.class = "ignored"
+		return 0, s.indent(0) + strings.TrimSpace(line)
+	}
+
+	if s.lineComment.MatchString(line) {
+		s.isLineComment = 2
+	} else {
+		if s.isLineComment > 0 {
+			s.isLineComment--
+		}
+	}
+
+	switch {
+	case s.blockCommentOpen.MatchString(line):
+		s.isBlockComment = blockCommentSentinel
+	case s.blockCommentClose.MatchString(line):
+		s.isBlockComment = 1
+	default:
+		if s.isBlockComment > 0 {
+			s.isBlockComment--
+		}
+	}
+
+	toks := s.dialect.Tokenize(line)
+	first := firstToken(toks)
+	s.literal = ""
+	ellipsisInComment := s.isLineComment == 2 || s.isBlockComment > 0
+
+	if first.IsClose() || ellipsisInComment {
+		s.continueLine = 0
+	} else {
+		s.continueLine = s.longLine
+	}
+
+	if hasContinuation(toks) && !ellipsisInComment {
+		s.longLine = 1
+	} else {
+		s.longLine = 0
+	}
+
+	if s.isBlockComment > 0 || s.dialect == syntax.Octave && octaveTestLine.MatchString(line) {
+		s.class = "block-comment"
+		return 0, strings.TrimRight(line, " \t\r\n")
+	}
+
+	if s.isLineComment == 2 {
+		if m := s.ignoreCommand.FindStringSubmatch(line); len(m) == 2 {
+			if m[1] != "" {
+				if v, err := strconv.Atoi(m[1]); err == nil {
+					if v > 1 {
+						s.ignoreLines = v
+					} else {
+						s.ignoreLines = 1
+					}
+				}
+			} else {
+				s.ignoreLines = 1
+			}
+		}
+		s.class = "comment"
+		return 0, s.indent(0) + strings.TrimSpace(line)
+	}
+
+	if first.Kind == syntax.TokenIdentifier && commandWords[first.Text] {
+		s.class = "command"
+		return 0, s.indent(0) + strings.TrimSpace(line)
+	}
+
+	prevMatrix := s.matrix
+	if diff := s.multilineMatrix(toks); diff != 0 || prevMatrix != 0 {
+		s.class = "matrix-continuation"
+		if prevMatrix == 0 {
+			s.class = "matrix"
+		} else {
+			s.literal = "["
+		}
+		return 0, s.indent(prevMatrix) + s.spaceTokens(toks)
+	}
+
+	prevCell := s.cell
+	if diff := s.cellArray(toks); diff != 0 || prevCell != 0 {
+		s.class = "cell-continuation"
+		if prevCell == 0 {
+			s.class = "cell"
+		} else {
+			s.literal = "{"
+		}
+		return 0, s.indent(prevCell) + s.spaceTokens(toks)
+	}
+
+	keyword := ""
+	if first.Kind == syntax.TokenKeyword {
+		keyword = first.Text
+	} else if first.Kind == syntax.TokenIdentifier && octaveWords[first.Text] {
+		keyword = s.octaveKeyword(toks)
+	}
+	switch {
+	case controlKeywords[keyword] && closesOnLine(toks):
+		s.class = "ctrl1Line"
+		return 0, s.indent(0) + s.spaceTokens(toks)
+
+	case keyword == "function" || keyword == "classdef":
+		if keyword == "function" && !s.functionEnds {
+			s.closeFunctions()
+		}
+		s.class = "fcnStart"
+		formatted := s.indent(0) + s.spaceTokens(toks)
+		return s.openBlock(keyword), formatted
+
+	case blockKeywords[keyword]:
+		s.class = "ctrlStart"
+		formatted := s.indent(0) + s.spaceTokens(toks)
+		return s.openBlock(keyword), formatted
+
+	case keyword == "switch":
+		s.class = "ctrlStartSwitch"
+		formatted := s.indent(0) + s.spaceTokens(toks)
+		return s.openBlock(keyword), formatted
+
+	case continueKeywords[keyword]:
+		s.class = "ctrlCont"
+		return 0, s.indent(-s.iwidth) + s.spaceTokens(toks)
+
+	case endKeywords[keyword]:
+		step := 0
+		indentExtra := 0
+		if l := len(s.istep); l > 0 {
+			step = s.istep[l-1]
+			s.istep = s.istep[:l-1]
+			indentExtra = -step * s.iwidth
+		} else if l := len(s.fstep); l > 0 {
+			step = s.fstep[l-1]
+			s.fstep = s.fstep[:l-1]
+			indentExtra = -step * s.iwidth
+			if n := len(s.funcs); n > 0 {
+				s.nestedCols -= s.funcs[n-1].cols
+				s.funcs = s.funcs[:n-1]
+			}
+		} else if s.ilvl > 0 {
+			// When the formatter is asked to operate on a partial selection that
+			// only contains closing statements (e.g. one or more "end" lines),
+			// we may not have matching openers recorded on the stack. In that
+			// case we still need to reduce the indent depth for subsequent lines
+			// while keeping the current line aligned with its existing indent.
+			step = 1
+			indentExtra = 0
+		}
+		if keyword == "until" {
+			s.doBlocks--
+		}
+		if n := len(s.decls); n > 0 && step > 0 && s.ilvl-step == s.decls[n-1] {
+			s.decls = s.decls[:n-1]
+		}
+		if n := len(s.members); n > 0 && step > 0 && s.ilvl-step == s.members[n-1] {
+			s.members = s.members[:n-1]
+			s.nestedCols += s.iwidth
+		}
+		s.class = "ctrlEnd"
+		if s.opts.NormalizeEnds && keyword != "until" && keyword != "end_unwind_protect" {
+			if normalized, ok := normalizeEnd(toks); ok {
+				s.fire("end")
+				toks = normalized
+			}
+		}
+		return -step, s.indent(indentExtra) + s.spaceTokens(toks)
+	}
+
+	s.class = "code"
+	formatted := s.indent(0) + s.spaceTokens(toks)
+	if n := len(s.decls); n > 0 && s.ilvl == s.decls[n-1]+1 {
+		if declared := s.formatDeclaration(formatted); declared != formatted {
+			s.fire("declaration")
+			formatted = declared
+		}
+	}
+	return 0, formatted
+}
+
+// octaveKeyword returns the first word of toks when it opens, continues or
+// closes one of Octave's do and unwind_protect blocks, or "" when it is a
+// name. A word counts as a keyword when it is the only statement of the
+// line, or for until, when it starts the condition closing an open do block.
+func (s *session) octaveKeyword(toks []syntax.Token) string {
+	var code []syntax.Token
+	for _, t := range toks {
+		if t.Kind != syntax.TokenSpace && t.Kind != syntax.TokenComment {
+			code = append(code, t)
+		}
+	}
+	word := code[0].Text
+	if word == "until" {
+		if s.doBlocks > 0 && len(code) > 1 && code[1].Text != "=" {
+			return word
+		}
+		return ""
+	}
+	if len(code) == 1 || len(code) == 2 && (code[1].Text == ";" || code[1].Text == ",") {
+		return word
+	}
+	return ""
+}
+
+// normalizeEnd returns toks, a line starting with a keyword closing a
+// block, with the keyword written as end and without a semicolon ending the
+// code after it, and whether anything changed.
+func normalizeEnd(toks []syntax.Token) ([]syntax.Token, bool) {
+	toks = slices.Clone(toks)
+	changed := false
+	var code []int
+	for i, t := range toks {
+		if t.Kind != syntax.TokenSpace && t.Kind != syntax.TokenComment {
+			code = append(code, i)
+		}
+	}
+	if toks[code[0]].Text != "end" {
+		toks[code[0]].Text = "end"
+		changed = true
+	}
+	if len(code) == 2 && toks[code[1]].Text == ";" {
+		toks = slices.Delete(toks, code[1], code[1]+1)
+		changed = true
+	}
+	return toks, changed
+}
+
+// openBlock records the function, classdef or block opened by keyword on the
+// current line and returns the change of the indentation level of the lines
+// following it.
+func (s *session) openBlock(keyword string) int {
+	switch keyword {
+	case "function", "classdef":
+		offset := s.indentMode
+		nested := keyword == "function" && len(s.funcs) > 0 && s.funcs[len(s.funcs)-1].keyword == "function"
+		s.fstep = append(s.fstep, 1)
+		if s.indentMode == -1 {
+			if len(s.fstep) > 1 {
+				offset = 1
+			} else {
+				offset = 0
+			}
+		}
+		if keyword == "classdef" && s.classdefIndent != "all" {
+			offset = 0
+			if s.classdefIndent == "classdef" {
+				offset = 1
+			}
+			s.fstep[len(s.fstep)-1] = offset
+		}
+		block := funcBlock{keyword: keyword}
+		if nested && offset > 0 && s.opts.NestedIndentWidth > 0 {
+			// The body still counts as a level but is indented by
+			// NestedIndentWidth columns instead of IndentWidth.
+			block.cols = s.opts.NestedIndentWidth - s.iwidth
+			s.nestedCols += block.cols
+		}
+		s.funcs = append(s.funcs, block)
+		return offset
+	case "switch":
+		s.istep = append(s.istep, 2)
+		return 2
+	}
+	s.istep = append(s.istep, 1)
+	if keyword == "do" {
+		s.doBlocks++
+	}
+	if keyword == "properties" || keyword == "arguments" {
+		s.decls = append(s.decls, s.ilvl)
+	}
+	if s.classdefIndent == "classdef" && memberBlocks[keyword] && len(s.funcs) > 0 && s.funcs[len(s.funcs)-1].keyword == "classdef" {
+		// The block keeps its level so that its end is matched as usual,
+		// but its contents stay in the column of the keyword.
+		s.members = append(s.members, s.ilvl)
+		s.nestedCols -= s.iwidth
+	}
+	return 1
+}
+
+// closeFunctions ends the open functions of a file whose functions are not
+// terminated with end, where a function declaration ends the function
+// before it instead of nesting in it.
+func (s *session) closeFunctions() {
+	for len(s.funcs) > 0 && len(s.fstep) > 0 && s.funcs[len(s.funcs)-1].keyword == "function" {
+		n := len(s.funcs) - 1
+		s.ilvl -= s.fstep[len(s.fstep)-1]
+		s.nestedCols -= s.funcs[n].cols
+		s.fstep = s.fstep[:len(s.fstep)-1]
+		s.funcs = s.funcs[:n]
+	}
+	if s.ilvl < 0 {
+		s.ilvl = 0
+	}
+}
+
+// firstToken returns the first token of toks that is not whitespace, or the
+// zero Token if there is none.
+func firstToken(toks []syntax.Token) syntax.Token {
+	for _, t := range toks {
+		if t.Kind != syntax.TokenSpace {
+			return t
+		}
+	}
+	return syntax.Token{}
+}
+
+// hasContinuation reports whether the line of toks continues on the next.
+func hasContinuation(toks []syntax.Token) bool {
+	for _, t := range toks {
+		if t.Kind == syntax.TokenContinuation {
+			return true
+		}
+	}
+	return false
+}
+
+// closesOnLine reports whether the control block opened at the start of the
+// line of toks is closed on the line too: it has an end keyword for each
+// control keyword.
+func closesOnLine(toks []syntax.Token) bool {
+	open, ends := 0, 0
+	for _, t := range toks {
+		switch {
+		case t.Kind != syntax.TokenKeyword:
+		case controlKeywords[t.Text]:
+			open++
+		case endKeywords[t.Text]:
+			ends++
+		}
+	}
+	return ends > 0 && ends >= open
+}
+
+// cellIndent returns the change in nesting of the brackets open in the line
+// of toks and the indentation of the continuation lines of the innermost
+// bracket opened last, or indent if the nesting does not increase.
+func (s *session) cellIndent(toks []syntax.Token, open, close string, indent int) (int, int) {
+	openCount := 0
+	last := -1
+	for _, t := range toks {
+		switch {
+		case t.Kind != syntax.TokenPunctuation:
+		case t.Text == open:
+			openCount++
+			last = t.Offset
+		case t.Text == close:
+			openCount--
+		}
+	}
+
+	if openCount > 0 {
+		if s.matrixIndent {
+			indent = last - firstToken(toks).Offset + 1
+			if s.padded(open) {
+				indent++
+			}
+		} else {
+			indent = s.iwidth
+		}
+	} else if openCount < 0 {
+		indent = 0
+	}
+
+	return openCount, indent
+}
+
+func (s *session) multilineMatrix(toks []syntax.Token) int {
+	diff, indent := s.cellIndent(toks, "[", "]", s.matrix)
+	s.matrix = indent
+	return diff
+}
+
+func (s *session) cellArray(toks []syntax.Token) int {
+	diff, indent := s.cellIndent(toks, "{", "}", s.cell)
+	s.cell = indent
+	return diff
+}
+
+func (s *session) indent(extra int) string {
+	width := s.ilvl*s.iwidth + s.continueLine*s.continuationWidth() + s.nestedCols
+	if n := len(s.brackets); n > 0 && s.continueLine > 0 && extra == 0 && s.opts.ContinuationStyle == "aligned" {
+		width = s.brackets[n-1]
+	}
+	width += extra
+	if width < 0 {
+		width = 0
+	}
+	if width == 0 {
+		return ""
+	}
+	return strings.Repeat(" ", width)
+}
+
+// continuationWidth returns the number of columns continuation lines are
+// indented by.
+func (f *Formatter) continuationWidth() int {
+	if f.opts.ContinuationIndent > 0 {
+		return f.opts.ContinuationIndent
+	}
+	return f.iwidth
+}
+
+// trackBrackets updates the brackets left open by the statement continued
+// on the formatted line, which is emptied when the statement ends.
+func (s *session) trackBrackets(line string) {
+	for _, t := range s.dialect.Tokenize(line) {
+		switch {
+		case t.IsOpen() && s.padded(t.Text):
+			s.brackets = append(s.brackets, t.Offset+2)
+		case t.IsOpen():
+			s.brackets = append(s.brackets, t.Offset+1)
+		case t.IsClose() && len(s.brackets) > 0:
+			s.brackets = s.brackets[:len(s.brackets)-1]
+		}
+	}
+	if s.longLine == 0 {
+		s.brackets = s.brackets[:0]
+	}
+}
+
+// IgnoredLines reports for each line whether it is covered by a
+// "formatter ignore N" directive. Such lines keep their content untouched and
+// only have their indentation adjusted. In files using syntax only Octave
+// accepts, directives in # comments count too.
+func IgnoredLines(lines []string) []bool {
+	ignored := make([]bool, len(lines))
+	comment := commentLine
+	if syntax.DetectDialect(lines) == syntax.Octave {
+		comment = octaveCommentLine
+	}
+	remaining := 0
+	for i, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if remaining > 0 {
+			ignored[i] = true
+			remaining--
+			continue
+		}
+		if !comment.MatchString(line) {
+			continue
+		}
+		if m := ignoreDirective.FindStringSubmatch(line); len(m) == 2 {
+			remaining = 1
+			if v, err := strconv.Atoi(m[1]); err == nil && v > 1 {
+				remaining = v
+			}
+		}
+	}
+	return ignored
+}
+
+// tabStop returns the distance between the tab stops of the input, which is
+// TabWidth or, when tabs are kept, IndentWidth.
+func (f *Formatter) tabStop() int {
+	if f.opts.TabWidth > 0 {
+		return f.opts.TabWidth
+	}
+	return f.iwidth
+}
+
+// columnAt returns the display column of the byte offset i of line, with tab
+// stops every tabWidth columns.
+func columnAt(line string, i, tabWidth int) int {
+	column := 0
+	for _, c := range line[:i] {
+		if c == '\t' {
+			column += tabWidth - column%tabWidth
+			continue
+		}
+		column++
+	}
+	return column
+}
+
+// tabIndent writes each width columns of the leading spaces of line as a tab,
+// keeping the remaining spaces.
+func tabIndent(line string, width int) string {
+	n := len(line) - len(strings.TrimLeft(line, " "))
+	if n < width {
+		return line
+	}
+	return strings.Repeat("\t", n/width) + line[n-n%width:]
+}
+
+// leadingSpace returns the leading spaces and tabs of line.
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// ExpandIndent replaces tabs in the leading whitespace of line with spaces up
+// to the next multiple of tabWidth.
+func ExpandIndent(line string, tabWidth int) string {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	if tabWidth <= 0 || !strings.Contains(line[:indent], "\t") {
+		return line
+	}
+
+	var b strings.Builder
+	column := 0
+	for _, c := range line[:indent] {
+		if c == '\t' {
+			n := tabWidth - column%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+			continue
+		}
+		b.WriteRune(c)
+		column++
+	}
+	return b.String() + line[indent:]
+}
+
+// ReadLines reads r and splits it into lines, accepting LF, CRLF and CR line
+// endings. A single trailing line ending does not produce an extra empty line.
+func ReadLines(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	lines := strings.Split(content, "\n")
+
+	// strings.Split always adds an empty string after a trailing delimiter.
+	// Remove it to avoid outputting an extra newline, except for empty files.
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,1613 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFormatterMatchesReferenceSample(t *testing.T) {
+	unformatted, err := os.ReadFile("testdata/sample_unformatted.m")
+	if err != nil {
+		t.Fatalf("read unformatted: %v", err)
+	}
+	expected, err := os.ReadFile("testdata/sample_formatted.m")
+	if err != nil {
+		t.Fatalf("read expected: %v", err)
+	}
+
+	fmttr, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+
+	lines, err := ReadLines(bytes.NewReader(unformatted))
+	if err != nil {
+		t.Fatalf("ReadLines unformatted: %v", err)
+	}
+	formatted, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+
+	got := strings.Join(formatted, "\n") + "\n"
+	want := string(expected)
+
+	if got != want {
+		t.Fatalf("formatted content mismatch\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+}
+
+func TestFormatLinesPartialRangePreservesSurroundingLines(t *testing.T) {
+	lines := []string{
+		"function y=foo(x)",
+		"    if x>0",
+		"y=x+1;",
+		"    end",
+		"end",
+	}
+
+	opts := DefaultOptions()
+	opts.StartLine = 2
+	opts.EndLine = 4
+
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+
+	want := []string{
+		"function y=foo(x)",
+		"    if x > 0",
+		"        y = x + 1;",
+		"    end",
+		"",
+		"end",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected line count: got %d want %d\nlines: %#v", len(got), len(want), got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d mismatch: got %q want %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestFormatLinesStartBeyondFileIsNoOp(t *testing.T) {
+	lines := []string{
+		"function y=foo(x)",
+		"end",
+	}
+
+	opts := DefaultOptions()
+	opts.StartLine = 99
+	opts.EndLine = 0
+
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+
+	if len(got) != len(lines) {
+		t.Fatalf("unexpected line count: got %d want %d", len(got), len(lines))
+	}
+
+	for i := range lines {
+		if got[i] != lines[i] {
+			t.Fatalf("line %d mismatch: got %q want %q", i+1, got[i], lines[i])
+		}
+	}
+}
+
+func TestFormatLinesDanglingEndsReduceIndent(t *testing.T) {
+	lines := []string{
+		"function foo",
+		"    if a",
+		"        if b",
+		"            disp('x');",
+		"        end",
+		"    end",
+		"end",
+	}
+
+	opts := DefaultOptions()
+	opts.StartLine = 5
+	opts.EndLine = 6
+
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+
+	want := []string{
+		"function foo",
+		"    if a",
+		"        if b",
+		"            disp('x');",
+		"        end",
+		"",
+		"    end",
+		"",
+		"end",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected line count: got %d want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d mismatch: got %q want %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestSortImports(t *testing.T) {
+	lines := []string{
+		"function run()",
+		"import pkg.zeta",
+		"import pkg.alpha.*;",
+		"import pkg.zeta",
+		"x = 1;",
+		"import late.b",
+		"import late.a",
+		"end",
+	}
+
+	opts := DefaultOptions()
+	opts.SortImports = true
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+
+	want := []string{
+		"function run()",
+		"    import pkg.alpha.*;",
+		"    import pkg.zeta",
+		"    x = 1;",
+		"    import late.b",
+		"    import late.a",
+		"end",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestExpandIndent(t *testing.T) {
+	tests := map[string]string{
+		"\tx = 1;":     "    x = 1;",
+		"  \tx = 1;":   "    x = 1;",
+		"\t  \tx = 1;": "        x = 1;",
+		"x =\t1;":      "x =\t1;",
+	}
+	for in, want := range tests {
+		if got := ExpandIndent(in, 4); got != want {
+			t.Errorf("ExpandIndent(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatLinesExpandsTabsInInitialIndent(t *testing.T) {
+	// A range inside a block is formatted at the level of the block, not at
+	// that of the tabs and spaces its first line is indented with.
+	lines := []string{
+		"if x",
+		"\t  \ty=1;",
+		"end",
+	}
+	opts := DefaultOptions()
+	opts.StartLine = 2
+	opts.EndLine = 2
+	opts.IndentWidth = 4
+
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+	if got[1] != "    y = 1;" {
+		t.Fatalf("unexpected indentation: %q", got[1])
+	}
+}
+
+func TestFormatLinesExpandsTabsInInitialIndentOutsideBlocks(t *testing.T) {
+	// The indentation of the first line of a file sets the level it is
+	// formatted at.
+	lines := []string{
+		"\t  \ty=1;",
+		"end",
+	}
+	opts := DefaultOptions()
+	opts.StartLine = 1
+	opts.EndLine = 1
+	opts.IndentWidth = 4
+
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+	if got[0] != "        y = 1;" {
+		t.Fatalf("unexpected indentation: %q", got[0])
+	}
+
+	// Kept tabs are measured with IndentWidth as their display width.
+	opts = DefaultOptions()
+	opts.TabWidth = 0
+	opts.IndentStyle = "tab"
+	if fmttr, err = New(opts); err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	if got, err = fmttr.FormatLines([]string{"\t\tx=1;", "\t\ty=2;"}); err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+	if want := []string{"\t\tx = 1;", "\t\ty = 2;"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesRangeStartsAtBlockLevel(t *testing.T) {
+	lines := []string{
+		"function f(x)",
+		"switch x",
+		"case 1",
+		"\t  \ty=1;",
+		"end",
+		"end",
+	}
+	opts := DefaultOptions()
+	opts.StartLine = 4
+	opts.EndLine = 5
+	fmttr, err := New(opts)
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+	want := []string{"function f(x)", "switch x", "case 1", "            y = 1;", "    end", "", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesFunctionsWithoutEnd(t *testing.T) {
+	lines := []string{"function a", "x=1;", "function b", "if x", "y=2;", "end"}
+	fmttr, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("formatter init: %v", err)
+	}
+	got, err := fmttr.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("format lines: %v", err)
+	}
+	want := []string{"function a", "    x = 1;", "", "function b", "", "    if x", "        y = 2;", "    end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  bool
+	}{
+		{[]string{"function y = f(x)", "% Code generated by MATLAB Coder."}, true},
+		{[]string{"%% DO NOT EDIT"}, true},
+		{[]string{"x = 'auto-generated';"}, false},
+		{[]string{"function y = f(x)", "% Adds one."}, false},
+	}
+	for _, tt := range tests {
+		if got := IsGenerated(tt.lines, DefaultGeneratedMarkers); got != tt.want {
+			t.Errorf("IsGenerated(%q) = %t, want %t", tt.lines, got, tt.want)
+		}
+	}
+	if !IsGenerated([]string{"% Built by gen_tables"}, []string{"Built by gen_"}) {
+		t.Error("custom marker not detected")
+	}
+}
+
+func TestRules(t *testing.T) {
+	seen := map[string]bool{}
+	for _, r := range Rules() {
+		if r.ID == "" || r.Description == "" {
+			t.Errorf("rule %+v lacks an ID or description", r)
+		}
+		if seen[r.ID] {
+			t.Errorf("duplicate rule ID %q", r.ID)
+		}
+		seen[r.ID] = true
+		for _, o := range r.Options {
+			if o.Name == "" || o.Type == "" {
+				t.Errorf("rule %s has an incomplete option %+v", r.ID, o)
+			}
+		}
+	}
+	if !seen["indent"] {
+		t.Error("indent rule not listed")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Fatalf("Validate(DefaultOptions()): %v", err)
+	}
+	opts := DefaultOptions()
+	opts.IndentMode = "all_function"
+	want := `invalid indent mode "all_function" (valid values: all_functions, classic, only_nested_functions)`
+	if err := opts.Validate(); err == nil || err.Error() != want {
+		t.Errorf("Validate: got %v, want %s", err, want)
+	}
+	if _, err := NewStrict(opts); err == nil || err.Error() != want {
+		t.Errorf("NewStrict: got %v, want %s", err, want)
+	}
+	if _, err := New(opts); err != nil {
+		t.Errorf("New falls back to the default indent mode, got %v", err)
+	}
+
+	opts = DefaultOptions()
+	opts.ComplexSpacing = "spaces"
+	if err := opts.Validate(); err == nil || !strings.Contains(err.Error(), "valid values: keep, spaced, tight") {
+		t.Errorf("Validate: got %v for an invalid complex spacing", err)
+	}
+	opts = DefaultOptions()
+	opts.IndentWidth = 0
+	if err := opts.Validate(); err == nil {
+		t.Error("Validate accepted an indent width of 0")
+	}
+}
+
+func TestFormatLinesOnlyIndent(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Only = "indent"
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"function f()", "if x", "y=a+b  ;%c", "  z = [1,2];", "end", "end"}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{"function f()", "", "    if x", "        y=a+b  ;%c", "        z = [1,2];", "    end", "", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	opts.Only = "everything"
+	if _, err := New(opts); err == nil {
+		t.Fatal("expected error for invalid only mode")
+	}
+}
+
+func TestFormatLinesOnlySpacing(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Only = "spacing"
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"function f()", "if x", "", "", "y=a+b  ;%c", "\t  z = [1,2];", "end", "end", ""}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{"function f()", "if x", "", "", "y = a + b; %c", "\t  z = [1, 2];", "end", "end", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesAlignsMatrixComments(t *testing.T) {
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{
+		"A = [1,2      % first",
+		"     10,20    % second",
+		"     x+1,y];  % third",
+		"b = 1; % other",
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"A = [1, 2       % first",
+		"     10, 20     % second",
+		"     x + 1, y]; % third",
+		"b = 1; % other",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	// Tabs advance to the next tab stop when comparing the columns.
+	lines = []string{"A = [1,2\t% first", "     10,20  % second"}
+	if got, err = f.FormatLines(lines); err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want = []string{"A = [1, 2   % first", "     10, 20 % second"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesMatrixSeparators(t *testing.T) {
+	lines := []string{
+		"x = [1 2 -3;4,5 ,6;];",
+		"y = [a' b - c, f(1, 2) 'p q'];",
+		"z = {1 2,};",
+	}
+	tests := []struct {
+		separator string
+		trim      bool
+		want      []string
+	}{
+		{"comma", true, []string{"x = [1, 2, -3; 4, 5, 6];", "y = [a', b - c, f(1, 2), 'p q'];", "z = {1 2, };"}},
+		{"space", false, []string{"x = [1 2 -3; 4 5 6;];", "y = [a' b - c f(1, 2) 'p q'];", "z = {1 2, };"}},
+		{"keep", true, []string{"x = [1 2 -3; 4, 5, 6];", "y = [a' b - c, f(1, 2) 'p q'];", "z = {1 2, };"}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.MatrixSeparator = tt.separator
+		opts.TrimMatrixSeparators = tt.trim
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: unexpected result:\n got %q\nwant %q", tt.separator, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLinesNumbers(t *testing.T) {
+	lines := []string{
+		"x = [.5 1.50 5.e3 2.0 3.' 2.^y];",
+		"s = '1.50 .5'; % 1.0 .5",
+		"z = 3i + 0x1F + .50e-3;",
+	}
+	tests := []struct {
+		normalize bool
+		trim      bool
+		want      []string
+	}{
+		{false, false, lines},
+		{true, false, []string{"x = [0.5 1.50 5e3 2.0 3.' 2.^y];", lines[1], "z = 3i + 0x1F + 0.50e-3;"}},
+		{false, true, []string{"x = [.5 1.5 5e3 2 3.' 2.^y];", lines[1], "z = 3i + 0x1F + .5e-3;"}},
+		{true, true, []string{"x = [0.5 1.5 5e3 2 3.' 2.^y];", lines[1], "z = 3i + 0x1F + 0.5e-3;"}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.NormalizeNumbers = tt.normalize
+		opts.TrimNumberZeros = tt.trim
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalize=%t trim=%t: unexpected result:\n got %q\nwant %q", tt.normalize, tt.trim, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLinesExponents(t *testing.T) {
+	lines := []string{"x = [1E+03 2.5e-007 1e+00 3E4] * x.e10;", "s = '1E+03';"}
+	tests := []struct {
+		exponentCase string
+		trim         bool
+		want         string
+	}{
+		{"keep", false, lines[0]},
+		{"lower", false, "x = [1e+03 2.5e-007 1e+00 3e4] * x.e10;"},
+		{"upper", true, "x = [1E3 2.5E-7 1E0 3E4] * x.e10;"},
+		{"lower", true, "x = [1e3 2.5e-7 1e0 3e4] * x.e10;"},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.ExponentCase = tt.exponentCase
+		opts.TrimExponents = tt.trim
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if want := []string{tt.want, lines[1]}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s trim=%t: unexpected result:\n got %q\nwant %q", tt.exponentCase, tt.trim, got, want)
+		}
+	}
+}
+
+func TestFormatLinesKeepsBinaryOperatorsBeforeNumbers(t *testing.T) {
+	lines := []string{"y = [a - 2e3, b -2e3];", "y = x + 1/2 - 1e-3i;"}
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, lines) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, lines)
+	}
+}
+
+func TestFormatLinesTokens(t *testing.T) {
+	lines := []string{
+		"function r=f(x,s)",
+		"if x(end - 1)>0",
+		"r=[x' s.end'];",
+		"end",
+		"events={};",
+		"for i=1:3, if i>1, r=x(i); end",
+		"end",
+		"t='50% done';u=\"say \"\"hi\"\" %\";",
+		"q=a<-1 & ~b;",
+		"h=2.\\x;",
+		"imported=1;",
+		"end",
+	}
+	want := []string{
+		"function r = f(x, s)",
+		"",
+		"    if x(end - 1) > 0",
+		"        r = [x' s.end'];",
+		"    end",
+		"",
+		"    events = {};",
+		"",
+		"    for i = 1:3, if i > 1, r = x(i); end",
+		"    end",
+		"",
+		"    t = '50% done'; u = \"say \"\"hi\"\" %\";",
+		"    q = a < -1 & ~b;",
+		"    h = 2 .\\ x;",
+		"    imported = 1;",
+		"end",
+	}
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesComplexSpacing(t *testing.T) {
+	lines := []string{
+		"z = 3+4i;",
+		"a = [1 -2i, 3 - 4j];",
+		"g = [3+4i -1+2j 1e3 - 2.5e-3i];",
+		"y = x - 3+4i;",
+		"w = f(1+2i) * 2.5j + 1e-3i;",
+		"s = '3+4i';",
+	}
+	tests := []struct {
+		spacing string
+		want    []string
+	}{
+		{"keep", []string{
+			"z = 3 + 4i;",
+			"a = [1 -2i, 3 - 4j];",
+			"g = [3 + 4i -1 + 2j 1e3 - 2.5e-3i];",
+			"y = x - 3 + 4i;",
+			"w = f(1 + 2i) * 2.5j + 1e-3i;",
+			"s = '3+4i';",
+		}},
+		{"tight", []string{
+			"z = 3+4i;",
+			"a = [1 -2i, 3-4j];",
+			"g = [3+4i -1+2j 1e3-2.5e-3i];",
+			"y = x - 3 + 4i;",
+			"w = f(1+2i) * 2.5j + 1e-3i;",
+			"s = '3+4i';",
+		}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.ComplexSpacing = tt.spacing
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: unexpected result:\n got %q\nwant %q", tt.spacing, got, tt.want)
+		}
+	}
+
+	// With no_spaces, spaced complex literals still keep the signs of matrix
+	// elements attached.
+	opts := DefaultOptions()
+	opts.AddSpaces = "no_spaces"
+	opts.ComplexSpacing = "spaced"
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines([]string{"g = [3+4i -1+2j 5];", "y = x-3+4i;"})
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if want := []string{"g=[3 + 4i -1 + 2j 5];", "y=x-3+4i;"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("spaced: unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesAlignMatrixColumns(t *testing.T) {
+	lines := []string{
+		"A = [1, 22, -3.5",
+		"100, 2, 4",
+		"x, 1e3, 5];",
+	}
+	f, err := New(WithAlignMatrixColumns(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"A = [  1,  22, -3.5",
+		"     100,   2,    4",
+		"     x,   1e3,    5];",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesMatrixIndentPreserve(t *testing.T) {
+	lines := []string{
+		"if x",
+		"      A = [1    2.50   3",
+		"           10   20     30];",
+		"B={'a',  1",
+		"   'bc', 2};",
+		"end",
+	}
+	f, err := New(WithMatrixIndent(MatrixIndentPreserve))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"if x",
+		"    A = [1    2.50   3",
+		"         10   20     30];",
+		"    B = {'a',  1",
+		"         'bc', 2};",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesSpacedOperators(t *testing.T) {
+	lines := []string{"y=a*b+c.^2-d.*e;", "z=(a*b>c)&&d;"}
+	tests := []struct {
+		classes []string
+		want    []string
+	}{
+		{[]string{"assignment", "relational", "logical"}, []string{"y = a*b+c.^2-d.*e;", "z = (a*b > c) && d;"}},
+		{[]string{"arithmetic", "power"}, []string{"y=a * b + c .^ 2 - d.*e;", "z=(a * b>c)&&d;"}},
+		{nil, []string{"y=a*b+c.^2-d.*e;", "z=(a*b>c)&&d;"}},
+	}
+	for _, tt := range tests {
+		f, err := NewStrict(WithSpacedOperators(tt.classes...))
+		if err != nil {
+			t.Fatalf("NewStrict: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("classes %q: got %q, want %q", tt.classes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLinesSpaceShortCircuit(t *testing.T) {
+	f, err := New(WithOperatorSpacing(SpaceNoOperators), WithSpaceShortCircuit(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines([]string{"ok = a > 0 && b < 1 || c & d;"})
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if want := []string{"ok=a>0 && b<1 || c&d;"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatLinesColonSpacing(t *testing.T) {
+	lines := []string{"r = 1 : 0.1:10;", "b = a(:, 1:end);"}
+	tests := map[ColonSpacing][]string{
+		ColonTight:  {"r = 1:0.1:10;", "b = a(:, 1:end);"},
+		ColonSpaced: {"r = 1 : 0.1 : 10;", "b = a(:, 1 : end);"},
+		ColonKeep:   {"r = 1 : 0.1:10;", "b = a(:, 1:end);"},
+	}
+	for mode, want := range tests {
+		f, err := New(WithColonSpacing(mode))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestFormatLinesUnarySigns(t *testing.T) {
+	lines := []string{
+		"a = f() -1;",
+		"b = [f() -1, x(2) -3];",
+		"c = x(a -1);",
+		"d = {a -1, 1e-3 -2e+1};",
+		"e = 2^-1 * -x;",
+		"m = [1 2",
+		"3 -4];",
+	}
+	tests := map[UnarySigns][]string{
+		UnarySignsContext: {
+			"a = f() - 1;", "b = [f() -1, x(2) -3];", "c = x(a - 1);", "d = {a -1, 1e-3 -2e+1};", "e = 2^-1 * -x;", "m = [1 2", "     3 -4];",
+		},
+		UnarySignsWhitespace: {
+			"a = f() -1;", "b = [f() -1, x(2) -3];", "c = x(a -1);", "d = {a -1, 1e-3 -2e+1};", "e = 2^-1 * -x;", "m = [1 2", "     3 -4];",
+		},
+	}
+	for mode, want := range tests {
+		f, err := New(WithUnarySigns(mode))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %q\nwant %q", mode, got, want)
+		}
+	}
+}
+
+func TestFormatLinesUnaryMinus(t *testing.T) {
+	// A unary minus is a token of its own: the assignment before it is
+	// spaced like any operator, and a sign keeps the space separating it from
+	// the sign it applies to, but gets none before its operand.
+	lines := []string{"F =- -1;", "F=- -1;", "F = --1;", "G = -(-x);", "H = a - -b;", "I = [- -1];", "J=-1;"}
+	want := []string{"F = - -1;", "F = - -1;", "F = --1;", "G = -(-x);", "H = a - -b;", "I = [- -1];", "J = -1;"}
+	f, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatLinesNormalizeEnds(t *testing.T) {
+	lines := []string{"if x", "y=1;", "endif; % done", "while y", "end; z = 2;", "for i=1:2", "endfor"}
+	want := []string{"if x", "    y = 1;", "end % done", "while y", "end; z = 2;", "for i = 1:2", "end"}
+	f, err := New(WithNormalizeEnds(true), WithSeparatedBlocks())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatLinesOctaveBlocks(t *testing.T) {
+	lines := []string{
+		"do",
+		"x++;",
+		"until (x > 3)",
+		"unwind_protect",
+		"f(x);",
+		"unwind_protect_cleanup",
+		"fclose(fid);",
+		"end_unwind_protect",
+		"do = 1;",
+		"until = do;",
+	}
+	want := []string{
+		"do",
+		"    x++;",
+		"until (x > 3)",
+		"unwind_protect",
+		"    f(x);",
+		"unwind_protect_cleanup",
+		"    fclose(fid);",
+		"end_unwind_protect",
+		"do = 1;",
+		"until = do;",
+	}
+	f, err := New(WithSeparatedBlocks(), WithNormalizeEnds(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatLinesOctaveComments(t *testing.T) {
+	lines := []string{
+		"function r = f(x)",
+		"# returns (x",
+		"if x>1 # large [",
+		`r=sprintf("a\"b # %d", x);`,
+		"#{",
+		"  kept   as is",
+		"#}",
+		"# formatter ignore 1",
+		"r  =  2;",
+		"endif",
+		"endfunction",
+		"%!assert (f (2), 1)",
+	}
+	want := []string{
+		"function r = f(x)",
+		"    # returns (x",
+		"    if x > 1 # large [",
+		`        r = sprintf("a\"b # %d", x);`,
+		"#{",
+		"  kept   as is",
+		"#}",
+		"        # formatter ignore 1",
+		"        r  =  2;",
+		"    endif",
+		"endfunction",
+		"%!assert (f (2), 1)",
+	}
+	for _, d := range []Dialect{DialectAuto, DialectOctave} {
+		f, err := New(WithDialect(d), WithSeparatedBlocks())
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("dialect %s:\n got %q\nwant %q", d, got, want)
+		}
+	}
+	if ignored := IgnoredLines(lines); !ignored[8] {
+		t.Errorf("IgnoredLines missed the directive in a # comment")
+	}
+}
+
+func TestFormatLinesSpaceAfterComma(t *testing.T) {
+	lines := []string{"x = f(a+1,b);", "y = [1 2; 3 4];"}
+	tests := []struct {
+		opts []Option
+		want []string
+	}{
+		{[]Option{WithOperatorSpacing(SpaceNoOperators)}, []string{"x=f(a+1, b);", "y=[1 2; 3 4];"}},
+		{[]Option{WithSpaceAfterComma(false), WithMatrixSeparator(MatrixSeparatorComma)}, []string{"x = f(a + 1,b);", "y = [1,2;3,4];"}},
+	}
+	for _, tt := range tests {
+		f, err := New(tt.opts...)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFormatLinesBracketSpacing(t *testing.T) {
+	lines := []string{"x = f(a+1, g());", "y = [1, 2, 3,];", "z = {1, [2 3]};", "A = [1 2", "3 4];"}
+	want := []string{"x = f( a + 1, g() );", "y = [ 1, 2, 3 ];", "z = {1, [ 2 3 ]};", "A = [ 1 2", "      3 4 ];"}
+	f, err := New(WithBracketSpacing(true, true, false), WithTrimMatrixSeparators(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesAlignsCellColumns(t *testing.T) {
+	lines := []string{
+		"opts = {'name', 'Alice', 1",
+		"'longer_name', 'Bob', 22",
+		"'x', f(1, 2), 3};",
+	}
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"opts = {'name',        'Alice', 1",
+		"        'longer_name', 'Bob',   22",
+		"        'x',           f(1, 2), 3};",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	opts := DefaultOptions()
+	opts.MatrixIndent = "simple"
+	if f, err = New(opts); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got, err = f.FormatLines(lines); err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if got[0] != "opts = {'name', 'Alice', 1" {
+		t.Fatalf("simple mode padded the cell array: %q", got)
+	}
+}
+
+func TestFormatLinesNestedIndentWidth(t *testing.T) {
+	opts := DefaultOptions()
+	opts.NestedIndentWidth = 2
+	opts.SeparateBlocks = false
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"function outer()", "x = 1;", "function inner()", "if x", "y = 2;", "end", "end", "z = 3;", "end"}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"function outer()",
+		"    x = 1;",
+		"    function inner()",
+		"      if x",
+		"          y = 2;",
+		"      end",
+		"    end",
+		"    z = 3;",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	opts.NestedIndentWidth = -1
+	if _, err := New(opts); err == nil {
+		t.Fatal("expected error for negative nested indent width")
+	}
+}
+
+func TestFormatLinesIndentStyle(t *testing.T) {
+	opts := DefaultOptions()
+	opts.IndentStyle = "tab"
+	opts.SeparateBlocks = false
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"if x", "for k = 1:n", "y = [1, ...", "2];", "end", "end"}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{"if x", "\tfor k = 1:n", "\t\ty = [1, ...", "\t\t\t\t 2];", "\tend", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	opts.IndentStyle = "tabs"
+	if _, err := New(opts); err == nil {
+		t.Fatal("expected error for unknown indent style")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LineEnding = "crlf"
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	src := "if x\ny=1;\r\nend"
+	want := "if x\r\n    y = 1;\r\nend\r\n"
+
+	got, err := f.Format([]byte(src))
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := f.FormatReader(strings.NewReader(src), &buf); err != nil {
+		t.Fatalf("FormatReader: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("FormatReader wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFormatLinesContext(t *testing.T) {
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := make([]string, 3*cancelCheckLines)
+	for i := range lines {
+		lines[i] = "x=1;"
+	}
+	got, err := f.FormatLinesContext(context.Background(), lines)
+	if err != nil || len(got) != len(lines) || got[0] != "x = 1;" {
+		t.Fatalf("FormatLinesContext: got %d lines starting with %q, error %v", len(got), got[0], err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.FormatLinesContext(ctx, lines); err != context.Canceled {
+		t.Errorf("FormatLinesContext after cancel: got error %v, want %v", err, context.Canceled)
+	}
+	if _, err := f.FormatRangesContext(ctx, lines, []LineRange{NewLineRange(2, 3)}); err != context.Canceled {
+		t.Errorf("FormatRangesContext after cancel: got error %v, want %v", err, context.Canceled)
+	}
+	var out strings.Builder
+	if err := f.FormatFileContext(ctx, "testdata/sample_unformatted.m", &out); err != context.Canceled || out.Len() > 0 {
+		t.Errorf("FormatFileContext after cancel: got error %v and %d bytes", err, out.Len())
+	}
+}
+
+func TestFormatterConcurrentUse(t *testing.T) {
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	sources := [][]string{
+		{"function f(x)", "if x", "y=1;", "end", "end"},
+		{"A = [1 2", "3 4];", "for k=1:3", "disp(k)", "end"},
+		{"classdef C", "properties", "a", "end", "end"},
+	}
+	want := make([][]string, len(sources))
+	for i, src := range sources {
+		if want[i], err = f.FormatLines(src); err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		for i, src := range sources {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, traces, err := f.FormatLinesTrace(context.Background(), src, InPackage("p", []string{"p"}))
+				if err != nil || !reflect.DeepEqual(got, want[i]) {
+					t.Errorf("concurrent FormatLinesTrace(%q) = %q, %v; want %q", src, got, err, want[i])
+				}
+				if len(traces) != len(src) {
+					t.Errorf("concurrent FormatLinesTrace(%q) traced %d lines, want %d", src, len(traces), len(src))
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestJoinLines(t *testing.T) {
+	tests := []struct {
+		ending string
+		final  bool
+		want   string
+	}{
+		{"lf", true, "a\nb\n"},
+		{"crlf", true, "a\r\nb\r\n"},
+		{"cr", false, "a\rb"},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.LineEnding = tt.ending
+		opts.FinalNewline = tt.final
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if got := f.JoinLines([]string{"a", "b"}); got != tt.want {
+			t.Errorf("JoinLines(%s, %v) = %q, want %q", tt.ending, tt.final, got, tt.want)
+		}
+	}
+}
+
+func TestLineEndingAuto(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"a\r\nb\r\nc\n", "crlf"},
+		{"a\nb\r\n", "lf"},
+		{"a\rb\rc", "cr"},
+		{"a", "lf"},
+	}
+	for _, tt := range tests {
+		if got := DetectLineEnding([]byte(tt.src)); got != tt.want {
+			t.Errorf("DetectLineEnding(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.Format([]byte("if x\r\ny=1;\r\nend\r\n"))
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if want := "if x\r\n    y = 1;\r\nend\r\n"; string(got) != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+	if got := f.JoinLines([]string{"a", "b"}); got != "a\nb\n" {
+		t.Errorf("JoinLines = %q, want LF line endings", got)
+	}
+}
+
+func TestFormatLinesMaxBlankLines(t *testing.T) {
+	lines := []string{"", "a = 1;", "", "", "", "b = 2;", "", "c = 3;"}
+	tests := map[int][]string{
+		0: {"a = 1;", "b = 2;", "c = 3;"},
+		1: {"a = 1;", "", "b = 2;", "", "c = 3;"},
+		2: {"a = 1;", "", "", "b = 2;", "", "c = 3;"},
+	}
+	for n, want := range tests {
+		f, err := New(WithMaxBlankLines(n))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MaxBlankLines %d:\n got %q\nwant %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatLinesSeparatedBlocks(t *testing.T) {
+	lines := []string{"function a", "if x", "y = 1;", "end", "for i = 1:2", "end", "end", "function b", "end"}
+	tests := []struct {
+		kinds []string
+		want  []string
+	}{
+		{[]string{"function"}, []string{
+			"function a", "    if x", "        y = 1;", "    end", "    for i = 1:2", "    end", "end", "", "function b", "end",
+		}},
+		{[]string{"loop"}, []string{
+			"function a", "    if x", "        y = 1;", "    end", "", "    for i = 1:2", "    end", "", "end", "function b", "end",
+		}},
+		{nil, []string{
+			"function a", "    if x", "        y = 1;", "    end", "    for i = 1:2", "    end", "end", "function b", "end",
+		}},
+	}
+	for _, tt := range tests {
+		f, err := New(WithSeparatedBlocks(tt.kinds...))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("kinds %q:\n got %q\nwant %q", tt.kinds, got, tt.want)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.SeparatedBlocks = "function,loops"
+	if err := opts.Validate(); err == nil {
+		t.Error("Validate accepted the unknown kind loops")
+	}
+}
+
+func TestFormatLinesFunctionBlankLines(t *testing.T) {
+	lines := []string{
+		"function a", "x = 1;", "function b", "end", "end",
+		"", "", "", "% helper", "function c", "end",
+	}
+	want := []string{
+		"function a", "    x = 1;", "", "    function b", "    end", "", "end",
+		"", "", "% helper", "function c", "end",
+	}
+	f, err := New(WithFunctionBlankLines(2, 1))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFormatLinesAlignAssignments(t *testing.T) {
+	lines := []string{"kp=1;", "kid=0.5; % i", "x(1:2) = [1 2];", "% gains", "a=1;", "if a==1", "bb=2;", "c=3;", "end", "d = 1; e = 2;", "f = 3;", "gg=4;"}
+	want := []string{
+		"kp     = 1;", "kid    = 0.5; % i", "x(1:2) = [1 2];", "% gains", "a = 1;",
+		"if a == 1", "    bb = 2;", "    c  = 3;", "end",
+		"d = 1; e = 2;", "f  = 3;", "gg = 4;",
+	}
+	f, err := New(WithAlignAssignments(true), WithSeparatedBlocks())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFormatLinesCommentColumn(t *testing.T) {
+	lines := []string{"x=1; % one", "if x % cond", "longName = f(a, b); % long", "% plain", "y = [1 % a", " 22]; % b", "end"}
+	want := []string{
+		"x = 1;        % one",
+		"if x          % cond",
+		"    longName = f(a, b); % long",
+		"    % plain",
+		"    y = [1    % a",
+		"         22]; % b",
+		"end",
+	}
+	f, err := New(WithCommentColumn(15), WithSeparatedBlocks())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestKeepTrailingLines(t *testing.T) {
+	tests := []struct {
+		keep      bool
+		src, want string
+	}{
+		{false, "x=1;\n\n\n", "x = 1;\n"},
+		{false, "x=1;", "x = 1;\n"},
+		{true, "x=1;\n\n\n", "x = 1;\n\n\n"},
+		{true, "x=1;", "x = 1;"},
+		{true, "x=1;\n", "x = 1;\n"},
+	}
+	for _, tt := range tests {
+		f, err := New(WithKeepTrailingLines(tt.keep))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.Format([]byte(tt.src))
+		if err != nil {
+			t.Fatalf("Format: %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("KeepTrailingLines %t: Format(%q) = %q, want %q", tt.keep, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLinesClassdefIndent(t *testing.T) {
+	lines := []string{"classdef Foo", "properties", "x = 1;", "end", "methods", "function f(obj)", "y = 2;", "end", "end", "end"}
+	tests := map[string][]string{
+		"all":      {"classdef Foo", "    properties", "        x = 1;", "    end", "    methods", "        function f(obj)", "            y = 2;", "        end", "    end", "end"},
+		"blocks":   {"classdef Foo", "properties", "    x = 1;", "end", "methods", "    function f(obj)", "        y = 2;", "    end", "end", "end"},
+		"classdef": {"classdef Foo", "    properties", "    x = 1;", "    end", "    methods", "    function f(obj)", "        y = 2;", "    end", "    end", "end"},
+	}
+	for mode, want := range tests {
+		opts := DefaultOptions()
+		opts.ClassdefIndent = mode
+		opts.SeparateBlocks = false
+		f, err := New(opts)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		got, err := f.FormatLines(lines)
+		if err != nil {
+			t.Fatalf("FormatLines: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: unexpected result:\n got %q\nwant %q", mode, got, want)
+		}
+	}
+}
+
+func TestFormatLinesDeclarations(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SeparateBlocks = false
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{
+		"function f(a, b)",
+		"arguments",
+		"a(1,:)double{ mustBePositive , mustBeInteger}=1 % count",
+		"b  { mustBeMember(b,{'x','y'}) }",
+		"end",
+		"c = x(1,:);",
+		"end",
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"function f(a, b)",
+		"    arguments",
+		"        a (1, :) double {mustBePositive, mustBeInteger} = 1 % count",
+		"        b {mustBeMember(b, {'x', 'y'})}",
+		"    end",
+		"    c = x(1, :);",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesSortImportsKeepsStructure(t *testing.T) {
+	// The duplicate import removed shifts the lines after it, which must
+	// not shift the blocks the blank lines are placed around.
+	lines := []string{"function f()", "import a.b", "import a.b", "x=1;", "if x", "y=1;", "end", "z=2;", "end"}
+	want := []string{"function f()", "    import a.b", "    x = 1;", "", "    if x", "        y = 1;", "    end", "", "    z = 2;", "end"}
+	f, err := New(WithSortImports(true), WithSeparatedBlocks("if"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQualifyAndGroupImports(t *testing.T) {
+	opts := DefaultOptions()
+	opts.GroupImports = true
+	opts.QualifyImports = true
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{
+		"function foo()",
+		"import c.helper",
+		"import z.thing",
+		"",
+		"import matlab.unittest.TestCase",
+		"import b.other",
+		"x = 1;",
+		"end",
+	}
+	got, err := f.FormatLines(lines, InPackage("a.b", []string{"a", "a.b", "a.b.c", "z"}))
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"function foo()",
+		"    import a.b.c.helper",
+		"    import a.b.other",
+		"",
+		"    import matlab.unittest.TestCase",
+		"",
+		"    import z.thing",
+		"    x = 1;",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	// The package applies to its call only.
+	got, err = f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	if got[1] != "    import b.other" || got[3] != "    import c.helper" {
+		t.Errorf("imports qualified without a package: %q", got)
+	}
+}
+
+func TestFormatLinesJoinContinuations(t *testing.T) {
+	f, err := New(WithMaxLineLength(30), WithJoinContinuations(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{
+		"function f()",
+		"x = g(a, ...",
+		"      b);",
+		"y = [1 2 ...",
+		"     -3];",
+		"z = h(...",
+		"  'a ... b' ...",
+		"  );",
+		"long = first_argument + ...",
+		"       second_argument;",
+		"c = 1 + ... % one",
+		"    2;",
+		"d = [1, ...",
+		"% note",
+		"     2];",
+		"end",
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"function f()",
+		"    x = g(a, b);",
+		"    y = [1 2 -3];",
+		"    z = h('a ... b');",
+		"    long = first_argument + ...",
+		"        second_argument;",
+		"    c = 1 + ... % one",
+		"        2;",
+		"    d = [1, ...",
+		"    % note",
+		"         2];",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesAlignedContinuations(t *testing.T) {
+	f, err := New(WithContinuationStyle(ContinuationAligned))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{
+		"function f()",
+		"x = foo(a, ...",
+		"bar(b, ...",
+		"c), ...",
+		"d);",
+		"y = a + ...",
+		"b;",
+		"end",
+	}
+	got, err := f.FormatLines(lines)
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{
+		"function f()",
+		"    x = foo(a, ...",
+		"            bar(b, ...",
+		"                c), ...",
+		"            d);",
+		"    y = a + ...",
+		"        b;",
+		"end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatLinesContinuationIndent(t *testing.T) {
+	f, err := New(WithContinuationIndent(8))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got, err := f.FormatLines([]string{"if x", "y = a + ...", "b;", "end"})
+	if err != nil {
+		t.Fatalf("FormatLines: %v", err)
+	}
+	want := []string{"if x", "    y = a + ...", "            b;", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+	if _, err := New(WithContinuationIndent(-1)); err == nil {
+		t.Error("New accepted a negative continuation indent")
+	}
+}
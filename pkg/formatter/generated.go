@@ -0,0 +1,35 @@
+package formatter
+
+import "strings"
+
+// DefaultGeneratedMarkers are the phrases marking machine-generated files,
+// such as the banners of MATLAB Coder and Simulink Coder output.
+var DefaultGeneratedMarkers = []string{
+	"auto-generated",
+	"automatically generated",
+	"generated by",
+	"do not edit",
+}
+
+// generatedHeaderLines is the number of leading lines searched for markers.
+const generatedHeaderLines = 10
+
+// IsGenerated reports whether one of the comment lines among the first lines
+// of a file contains one of the markers, compared case-insensitively.
+func IsGenerated(lines []string, markers []string) bool {
+	for i, line := range lines {
+		if i >= generatedHeaderLines {
+			break
+		}
+		if !commentLine.MatchString(line) {
+			continue
+		}
+		lower := strings.ToLower(line)
+		for _, marker := range markers {
+			if marker != "" && strings.Contains(lower, strings.ToLower(marker)) {
+				return true
+			}
+		}
+	}
+	return false
+}
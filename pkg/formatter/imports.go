@@ -0,0 +1,192 @@
+package formatter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	importLine  = regexp.MustCompile(`^\s*import\s+([A-Za-z]\w*(?:\.\w+)*(?:\.\*)?)\s*;?\s*(%.*)?$`)
+	declaration = regexp.MustCompile(`^\s*(function|classdef)\b`)
+)
+
+// InPackage sets the package of the file formatted, such as "a.b" for a file
+// in +a/+b, and the packages of the hierarchy around it, used by
+// QualifyImports to tell imports relative to the package from fully-qualified
+// ones. An empty name stands for a file outside of any package, which is also
+// assumed without InPackage.
+func InPackage(name string, packages []string) CallOption {
+	return func(c *call) {
+		c.pkg = name
+		c.packages = make(map[string]bool, len(packages))
+		for _, p := range packages {
+			c.packages[p] = true
+		}
+	}
+}
+
+// normalizeImports applies SortImports, GroupImports and QualifyImports to
+// the import statements of lines. It also returns the index in lines each
+// resulting line comes from, which is -1 for inserted blank lines.
+func (s *session) normalizeImports(lines []string) ([]string, []int) {
+	if s.opts.QualifyImports {
+		lines = s.qualifyImports(lines)
+	}
+	if s.opts.SortImports || s.opts.GroupImports {
+		return sortImports(lines, s.opts.GroupImports)
+	}
+	origins := make([]int, len(lines))
+	for i := range origins {
+		origins[i] = i
+	}
+	return lines, origins
+}
+
+// qualifyImports rewrites imports that name a package relative to the package
+// of the file, or to one of its parents, into fully-qualified form.
+func (s *session) qualifyImports(lines []string) []string {
+	if s.pkg == "" {
+		return lines
+	}
+	ignored := IgnoredLines(lines)
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = line
+		m := importLine.FindStringSubmatchIndex(line)
+		if m == nil || ignored[i] {
+			continue
+		}
+		name := line[m[2]:m[3]]
+		if qualified := s.qualify(name); qualified != name {
+			result[i] = line[:m[2]] + qualified + line[m[3]:]
+		}
+	}
+	return result
+}
+
+// qualify returns the fully-qualified form of an imported name. Names whose
+// package is known at the top level, and names that do not resolve, are
+// returned unchanged.
+func (s *session) qualify(name string) string {
+	if s.knownPackage(name) {
+		return name
+	}
+	for p := s.pkg; p != ""; p = parentPackage(p) {
+		if s.knownPackage(p + "." + name) {
+			return p + "." + name
+		}
+	}
+	return name
+}
+
+// knownPackage reports whether name is a package of the hierarchy or a
+// member of one.
+func (s *session) knownPackage(name string) bool {
+	name = strings.TrimSuffix(name, ".*")
+	return s.packages[name] || s.packages[parentPackage(name)]
+}
+
+func parentPackage(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// sortImports sorts groups of consecutive command-form import statements at
+// the top of the file, a function or a classdef, and removes imports of a name
+// already imported by the same group. Other imports are left untouched. With
+// group set, the imports of a group are separated by a blank line whenever
+// their top-level package changes, and imports separated only by blank lines
+// form one group. The index in lines each resulting line comes from is
+// returned too, or -1 for the blank lines separating groups.
+func sortImports(lines []string, group bool) ([]string, []int) {
+	ignored := IgnoredLines(lines)
+	result := make([]string, 0, len(lines))
+	origins := make([]int, 0, len(lines))
+
+	// atTop is set while no statement has been seen since the start of the
+	// file or the last declaration.
+	atTop := true
+	inBlockComment := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case blockCommentOpenLine.MatchString(line):
+			inBlockComment = true
+		case inBlockComment:
+			inBlockComment = !blockCommentCloseLine.MatchString(line)
+		case strings.TrimSpace(line) == "" || commentLine.MatchString(line):
+		case atTop && !ignored[i] && importLine.MatchString(line):
+			var imports []string
+			var indices []int
+			end := i
+			for end < len(lines) {
+				next := end
+				if group {
+					for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+						next++
+					}
+				}
+				if next == len(lines) || ignored[next] || !importLine.MatchString(lines[next]) {
+					break
+				}
+				imports = append(imports, lines[next])
+				indices = append(indices, next)
+				end = next + 1
+			}
+			sorted, from := sortImportGroup(imports, indices, group)
+			result = append(result, sorted...)
+			origins = append(origins, from...)
+			i = end - 1
+			atTop = false
+			continue
+		default:
+			atTop = declaration.MatchString(line)
+		}
+		result = append(result, line)
+		origins = append(origins, i)
+	}
+	return result, origins
+}
+
+// sortImportGroup sorts the import lines of group, found at indices, and
+// returns them with the index of each, or -1 for separating blank lines.
+func sortImportGroup(group []string, indices []int, separate bool) ([]string, []int) {
+	seen := make(map[string]bool, len(group))
+	type entry struct {
+		name, line string
+		index      int
+	}
+	var entries []entry
+	for i, line := range group {
+		name := importLine.FindStringSubmatch(line)[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		entries = append(entries, entry{name, line, indices[i]})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	sorted := make([]string, 0, len(entries))
+	from := make([]int, 0, len(entries))
+	for i, e := range entries {
+		if separate && i > 0 && topPackage(e.name) != topPackage(entries[i-1].name) {
+			sorted = append(sorted, "")
+			from = append(from, -1)
+		}
+		sorted = append(sorted, e.line)
+		from = append(from, e.index)
+	}
+	return sorted, from
+}
+
+// topPackage returns the first component of an imported name.
+func topPackage(name string) string {
+	top, _, _ := strings.Cut(name, ".")
+	return top
+}
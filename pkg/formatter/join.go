@@ -0,0 +1,60 @@
+package formatter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// joinContinuations joins the lines of the statements of output continued
+// with ... that fit in MaxLineLength columns on one line. code marks the
+// lines holding code; comments and ignored lines are never joined. A
+// statement is joined as a whole or not at all.
+func (s *session) joinContinuations(output []string, code map[int]bool) []string {
+	var joined []string
+	for i := 0; i < len(output); i++ {
+		line, end := output[i], i
+		for code[end] && code[end+1] {
+			head, ok := s.bareContinuation(line)
+			if !ok {
+				break
+			}
+			end++
+			next := strings.TrimLeft(output[end], " \t")
+			sep := " "
+			if strings.IndexByte("([{", head[len(head)-1]) >= 0 || next != "" && strings.IndexByte(")]},;", next[0]) >= 0 {
+				sep = ""
+			}
+			line = head + sep + next
+		}
+		if end > i && s.opts.CommentColumn > 0 {
+			line = s.alignComment(line, s.opts.CommentColumn, s.tabStop())
+		}
+		if end > i && !hasContinuation(s.dialect.Tokenize(line)) && utf8.RuneCountInString(line) <= s.opts.MaxLineLength {
+			joined = append(joined, line)
+		} else {
+			joined = append(joined, output[i:end+1]...)
+		}
+		i = end
+	}
+	return joined
+}
+
+// bareContinuation returns line without its trailing ... and the whitespace
+// before it, and whether line ends with a continuation that neither a
+// comment follows nor stands alone.
+func (f *Formatter) bareContinuation(line string) (string, bool) {
+	toks := f.dialect.Tokenize(line)
+	for i := len(toks) - 1; i >= 0; i-- {
+		switch toks[i].Kind {
+		case syntax.TokenSpace:
+			continue
+		case syntax.TokenContinuation:
+			head := strings.TrimRight(line[:toks[i].Offset], " \t")
+			return head, strings.TrimSpace(head) != ""
+		}
+		break
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+package formatter
+
+import "strings"
+
+// matrixSeparators lists the accepted values of Options.MatrixSeparator.
+var matrixSeparators = map[string]bool{
+	"keep":  true,
+	"comma": true,
+	"space": true,
+}
+
+// separateMatrix normalizes the element and row separators inside the [...]
+// literals of a formatted line. inMatrix reports whether the line starts
+// inside a matrix opened on an earlier line.
+func (f *Formatter) separateMatrix(line string, inMatrix bool) string {
+	normalize := f.matrixSeparator != "keep"
+	if !normalize && !f.opts.TrimMatrixSeparators {
+		return line
+	}
+
+	code, _ := f.dialect.ScanLine(line)
+	var stack []byte
+	if inMatrix {
+		stack = append(stack, '[')
+	}
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		c := code[i]
+		inBrackets := len(stack) > 0 && stack[len(stack)-1] == '['
+		switch {
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, c)
+			b.WriteByte(c)
+			i++
+		case c == ')' || c == ']' || c == '}':
+			if c == ']' && inBrackets && f.opts.TrimMatrixSeparators {
+				trimSeparators(&b)
+				if s := b.String(); f.opts.SpaceInBrackets && s != "" && s[len(s)-1] != '[' {
+					b.WriteByte(' ')
+				}
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			b.WriteByte(c)
+			i++
+		case normalize && inBrackets && (c == ',' || c == ';'):
+			j := skipSpace(code, i+1)
+			last := strings.TrimRight(b.String(), " \t")
+			b.Reset()
+			b.WriteString(last)
+			switch {
+			case j < len(code) && code[j] == ']':
+				b.WriteByte(c)
+			case j == len(code):
+				// Keep the spaces before a trailing comment.
+				b.WriteByte(c)
+				b.WriteString(line[i+1 : j])
+			case c == ',' && f.matrixSeparator == "space":
+				b.WriteByte(' ')
+			default:
+				b.WriteByte(c)
+				if f.opts.SpaceAfterComma {
+					b.WriteByte(' ')
+				}
+			}
+			i = j
+		case normalize && inBrackets && (c == ' ' || c == '\t'):
+			j := skipSpace(code, i+1)
+			if elementBreak(strings.TrimRight(b.String(), " \t"), code, j) {
+				if f.matrixSeparator == "comma" {
+					b.WriteByte(',')
+					if f.opts.SpaceAfterComma {
+						b.WriteByte(' ')
+					}
+				} else {
+					b.WriteByte(' ')
+				}
+			} else {
+				b.WriteString(line[i:j])
+			}
+			i = j
+		default:
+			b.WriteByte(line[i])
+			i++
+		}
+	}
+	b.WriteString(line[len(code):])
+	return b.String()
+}
+
+// elementBreak reports whether the whitespace between before and code[next:]
+// separates two elements of a matrix rather than the operands of a binary
+// operator.
+func elementBreak(before, code string, next int) bool {
+	if before == "" || next >= len(code) {
+		return false
+	}
+	if strings.IndexByte("[(,;+-*/\\^=<>&|~:!@", before[len(before)-1]) >= 0 {
+		return false
+	}
+	c := code[next]
+	following := byte(' ')
+	if next+1 < len(code) {
+		following = code[next+1]
+	}
+	switch {
+	case strings.IndexByte("]),;*/\\^=<>&|:", c) >= 0:
+		return false
+	case c == '.':
+		// A leading dot starts an element operator such as .* or a number
+		// such as .5.
+		return following >= '0' && following <= '9'
+	case c == '+' || c == '-':
+		// A sign directly followed by its operand is unary.
+		return following != ' ' && following != '\t' && following != c
+	case c == '~':
+		return following != '='
+	}
+	return true
+}
+
+// trimSeparators drops the separators and spaces at the end of b.
+func trimSeparators(b *strings.Builder) {
+	s := strings.TrimRight(b.String(), " \t,;")
+	b.Reset()
+	b.WriteString(s)
+}
+
+// skipSpace returns the index of the first byte of s at or after i that is
+// not a space or a tab.
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,272 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// numberLiteral is a numeric literal split into its parts: the integer and
+// fraction digits, whether it is written with a decimal point, and the
+// exponent including its marker, such as "e-3".
+type numberLiteral struct {
+	integer  string
+	point    bool
+	fraction string
+	exponent string
+}
+
+// exponentCases lists the accepted values of Options.ExponentCase.
+var exponentCases = map[string]bool{
+	"keep":  true,
+	"lower": true,
+	"upper": true,
+}
+
+// normalizeNumbers rewrites the numeric literals of a formatted line as
+// selected by NormalizeNumbers, TrimNumberZeros, ExponentCase and
+// TrimExponents. Strings and comments are left untouched.
+func (f *Formatter) normalizeNumbers(line string) string {
+	if !f.opts.NormalizeNumbers && !f.opts.TrimNumberZeros && f.opts.ExponentCase == "keep" && !f.opts.TrimExponents {
+		return line
+	}
+
+	code, _ := f.dialect.ScanLine(line)
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(code); {
+		if i > 0 && (syntax.IsIdentChar(code[i-1]) || code[i-1] == '.') {
+			i++
+			continue
+		}
+		n, end, ok := scanNumber(code, i)
+		if !ok {
+			i++
+			continue
+		}
+		if end < len(code) && syntax.IsIdentChar(code[end]) {
+			// Suffixed literals such as 3i or 0x1F are left as written.
+			for end < len(code) && syntax.IsIdentChar(code[end]) {
+				end++
+			}
+			i = end
+			continue
+		}
+		if s := f.formatNumber(n); s != line[i:end] {
+			b.WriteString(line[last:i])
+			b.WriteString(s)
+			last = end
+		}
+		i = end
+	}
+	if last == 0 {
+		return line
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// scanNumber scans the numeric literal starting at code[i]. It reports false
+// when none starts there. A decimal point directly followed by an element-wise
+// operator such as .* or a transpose .' is not part of the literal.
+func scanNumber(code string, i int) (numberLiteral, int, bool) {
+	var n numberLiteral
+	j := skipDigits(code, i)
+	n.integer = code[i:j]
+	if j < len(code) && code[j] == '.' && !strings.HasPrefix(code[j:], "...") {
+		if j+1 == len(code) || strings.IndexByte(`*/\^'`, code[j+1]) < 0 {
+			n.point = true
+			k := skipDigits(code, j+1)
+			n.fraction = code[j+1 : k]
+			j = k
+		}
+	}
+	if n.integer == "" && n.fraction == "" {
+		return numberLiteral{}, 0, false
+	}
+	if j < len(code) && (code[j] == 'e' || code[j] == 'E') {
+		k := j + 1
+		if k < len(code) && (code[k] == '+' || code[k] == '-') {
+			k++
+		}
+		if l := skipDigits(code, k); l > k {
+			n.exponent = code[j:l]
+			j = l
+		}
+	}
+	return n, j, true
+}
+
+// formatNumber spells n as selected by the options.
+func (f *Formatter) formatNumber(n numberLiteral) string {
+	if f.opts.TrimNumberZeros && n.point {
+		n.fraction = strings.TrimRight(n.fraction, "0")
+		if n.fraction == "" {
+			n.point = false
+			if n.integer == "" {
+				n.integer = "0"
+			}
+		}
+	}
+	if f.opts.NormalizeNumbers {
+		if n.integer == "" {
+			n.integer = "0"
+		}
+		if n.fraction == "" {
+			n.point = false
+		}
+	}
+
+	if n.exponent != "" {
+		marker, digits := n.exponent[:1], n.exponent[1:]
+		switch f.opts.ExponentCase {
+		case "lower":
+			marker = "e"
+		case "upper":
+			marker = "E"
+		}
+		if f.opts.TrimExponents {
+			sign := ""
+			if strings.HasPrefix(digits, "-") {
+				sign = "-"
+			}
+			digits = strings.TrimLeft(digits, "+-")
+			if trimmed := strings.TrimLeft(digits, "0"); trimmed != "" {
+				digits = trimmed
+			} else {
+				digits, sign = "0", ""
+			}
+			digits = sign + digits
+		}
+		n.exponent = marker + digits
+	}
+
+	s := n.integer
+	if n.point {
+		s += "." + n.fraction
+	}
+	return s + n.exponent
+}
+
+// skipDigits returns the index of the first byte of s at or after i that is
+// not a decimal digit.
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// complexSpacings lists the accepted values of Options.ComplexSpacing.
+var complexSpacings = map[string]bool{
+	"keep":   true,
+	"tight":  true,
+	"spaced": true,
+}
+
+// spaceComplex writes the complex literals of a formatted line, a real and an
+// imaginary literal joined by + or - such as 3+4i, as selected by
+// ComplexSpacing. inMatrix reports whether the line starts inside a matrix
+// opened on an earlier line. Only literals standing alone as an operand or a
+// matrix element are rewritten, and only when the operator is binary: in
+// [1 -2i] the sign belongs to the second element and stays attached.
+func (f *Formatter) spaceComplex(line string, inMatrix bool) string {
+	if f.opts.ComplexSpacing == "keep" {
+		return line
+	}
+
+	code, _ := f.dialect.ScanLine(line)
+	var stack []byte
+	if inMatrix {
+		stack = append(stack, '[')
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '(', '[', '{':
+			stack = append(stack, c)
+			continue
+		case ')', ']', '}':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		if i > 0 && (syntax.IsIdentChar(code[i-1]) || code[i-1] == '.') {
+			continue
+		}
+		inBrackets := len(stack) > 0 && stack[len(stack)-1] != '('
+		opStart, imag, end, ok := complexLiteral(code, i, inBrackets)
+		if !ok {
+			continue
+		}
+		op := code[opStart : opStart+1]
+		if f.opts.ComplexSpacing == "spaced" {
+			op = " " + op + " "
+		}
+		b.WriteString(line[last:i])
+		b.WriteString(strings.TrimRight(line[i:opStart], " \t"))
+		b.WriteString(op)
+		b.WriteString(line[imag:end])
+		last = end
+		i = end - 1
+	}
+	if last == 0 {
+		return line
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// complexLiteral reports whether a complex literal such as 3 + 4i starts with
+// the real literal at code[i], returning the index of its operator and the
+// start and end of its imaginary literal. The operator must be spaced on both
+// sides or on neither, and the literal must stand alone: preceded by the
+// start of the code, an opening bracket, a separator, an assignment or a
+// comparison, or by whitespace separating the elements of a matrix when
+// inBrackets; and followed by the end of the code, a closing bracket, a
+// separator or whitespace separating elements.
+func complexLiteral(code string, i int, inBrackets bool) (opStart, imag, end int, ok bool) {
+	_, realEnd, ok := scanNumber(code, i)
+	if !ok || realEnd < len(code) && syntax.IsIdentChar(code[realEnd]) {
+		return 0, 0, 0, false
+	}
+	opStart = skipSpace(code, realEnd)
+	if opStart >= len(code) || code[opStart] != '+' && code[opStart] != '-' {
+		return 0, 0, 0, false
+	}
+	imag = skipSpace(code, opStart+1)
+	if (opStart == realEnd) != (imag == opStart+1) {
+		return 0, 0, 0, false
+	}
+	_, end, ok = scanNumber(code, imag)
+	if !ok || end >= len(code) || code[end] != 'i' && code[end] != 'j' {
+		return 0, 0, 0, false
+	}
+	end++
+	if end < len(code) && (syntax.IsIdentChar(code[end]) || code[end] == '.') {
+		return 0, 0, 0, false
+	}
+
+	// A unary sign belongs to the real literal.
+	start := i
+	if start > 0 && (code[start-1] == '+' || code[start-1] == '-') {
+		start--
+	}
+	before := strings.TrimRight(code[:start], " \t")
+	switch {
+	case before == "" || strings.IndexByte("([{,;=<>", before[len(before)-1]) >= 0:
+	case inBrackets && len(before) < start && elementBreak(before, code, start):
+	default:
+		return 0, 0, 0, false
+	}
+	next := skipSpace(code, end)
+	switch {
+	case next == len(code) || strings.IndexByte(")]},;", code[next]) >= 0:
+	case inBrackets && next > end && elementBreak(code[:end], code, next):
+	default:
+		return 0, 0, 0, false
+	}
+	return opStart, imag, end, true
+}
@@ -0,0 +1,407 @@
+package formatter
+
+import "strings"
+
+// Option configures a Formatter built by New. Options is an Option too that
+// replaces every setting, so that New(opts) starts from opts instead of
+// DefaultOptions; the functions named With* set one setting each.
+type Option interface {
+	apply(*Options)
+}
+
+type optionFunc func(*Options)
+
+func (f optionFunc) apply(o *Options) { f(o) }
+
+func (o Options) apply(dst *Options) { *dst = o }
+
+// CallOption configures a single call formatting lines, such as FormatLines,
+// without changing the Formatter, so that goroutines sharing a Formatter can
+// each pass their own.
+type CallOption func(*call)
+
+// call holds the settings of a call formatting lines.
+type call struct {
+	// pkg and packages are set by InPackage.
+	pkg      string
+	packages map[string]bool
+}
+
+func newCall(opts []CallOption) call {
+	var c call
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// IndentMode selects which function bodies are indented, as
+// Options.IndentMode.
+type IndentMode string
+
+const (
+	IndentAllFunctions        IndentMode = "all_functions"
+	IndentOnlyNestedFunctions IndentMode = "only_nested_functions"
+	IndentClassic             IndentMode = "classic"
+)
+
+// OperatorSpacing selects the spaces around binary operators, as
+// Options.AddSpaces.
+type OperatorSpacing string
+
+const (
+	SpaceAllOperators OperatorSpacing = "all_operators"
+	SpaceExcludePow   OperatorSpacing = "exclude_pow"
+	SpaceNoOperators  OperatorSpacing = "no_spaces"
+)
+
+// MatrixIndent selects the indentation of the continuation rows of
+// multi-line matrices and cell arrays, as Options.MatrixIndent.
+type MatrixIndent string
+
+const (
+	MatrixIndentAligned  MatrixIndent = "aligned"
+	MatrixIndentSimple   MatrixIndent = "simple"
+	MatrixIndentPreserve MatrixIndent = "preserve"
+)
+
+// ClassdefIndent selects which levels a classdef adds, as
+// Options.ClassdefIndent.
+type ClassdefIndent string
+
+const (
+	ClassdefIndentAll      ClassdefIndent = "all"
+	ClassdefIndentBlocks   ClassdefIndent = "blocks"
+	ClassdefIndentClassdef ClassdefIndent = "classdef"
+)
+
+// IndentStyle selects the characters of indentation, as Options.IndentStyle.
+type IndentStyle string
+
+const (
+	IndentSpaces IndentStyle = "space"
+	IndentTabs   IndentStyle = "tab"
+)
+
+// MatrixSeparator selects the separator between the elements of a row of a
+// matrix, as Options.MatrixSeparator.
+type MatrixSeparator string
+
+const (
+	MatrixSeparatorComma MatrixSeparator = "comma"
+	MatrixSeparatorSpace MatrixSeparator = "space"
+	MatrixSeparatorKeep  MatrixSeparator = "keep"
+)
+
+// ExponentCase selects the exponent marker of numeric literals, as
+// Options.ExponentCase.
+type ExponentCase string
+
+const (
+	ExponentLower ExponentCase = "lower"
+	ExponentUpper ExponentCase = "upper"
+	ExponentKeep  ExponentCase = "keep"
+)
+
+// ComplexSpacing selects the spacing around the operator of complex
+// literals, as Options.ComplexSpacing.
+type ComplexSpacing string
+
+const (
+	ComplexTight  ComplexSpacing = "tight"
+	ComplexSpaced ComplexSpacing = "spaced"
+	ComplexKeep   ComplexSpacing = "keep"
+)
+
+// ContinuationStyle selects the indentation of continuation lines, as
+// Options.ContinuationStyle.
+type ContinuationStyle string
+
+const (
+	ContinuationIndent  ContinuationStyle = "indent"
+	ContinuationAligned ContinuationStyle = "aligned"
+)
+
+// ColonSpacing selects the spacing around the colons of ranges, as
+// Options.ColonSpacing.
+type ColonSpacing string
+
+const (
+	ColonTight  ColonSpacing = "tight"
+	ColonSpaced ColonSpacing = "spaced"
+	ColonKeep   ColonSpacing = "keep"
+)
+
+// UnarySigns selects where whitespace makes a + or - a sign, as
+// Options.UnarySigns.
+type UnarySigns string
+
+const (
+	UnarySignsContext    UnarySigns = "context"
+	UnarySignsWhitespace UnarySigns = "whitespace"
+)
+
+// LineEnding selects the line ending written by JoinLines, as
+// Options.LineEnding.
+type LineEnding string
+
+const (
+	LineEndingLF   LineEnding = "lf"
+	LineEndingCRLF LineEnding = "crlf"
+	LineEndingCR   LineEnding = "cr"
+	LineEndingAuto LineEnding = "auto"
+)
+
+// Dialect selects the language of the code, as Options.Dialect.
+type Dialect string
+
+const (
+	DialectAuto   Dialect = "auto"
+	DialectMATLAB Dialect = "matlab"
+	DialectOctave Dialect = "octave"
+)
+
+// OnlyMode restricts formatting to one kind of change, as Options.Only.
+type OnlyMode string
+
+const (
+	OnlyAll     OnlyMode = ""
+	OnlyIndent  OnlyMode = "indent"
+	OnlySpacing OnlyMode = "spacing"
+)
+
+// WithLines selects the lines start through end, as Options.StartLine and
+// Options.EndLine.
+func WithLines(start, end int) Option {
+	return optionFunc(func(o *Options) { o.StartLine, o.EndLine = start, end })
+}
+
+// WithIndentWidth sets Options.IndentWidth.
+func WithIndentWidth(n int) Option {
+	return optionFunc(func(o *Options) { o.IndentWidth = n })
+}
+
+// WithNestedIndentWidth sets Options.NestedIndentWidth.
+func WithNestedIndentWidth(n int) Option {
+	return optionFunc(func(o *Options) { o.NestedIndentWidth = n })
+}
+
+// WithTabWidth sets Options.TabWidth.
+func WithTabWidth(n int) Option {
+	return optionFunc(func(o *Options) { o.TabWidth = n })
+}
+
+// WithDetectIndentation sets Options.DetectIndentation.
+func WithDetectIndentation(on bool) Option {
+	return optionFunc(func(o *Options) { o.DetectIndentation = on })
+}
+
+// WithIndentMode sets Options.IndentMode.
+func WithIndentMode(m IndentMode) Option {
+	return optionFunc(func(o *Options) { o.IndentMode = string(m) })
+}
+
+// WithOperatorSpacing sets Options.AddSpaces.
+func WithOperatorSpacing(s OperatorSpacing) Option {
+	return optionFunc(func(o *Options) { o.AddSpaces = string(s) })
+}
+
+// WithSpacedOperators sets Options.SpacedOperators to the given classes of
+// binary operators, or to "none" when there are none.
+func WithSpacedOperators(classes ...string) Option {
+	if len(classes) == 0 {
+		classes = []string{"none"}
+	}
+	return optionFunc(func(o *Options) { o.SpacedOperators = strings.Join(classes, ",") })
+}
+
+// WithSpaceShortCircuit sets Options.SpaceShortCircuit.
+func WithSpaceShortCircuit(on bool) Option {
+	return optionFunc(func(o *Options) { o.SpaceShortCircuit = on })
+}
+
+// WithColonSpacing sets Options.ColonSpacing.
+func WithColonSpacing(c ColonSpacing) Option {
+	return optionFunc(func(o *Options) { o.ColonSpacing = string(c) })
+}
+
+// WithNormalizeEnds sets Options.NormalizeEnds.
+func WithNormalizeEnds(on bool) Option {
+	return optionFunc(func(o *Options) { o.NormalizeEnds = on })
+}
+
+// WithUnarySigns sets Options.UnarySigns.
+func WithUnarySigns(u UnarySigns) Option {
+	return optionFunc(func(o *Options) { o.UnarySigns = string(u) })
+}
+
+// WithSpaceAfterComma sets Options.SpaceAfterComma.
+func WithSpaceAfterComma(on bool) Option {
+	return optionFunc(func(o *Options) { o.SpaceAfterComma = on })
+}
+
+// WithBracketSpacing sets Options.SpaceInParens, Options.SpaceInBrackets
+// and Options.SpaceInBraces.
+func WithBracketSpacing(parens, brackets, braces bool) Option {
+	return optionFunc(func(o *Options) { o.SpaceInParens, o.SpaceInBrackets, o.SpaceInBraces = parens, brackets, braces })
+}
+
+// WithMatrixIndent sets Options.MatrixIndent.
+func WithMatrixIndent(m MatrixIndent) Option {
+	return optionFunc(func(o *Options) { o.MatrixIndent = string(m) })
+}
+
+// WithClassdefIndent sets Options.ClassdefIndent.
+func WithClassdefIndent(c ClassdefIndent) Option {
+	return optionFunc(func(o *Options) { o.ClassdefIndent = string(c) })
+}
+
+// WithIndentStyle sets Options.IndentStyle.
+func WithIndentStyle(s IndentStyle) Option {
+	return optionFunc(func(o *Options) { o.IndentStyle = string(s) })
+}
+
+// WithMatrixSeparator sets Options.MatrixSeparator.
+func WithMatrixSeparator(s MatrixSeparator) Option {
+	return optionFunc(func(o *Options) { o.MatrixSeparator = string(s) })
+}
+
+// WithExponentCase sets Options.ExponentCase.
+func WithExponentCase(c ExponentCase) Option {
+	return optionFunc(func(o *Options) { o.ExponentCase = string(c) })
+}
+
+// WithComplexSpacing sets Options.ComplexSpacing.
+func WithComplexSpacing(s ComplexSpacing) Option {
+	return optionFunc(func(o *Options) { o.ComplexSpacing = string(s) })
+}
+
+// WithContinuationIndent sets Options.ContinuationIndent.
+func WithContinuationIndent(n int) Option {
+	return optionFunc(func(o *Options) { o.ContinuationIndent = n })
+}
+
+// WithContinuationStyle sets Options.ContinuationStyle.
+func WithContinuationStyle(c ContinuationStyle) Option {
+	return optionFunc(func(o *Options) { o.ContinuationStyle = string(c) })
+}
+
+// WithLineEnding sets Options.LineEnding.
+func WithLineEnding(e LineEnding) Option {
+	return optionFunc(func(o *Options) { o.LineEnding = string(e) })
+}
+
+// WithOnly sets Options.Only.
+func WithOnly(m OnlyMode) Option {
+	return optionFunc(func(o *Options) { o.Only = string(m) })
+}
+
+// WithSeparateBlocks sets Options.SeparateBlocks.
+func WithSeparateBlocks(on bool) Option {
+	return optionFunc(func(o *Options) { o.SeparateBlocks = on })
+}
+
+// WithFunctionBlankLines sets Options.FunctionBlankLines and
+// Options.NestedFunctionBlankLines.
+func WithFunctionBlankLines(n, nested int) Option {
+	return optionFunc(func(o *Options) { o.FunctionBlankLines, o.NestedFunctionBlankLines = n, nested })
+}
+
+// WithSeparatedBlocks sets Options.SeparateBlocks and
+// Options.SeparatedBlocks to separate the given kinds of blocks, or none.
+func WithSeparatedBlocks(kinds ...string) Option {
+	return optionFunc(func(o *Options) {
+		o.SeparateBlocks, o.SeparatedBlocks = len(kinds) > 0, strings.Join(kinds, ",")
+	})
+}
+
+// WithMaxBlankLines sets Options.MaxBlankLines.
+func WithMaxBlankLines(n int) Option {
+	return optionFunc(func(o *Options) { o.MaxBlankLines = n })
+}
+
+// WithTrimMatrixSeparators sets Options.TrimMatrixSeparators.
+func WithTrimMatrixSeparators(on bool) Option {
+	return optionFunc(func(o *Options) { o.TrimMatrixSeparators = on })
+}
+
+// WithSortImports sets Options.SortImports.
+func WithSortImports(on bool) Option {
+	return optionFunc(func(o *Options) { o.SortImports = on })
+}
+
+// WithGroupImports sets Options.GroupImports.
+func WithGroupImports(on bool) Option {
+	return optionFunc(func(o *Options) { o.GroupImports = on })
+}
+
+// WithQualifyImports sets Options.QualifyImports.
+func WithQualifyImports(on bool) Option {
+	return optionFunc(func(o *Options) { o.QualifyImports = on })
+}
+
+// WithTestConventions sets Options.TestFixturesFirst and
+// Options.TestFunctionSpacing.
+func WithTestConventions(fixturesFirst, functionSpacing bool) Option {
+	return optionFunc(func(o *Options) { o.TestFixturesFirst, o.TestFunctionSpacing = fixturesFirst, functionSpacing })
+}
+
+// WithNormalizeNumbers sets Options.NormalizeNumbers.
+func WithNormalizeNumbers(on bool) Option {
+	return optionFunc(func(o *Options) { o.NormalizeNumbers = on })
+}
+
+// WithTrimNumberZeros sets Options.TrimNumberZeros.
+func WithTrimNumberZeros(on bool) Option {
+	return optionFunc(func(o *Options) { o.TrimNumberZeros = on })
+}
+
+// WithTrimExponents sets Options.TrimExponents.
+func WithTrimExponents(on bool) Option {
+	return optionFunc(func(o *Options) { o.TrimExponents = on })
+}
+
+// WithKeepTrailingLines sets Options.KeepTrailingLines.
+func WithKeepTrailingLines(on bool) Option {
+	return optionFunc(func(o *Options) { o.KeepTrailingLines = on })
+}
+
+// WithCommentColumn sets Options.CommentColumn.
+func WithCommentColumn(n int) Option {
+	return optionFunc(func(o *Options) { o.CommentColumn = n })
+}
+
+// WithAlignMatrixColumns sets Options.AlignMatrixColumns.
+func WithAlignMatrixColumns(on bool) Option {
+	return optionFunc(func(o *Options) { o.AlignMatrixColumns = on })
+}
+
+// WithAlignAssignments sets Options.AlignAssignments.
+func WithAlignAssignments(on bool) Option {
+	return optionFunc(func(o *Options) { o.AlignAssignments = on })
+}
+
+// WithMaxLineLength sets Options.MaxLineLength.
+func WithMaxLineLength(n int) Option {
+	return optionFunc(func(o *Options) { o.MaxLineLength = n })
+}
+
+// WithJoinContinuations sets Options.JoinContinuations.
+func WithJoinContinuations(on bool) Option {
+	return optionFunc(func(o *Options) { o.JoinContinuations = on })
+}
+
+// WithDialect sets Options.Dialect.
+func WithDialect(d Dialect) Option {
+	return optionFunc(func(o *Options) { o.Dialect = string(d) })
+}
+
+// WithStrictBlocks sets Options.StrictBlocks.
+func WithStrictBlocks(on bool) Option {
+	return optionFunc(func(o *Options) { o.StrictBlocks = on })
+}
+
+// WithFinalNewline sets Options.FinalNewline.
+func WithFinalNewline(on bool) Option {
+	return optionFunc(func(o *Options) { o.FinalNewline = on })
+}
@@ -0,0 +1,64 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	f, err := New(
+		WithIndentWidth(2),
+		WithIndentMode(IndentClassic),
+		WithOperatorSpacing(SpaceAllOperators),
+		WithLineEnding(LineEndingCRLF),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	want := DefaultOptions()
+	want.IndentWidth = 2
+	want.IndentMode = "classic"
+	want.AddSpaces = "all_operators"
+	want.LineEnding = "crlf"
+	if !reflect.DeepEqual(f.opts, want) {
+		t.Errorf("options:\n got %+v\nwant %+v", f.opts, want)
+	}
+
+	// Options replaces every setting, and later options win.
+	opts := DefaultOptions()
+	opts.IndentWidth = 3
+	if f, err = New(WithIndentWidth(2), opts, WithTabWidth(8)); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if f.opts.IndentWidth != 3 || f.opts.TabWidth != 8 {
+		t.Errorf("got IndentWidth %d and TabWidth %d, want 3 and 8", f.opts.IndentWidth, f.opts.TabWidth)
+	}
+
+	if _, err := New(WithIndentWidth(0)); err == nil {
+		t.Error("New accepted an indent width of 0")
+	}
+}
+
+func TestOptionConstantsAreValid(t *testing.T) {
+	opts := []Option{
+		WithIndentMode(IndentAllFunctions), WithIndentMode(IndentOnlyNestedFunctions), WithIndentMode(IndentClassic),
+		WithOperatorSpacing(SpaceAllOperators), WithOperatorSpacing(SpaceExcludePow), WithOperatorSpacing(SpaceNoOperators),
+		WithMatrixIndent(MatrixIndentAligned), WithMatrixIndent(MatrixIndentSimple), WithMatrixIndent(MatrixIndentPreserve),
+		WithClassdefIndent(ClassdefIndentAll), WithClassdefIndent(ClassdefIndentBlocks), WithClassdefIndent(ClassdefIndentClassdef),
+		WithIndentStyle(IndentSpaces), WithIndentStyle(IndentTabs),
+		WithMatrixSeparator(MatrixSeparatorComma), WithMatrixSeparator(MatrixSeparatorSpace), WithMatrixSeparator(MatrixSeparatorKeep),
+		WithExponentCase(ExponentLower), WithExponentCase(ExponentUpper), WithExponentCase(ExponentKeep),
+		WithComplexSpacing(ComplexTight), WithComplexSpacing(ComplexSpaced), WithComplexSpacing(ComplexKeep),
+		WithColonSpacing(ColonTight), WithColonSpacing(ColonSpaced), WithColonSpacing(ColonKeep),
+		WithUnarySigns(UnarySignsContext), WithUnarySigns(UnarySignsWhitespace),
+		WithContinuationStyle(ContinuationIndent), WithContinuationStyle(ContinuationAligned),
+		WithLineEnding(LineEndingLF), WithLineEnding(LineEndingCRLF), WithLineEnding(LineEndingCR), WithLineEnding(LineEndingAuto),
+		WithDialect(DialectAuto), WithDialect(DialectMATLAB), WithDialect(DialectOctave),
+		WithOnly(OnlyAll), WithOnly(OnlyIndent), WithOnly(OnlySpacing),
+	}
+	for _, opt := range opts {
+		if _, err := NewStrict(opt); err != nil {
+			t.Errorf("NewStrict: %v", err)
+		}
+	}
+}
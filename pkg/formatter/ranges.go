@@ -0,0 +1,156 @@
+package formatter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is a range of lines written as START:END. Lines are 1-based and
+// both ends are inclusive. A negative START or END counts from the end of the
+// file, -1 being the last line. END may be +N for N lines starting at START,
+// and either end may be omitted for the start or end of the file. A single
+// number selects one line.
+type LineRange struct {
+	start, end int
+	// relative marks an END of the form +N, stored in end.
+	relative bool
+}
+
+// ParseLineRange parses a line range such as "120:+40" or "-50:".
+func ParseLineRange(s string) (LineRange, error) {
+	startText, endText, found := strings.Cut(s, ":")
+	if !found {
+		endText = startText
+	}
+
+	var r LineRange
+	if startText != "" {
+		v, err := parseLineNumber(startText)
+		if err != nil {
+			return LineRange{}, fmt.Errorf("invalid line range %q: %v", s, err)
+		}
+		r.start = v
+	}
+
+	switch {
+	case strings.HasPrefix(endText, "+"):
+		v, err := strconv.Atoi(endText[1:])
+		if err != nil || v < 1 {
+			return LineRange{}, fmt.Errorf("invalid line range %q: line count must be a positive integer", s)
+		}
+		if startText == "" {
+			return LineRange{}, fmt.Errorf("invalid line range %q: a line count requires a start line", s)
+		}
+		r.end, r.relative = v, true
+	case endText != "":
+		v, err := parseLineNumber(endText)
+		if err != nil {
+			return LineRange{}, fmt.Errorf("invalid line range %q: %v", s, err)
+		}
+		r.end = v
+	}
+	return r, nil
+}
+
+func parseLineNumber(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("%q is not a line number", s)
+	}
+	return v, nil
+}
+
+// Resolve returns the first and last line of the range in a file of n lines,
+// clamped to the file. The range is empty when last < first.
+func (r LineRange) Resolve(n int) (first, last int) {
+	first, last = 1, n
+	switch {
+	case r.start > 0:
+		first = r.start
+	case r.start < 0:
+		first = n + 1 + r.start
+	}
+	switch {
+	case r.relative:
+		last = first + r.end - 1
+	case r.end > 0:
+		last = r.end
+	case r.end < 0:
+		last = n + 1 + r.end
+	}
+	return max(first, 1), min(last, n)
+}
+
+// String returns the range in the form accepted by ParseLineRange.
+func (r LineRange) String() string {
+	var b strings.Builder
+	if r.start != 0 {
+		b.WriteString(strconv.Itoa(r.start))
+	}
+	b.WriteByte(':')
+	if r.relative {
+		b.WriteByte('+')
+	}
+	if r.end != 0 {
+		b.WriteString(strconv.Itoa(r.end))
+	}
+	return b.String()
+}
+
+// NormalizeRanges resolves ranges in a file of n lines and returns the
+// non-empty ones as [first, last] pairs, sorted and with overlapping or
+// adjacent ranges merged.
+func NormalizeRanges(ranges []LineRange, n int) [][2]int {
+	var resolved [][2]int
+	for _, r := range ranges {
+		if first, last := r.Resolve(n); first <= last {
+			resolved = append(resolved, [2]int{first, last})
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i][0] < resolved[j][0] })
+
+	var merged [][2]int
+	for _, r := range resolved {
+		if k := len(merged) - 1; k >= 0 && r[0] <= merged[k][1]+1 {
+			merged[k][1] = max(merged[k][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// FormatRanges formats each of the ranges of lines, ignoring the StartLine and
+// EndLine options. Ranges are formatted from the last to the first so inserted
+// or removed lines do not shift the ranges still to be formatted.
+func (f *Formatter) FormatRanges(lines []string, ranges []LineRange, opts ...CallOption) ([]string, error) {
+	return f.FormatRangesContext(context.Background(), lines, ranges, opts...)
+}
+
+// FormatRangesContext is FormatRanges stopping with the error of ctx when ctx
+// is cancelled or its deadline passes.
+func (f *Formatter) FormatRangesContext(ctx context.Context, lines []string, ranges []LineRange, opts ...CallOption) ([]string, error) {
+	return f.newSession(ctx, lines, opts).formatRanges(lines, ranges)
+}
+
+// formatRanges formats each of the ranges of lines, from the last to the
+// first.
+func (s *session) formatRanges(lines []string, ranges []LineRange) ([]string, error) {
+	result := append([]string{}, lines...)
+	normalized := NormalizeRanges(ranges, len(lines))
+	for i := len(normalized) - 1; i >= 0; i-- {
+		var err error
+		if result, err = s.formatRange(result, normalized[i][0], normalized[i][1]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// NewLineRange returns the range of lines first through last.
+func NewLineRange(first, last int) LineRange {
+	return LineRange{start: first, end: last}
+}
@@ -0,0 +1,76 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		spec        string
+		first, last int
+	}{
+		{"3:5", 3, 5},
+		{"120:+40", 120, 159},
+		{"-50:", 151, 200},
+		{"-10:-2", 191, 199},
+		{":4", 1, 4},
+		{"7", 7, 7},
+		{"190:+40", 190, 200},
+	}
+	for _, tt := range tests {
+		r, err := ParseLineRange(tt.spec)
+		if err != nil {
+			t.Errorf("ParseLineRange(%q): %v", tt.spec, err)
+			continue
+		}
+		if first, last := r.Resolve(200); first != tt.first || last != tt.last {
+			t.Errorf("%q resolved to %d:%d, want %d:%d", tt.spec, first, last, tt.first, tt.last)
+		}
+		if again, err := ParseLineRange(r.String()); err != nil || again != r {
+			t.Errorf("%q does not round-trip through %q", tt.spec, r.String())
+		}
+	}
+
+	for _, spec := range []string{"0:3", "a:b", "3:+0", ":+4", "1:2:3"} {
+		if _, err := ParseLineRange(spec); err == nil {
+			t.Errorf("ParseLineRange(%q): expected error", spec)
+		}
+	}
+}
+
+func TestNormalizeRanges(t *testing.T) {
+	var ranges []LineRange
+	for _, spec := range []string{"8:9", "1:3", "3:5", "6", "20:30"} {
+		r, err := ParseLineRange(spec)
+		if err != nil {
+			t.Fatalf("ParseLineRange(%q): %v", spec, err)
+		}
+		ranges = append(ranges, r)
+	}
+	want := [][2]int{{1, 6}, {8, 9}}
+	if got := NormalizeRanges(ranges, 12); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NormalizeRanges = %v, want %v", got, want)
+	}
+}
+
+func TestFormatRanges(t *testing.T) {
+	lines := []string{"if a", "x=1;", "end", "if b", "y=2;", "end", "if c", "z=3;", "end"}
+	opts := DefaultOptions()
+	opts.SeparateBlocks = false
+	f, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	first, _ := ParseLineRange("1:+3")
+	last, _ := ParseLineRange("-3:")
+	got, err := f.FormatRanges(lines, []LineRange{last, first})
+	if err != nil {
+		t.Fatalf("FormatRanges: %v", err)
+	}
+	want := []string{"if a", "    x = 1;", "end", "if b", "y=2;", "end", "if c", "    z = 3;", "end"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+}
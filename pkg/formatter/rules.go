@@ -0,0 +1,178 @@
+package formatter
+
+import "sort"
+
+// Rule describes a formatting rule for listings such as the rules
+// subcommand. Options name the Options fields, by their command-line flag,
+// that configure the rule.
+type Rule struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description"`
+	Options     []RuleOption `json:"options"`
+}
+
+// RuleOption describes a setting of a rule. Values lists the accepted values
+// of enumerated options.
+type RuleOption struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Default any      `json:"default"`
+	Values  []string `json:"values,omitempty"`
+}
+
+// Rules returns the formatting rules applied by the formatter.
+func Rules() []Rule {
+	d := DefaultOptions()
+	return []Rule{
+		{
+			ID:          "indent",
+			Description: "Reindent lines according to the block structure",
+			Options: []RuleOption{
+				{Name: "indentWidth", Type: "int", Default: d.IndentWidth},
+				{Name: "indentMode", Type: "string", Default: d.IndentMode, Values: sortedKeys(indentModes)},
+				{Name: "nestedIndentWidth", Type: "int", Default: d.NestedIndentWidth},
+				{Name: "classdefIndent", Type: "string", Default: d.ClassdefIndent, Values: sortedKeys(classdefIndents)},
+				{Name: "tabWidth", Type: "int", Default: d.TabWidth},
+				{Name: "indentStyle", Type: "string", Default: d.IndentStyle, Values: sortedKeys(indentStyles)},
+				{Name: "detectIndentation", Type: "bool", Default: d.DetectIndentation},
+				{Name: "dialect", Type: "string", Default: d.Dialect, Values: sortedKeys(dialects)},
+				{Name: "strictBlocks", Type: "bool", Default: d.StrictBlocks},
+			},
+		},
+		{
+			ID:          "line-endings",
+			Description: "End lines with one line ending and the file with a final newline",
+			Options: []RuleOption{
+				{Name: "lineEnding", Type: "string", Default: d.LineEnding, Values: sortedKeys(lineEndings)},
+				{Name: "finalNewline", Type: "bool", Default: d.FinalNewline},
+				{Name: "keepTrailingLines", Type: "bool", Default: d.KeepTrailingLines},
+			},
+		},
+		{
+			ID:          "operator-spacing",
+			Description: "Normalize spaces around operators, commas and brackets",
+			Options: []RuleOption{
+				{Name: "addSpaces", Type: "string", Default: d.AddSpaces, Values: sortedKeys(operatorSpaces)},
+				{Name: "spacedOperators", Type: "string", Default: d.SpacedOperators, Values: append(sortedKeys(operatorClasses), "none")},
+				{Name: "spaceShortCircuit", Type: "bool", Default: d.SpaceShortCircuit},
+				{Name: "colonSpacing", Type: "string", Default: d.ColonSpacing, Values: sortedKeys(colonSpacings)},
+				{Name: "unarySigns", Type: "string", Default: d.UnarySigns, Values: sortedKeys(unarySigns)},
+				{Name: "spaceAfterComma", Type: "bool", Default: d.SpaceAfterComma},
+				{Name: "spaceInParens", Type: "bool", Default: d.SpaceInParens},
+				{Name: "spaceInBrackets", Type: "bool", Default: d.SpaceInBrackets},
+				{Name: "spaceInBraces", Type: "bool", Default: d.SpaceInBraces},
+			},
+		},
+		{
+			ID:          "declaration-spacing",
+			Description: "Separate the size, class, validators and default of property and argument declarations by one space",
+		},
+		{
+			ID:          "assignment-alignment",
+			Description: "Align the = of consecutive assignments",
+			Options: []RuleOption{
+				{Name: "alignAssignments", Type: "bool", Default: d.AlignAssignments},
+			},
+		},
+		{
+			ID:          "comment-column",
+			Description: "Start trailing comments in a fixed column",
+			Options: []RuleOption{
+				{Name: "commentColumn", Type: "int", Default: d.CommentColumn},
+			},
+		},
+		{
+			ID:          "block-separation",
+			Description: "Separate blocks with blank lines and collapse repeated blank lines",
+			Options: []RuleOption{
+				{Name: "separateBlocks", Type: "bool", Default: d.SeparateBlocks},
+				{Name: "separatedBlocks", Type: "string", Default: d.SeparatedBlocks, Values: blockKindNames()},
+				{Name: "maxBlankLines", Type: "int", Default: d.MaxBlankLines},
+				{Name: "functionBlankLines", Type: "int", Default: d.FunctionBlankLines},
+				{Name: "nestedFunctionBlankLines", Type: "int", Default: d.NestedFunctionBlankLines},
+			},
+		},
+		{
+			ID:          "matrix-indent",
+			Description: "Indent continuation rows of multi-line matrices and cell arrays",
+			Options: []RuleOption{
+				{Name: "matrixIndent", Type: "string", Default: d.MatrixIndent, Values: sortedKeys(matrixIndentation)},
+				{Name: "alignMatrixColumns", Type: "bool", Default: d.AlignMatrixColumns},
+			},
+		},
+		{
+			ID:          "matrix-separators",
+			Description: "Normalize element and row separators inside matrix literals",
+			Options: []RuleOption{
+				{Name: "matrixSeparator", Type: "string", Default: d.MatrixSeparator, Values: sortedKeys(matrixSeparators)},
+				{Name: "trimMatrixSeparators", Type: "bool", Default: d.TrimMatrixSeparators},
+			},
+		},
+		{
+			ID:          "end-keywords",
+			Description: "Write the keywords closing blocks as end without a trailing semicolon",
+			Options: []RuleOption{
+				{Name: "normalizeEnds", Type: "bool", Default: d.NormalizeEnds},
+			},
+		},
+		{
+			ID:          "number-format",
+			Description: "Normalize the spelling of numeric literals outside strings and comments",
+			Options: []RuleOption{
+				{Name: "normalizeNumbers", Type: "bool", Default: d.NormalizeNumbers},
+				{Name: "trimNumberZeros", Type: "bool", Default: d.TrimNumberZeros},
+				{Name: "exponentCase", Type: "string", Default: d.ExponentCase, Values: sortedKeys(exponentCases)},
+				{Name: "trimExponents", Type: "bool", Default: d.TrimExponents},
+			},
+		},
+		{
+			ID:          "complex-spacing",
+			Description: "Keep the operator of complex literals such as 3+4i tight or spaced, never detaching the sign of a matrix element",
+			Options: []RuleOption{
+				{Name: "complexSpacing", Type: "string", Default: d.ComplexSpacing, Values: sortedKeys(complexSpacings)},
+			},
+		},
+		{
+			ID:          "continuation-indent",
+			Description: "Indent the lines continuing a statement after ... by one level or align them with the open bracket",
+			Options: []RuleOption{
+				{Name: "continuationIndent", Type: "int", Default: d.ContinuationIndent},
+				{Name: "continuationStyle", Type: "string", Default: d.ContinuationStyle, Values: sortedKeys(continuationStyles)},
+			},
+		},
+		{
+			ID:          "join-continuations",
+			Description: "Join the lines of statements continued with ... that fit within the maximum line length",
+			Options: []RuleOption{
+				{Name: "maxLineLength", Type: "int", Default: d.MaxLineLength},
+				{Name: "joinContinuations", Type: "bool", Default: d.JoinContinuations},
+			},
+		},
+		{
+			ID:          "test-conventions",
+			Description: "Place shared fixtures first and separate the local functions of function-based test files by one blank line",
+			Options: []RuleOption{
+				{Name: "testFixturesFirst", Type: "bool", Default: d.TestFixturesFirst},
+				{Name: "testFunctionSpacing", Type: "bool", Default: d.TestFunctionSpacing},
+			},
+		},
+		{
+			ID:          "sort-imports",
+			Description: "Sort and deduplicate import statements at the top of the file, a function or a classdef",
+			Options: []RuleOption{
+				{Name: "sortImports", Type: "bool", Default: d.SortImports},
+				{Name: "groupImports", Type: "bool", Default: d.GroupImports},
+				{Name: "qualifyImports", Type: "bool", Default: d.QualifyImports},
+			},
+		},
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,285 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// spaceTokens writes the tokens of a line without its leading and trailing
+// whitespace, replacing the whitespace between the tokens as the spacing
+// rules require. Strings and comments are written as they are.
+func (s *session) spaceTokens(toks []syntax.Token) string {
+	var code []syntax.Token
+	// gaps holds the whitespace preceding each token of code.
+	var gaps []string
+	gap := ""
+	for _, t := range toks {
+		if t.Kind == syntax.TokenSpace {
+			gap = t.Text
+			continue
+		}
+		code = append(code, t)
+		gaps = append(gaps, gap)
+		gap = ""
+	}
+	space := make([]bool, len(code))
+	for i, g := range gaps {
+		space[i] = g != ""
+	}
+
+	// brackets holds the brackets open at each token.
+	var brackets []string
+	if s.literal != "" {
+		brackets = append(brackets, s.literal)
+	}
+	unary := make([]bool, len(code))
+	for i, t := range code {
+		literal := len(brackets) > 0 && brackets[len(brackets)-1] != "("
+		unary[i] = isUnary(code, space, i, literal || s.opts.UnarySigns == "whitespace")
+		s.fireToken(code, unary, i, t)
+		switch {
+		case t.IsOpen():
+			brackets = append(brackets, t.Text)
+		case t.IsClose() && len(brackets) > 0:
+			brackets = brackets[:len(brackets)-1]
+		}
+	}
+
+	var b strings.Builder
+	for i, t := range code {
+		if i > 0 {
+			sep := s.separator(code, unary, space, i)
+			if sep == " " && gaps[i] != "" && gaps[i] != " " {
+				s.fire("multiWS")
+			}
+			b.WriteString(sep)
+		}
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+// isUnary reports whether the +, -, ~ or ! at code[i] is a unary operator:
+// when it starts the code or follows an operator, an opening bracket, a
+// separator or a keyword, and, with spaced, as elements of matrices such as
+// [1 -2] are written, when whitespace precedes but does not follow it.
+func isUnary(code []syntax.Token, space []bool, i int, spaced bool) bool {
+	t := code[i]
+	if t.Kind != syntax.TokenOperator {
+		return false
+	}
+	switch t.Text {
+	case "~", "!":
+		return true
+	case "+", "-":
+	default:
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch prev := code[i-1]; {
+	case prev.Kind == syntax.TokenOperator:
+		return !prev.IsTranspose() && prev.Text != "++" && prev.Text != "--"
+	case prev.Kind == syntax.TokenKeyword:
+		return true
+	case prev.Kind == syntax.TokenPunctuation && !prev.IsClose():
+		return true
+	}
+	if !spaced || !space[i] || i+1 == len(code) || space[i+1] {
+		return false
+	}
+	next := code[i+1]
+	return next.Kind == syntax.TokenIdentifier || next.Kind == syntax.TokenNumber || next.IsOpen()
+}
+
+// separator returns the whitespace written between code[i-1] and code[i].
+func (s *session) separator(code []syntax.Token, unary, space []bool, i int) string {
+	prev, t := code[i-1], code[i]
+	kept := ""
+	if space[i] {
+		kept = " "
+	}
+	switch {
+	case t.Kind == syntax.TokenComment || t.Kind == syntax.TokenContinuation:
+		return " "
+	case prev.IsOpen():
+		if t.IsClose() || !s.padded(prev.Text) {
+			return ""
+		}
+		return " "
+	case t.Text == "," || t.Text == ";":
+		return ""
+	case prev.Text == "," || prev.Text == ";":
+		if s.opts.SpaceAfterComma {
+			return " "
+		}
+		return ""
+	case t.IsClose():
+		if s.padded(t.Text) {
+			return " "
+		}
+		return ""
+	case prev.Kind == syntax.TokenKeyword || t.Kind == syntax.TokenKeyword:
+		return " "
+	case prev.Kind == syntax.TokenOperator && (prev.Text == "@" || prev.Text == "." || prev.Text == "?"):
+		return ""
+	case t.Kind == syntax.TokenOperator && (t.Text == "." || t.IsTranspose() || t.Text == "++" || t.Text == "--"):
+		return ""
+	case prev.Text == ":" || t.Text == ":":
+		c := i
+		if prev.Text == ":" {
+			c = i - 1
+		}
+		if !isRangeColon(code, c) {
+			return ""
+		}
+		switch s.opts.ColonSpacing {
+		case "spaced":
+			return " "
+		case "keep":
+			return kept
+		}
+		return ""
+	case unary[i-1]:
+		// Keep two signs such as - -1 apart.
+		if unary[i] && (t.Text == "+" || t.Text == "-") {
+			return kept
+		}
+		return ""
+	case isBinary(prev, unary[i-1]):
+		return s.operatorSpace(code, i-1)
+	case isBinary(t, unary[i]):
+		return s.operatorSpace(code, i)
+	case unary[i] && (t.Text == "~" || t.Text == "!"):
+		return " "
+	}
+	return kept
+}
+
+// padded reports whether the bracket is written with a space inside it.
+func (s *session) padded(bracket string) bool {
+	switch bracket {
+	case "(", ")":
+		return s.opts.SpaceInParens
+	case "[", "]":
+		return s.opts.SpaceInBrackets
+	case "{", "}":
+		return s.opts.SpaceInBraces
+	}
+	return false
+}
+
+// isBinary reports whether t is a binary operator.
+func isBinary(t syntax.Token, unary bool) bool {
+	if t.Kind != syntax.TokenOperator || unary || t.IsTranspose() {
+		return false
+	}
+	switch t.Text {
+	case ".", ":", "@", "?", "++", "--":
+		return false
+	}
+	return true
+}
+
+// operatorSpace returns the whitespace around the binary operator code[i].
+func (s *session) operatorSpace(code []syntax.Token, i int) string {
+	op := code[i].Text
+	if s.opts.SpaceShortCircuit && (op == "&&" || op == "||") || s.spacedOps[operatorClass(op)] && !isRational(code, i) {
+		return " "
+	}
+	return ""
+}
+
+// isRangeColon reports whether the colon code[c] separates the operands of
+// a range rather than standing for a whole dimension, as in a(:, 1).
+func isRangeColon(code []syntax.Token, c int) bool {
+	if c == 0 || c+1 == len(code) {
+		return false
+	}
+	prev, next := code[c-1], code[c+1]
+	switch {
+	case prev.IsOpen() || prev.Text == "," || prev.Text == ";" || prev.Text == ":":
+		return false
+	case next.IsClose() || next.Text == "," || next.Text == ";" || next.Text == ":":
+		return false
+	case next.Kind == syntax.TokenComment || next.Kind == syntax.TokenContinuation:
+		return false
+	}
+	return true
+}
+
+// operatorClass returns the class of the binary operator op in
+// SpacedOperators.
+func operatorClass(op string) string {
+	switch op {
+	case "=":
+		return "assignment"
+	case "==", "~=", "!=", "<", "<=", ">", ">=":
+		return "relational"
+	case "&", "|", "&&", "||":
+		return "logical"
+	case "^", ".^":
+		return "power"
+	case ".*", "./", ".\\":
+		return "elementwise"
+	}
+	return "arithmetic"
+}
+
+// isRational reports whether code[i] is the / of a fraction of two numbers
+// such as 1/2, which is kept tight.
+func isRational(code []syntax.Token, i int) bool {
+	return code[i].Text == "/" && i > 0 && i+1 < len(code) &&
+		code[i-1].Kind == syntax.TokenNumber && code[i+1].Kind == syntax.TokenNumber
+}
+
+// fireToken records the spacing rule applying to code[i].
+func (s *session) fireToken(code []syntax.Token, unary []bool, i int, t syntax.Token) {
+	switch t.Kind {
+	case syntax.TokenString:
+		s.fire("string")
+	case syntax.TokenComment:
+		s.fire("comment")
+	case syntax.TokenContinuation:
+		s.fire("ellipsis")
+	case syntax.TokenNumber:
+		if strings.ContainsAny(t.Text, "eEdD") && !strings.HasPrefix(t.Text, "0x") && !strings.HasPrefix(t.Text, "0X") {
+			s.fire("numSci")
+		}
+	case syntax.TokenPunctuation:
+		switch {
+		case t.Text == "," || t.Text == ";":
+			s.fire("comma")
+		case t.IsClose():
+			s.fire("close")
+		case t.Text == "(" && i > 0 && code[i-1].Kind == syntax.TokenIdentifier:
+			s.fire("func")
+		default:
+			s.fire("open")
+		}
+	case syntax.TokenOperator:
+		switch {
+		case t.Text == ":":
+			s.fire("colon")
+		case t.Text == "++" || t.Text == "--":
+			s.fire("increment")
+		case unary[i] && (t.Text == "~" || t.Text == "!"):
+			s.fire("not")
+		case unary[i]:
+			s.fire("sign")
+		case !isBinary(t, false):
+		case t.Text == "^":
+			s.fire("pow")
+		case t.Text == ".^":
+			s.fire("powDot")
+		case isRational(code, i):
+			s.fire("numRational")
+		case len(t.Text) == 2:
+			s.fire("opComb")
+		default:
+			s.fire("op")
+		}
+	}
+}
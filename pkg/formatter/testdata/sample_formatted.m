@@ -23,7 +23,7 @@ function y = foo(x)
     D = 1.^2;
     E = 1 ...
         +2;
-    F =- -1;
+    F = - -1;
     G = a + b;
     H = 1/2;
     % formatter ignore 2
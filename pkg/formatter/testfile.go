@@ -0,0 +1,115 @@
+package formatter
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/koyashimano/matlab-formatter/internal/syntax"
+)
+
+// fixtureOrder lists the shared fixture functions of function-based tests in
+// the order they are placed after the main function.
+var fixtureOrder = []string{"setupOnce", "teardownOnce", "setup", "teardown"}
+
+// testChunk is a top-level function of a test file together with the comment
+// lines directly above it. start and end are 0-based, inclusive indexes.
+type testChunk struct {
+	name       string
+	start, end int
+}
+
+// IsTestFile reports whether lines, read from path, form a function-based
+// unit test file: a function file whose name ends in "Test" or that has a
+// local function whose name starts with "test". path may be "-" or empty for
+// input without a name.
+func IsTestFile(path string, lines []string) bool {
+	chunks := testChunks(lines)
+	if len(chunks) == 0 {
+		return false
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if strings.HasSuffix(base, "Test") {
+		return true
+	}
+	for _, c := range chunks[1:] {
+		if strings.HasPrefix(strings.ToLower(c.name), "test") {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyTestConventions rearranges the local functions of a function-based
+// test file. With TestFixturesFirst the shared fixtures setupOnce,
+// teardownOnce, setup and teardown move directly after the main function;
+// with TestFunctionSpacing every local function is preceded by exactly one
+// blank line. The main function, which usually only calls
+// functiontests(localfunctions), is left untouched.
+func (f *Formatter) ApplyTestConventions(lines []string) []string {
+	chunks := testChunks(lines)
+	if len(chunks) < 2 || !f.opts.TestFixturesFirst && !f.opts.TestFunctionSpacing {
+		return lines
+	}
+
+	locals := slices.Clone(chunks[1:])
+	if f.opts.TestFixturesFirst {
+		rank := func(c testChunk) int {
+			if i := slices.Index(fixtureOrder, c.name); i >= 0 {
+				return i
+			}
+			return len(fixtureOrder)
+		}
+		slices.SortStableFunc(locals, func(a, b testChunk) int { return rank(a) - rank(b) })
+	}
+
+	out := append([]string{}, lines[:chunks[0].end+1]...)
+	for i, c := range locals {
+		if f.opts.TestFunctionSpacing {
+			out = append(out, "")
+		} else {
+			// Keep the lines that separated the functions at this position.
+			out = append(out, lines[chunks[i].end+1:chunks[i+1].start]...)
+		}
+		out = append(out, lines[c.start:c.end+1]...)
+	}
+	return append(out, lines[chunks[len(chunks)-1].end+1:]...)
+}
+
+// testChunks returns the top-level functions of a function file. It returns
+// nil for scripts, classdef files and files with unbalanced blocks.
+func testChunks(lines []string) []testChunk {
+	parsed := syntax.Parse(lines)
+	if len(parsed.Errors) > 0 || parsed.IsScript() {
+		return nil
+	}
+
+	var chunks []testChunk
+	for _, n := range parsed.Nodes {
+		if n.Kind != syntax.KindFunction {
+			return nil
+		}
+		c := testChunk{name: n.Name, start: n.Start.Line - 1, end: n.End.Line - 1}
+		// Comments above the first function belong to the file.
+		if len(chunks) > 0 {
+			for c.start > 0 && isCommentLine(parsed.Lines[c.start-1]) {
+				c.start--
+			}
+		}
+		chunks = append(chunks, c)
+	}
+
+	for i := range chunks {
+		if i+1 < len(chunks) && chunks[i].end >= chunks[i+1].start {
+			chunks[i].end = chunks[i+1].start - 1
+		}
+		for chunks[i].end > chunks[i].start && strings.TrimSpace(lines[chunks[i].end]) == "" {
+			chunks[i].end--
+		}
+	}
+	return chunks
+}
+
+func isCommentLine(l syntax.Line) bool {
+	return !l.HasCode() && l.HasComment()
+}
@@ -0,0 +1,62 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyTestConventions(t *testing.T) {
+	lines := []string{
+		"function tests = fooTest",
+		"    tests = functiontests(localfunctions);",
+		"end",
+		"function testA(testCase)",
+		"end",
+		"",
+		"",
+		"% Runs before each test.",
+		"function setup(testCase)",
+		"end",
+		"function setupOnce(testCase)",
+		"end",
+	}
+	if !IsTestFile("fooTest.m", lines) || !IsTestFile("-", lines) {
+		t.Fatal("test file not detected")
+	}
+	if IsTestFile("foo.m", []string{"function foo", "end", "function helper", "end"}) {
+		t.Fatal("plain function file detected as test file")
+	}
+
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	want := []string{
+		"function tests = fooTest",
+		"    tests = functiontests(localfunctions);",
+		"end",
+		"",
+		"function setupOnce(testCase)",
+		"end",
+		"",
+		"% Runs before each test.",
+		"function setup(testCase)",
+		"end",
+		"",
+		"function testA(testCase)",
+		"end",
+	}
+	if got := f.ApplyTestConventions(lines); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got, want)
+	}
+
+	opts := DefaultOptions()
+	opts.TestFixturesFirst = false
+	opts.TestFunctionSpacing = false
+	if f, err = New(opts); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := f.ApplyTestConventions(lines); !reflect.DeepEqual(got, lines) {
+		t.Fatalf("disabled conventions changed the file:\n%q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package formatter
+
+import (
+	"context"
+	"slices"
+	"sort"
+)
+
+// LineTrace records how a line was classified and which spacing rules
+// applied to it.
+type LineTrace struct {
+	// Line is the 1-based line number in the input.
+	Line int `json:"line"`
+	// Class is the kind of line: blank, ignored, block-comment, comment,
+	// command, matrix, matrix-continuation, cell, cell-continuation,
+	// ctrl1Line, fcnStart, ctrlStart, ctrlStartSwitch, ctrlCont, ctrlEnd or
+	// code.
+	Class string `json:"class"`
+	// Rules lists the spacing rules that matched the line, in the order they
+	// first applied.
+	Rules []string `json:"rules,omitempty"`
+}
+
+// FormatLinesTrace is FormatLinesContext returning also the traces of the
+// formatted lines, ordered by line.
+func (f *Formatter) FormatLinesTrace(ctx context.Context, lines []string, opts ...CallOption) ([]string, []LineTrace, error) {
+	s := f.newSession(ctx, lines, opts)
+	s.tracing = true
+	result, err := s.formatLines(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, s.sortedTraces(), nil
+}
+
+// FormatRangesTrace is FormatRangesContext returning also the traces of the
+// formatted lines, ordered by line.
+func (f *Formatter) FormatRangesTrace(ctx context.Context, lines []string, ranges []LineRange, opts ...CallOption) ([]string, []LineTrace, error) {
+	s := f.newSession(ctx, lines, opts)
+	s.tracing = true
+	result, err := s.formatRanges(lines, ranges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, s.sortedTraces(), nil
+}
+
+// sortedTraces returns the recorded traces ordered by line, as ranges are
+// formatted from the last to the first.
+func (s *session) sortedTraces() []LineTrace {
+	sort.SliceStable(s.traces, func(i, j int) bool { return s.traces[i].Line < s.traces[j].Line })
+	return s.traces
+}
+
+// record adds the trace of the line just formatted, the 1-based line of the
+// input it comes from. Lines inserted by the formatter, given as line 0, are
+// not traced.
+func (s *session) record(line int, class string) {
+	if !s.tracing || line == 0 {
+		s.fired = nil
+		return
+	}
+	s.traces = append(s.traces, LineTrace{Line: line, Class: class, Rules: s.fired})
+	s.fired = nil
+}
+
+// fire records that the spacing rule name applied to the current line.
+func (s *session) fire(name string) {
+	if s.tracing && !slices.Contains(s.fired, name) {
+		s.fired = append(s.fired, name)
+	}
+}
@@ -0,0 +1,76 @@
+package formatter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"function f()", "", "x = [1, ...", "2];", "% formatter ignore 1", "y  =  1;", "end"}
+	_, traces, err := f.FormatLinesTrace(context.Background(), lines)
+	if err != nil {
+		t.Fatalf("FormatLinesTrace: %v", err)
+	}
+
+	var classes []string
+	for _, lt := range traces {
+		classes = append(classes, lt.Class)
+	}
+	want := []string{"fcnStart", "blank", "matrix", "matrix-continuation", "comment", "ignored", "ctrlEnd"}
+	if !reflect.DeepEqual(classes, want) {
+		t.Fatalf("unexpected classes:\n got %q\nwant %q", classes, want)
+	}
+	if rules := traces[2].Rules; !reflect.DeepEqual(rules, []string{"op", "open", "comma", "ellipsis"}) {
+		t.Errorf("unexpected rules for line 3: %q", rules)
+	}
+}
+
+func TestFormatRangesTrace(t *testing.T) {
+	f, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"x=1;", "y=2;", "z=3;", "w=4;"}
+	_, traces, err := f.FormatRangesTrace(context.Background(), lines, []LineRange{NewLineRange(3, 4), NewLineRange(1, 1)})
+	if err != nil {
+		t.Fatalf("FormatRangesTrace: %v", err)
+	}
+	// Only the lines of the ranges are traced, in line order although the
+	// ranges are formatted from the last.
+	var got []int
+	for _, lt := range traces {
+		got = append(got, lt.Line)
+	}
+	if want := []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("traced lines %v, want %v", got, want)
+	}
+}
+
+func TestTraceAfterRemovedImport(t *testing.T) {
+	f, err := New(WithSortImports(true), WithGroupImports(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lines := []string{"import b.x", "import a.y", "import b.x", "z=1;"}
+	_, traces, err := f.FormatLinesTrace(context.Background(), lines)
+	if err != nil {
+		t.Fatalf("FormatLinesTrace: %v", err)
+	}
+	// Traces are keyed by the line each formatted line comes from; the
+	// removed duplicate and the blank line separating the groups have none.
+	var got []int
+	for _, lt := range traces {
+		got = append(got, lt.Line)
+	}
+	if want := []int{1, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("traced lines %v, want %v", got, want)
+	}
+	if class := traces[2].Class; class != "code" {
+		t.Errorf("line 4: got class %q, want code", class)
+	}
+}